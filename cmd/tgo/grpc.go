@@ -0,0 +1,9 @@
+//go:build grpc
+
+package main
+
+import "github.com/ks888/tgo/service"
+
+func serveGRPC(address string) error {
+	return service.ServeGRPC(address)
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/ks888/tgo/log"
-	"github.com/ks888/tgo/service"
+	"github.com/ks888/tgo/tracee"
+	"github.com/ks888/tgo/tracer"
 )
 
 const (
@@ -14,19 +19,89 @@ const (
 	tracelevelOptionDesc = "Functions are traced if the stack depth is within this `tracelevel`. The stack depth here is based on the point the tracing is enabled."
 	parselevelOptionDesc = "The trace log includes the function's args. The `parselevel` option determines how detailed these values should be."
 	verboseOptionDesc    = "Show the debug-level message"
+	syntaxOptionDesc     = "The assembly `syntax`. Either intel or att."
+	grpcOptionDesc       = "Serve the gRPC service instead of the net/rpc one. The binary must be built with the grpc tag."
+	globOptionDesc       = "The pattern is the glob pattern (e.g. main.*) instead of the regular expression. Unlike the shell, * matches / too."
+	jsonOptionDesc       = "Print in the JSON format, one object per line."
 )
 
-func serverCmd(args []string) error {
+func disasmCmd(args []string) error {
 	commandLine := flag.NewFlagSet("", flag.ExitOnError)
 	commandLine.Usage = func() {
 		fmt.Fprintf(commandLine.Output(), `Usage:
 
-  %s server [flags] [hostname:port]
+  %s disasm [flags] program address
+
+Disassembles the function which contains the address. The program is read from the file and not launched.
+The ELF binary cross-compiled for linux can be inspected on the other OSes.
 
 Flags:
 `, os.Args[0])
 		commandLine.PrintDefaults()
 	}
+	syntax := commandLine.String("syntax", "intel", syntaxOptionDesc)
+	verbose := commandLine.Bool("verbose", false, verboseOptionDesc)
+
+	commandLine.Parse(args)
+	if commandLine.NArg() < 2 {
+		commandLine.Usage()
+		os.Exit(1)
+	}
+	log.EnableDebugLog = *verbose
+
+	var asmSyntax tracee.AsmSyntax
+	switch *syntax {
+	case "intel":
+		asmSyntax = tracee.AsmSyntaxIntel
+	case "att":
+		asmSyntax = tracee.AsmSyntaxATT
+	default:
+		return fmt.Errorf("unknown syntax: %s", *syntax)
+	}
+
+	addr, err := strconv.ParseUint(commandLine.Arg(1), 0, 64)
+	if err != nil {
+		return err
+	}
+
+	binary, err := openListedBinary(commandLine.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer binary.Close()
+
+	function, err := binary.FindFunction(addr)
+	if err != nil {
+		return err
+	}
+	insts, err := tracee.DisassembleBinary(binary, function.StartAddr, function.EndAddr)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s:\n", function.Name)
+	for _, inst := range insts {
+		fmt.Printf("%#x\t%-40s\t%s\n", inst.Addr, inst.Text(asmSyntax), inst.SourceLine)
+	}
+	return nil
+}
+
+func listCmd(args []string) error {
+	commandLine := flag.NewFlagSet("", flag.ExitOnError)
+	commandLine.Usage = func() {
+		fmt.Fprintf(commandLine.Output(), `Usage:
+
+  %s list [flags] program [pattern]
+
+Lists the traceable functions in the program. The pattern is the regular expression the function name matches.
+The ELF binary cross-compiled for linux can be inspected on the other OSes.
+
+Flags:
+`, os.Args[0])
+		commandLine.PrintDefaults()
+	}
+	glob := commandLine.Bool("glob", false, globOptionDesc)
+	jsonOutput := commandLine.Bool("json", false, jsonOptionDesc)
 	verbose := commandLine.Bool("verbose", false, verboseOptionDesc)
 
 	commandLine.Parse(args)
@@ -36,7 +111,214 @@ Flags:
 	}
 	log.EnableDebugLog = *verbose
 
-	return service.Serve(commandLine.Arg(0))
+	match := func(name string) bool { return true }
+	if pattern := commandLine.Arg(1); pattern != "" {
+		if *glob {
+			pattern = globToRegexp(pattern)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return err
+		}
+		match = re.MatchString
+	}
+
+	binary, err := openListedBinary(commandLine.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer binary.Close()
+
+	encoder := json.NewEncoder(os.Stdout)
+	return binary.IterateFunctions(func(f *tracee.Function) bool {
+		if !tracer.IsTraceable(f) || !match(f.Name) {
+			return true
+		}
+
+		file, line, err := binary.PCToFileLine(f.StartAddr)
+		if err != nil {
+			log.Debugf("failed to find the source location of %s: %v", f.Name, err)
+		}
+		if *jsonOutput {
+			if err := encoder.Encode(listedFunction{Name: f.Name, Address: f.StartAddr, File: file, Line: line}); err != nil {
+				log.Debugf("failed to print %s: %v", f.Name, err)
+			}
+			return true
+		}
+
+		if file == "" {
+			fmt.Printf("%#x\t%s\n", f.StartAddr, f.Name)
+		} else {
+			fmt.Printf("%#x\t%s\t%s:%d\n", f.StartAddr, f.Name, file, line)
+		}
+		return true
+	})
+}
+
+// globToRegexp converts the glob pattern to the regular expression which matches the whole name.
+// Unlike path.Match, `*` matches any sequence of characters including '/', because the function name
+// contains the package path like `github.com/ks888/tgo/tracee.(*Process).Detach`.
+func globToRegexp(pattern string) string {
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch ch := pattern[i]; ch {
+		case '*':
+			re.WriteString(".*")
+		case '?':
+			re.WriteString(".")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				re.WriteString(regexp.QuoteMeta(pattern[i:]))
+				i = len(pattern)
+				break
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + class + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			re.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	re.WriteString("$")
+	return re.String()
+}
+
+// openListedBinary opens the program in the host OS's format. If failed, it tries the ELF format so that
+// the binary cross-compiled for linux can be inspected on the other OSes.
+func openListedBinary(pathToProgram string) (tracee.BinaryFile, error) {
+	binary, err := tracee.OpenBinaryFile(pathToProgram, tracee.GoVersion{})
+	if err == nil {
+		return binary, nil
+	}
+	if elfBinary, elfErr := tracee.OpenBinaryFileELF(pathToProgram, tracee.GoVersion{}); elfErr == nil {
+		return elfBinary, nil
+	}
+	return nil, err
+}
+
+// listedFunction is the JSON representation of the function the list command prints.
+type listedFunction struct {
+	Name    string `json:"name"`
+	Address uint64 `json:"address"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+}
+
+func listPackagesCmd(args []string) error {
+	commandLine := flag.NewFlagSet("", flag.ExitOnError)
+	commandLine.Usage = func() {
+		fmt.Fprintf(commandLine.Output(), `Usage:
+
+  %s list-packages [flags] program
+
+Lists the packages compiled into the program.
+
+Flags:
+`, os.Args[0])
+		commandLine.PrintDefaults()
+	}
+	verbose := commandLine.Bool("verbose", false, verboseOptionDesc)
+
+	commandLine.Parse(args)
+	if commandLine.NArg() < 1 {
+		commandLine.Usage()
+		os.Exit(1)
+	}
+	log.EnableDebugLog = *verbose
+
+	binary, err := tracee.OpenBinaryFile(commandLine.Arg(0), tracee.GoVersion{})
+	if err != nil {
+		return err
+	}
+	defer binary.Close()
+
+	pkgs, err := binary.ListPackages()
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		fmt.Println(pkg)
+	}
+	return nil
+}
+
+func listMethodsCmd(args []string) error {
+	commandLine := flag.NewFlagSet("", flag.ExitOnError)
+	commandLine.Usage = func() {
+		fmt.Fprintf(commandLine.Output(), `Usage:
+
+  %s list-methods [flags] program type
+
+Lists the methods of the type, like http.Transport.
+
+Flags:
+`, os.Args[0])
+		commandLine.PrintDefaults()
+	}
+	verbose := commandLine.Bool("verbose", false, verboseOptionDesc)
+
+	commandLine.Parse(args)
+	if commandLine.NArg() < 2 {
+		commandLine.Usage()
+		os.Exit(1)
+	}
+	log.EnableDebugLog = *verbose
+
+	binary, err := tracee.OpenBinaryFile(commandLine.Arg(0), tracee.GoVersion{})
+	if err != nil {
+		return err
+	}
+	defer binary.Close()
+
+	methods, err := binary.ListMethods(commandLine.Arg(1))
+	if err != nil {
+		return err
+	}
+	for _, method := range methods {
+		fmt.Println(methodSignature(method))
+	}
+	return nil
+}
+
+// methodSignature returns the signature like 'net/http.(*Transport).RoundTrip(t *net/http.Transport, req *net/http.Request) (*net/http.Response, error)'.
+// Only the name is returned if the parameters are unknown.
+func methodSignature(method *tracee.Function) string {
+	if method.Parameters == nil {
+		return method.Name
+	}
+
+	var inputs, outputs []string
+	for _, param := range method.Parameters {
+		typeName := "?"
+		if param.Typ != nil {
+			typeName = param.Typ.String()
+		}
+		if param.IsOutput {
+			outputs = append(outputs, typeName)
+		} else {
+			inputs = append(inputs, strings.TrimSpace(param.Name+" "+typeName))
+		}
+	}
+
+	signature := fmt.Sprintf("%s(%s)", method.Name, strings.Join(inputs, ", "))
+	switch len(outputs) {
+	case 0:
+		return signature
+	case 1:
+		return signature + " " + outputs[0]
+	default:
+		return fmt.Sprintf("%s (%s)", signature, strings.Join(outputs, ", "))
+	}
 }
 
 func main() {
@@ -51,6 +333,13 @@ Usage:
 Commands:
 
   server   launches the server which offers tracing service. See https://godoc.org/github.com/ks888/tgo/service for the detail.
+  disasm   disassembles the function of the program.
+  list     lists the traceable functions of the program.
+  list-packages
+           lists the packages compiled into the program.
+  list-methods
+           lists the methods of the type.
+  version  prints the version of tgo.
 
 Use "tgo <command> --help" for more information about a command.
 `, os.Args[0])
@@ -66,6 +355,16 @@ Use "tgo <command> --help" for more information about a command.
 	switch os.Args[1] {
 	case "server":
 		err = serverCmd(os.Args[2:])
+	case "disasm":
+		err = disasmCmd(os.Args[2:])
+	case "list":
+		err = listCmd(os.Args[2:])
+	case "list-packages":
+		err = listPackagesCmd(os.Args[2:])
+	case "list-methods":
+		err = listMethodsCmd(os.Args[2:])
+	case "version":
+		err = versionCmd(os.Args[2:])
 	default:
 		commandLine.Usage()
 		os.Exit(1)
@@ -0,0 +1,9 @@
+//go:build !grpc
+
+package main
+
+import "errors"
+
+func serveGRPC(address string) error {
+	return errors.New("the gRPC service is not available. Build the binary with the grpc tag")
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ks888/tgo/log"
+	"github.com/ks888/tgo/service"
+)
+
+func serverCmd(args []string) error {
+	commandLine := flag.NewFlagSet("", flag.ExitOnError)
+	commandLine.Usage = func() {
+		fmt.Fprintf(commandLine.Output(), `Usage:
+
+  %s server [flags] [hostname:port]
+
+Flags:
+`, os.Args[0])
+		commandLine.PrintDefaults()
+	}
+	verbose := commandLine.Bool("verbose", false, verboseOptionDesc)
+	useGRPC := commandLine.Bool("grpc", false, grpcOptionDesc)
+
+	commandLine.Parse(args)
+	if commandLine.NArg() < 1 {
+		commandLine.Usage()
+		os.Exit(1)
+	}
+	log.EnableDebugLog = *verbose
+
+	if *useGRPC {
+		return serveGRPC(commandLine.Arg(0))
+	}
+	return service.Serve(commandLine.Arg(0))
+}
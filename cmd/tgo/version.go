@@ -0,0 +1,56 @@
+package main
+
+import (
+	"debug/buildinfo"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+)
+
+func versionCmd(args []string) error {
+	commandLine := flag.NewFlagSet("", flag.ExitOnError)
+	commandLine.Usage = func() {
+		fmt.Fprintf(commandLine.Output(), `Usage:
+
+  %s version [program]
+
+Prints the version of tgo and the go version it's built with.
+If the program is given, its go version and build ID are printed too.
+`, os.Args[0])
+	}
+	commandLine.Parse(args)
+
+	fmt.Printf("tgo version %s %s\n", buildVersion(), runtime.Version())
+	if commandLine.NArg() < 1 {
+		return nil
+	}
+
+	programPath := commandLine.Arg(0)
+	binary, err := openListedBinary(programPath)
+	if err != nil {
+		return err
+	}
+	defer binary.Close()
+
+	buildID, err := binary.BuildID()
+	if err != nil {
+		return fmt.Errorf("failed to read the build ID of %s: %w", programPath, err)
+	}
+	goVersion := "unknown"
+	if info, err := buildinfo.ReadFile(programPath); err == nil {
+		goVersion = info.GoVersion
+	}
+	fmt.Printf("%s: %s build ID %s\n", programPath, goVersion, buildID)
+	return nil
+}
+
+// buildVersion returns the version of the tgo module, or "(devel)" if it's built inside the module.
+func buildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Version == "" {
+		return "(devel)"
+	}
+	return info.Main.Version
+}
@@ -1,24 +1,114 @@
+// Package debugapi provides the low-level api to control the tracee process.
+// The client uses ptrace(2) directly on linux and lldb's debugserver on darwin.
 package debugapi
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
 )
 
-// client is the client interface to control the tracee process.
-// It's still unstable and so do not export it.
-type client interface {
+// Debugger is the interface to control the tracee process. Client implements it on each OS.
+// Other implementations, such as the mock in the debugapi/mock package, can be used instead of Client.
+type Debugger interface {
 	// LaunchProcess launches the new prcoess.
 	LaunchProcess(name string, arg ...string) error
 	// AttachProcess attaches to the existing process.
 	AttachProcess(pid int) error
+	// DetachProcess detaches from the process. The process keeps running.
 	DetachProcess() error
+	// ReadMemory reads the memory at the address. The size to read is the length of `out`.
 	ReadMemory(addr uint64, out []byte) error
+	// ReadMemoryBatch reads the multiple memory regions at once.
+	ReadMemoryBatch(reads []MemoryRead) error
+	// WriteMemory writes the data to the memory at the address.
 	WriteMemory(addr uint64, data []byte) error
+	// ThreadIDs returns the ids of all the threads of the process.
+	ThreadIDs() ([]int, error)
+	// ReadRegisters reads the registers of the thread. The xmm registers may not be read. Use ReadXMMRegisters to read them.
 	ReadRegisters(threadID int) (Registers, error)
+	// ReadXMMRegisters reads the xmm registers of the thread into `regs`.
+	ReadXMMRegisters(threadID int, regs *Registers) error
+	// WriteRegisters writes the registers of the thread. The xmm registers are written only if they are read.
 	WriteRegisters(threadID int, regs Registers) error
+	// ReadTLS reads the 8 bytes value at the offset from the thread local storage of the thread.
+	// On arm64, it returns the value of the x28 register regardless of the offset, because the go runtime keeps
+	// the current g in the register rather than in the TLS.
 	ReadTLS(threadID int, offset int32) (uint64, error)
+	// ContinueAndWait resumes the process and waits until an event happens.
 	ContinueAndWait() (Event, error)
+	// StepAndWait executes the one instruction of the thread and waits until an event happens.
 	StepAndWait(threadID int) (Event, error)
+	// SetSignalForwarding sets whether the signal is delivered to the process.
+	SetSignalForwarding(sig syscall.Signal, forward bool)
+	// MemoryRegions returns the mapped memory regions of the process.
+	MemoryRegions() ([]MemoryRegion, error)
+	// Architecture returns the architecture of the process, like x86_64 and arm64.
+	Architecture() string
+	// MemoryRegionInfo returns the mapped memory region which contains the address. ErrNotMapped is returned if not mapped.
+	MemoryRegionInfo(addr uint64) (MemoryRegion, error)
+	// SetHardwareBreakpoint sets the hardware breakpoint at the address using the debug register of the slot.
+	SetHardwareBreakpoint(slot int, addr uint64) error
+	// ClearHardwareBreakpoint clears the hardware breakpoint or watchpoint of the slot.
+	ClearHardwareBreakpoint(slot int) error
+	// SetWatchpoint sets the watchpoint of the memory region using the debug register of the slot.
+	SetWatchpoint(slot int, addr uint64, size int, mode WatchMode) error
+	// HardwareBreakpointHit returns the slot of the hardware breakpoint or watchpoint the thread hit last time.
+	HardwareBreakpointHit(threadID int) (slot int, hit bool, err error)
+}
+
+// MaxHardwareBreakpoints is the number of the hardware breakpoints which can be set at the same time.
+// It's the number of the debug address registers (DR0-DR3) in x86-64. The watchpoints share these registers.
+const MaxHardwareBreakpoints = 4
+
+// WatchMode represents the type of the memory access which triggers the watchpoint.
+type WatchMode int
+
+const (
+	// WatchWrite mode triggers the watchpoint when the memory is written.
+	WatchWrite WatchMode = iota + 1
+	// WatchRead mode triggers the watchpoint when the memory is read.
+	// On x86-64, the read-only watchpoint is not supported and so the write triggers the watchpoint too.
+	WatchRead
+	// WatchReadWrite mode triggers the watchpoint when the memory is read or written.
+	WatchReadWrite
+)
+
+// hardwareBreakpoint represents the breakpoint or watchpoint set to the debug register.
+type hardwareBreakpoint struct {
+	addr uint64
+	// mode is 0 if it's the breakpoint, which traps the instruction execution.
+	mode WatchMode
+	size int
+}
+
+func (b hardwareBreakpoint) used() bool {
+	return b.addr != 0
+}
+
+// contains returns true if the address is in the range the breakpoint watches.
+func (b hardwareBreakpoint) contains(addr uint64) bool {
+	if b.mode == 0 {
+		return b.addr == addr
+	}
+	return b.addr <= addr && addr < b.addr+uint64(b.size)
+}
+
+// MemoryRegion represents the mapped memory region of the process.
+type MemoryRegion struct {
+	// Start is inclusive and End is exclusive.
+	Start, End                     uint64
+	Readable, Writable, Executable bool
+	// Name is the path of the mapped file or the special name like [stack]. May be empty.
+	Name string
+}
+
+// Contains returns true if the region contains the address.
+func (r MemoryRegion) Contains(addr uint64) bool {
+	return r.Start <= addr && addr < r.End
 }
 
 // EventType represents the type of the event.
@@ -33,6 +123,8 @@ const (
 	EventTypeExited
 	// EventTypeTerminated event happens when the process is terminated by a signal.
 	EventTypeTerminated
+	// EventTypeWatched event happens when the process is trapped and some thread hits the watchpoint.
+	EventTypeWatched
 )
 
 // IsExitEvent returns true if the event indicates the process exits for some reason.
@@ -51,14 +143,157 @@ type Event struct {
 	//    EventTypeCoreDump    NA          NA
 	//    EventTypeExited      int         Exit status
 	//    EventTypeTerminated  int         Signal number
+	//    EventTypeWatched     WatchedData The address of the watchpoint and a list of trapped thread id
 	Data interface{}
 }
 
+// WatchedData is the data of the EventTypeWatched event.
+type WatchedData struct {
+	// Addr is the address of the watchpoint which triggered.
+	Addr uint64
+	// ThreadIDs is a list of trapped thread id, including the threads trapped by the breakpoints at the same time.
+	ThreadIDs []int
+}
+
 // Registers represents the target's registers.
+// On arm64, Rip, Rsp, X30 and X28 hold the value of the pc, sp, lr and x28 registers respectively and other registers are not available.
 type Registers struct {
 	Rip uint64
 	Rsp uint64
+	// X30 is the link register of arm64, which holds the return address at the beginning of the function. 0 on x86-64.
+	X30 uint64
+	// X28 is the register in which the go runtime keeps the current g on arm64. 0 on x86-64.
+	X28 uint64
 	Rcx uint64
+	Rax uint64
+	Rbx uint64
+	Rdx uint64
+	Rsi uint64
+	Rdi uint64
+	Rbp uint64
+	R8  uint64
+	R9  uint64
+	R10 uint64
+	R11 uint64
+	R12 uint64
+	R13 uint64
+	R14 uint64
+	R15 uint64
+	// Xmm0-Xmm15 are the raw values of the 128-bit SSE registers in little endian.
+	Xmm0  [16]byte
+	Xmm1  [16]byte
+	Xmm2  [16]byte
+	Xmm3  [16]byte
+	Xmm4  [16]byte
+	Xmm5  [16]byte
+	Xmm6  [16]byte
+	Xmm7  [16]byte
+	Xmm8  [16]byte
+	Xmm9  [16]byte
+	Xmm10 [16]byte
+	Xmm11 [16]byte
+	Xmm12 [16]byte
+	Xmm13 [16]byte
+	Xmm14 [16]byte
+	Xmm15 [16]byte
+	// xmmRead is true if the xmm registers are read. Reading them on linux requires another system call.
+	xmmRead bool
+}
+
+// numXMMRegisters is the number of the xmm registers in x86-64.
+const numXMMRegisters = 16
+
+// xmmRegisters returns the pointers to the xmm registers. The index is the register number.
+func (r *Registers) xmmRegisters() [numXMMRegisters]*[16]byte {
+	return [numXMMRegisters]*[16]byte{
+		&r.Xmm0, &r.Xmm1, &r.Xmm2, &r.Xmm3, &r.Xmm4, &r.Xmm5, &r.Xmm6, &r.Xmm7,
+		&r.Xmm8, &r.Xmm9, &r.Xmm10, &r.Xmm11, &r.Xmm12, &r.Xmm13, &r.Xmm14, &r.Xmm15,
+	}
+}
+
+// xmmRegisterByName returns the pointer to the xmm register which has the specified name. Returns nil if not found.
+func (r *Registers) xmmRegisterByName(name string) *[16]byte {
+	if !strings.HasPrefix(name, "xmm") {
+		return nil
+	}
+	index, err := strconv.Atoi(name[len("xmm"):])
+	if err != nil || index < 0 || index >= numXMMRegisters {
+		return nil
+	}
+	return r.xmmRegisters()[index]
+}
+
+// registerByName returns the pointer to the register which has the specified name. Returns nil if not found.
+func (r *Registers) registerByName(name string) *uint64 {
+	switch name {
+	case "rip", "pc":
+		return &r.Rip
+	case "rsp", "sp":
+		return &r.Rsp
+	case "rax":
+		return &r.Rax
+	case "rbx":
+		return &r.Rbx
+	case "rcx":
+		return &r.Rcx
+	case "rdx":
+		return &r.Rdx
+	case "rsi":
+		return &r.Rsi
+	case "rdi":
+		return &r.Rdi
+	case "rbp":
+		return &r.Rbp
+	case "r8":
+		return &r.R8
+	case "r9":
+		return &r.R9
+	case "r10":
+		return &r.R10
+	case "r11":
+		return &r.R11
+	case "r12":
+		return &r.R12
+	case "r13":
+		return &r.R13
+	case "r14":
+		return &r.R14
+	case "r15":
+		return &r.R15
+	case "x30", "lr":
+		return &r.X30
+	case "x28":
+		return &r.X28
+	}
+	return nil
+}
+
+// ErrNotMapped is returned when the address is not mapped.
+var ErrNotMapped = errors.New("not mapped")
+
+// ErrNotSupported is returned when the debug server doesn't support the requested feature.
+var ErrNotSupported = errors.New("not supported")
+
+func checkHardwareBreakpointSlot(slot int) error {
+	if slot < 0 || slot >= MaxHardwareBreakpoints {
+		return fmt.Errorf("invalid hardware breakpoint slot: %d", slot)
+	}
+	return nil
+}
+
+func checkWatchpoint(addr uint64, size int, mode WatchMode) error {
+	switch size {
+	case 1, 2, 4, 8:
+	default:
+		return fmt.Errorf("invalid watchpoint size: %d", size)
+	}
+	if addr%uint64(size) != 0 {
+		return fmt.Errorf("watchpoint address %#x is not aligned to the size %d", addr, size)
+	}
+	if mode != WatchWrite && mode != WatchRead && mode != WatchReadWrite {
+		return fmt.Errorf("invalid watch mode: %d", mode)
+	}
+	return nil
 }
 
 // UnspecifiedThreadError indicates the stopped threads include unspecified ones.
@@ -70,3 +305,107 @@ type UnspecifiedThreadError struct {
 func (e UnspecifiedThreadError) Error() string {
 	return fmt.Sprintf("unspecified threads: %v", e.ThreadIDs)
 }
+
+// MemoryReadError indicates the memory of the process can't be read. The process may have exited or the address may be invalid.
+type MemoryReadError struct {
+	Addr uint64
+	Size int
+	Err  error
+}
+
+// Error returns the address and size of the failed read.
+func (e MemoryReadError) Error() string {
+	return fmt.Sprintf("failed to read memory at %#x (size: %d): %v", e.Addr, e.Size, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e MemoryReadError) Unwrap() error {
+	return e.Err
+}
+
+// MemoryRead represents one memory read request in the batch read.
+type MemoryRead struct {
+	Addr uint64
+	// Buf is filled with the data read from Addr. Its length determines the size of the read.
+	Buf []byte
+}
+
+// maxMemoryReadGap is the max size of the gap between 2 memory regions which are read at once.
+const maxMemoryReadGap = 64
+
+// defaultMaxMemoryReadSize is the max size of the coalesced region when the client has no better limit.
+const defaultMaxMemoryReadSize = 4096
+
+// coalescedMemoryRead is the memory region which covers one or more memory read requests.
+type coalescedMemoryRead struct {
+	addr  uint64
+	size  int
+	reads []MemoryRead
+}
+
+// coalesceMemoryReads merges the overlapping, adjacent or nearby read requests into one region.
+// The region doesn't grow beyond `maxSize` bytes, though the request larger than `maxSize` is kept as it is.
+func coalesceMemoryReads(reads []MemoryRead, maxSize int) []coalescedMemoryRead {
+	sortedReads := make([]MemoryRead, len(reads))
+	copy(sortedReads, reads)
+	sort.Slice(sortedReads, func(i, j int) bool { return sortedReads[i].Addr < sortedReads[j].Addr })
+
+	var coalescedReads []coalescedMemoryRead
+	for _, read := range sortedReads {
+		if len(read.Buf) == 0 {
+			continue
+		}
+
+		if len(coalescedReads) > 0 {
+			last := &coalescedReads[len(coalescedReads)-1]
+			lastEnd := last.addr + uint64(last.size)
+			readEnd := read.Addr + uint64(len(read.Buf))
+			if read.Addr <= lastEnd+maxMemoryReadGap && (readEnd <= lastEnd || readEnd-last.addr <= uint64(maxSize)) {
+				if readEnd > lastEnd {
+					last.size = int(readEnd - last.addr)
+				}
+				last.reads = append(last.reads, read)
+				continue
+			}
+		}
+
+		coalescedReads = append(coalescedReads, coalescedMemoryRead{addr: read.Addr, size: len(read.Buf), reads: []MemoryRead{read}})
+	}
+	return coalescedReads
+}
+
+// readMemoryBatch reads the coalesced memory regions using `readMemory` and then copies the data to each request's buffer.
+func readMemoryBatch(readMemory func(addr uint64, out []byte) error, reads []MemoryRead, maxSize int) error {
+	for _, coalescedRead := range coalesceMemoryReads(reads, maxSize) {
+		buff := make([]byte, coalescedRead.size)
+		if err := readMemory(coalescedRead.addr, buff); err != nil {
+			return err
+		}
+
+		for _, read := range coalescedRead.reads {
+			copy(read.Buf, buff[read.Addr-coalescedRead.addr:])
+		}
+	}
+	return nil
+}
+
+// signalFilter decides whether the signal the tracee received is delivered to the tracee.
+// All the signals are delivered by default.
+type signalFilter struct {
+	suppressedSignals map[syscall.Signal]bool
+}
+
+func (f *signalFilter) setForwarding(sig syscall.Signal, forward bool) {
+	if f.suppressedSignals == nil {
+		f.suppressedSignals = make(map[syscall.Signal]bool)
+	}
+	f.suppressedSignals[sig] = !forward
+}
+
+// filter returns the signal number to be delivered. It is 0 if the signal is suppressed.
+func (f *signalFilter) filter(signalNumber int) int {
+	if f.suppressedSignals[syscall.Signal(signalNumber)] {
+		return 0
+	}
+	return signalNumber
+}
@@ -3,6 +3,7 @@ package debugapi
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -11,6 +12,7 @@ import (
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -22,6 +24,10 @@ import (
 const (
 	maxPacketSize = 4096
 	excBadAccess  = syscall.Signal(0x91) // EXC_BAD_ACCESS
+
+	defaultMaxRetries = 3
+	// keepAliveInterval is the interval of the ping while there is no traffic.
+	keepAliveInterval = 30 * time.Second
 )
 
 // Client is the debug api client which depends on lldb's debugserver.
@@ -40,28 +46,74 @@ type Client struct {
 	readTLSFuncAddr  uint64
 	currentTLSOffset uint32
 	pendingSignal    int
+	signalFilter
+
+	// timeout is the read timeout of each packet. 0 means no timeout.
+	timeout time.Duration
+	// maxRetries is the max number of the retries when the read times out. The timeout doubles at each retry.
+	maxRetries int
+	// partialPacket holds the data received before the last read failed, so that the retry doesn't lose it.
+	partialPacket []byte
+
+	// mu guards the connection so that the keep-alive ping doesn't interleave with other packets.
+	mu sync.Mutex
+	// pendingReplies is the number of the sent commands whose replies are not received yet.
+	pendingReplies int
+	// running is true while the process is continued or stepped. The debugserver accepts no packets then.
+	running       bool
+	lastTraffic   time.Time
+	stopKeepAlive chan struct{}
+
+	// processInfo is the info of the process the debugserver controls.
+	processInfo ProcessInfo
+	// vContActions is the list of the actions the vCont packet supports.
+	vContActions []string
+	// hwBreakSupported is true if the debugserver reports the hardware breakpoint support in the qSupported response.
+	hwBreakSupported bool
+	// packetSize is the max packet size the debugserver reports in the qSupported response. 0 if not reported.
+	packetSize int
+	// hardwareBreakpoints holds the hardware breakpoints and watchpoints.
+	hardwareBreakpoints [MaxHardwareBreakpoints]hardwareBreakpoint
 }
 
 // NewClient returns the new debug api client which depends on OS API.
 func NewClient() *Client {
-	return &Client{buffer: make([]byte, maxPacketSize), outputWriter: os.Stdout}
+	return &Client{buffer: make([]byte, maxPacketSize), outputWriter: os.Stdout, maxRetries: defaultMaxRetries}
 }
 
 // LaunchProcess lets the debugserver launch the new prcoess.
 func (c *Client) LaunchProcess(name string, arg ...string) error {
-	listener, err := net.Listen("tcp", "localhost:")
-	if err != nil {
+	debugServerArgs := append([]string{"-F", "--", name}, arg...)
+	if err := c.startDebugServer(debugServerArgs); err != nil {
 		return err
 	}
+	c.killOnDetach = true
+
+	return c.initialize()
+}
+
+// startDebugServer starts the debugserver and connects to it. The connection is the unix domain socket
+// unless the debugserver is specified by the LLDB_DEBUGSERVER_PATH environment variable.
+// In that case, the debugserver may not support the --fd option and so the tcp connection is used.
+func (c *Client) startDebugServer(debugServerArgs []string) error {
+	if path := os.Getenv("LLDB_DEBUGSERVER_PATH"); path != "" {
+		return c.startDebugServerWithTCP(path, debugServerArgs)
+	}
 
 	path, err := debugServerPath()
 	if err != nil {
 		return err
 	}
+	return c.startDebugServerWithUnixSocket(path, debugServerArgs)
+}
+
+func (c *Client) startDebugServerWithTCP(path string, debugServerArgs []string) error {
+	listener, err := net.Listen("tcp", "localhost:")
+	if err != nil {
+		return err
+	}
 
-	debugServerArgs := []string{"-F", "-R", listener.Addr().String(), "--", name}
-	debugServerArgs = append(debugServerArgs, arg...)
-	cmd := exec.Command(path, debugServerArgs...)
+	cmd := exec.Command(path, append([]string{"-R", listener.Addr().String()}, debugServerArgs...)...)
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true} // Otherwise, the signal sent to all the group members.
 	if err := cmd.Start(); err != nil {
 		return err
@@ -72,7 +124,56 @@ func (c *Client) LaunchProcess(name string, arg ...string) error {
 		return err
 	}
 	c.pid = cmd.Process.Pid
-	c.killOnDetach = true
+	return nil
+}
+
+// startDebugServerWithUnixSocket passes one end of the socket pair to the debugserver.
+// Unlike the tcp case, no other process can connect to the debugserver.
+func (c *Client) startDebugServerWithUnixSocket(path string, debugServerArgs []string) error {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		return err
+	}
+	parentFile := os.NewFile(uintptr(fds[0]), "tgo")
+	defer parentFile.Close()
+	childFile := os.NewFile(uintptr(fds[1]), "debugserver")
+	defer childFile.Close()
+
+	// the first extra file is the fd 3 in the child process.
+	cmd := exec.Command(path, append([]string{"--fd=3"}, debugServerArgs...)...)
+	cmd.ExtraFiles = []*os.File{childFile}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true} // Otherwise, the signal sent to all the group members.
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+
+	c.conn, err = net.FileConn(parentFile)
+	if err != nil {
+		return err
+	}
+	c.pid = cmd.Process.Pid
+	return nil
+}
+
+// Dial connects to the debug server which already runs and listens to the tcp address.
+// The debug server must have launched or attached to the process.
+func (c *Client) Dial(addr string) error {
+	return c.dial("tcp", addr)
+}
+
+// DialUnix connects to the debug server which already runs and listens to the unix domain socket.
+// The debug server must have launched or attached to the process.
+func (c *Client) DialUnix(path string) error {
+	return c.dial("unix", path)
+}
+
+func (c *Client) dial(network, addr string) error {
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
 
 	return c.initialize()
 }
@@ -126,9 +227,77 @@ func (c *Client) initialize() error {
 		return err
 	}
 
-	readTLSFunction := c.buildReadTLSFunction(0) // need the function length here. So the offset doesn't matter.
-	c.readTLSFuncAddr, err = c.allocateMemory(len(readTLSFunction))
-	return err
+	if c.vContActions, err = c.queryVContActions(); err != nil {
+		return err
+	}
+
+	if c.processInfo, err = c.QueryProcessInfo(); err != nil {
+		// the architecture is unknown then, and the caller falls back to the binary's one.
+		log.Debugf("failed to query the process info: %v", err)
+	}
+
+	// the g is read from the x28 register on arm64. The function to read the TLS is necessary only on x86-64.
+	if !c.isARM64() {
+		readTLSFunction := c.buildReadTLSFunction(0) // need the function length here. So the offset doesn't matter.
+		if c.readTLSFuncAddr, err = c.allocateMemory(len(readTLSFunction)); err != nil {
+			return err
+		}
+	}
+
+	c.startKeepAlive(keepAliveInterval)
+	return nil
+}
+
+// SetTimeout sets the timeout of each read from the debugserver. 0 means no timeout.
+// If the read times out, it's retried with the doubled timeout up to the max retries.
+func (c *Client) SetTimeout(d time.Duration) {
+	c.timeout = d
+}
+
+// startKeepAlive starts the go routine which sends the qC packet when there is no traffic for the interval.
+func (c *Client) startKeepAlive(interval time.Duration) {
+	c.stopKeepAlive = make(chan struct{})
+	go func(stopCh chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				if err := c.ping(interval); err != nil {
+					log.Debugf("failed to ping: %v", err)
+				}
+			}
+		}
+	}(c.stopKeepAlive)
+}
+
+// ping sends the qC packet if no command is in flight and there is no traffic for the interval.
+func (c *Client) ping(interval time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pendingReplies > 0 || c.running || time.Since(c.lastTraffic) < interval {
+		return nil
+	}
+
+	if err := c.sendLocked("qC"); err != nil {
+		return err
+	}
+	data, err := c.receiveLocked(c.timeout)
+	if err != nil {
+		return err
+	} else if !strings.HasPrefix(data, "QC") {
+		return fmt.Errorf("unexpected response: %s", data)
+	}
+	return nil
+}
+
+func (c *Client) setRunning(running bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running = running
 }
 
 func (c *Client) setNoAckMode() error {
@@ -152,9 +321,156 @@ func (c *Client) qSupported() error {
 		return err
 	}
 
-	// TODO: adjust the buffer size so that it doesn't exceed the PacketSize in the response.
-	_, err := c.receive()
-	return err
+	data, err := c.receive()
+	if err != nil {
+		return err
+	}
+
+	for _, feature := range strings.Split(data, ";") {
+		if feature == "hwbreak+" {
+			c.hwBreakSupported = true
+		} else if strings.HasPrefix(feature, "PacketSize=") {
+			packetSize, err := strconv.ParseInt(feature[len("PacketSize="):], 16, 64)
+			if err != nil {
+				return err
+			}
+			c.packetSize = int(packetSize)
+		}
+	}
+	return nil
+}
+
+// maxMemoryReadSize returns the max size of the memory read by one m packet.
+// The size is limited so that the reply, in which each byte is sent as 2 hex chars, fits in the packet size.
+func (c *Client) maxMemoryReadSize() int {
+	if c.packetSize == 0 {
+		return defaultMaxMemoryReadSize
+	}
+	// the reply has 4 more bytes: `$`, `#` and the 2-digit checksum.
+	if size := (c.packetSize - 4) / 2; size > 0 {
+		return size
+	}
+	return 1
+}
+
+// ProcessInfo describes the process the debugserver controls.
+type ProcessInfo struct {
+	PID int
+	// Triple is the target triple like x86_64-apple-macosx. May be empty because the debugserver may send the cpu type instead.
+	Triple  string
+	CPUType uint64
+	OSType  string
+	Vendor  string
+	Endian  string
+	PtrSize int
+}
+
+const (
+	// must be same as the values defined in mach/machine.h
+	cpuTypeX86_64 = 0x1000007
+	cpuTypeARM64  = 0x100000c
+)
+
+// Architecture returns the architecture part of the triple, like x86_64 and arm64.
+func (info ProcessInfo) Architecture() string {
+	if info.Triple != "" {
+		return strings.SplitN(info.Triple, "-", 2)[0]
+	}
+
+	switch info.CPUType {
+	case cpuTypeX86_64:
+		return "x86_64"
+	case cpuTypeARM64:
+		return "arm64"
+	}
+	return ""
+}
+
+// QueryProcessInfo returns the process info using the qProcessInfo packet.
+func (c *Client) QueryProcessInfo() (ProcessInfo, error) {
+	const command = "qProcessInfo"
+	if err := c.send(command); err != nil {
+		return ProcessInfo{}, err
+	}
+
+	data, err := c.receive()
+	if err != nil {
+		return ProcessInfo{}, err
+	} else if data == "" || strings.HasPrefix(data, "E") {
+		return ProcessInfo{}, fmt.Errorf("error response: %s", data)
+	}
+	return c.parseProcessInfo(data)
+}
+
+func (c *Client) parseProcessInfo(data string) (info ProcessInfo, err error) {
+	for _, kvInStr := range strings.Split(data, ";") {
+		kvArr := strings.SplitN(kvInStr, ":", 2)
+		if len(kvArr) != 2 {
+			continue
+		}
+
+		key, value := kvArr[0], kvArr[1]
+		switch key {
+		case "pid":
+			var pid uint64
+			pid, err = hexToUint64(value, false)
+			info.PID = int(pid)
+		case "triple":
+			var triple []byte
+			triple, err = hexToByteArray(value)
+			info.Triple = string(triple)
+		case "cputype":
+			info.CPUType, err = hexToUint64(value, false)
+		case "ostype":
+			info.OSType = value
+		case "vendor":
+			info.Vendor = value
+		case "endian":
+			info.Endian = value
+		case "ptrsize":
+			info.PtrSize, err = strconv.Atoi(value)
+		}
+		if err != nil {
+			return ProcessInfo{}, err
+		}
+	}
+	return info, nil
+}
+
+// Architecture returns the architecture of the process, like x86_64 and arm64. Empty if unknown.
+func (c *Client) Architecture() string {
+	return c.processInfo.Architecture()
+}
+
+// isARM64 returns true if the process runs on arm64. The x86-64 code can't be injected into such a process.
+func (c *Client) isARM64() bool {
+	return c.Architecture() == "arm64"
+}
+
+// queryVContActions returns the actions the vCont packet supports. Empty if the vCont packet is not supported.
+func (c *Client) queryVContActions() ([]string, error) {
+	const command = "vCont?"
+	if err := c.send(command); err != nil {
+		return nil, err
+	}
+
+	data, err := c.receive()
+	if err != nil {
+		return nil, err
+	} else if !strings.HasPrefix(data, "vCont") {
+		// the empty response means the packet is not supported.
+		return nil, nil
+	}
+	return strings.Split(data, ";")[1:], nil
+}
+
+// vContSupported returns true if the vCont packet supports the continue and step actions.
+func (c *Client) vContSupported() bool {
+	supported := make(map[string]bool)
+	for _, action := range c.vContActions {
+		supported[action] = true
+	}
+	return supported["c"] && supported["C"] && supported["s"] && supported["S"]
 }
 
 func (c *Client) qThreadSuffixSupported() error {
@@ -281,21 +597,35 @@ func (c *Client) ThreadIDs() ([]int, error) {
 	if err != nil {
 		return nil, err
 	}
-	// TODO: call qsThreadInfo
 
 	var threadIDs []int
-	for _, rawThreadID := range strings.Split(rawThreadIDs, ",") {
-		threadID, err := hexToUint64(rawThreadID, false)
+	for rawThreadIDs != "" {
+		for _, rawThreadID := range strings.Split(rawThreadIDs, ",") {
+			threadID, err := hexToUint64(rawThreadID, false)
+			if err != nil {
+				return nil, err
+			}
+			threadIDs = append(threadIDs, int(threadID))
+		}
+
+		rawThreadIDs, err = c.qsThreadInfo()
 		if err != nil {
 			return nil, err
 		}
-		threadIDs = append(threadIDs, int(threadID))
 	}
 	return threadIDs, nil
 }
 
 func (c *Client) qfThreadInfo() (string, error) {
-	const command = "qfThreadInfo"
+	return c.qThreadInfo("qfThreadInfo")
+}
+
+func (c *Client) qsThreadInfo() (string, error) {
+	return c.qThreadInfo("qsThreadInfo")
+}
+
+// qThreadInfo returns the comma-separated thread ids. It's empty if there are no more threads.
+func (c *Client) qThreadInfo(command string) (string, error) {
 	if err := c.send(command); err != nil {
 		return "", err
 	}
@@ -303,6 +633,8 @@ func (c *Client) qfThreadInfo() (string, error) {
 	data, err := c.receive()
 	if err != nil {
 		return "", err
+	} else if data == "l" {
+		return "", nil
 	} else if !strings.HasPrefix(data, "m") {
 		return "", fmt.Errorf("unexpected response: %s", data)
 	}
@@ -312,29 +644,10 @@ func (c *Client) qfThreadInfo() (string, error) {
 
 // AttachProcess lets the debugserver attach the new prcoess.
 func (c *Client) AttachProcess(pid int) error {
-	listener, err := net.Listen("tcp", "localhost:")
-	if err != nil {
-		return err
-	}
-
-	path, err := debugServerPath()
-	if err != nil {
+	if err := c.startDebugServer([]string{"-F", fmt.Sprintf("--attach=%d", pid)}); err != nil {
 		return err
 	}
 
-	debugServerArgs := []string{"-F", "-R", listener.Addr().String(), fmt.Sprintf("--attach=%d", pid)}
-	cmd := exec.Command(path, debugServerArgs...)
-	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true} // Otherwise, the signal sent to all the group members.
-	if err := cmd.Start(); err != nil {
-		return err
-	}
-
-	c.conn, err = c.waitConnectOrExit(listener, cmd)
-	if err != nil {
-		return err
-	}
-	c.pid = cmd.Process.Pid
-
 	return c.initialize()
 }
 
@@ -353,6 +666,10 @@ func (c *Client) DetachProcess() error {
 }
 
 func (c *Client) close() error {
+	if c.stopKeepAlive != nil {
+		close(c.stopKeepAlive)
+		c.stopKeepAlive = nil
+	}
 	return c.conn.Close()
 }
 
@@ -380,6 +697,21 @@ func (c *Client) ReadRegisters(threadID int) (Registers, error) {
 	return c.parseRegisterData(data)
 }
 
+// ReadXMMRegisters reads the target threadID's xmm registers. ReadRegisters reads them as well, because they are
+// in the same reply.
+func (c *Client) ReadXMMRegisters(threadID int, regs *Registers) error {
+	allRegs, err := c.ReadRegisters(threadID)
+	if err != nil {
+		return err
+	}
+
+	for i, xmmReg := range regs.xmmRegisters() {
+		*xmmReg = *allRegs.xmmRegisters()[i]
+	}
+	regs.xmmRead = true
+	return nil
+}
+
 func (c *Client) readRegisters(threadID int) (string, error) {
 	command := fmt.Sprintf("g;thread:%x;", threadID)
 	if err := c.send(command); err != nil {
@@ -400,15 +732,21 @@ func (c *Client) parseRegisterData(data string) (Registers, error) {
 	for _, metadata := range c.registerMetadataList {
 		rawValue := data[metadata.offset*2 : (metadata.offset+metadata.size)*2]
 
-		var err error
-		switch metadata.name {
-		case "rip":
-			regs.Rip, err = hexToUint64(rawValue, true)
-		case "rsp":
-			regs.Rsp, err = hexToUint64(rawValue, true)
-		case "rcx":
-			regs.Rcx, err = hexToUint64(rawValue, true)
+		if xmmReg := regs.xmmRegisterByName(metadata.name); xmmReg != nil && metadata.size == len(xmmReg) {
+			if _, err := hex.Decode(xmmReg[:], []byte(rawValue)); err != nil {
+				return Registers{}, err
+			}
+			regs.xmmRead = true
+			continue
+		}
+
+		reg := regs.registerByName(metadata.name)
+		if reg == nil {
+			continue
 		}
+
+		var err error
+		*reg, err = hexToUint64(rawValue, true)
 		if err != nil {
 			return Registers{}, err
 		}
@@ -430,17 +768,13 @@ func (c *Client) WriteRegisters(threadID int, regs Registers) error {
 		prefix := data[0 : metadata.offset*2]
 		suffix := data[(metadata.offset+metadata.size)*2:]
 
-		var err error
-		switch metadata.name {
-		case "rip":
-			data = fmt.Sprintf("%s%s%s", prefix, uint64ToHex(regs.Rip, true), suffix)
-		case "rsp":
-			data = fmt.Sprintf("%s%s%s", prefix, uint64ToHex(regs.Rsp, true), suffix)
-		case "rcx":
-			data = fmt.Sprintf("%s%s%s", prefix, uint64ToHex(regs.Rcx, true), suffix)
-		}
-		if err != nil {
-			return err
+		if xmmReg := regs.xmmRegisterByName(metadata.name); xmmReg != nil && metadata.size == len(xmmReg) {
+			if !regs.xmmRead {
+				continue
+			}
+			data = fmt.Sprintf("%s%s%s", prefix, hex.EncodeToString(xmmReg[:]), suffix)
+		} else if reg := regs.registerByName(metadata.name); reg != nil {
+			data = fmt.Sprintf("%s%s%s", prefix, uint64ToHex(*reg, true), suffix)
 		}
 	}
 
@@ -453,17 +787,30 @@ func (c *Client) WriteRegisters(threadID int, regs Registers) error {
 }
 
 // ReadMemory reads the specified memory region.
+// The read larger than the packet size is split into multiple m packets.
 func (c *Client) ReadMemory(addr uint64, out []byte) error {
+	maxSize := c.maxMemoryReadSize()
+	for len(out) > maxSize {
+		if err := c.readMemory(addr, out[:maxSize]); err != nil {
+			return err
+		}
+		addr += uint64(maxSize)
+		out = out[maxSize:]
+	}
+	return c.readMemory(addr, out)
+}
+
+func (c *Client) readMemory(addr uint64, out []byte) error {
 	command := fmt.Sprintf("m%x,%x", addr, len(out))
 	if err := c.send(command); err != nil {
-		return err
+		return MemoryReadError{Addr: addr, Size: len(out), Err: err}
 	}
 
 	data, err := c.receive()
 	if err != nil {
-		return err
+		return MemoryReadError{Addr: addr, Size: len(out), Err: err}
 	} else if strings.HasPrefix(data, "E") {
-		return fmt.Errorf("error response: %s", data)
+		return MemoryReadError{Addr: addr, Size: len(out), Err: fmt.Errorf("error response: %s", data)}
 	}
 
 	byteArrary, err := hexToByteArray(data)
@@ -471,12 +818,98 @@ func (c *Client) ReadMemory(addr uint64, out []byte) error {
 		return err
 	}
 	if len(byteArrary) != len(out) {
-		log.Debugf("The data size read from the memory is smaller than the requested size. actual: %d, expected: %d", len(byteArrary), len(out))
+		return MemoryReadError{Addr: addr, Size: len(out), Err: fmt.Errorf("the data size read from the memory is smaller than the requested size. actual: %d, expected: %d", len(byteArrary), len(out))}
 	}
 	copy(out, byteArrary)
 	return nil
 }
 
+// SetSignalForwarding sets whether the signal the tracee received is delivered to the tracee.
+func (c *Client) SetSignalForwarding(sig syscall.Signal, forward bool) {
+	c.setForwarding(sig, forward)
+}
+
+// MemoryRegions returns the mapped memory regions of the process using the qMemoryRegionInfo packet.
+func (c *Client) MemoryRegions() ([]MemoryRegion, error) {
+	var regions []MemoryRegion
+	var addr uint64
+	for {
+		region, mapped, err := c.qMemoryRegionInfo(addr)
+		if err != nil {
+			return nil, err
+		}
+		if mapped {
+			regions = append(regions, region)
+		}
+
+		if region.End <= addr {
+			// reaches the end of the address space
+			return regions, nil
+		}
+		addr = region.End
+	}
+}
+
+// MemoryRegionInfo returns the mapped memory region which contains the address using the qMemoryRegionInfo packet.
+func (c *Client) MemoryRegionInfo(addr uint64) (MemoryRegion, error) {
+	region, mapped, err := c.qMemoryRegionInfo(addr)
+	if err != nil {
+		return MemoryRegion{}, err
+	} else if !mapped {
+		return MemoryRegion{}, ErrNotMapped
+	}
+	return region, nil
+}
+
+func (c *Client) qMemoryRegionInfo(addr uint64) (region MemoryRegion, mapped bool, err error) {
+	command := fmt.Sprintf("qMemoryRegionInfo:%x", addr)
+	if err := c.send(command); err != nil {
+		return MemoryRegion{}, false, err
+	}
+
+	data, err := c.receive()
+	if err != nil {
+		return MemoryRegion{}, false, err
+	} else if data == "" || strings.HasPrefix(data, "E") {
+		return MemoryRegion{}, false, fmt.Errorf("error response: %s", data)
+	}
+
+	var size uint64
+	for _, kvInStr := range strings.Split(data, ";") {
+		kvArr := strings.SplitN(kvInStr, ":", 2)
+		if len(kvArr) != 2 {
+			continue
+		}
+
+		key, value := kvArr[0], kvArr[1]
+		switch key {
+		case "start":
+			region.Start, err = hexToUint64(value, false)
+		case "size":
+			size, err = hexToUint64(value, false)
+		case "permissions":
+			mapped = true
+			region.Readable = strings.Contains(value, "r")
+			region.Writable = strings.Contains(value, "w")
+			region.Executable = strings.Contains(value, "x")
+		case "name":
+			var name []byte
+			name, err = hexToByteArray(value)
+			region.Name = string(name)
+		}
+		if err != nil {
+			return MemoryRegion{}, false, err
+		}
+	}
+	region.End = region.Start + size
+	return region, mapped, nil
+}
+
+// ReadMemoryBatch reads the multiple memory regions. The nearby regions are coalesced and read by one 'm' packet.
+func (c *Client) ReadMemoryBatch(reads []MemoryRead) error {
+	return readMemoryBatch(c.ReadMemory, reads, c.maxMemoryReadSize())
+}
+
 // WriteMemory write the data to the specified region
 func (c *Client) WriteMemory(addr uint64, data []byte) error {
 	dataInHex := ""
@@ -491,8 +924,162 @@ func (c *Client) WriteMemory(addr uint64, data []byte) error {
 	return c.receiveAndCheck()
 }
 
-// ReadTLS reads the offset from the beginning of the TLS block.
+// SetHardwareBreakpoint sets the hardware breakpoint using the Z1 packet.
+// The debugserver doesn't expose the debug registers, so the slot is just used to manage the breakpoints.
+func (c *Client) SetHardwareBreakpoint(slot int, addr uint64) error {
+	if !c.hwBreakSupported {
+		return ErrNotSupported
+	}
+	return c.setHardwareBreakpoint(slot, hardwareBreakpoint{addr: addr})
+}
+
+// SetWatchpoint sets the watchpoint using the Z2 (write), Z3 (read) or Z4 (access) packet.
+func (c *Client) SetWatchpoint(slot int, addr uint64, size int, mode WatchMode) error {
+	if err := checkWatchpoint(addr, size, mode); err != nil {
+		return err
+	}
+	return c.setHardwareBreakpoint(slot, hardwareBreakpoint{addr: addr, mode: mode, size: size})
+}
+
+func (c *Client) setHardwareBreakpoint(slot int, bp hardwareBreakpoint) error {
+	if err := c.ClearHardwareBreakpoint(slot); err != nil {
+		return err
+	}
+
+	command := fmt.Sprintf("Z%d,%x,%x", breakpointPacketType(bp), bp.addr, breakpointPacketKind(bp))
+	if err := c.send(command); err != nil {
+		return err
+	}
+	if err := c.receiveAndCheck(); err != nil {
+		return err
+	}
+
+	c.hardwareBreakpoints[slot] = bp
+	return nil
+}
+
+// ClearHardwareBreakpoint clears the hardware breakpoint or watchpoint using the z packet.
+func (c *Client) ClearHardwareBreakpoint(slot int) error {
+	if err := checkHardwareBreakpointSlot(slot); err != nil {
+		return err
+	}
+
+	bp := c.hardwareBreakpoints[slot]
+	if !bp.used() {
+		return nil
+	}
+
+	command := fmt.Sprintf("z%d,%x,%x", breakpointPacketType(bp), bp.addr, breakpointPacketKind(bp))
+	if err := c.send(command); err != nil {
+		return err
+	}
+	if err := c.receiveAndCheck(); err != nil {
+		return err
+	}
+
+	c.hardwareBreakpoints[slot] = hardwareBreakpoint{}
+	return nil
+}
+
+// breakpointPacketType returns the type field of the Z and z packets.
+func breakpointPacketType(bp hardwareBreakpoint) int {
+	switch bp.mode {
+	case WatchWrite:
+		return 2
+	case WatchRead:
+		return 3
+	case WatchReadWrite:
+		return 4
+	default:
+		return 1
+	}
+}
+
+// breakpointPacketKind returns the kind field of the Z and z packets, which is the watched size in the watchpoint case.
+func breakpointPacketKind(bp hardwareBreakpoint) int {
+	if bp.mode == 0 {
+		return 1
+	}
+	return bp.size
+}
+
+// HardwareBreakpointHit returns the slot of the hardware breakpoint or watchpoint which traps the thread.
+// The debug status register is not available, so the stop info of the thread is checked instead.
+func (c *Client) HardwareBreakpointHit(threadID int) (int, bool, error) {
+	data, err := c.qThreadStopInfo(threadID)
+	if err != nil {
+		return 0, false, err
+	}
+	if watchedAddr, ok := c.parseWatchpointStopInfo(data); ok {
+		for slot, bp := range c.hardwareBreakpoints {
+			if bp.used() && bp.mode != 0 && bp.contains(watchedAddr) {
+				return slot, true, nil
+			}
+		}
+		return 0, false, nil
+	}
+
+	regs, err := c.ReadRegisters(threadID)
+	if err != nil {
+		return 0, false, err
+	}
+
+	for slot, bp := range c.hardwareBreakpoints {
+		if bp.used() && bp.mode == 0 && bp.contains(regs.Rip) {
+			return slot, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// parseWatchpointStopInfo returns the watched address if the stop reason is the watchpoint.
+// The address is in the 'watch', 'rwatch' or 'awatch' field of the stop reply.
+// In addition, the debugserver sends the reason and the description, which is the hex-encoded string like '<watched addr> <index> <hit addr>'.
+func (c *Client) parseWatchpointStopInfo(data string) (uint64, bool) {
+	var reason, description string
+	for _, kvInStr := range strings.Split(data[3:], ";") {
+		kvArr := strings.SplitN(kvInStr, ":", 2)
+		if len(kvArr) != 2 {
+			continue
+		}
+		switch kvArr[0] {
+		case "watch", "rwatch", "awatch":
+			if watchedAddr, err := hexToUint64(kvArr[1], false); err == nil {
+				return watchedAddr, true
+			}
+		case "reason":
+			reason = kvArr[1]
+		case "description":
+			description = kvArr[1]
+		}
+	}
+	if reason != "watchpoint" {
+		return 0, false
+	}
+
+	rawDescription, err := hex.DecodeString(description)
+	if err != nil {
+		return 0, false
+	}
+	fields := strings.Fields(string(rawDescription))
+	if len(fields) == 0 {
+		return 0, false
+	}
+	watchedAddr, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return watchedAddr, true
+}
+
+// ReadTLS reads the offset from the beginning of the TLS block. The x86-64 instruction to read it is injected into the process.
+// On arm64, the value of the x28 register is returned instead, because the go runtime keeps the current g in it.
 func (c *Client) ReadTLS(threadID int, offset int32) (uint64, error) {
+	if c.isARM64() {
+		regs, err := c.ReadRegisters(threadID)
+		return regs.X28, err
+	}
+
 	if err := c.updateReadTLSFunction(uint32(offset)); err != nil {
 		return 0, err
 	}
@@ -548,15 +1135,11 @@ func (c *Client) ContinueAndWait() (Event, error) {
 // The returned event may not be the trapped event.
 // If unspecified thread is stopped, UnspecifiedThreadError is returned.
 func (c *Client) StepAndWait(threadID int) (Event, error) {
-	var command string
-	if c.pendingSignal == 0 {
-		command = fmt.Sprintf("vCont;s:%x", threadID)
-	} else {
-		command = fmt.Sprintf("vCont;S%02x:%x", c.pendingSignal, threadID)
-	}
+	c.setRunning(true)
+	defer c.setRunning(false)
 
-	if err := c.send(command); err != nil {
-		return Event{}, fmt.Errorf("send error: %v", err)
+	if err := c.sendStep(threadID, c.pendingSignal); err != nil {
+		return Event{}, fmt.Errorf("send error: %w", err)
 	}
 
 	event, err := c.wait()
@@ -570,22 +1153,56 @@ func (c *Client) StepAndWait(threadID int) (Event, error) {
 	return event, err
 }
 
-func (c *Client) continueAndWait(signalNumber int) (Event, error) {
-	var command string
+// sendStep sends the packet to step the thread. The vCont packet is used if supported.
+// Otherwise, the thread is selected by the Hc packet and then the s or S packet is sent.
+func (c *Client) sendStep(threadID, signalNumber int) error {
+	if c.vContSupported() {
+		if signalNumber == 0 {
+			return c.send(fmt.Sprintf("vCont;s:%x", threadID))
+		}
+		return c.send(fmt.Sprintf("vCont;S%02x:%x", signalNumber, threadID))
+	}
+
+	if err := c.send(fmt.Sprintf("Hc%x", threadID)); err != nil {
+		return err
+	}
+	if err := c.receiveAndCheck(); err != nil {
+		return err
+	}
 	if signalNumber == 0 {
-		command = "vCont;c"
-	} else {
-		// Though the signal number is specified, it's like the debugserver does not pass the signals like SIGTERM and SIGINT to the debugee.
-		// QPassSignals can change this setting, but debugserver (900.0.64) doesn't support the query.
-		command = fmt.Sprintf("vCont;C%02x", signalNumber)
+		return c.send("s")
 	}
-	if err := c.send(command); err != nil {
-		return Event{}, fmt.Errorf("send error: %v", err)
+	return c.send(fmt.Sprintf("S%02x", signalNumber))
+}
+
+func (c *Client) continueAndWait(signalNumber int) (Event, error) {
+	c.setRunning(true)
+	defer c.setRunning(false)
+
+	if err := c.sendContinue(signalNumber); err != nil {
+		return Event{}, fmt.Errorf("send error: %w", err)
 	}
 
 	return c.wait()
 }
 
+// sendContinue sends the packet to continue all the threads. The vCont packet is used if supported.
+func (c *Client) sendContinue(signalNumber int) error {
+	if signalNumber == 0 {
+		if c.vContSupported() {
+			return c.send("vCont;c")
+		}
+		return c.send("c")
+	}
+
+	// Though the signal number is specified, it's like the debugserver does not pass the signals like SIGTERM and SIGINT to the debugee.
+	// QPassSignals can change this setting, but debugserver (900.0.64) doesn't support the query.
+	if c.vContSupported() {
+		return c.send(fmt.Sprintf("vCont;C%02x", signalNumber))
+	}
+	return c.send(fmt.Sprintf("C%02x", signalNumber))
+}
+
 func (c *Client) wait() (Event, error) {
 	var data string
 	var err error
@@ -595,13 +1212,13 @@ func (c *Client) wait() (Event, error) {
 			// debugserver sometimes does not send a reply packet even when a thread is stopped.
 			data, err = c.checkStopReply()
 			if err != nil {
-				return Event{}, fmt.Errorf("failed to query stop reply: %v", err)
+				return Event{}, fmt.Errorf("failed to query stop reply: %w", err)
 			} else if data != "" {
 				log.Debugf("debugserver did not reply packets though there is the stopped thread.")
 				break
 			}
 		} else if err != nil {
-			return Event{}, fmt.Errorf("receive error: %v", err)
+			return Event{}, fmt.Errorf("receive error: %w", err)
 		}
 		if data != "" {
 			break
@@ -612,7 +1229,7 @@ func (c *Client) wait() (Event, error) {
 	// process O packet beforehand in order to simplify further processing.
 	stopReplies, err = c.processOutputPacket(stopReplies)
 	if err != nil {
-		return Event{}, fmt.Errorf("failed to process output packet: %v", err)
+		return Event{}, fmt.Errorf("failed to process output packet: %w", err)
 	}
 	if len(stopReplies) == 0 {
 		return c.wait()
@@ -722,14 +1339,17 @@ func (c *Client) handleTPacket(packet string) (Event, error) {
 	if err != nil {
 		return Event{}, err
 	} else if len(trappedThreadIDs) == 0 {
-		return c.continueAndWait(int(signalNumber))
+		return c.continueAndWait(c.filter(int(signalNumber)))
 	}
 	if syscall.Signal(signalNumber) != unix.SIGTRAP {
-		c.pendingSignal = int(signalNumber)
+		c.pendingSignal = c.filter(int(signalNumber))
 	} else {
 		c.pendingSignal = 0
 	}
 
+	if watchedAddr, ok := c.parseWatchpointStopInfo(packet); ok {
+		return Event{Type: EventTypeWatched, Data: WatchedData{Addr: watchedAddr, ThreadIDs: trappedThreadIDs}}, nil
+	}
 	return Event{Type: EventTypeTrapped, Data: trappedThreadIDs}, nil
 }
 
@@ -780,16 +1400,24 @@ func (c *Client) handleXPacket(packet string) (Event, error) {
 }
 
 func (c *Client) send(command string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sendLocked(command)
+}
+
+func (c *Client) sendLocked(command string) error {
 	packet := fmt.Sprintf("$%s#00", command)
 	if !c.noAckMode {
 		packet = fmt.Sprintf("$%s#%02x", command, calcChecksum([]byte(command)))
 	}
 
+	c.lastTraffic = time.Now()
 	if n, err := c.conn.Write([]byte(packet)); err != nil {
 		return err
 	} else if n != len(packet) {
 		return fmt.Errorf("only part of the buffer is sent: %d / %d", n, len(packet))
 	}
+	c.pendingReplies++
 
 	if !c.noAckMode {
 		return c.receiveAck()
@@ -807,11 +1435,44 @@ func (c *Client) receiveAndCheck() error {
 	return nil
 }
 
+// receive receives the packet. If the timeout is set and the read times out, it's retried with the exponential backoff.
 func (c *Client) receive() (string, error) {
-	var rawPacket []byte
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	timeout := c.timeout
+	for retries := 0; ; retries++ {
+		data, err := c.receiveLocked(timeout)
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() && timeout > 0 && retries < c.maxRetries {
+			log.Debugf("read timed out (timeout: %v). retry", timeout)
+			timeout *= 2
+			continue
+		}
+		return data, err
+	}
+}
+
+// receiveWithTimeout receives the packet. Unlike the receive, it's not retried when the read times out.
+func (c *Client) receiveWithTimeout(timeout time.Duration) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.receiveLocked(timeout)
+}
+
+// receiveLocked receives the packet. The read has no deadline if the timeout is 0.
+func (c *Client) receiveLocked(timeout time.Duration) (string, error) {
+	if timeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(timeout))
+		defer c.conn.SetReadDeadline(time.Time{})
+	}
+
+	rawPacket := c.partialPacket
+	c.partialPacket = nil
 	for {
 		n, err := c.conn.Read(c.buffer)
 		if err != nil {
+			c.partialPacket = rawPacket
 			return "", err
 		}
 
@@ -825,6 +1486,10 @@ func (c *Client) receive() (string, error) {
 			break
 		}
 	}
+	c.lastTraffic = time.Now()
+	if c.pendingReplies > 0 {
+		c.pendingReplies--
+	}
 
 	packet := string(rawPacket)
 	data := string(rawPacket[1 : len(rawPacket)-3])
@@ -838,13 +1503,6 @@ func (c *Client) receive() (string, error) {
 	return data, nil
 }
 
-func (c *Client) receiveWithTimeout(timeout time.Duration) (string, error) {
-	c.conn.SetReadDeadline(time.Now().Add(timeout))
-	defer c.conn.SetReadDeadline(time.Time{})
-
-	return c.receive()
-}
-
 func (c *Client) sendAck() error {
 	_, err := c.conn.Write([]byte("+"))
 	return err
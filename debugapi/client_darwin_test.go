@@ -2,6 +2,7 @@ package debugapi
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"net"
 	"os"
@@ -18,7 +19,7 @@ import (
 )
 
 func TestCheckInterface(t *testing.T) {
-	var _ client = NewClient()
+	var _ Debugger = NewClient()
 }
 
 func TestLaunchProcess(t *testing.T) {
@@ -30,6 +31,23 @@ func TestLaunchProcess(t *testing.T) {
 	defer client.DetachProcess()
 }
 
+func TestLaunchProcess_Unix(t *testing.T) {
+	if os.Getenv("LLDB_DEBUGSERVER_PATH") != "" {
+		t.Skip("the tcp connection is used when LLDB_DEBUGSERVER_PATH is set")
+	}
+
+	client := NewClient()
+	err := client.LaunchProcess(testutils.ProgramInfloop)
+	if err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	defer client.DetachProcess()
+
+	if _, ok := client.conn.(*net.UnixConn); !ok {
+		t.Errorf("not unix domain socket: %#v", client.conn)
+	}
+}
+
 func TestLaunchProcess_ProgramNotExist(t *testing.T) {
 	client := NewClient()
 	err := client.LaunchProcess("notexist")
@@ -201,6 +219,56 @@ func TestReadMemory(t *testing.T) {
 	}
 }
 
+func TestReadMemoryBatch(t *testing.T) {
+	client := NewClient()
+	err := client.LaunchProcess(testutils.ProgramInfloop)
+	if err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	defer client.DetachProcess()
+
+	expected := make([]byte, 2)
+	if err := client.ReadMemory(testutils.InfloopAddrMain, expected); err != nil {
+		t.Fatalf("failed to read memory: %v", err)
+	}
+
+	reads := []MemoryRead{
+		{Addr: testutils.InfloopAddrMain + 1, Buf: make([]byte, 1)},
+		{Addr: testutils.InfloopAddrMain, Buf: make([]byte, 1)},
+	}
+	if err := client.ReadMemoryBatch(reads); err != nil {
+		t.Fatalf("failed to read memory: %v", err)
+	}
+
+	if reads[1].Buf[0] != expected[0] || reads[0].Buf[0] != expected[1] {
+		t.Errorf("wrong memory: %v", reads)
+	}
+}
+
+func TestMemoryRegions(t *testing.T) {
+	client := NewClient()
+	err := client.LaunchProcess(testutils.ProgramInfloop)
+	if err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	defer client.DetachProcess()
+
+	regions, err := client.MemoryRegions()
+	if err != nil {
+		t.Fatalf("failed to get memory regions: %v", err)
+	}
+
+	for _, region := range regions {
+		if region.Contains(testutils.InfloopAddrMain) {
+			if !region.Readable || !region.Executable {
+				t.Errorf("wrong permissions: %#v", region)
+			}
+			return
+		}
+	}
+	t.Errorf("no region contains the main function: %v", regions)
+}
+
 func TestReadMemory_LargeSize(t *testing.T) {
 	client := NewClient()
 	err := client.LaunchProcess(testutils.ProgramInfloop)
@@ -220,6 +288,86 @@ func TestReadMemory_LargeSize(t *testing.T) {
 	}
 }
 
+func TestReadMemory_ConnectionClosed(t *testing.T) {
+	connForReceive, connForSend := net.Pipe()
+	connForSend.Close()
+	connForReceive.Close()
+
+	client := newTestClient(connForReceive, true)
+	err := client.ReadMemory(0x1000, make([]byte, 8))
+	var memoryReadErr MemoryReadError
+	if !errors.As(err, &memoryReadErr) {
+		t.Fatalf("not MemoryReadError: %#v", err)
+	}
+	if memoryReadErr.Addr != 0x1000 || memoryReadErr.Size != 8 {
+		t.Errorf("wrong error: %#v", memoryReadErr)
+	}
+}
+
+func TestReadMemory_ShortRead(t *testing.T) {
+	connForReceive, connForSend := net.Pipe()
+
+	sendDone := make(chan bool)
+	go func(conn net.Conn, ch chan bool) {
+		defer close(ch)
+
+		client := newTestClient(conn, true)
+		if _, err := client.receive(); err != nil {
+			t.Errorf("failed to receive command: %v", err)
+			return
+		}
+
+		if err := client.send("0102"); err != nil {
+			t.Errorf("failed to send command: %v", err)
+		}
+	}(connForSend, sendDone)
+
+	client := newTestClient(connForReceive, true)
+	err := client.ReadMemory(0x1000, make([]byte, 4))
+	var memoryReadErr MemoryReadError
+	if !errors.As(err, &memoryReadErr) {
+		t.Errorf("not MemoryReadError: %#v", err)
+	}
+
+	<-sendDone
+}
+
+func TestReadMemory_SplitByPacketSize(t *testing.T) {
+	connForReceive, connForSend := net.Pipe()
+
+	sendDone := make(chan bool)
+	go func(conn net.Conn, ch chan bool) {
+		defer close(ch)
+
+		client := newTestClient(conn, true)
+		for _, packet := range []struct{ command, reply string }{{"m1000,2", "0102"}, {"m1002,1", "03"}} {
+			if data, err := client.receive(); err != nil {
+				t.Errorf("failed to receive command: %v", err)
+				return
+			} else if data != packet.command {
+				t.Errorf("unexpected data: %s", data)
+			}
+
+			if err := client.send(packet.reply); err != nil {
+				t.Errorf("failed to send command: %v", err)
+				return
+			}
+		}
+	}(connForSend, sendDone)
+
+	client := newTestClient(connForReceive, true)
+	client.packetSize = 8
+	out := make([]byte, 3)
+	if err := client.ReadMemory(0x1000, out); err != nil {
+		t.Errorf("failed to read memory: %v", err)
+	}
+	if out[0] != 0x1 || out[1] != 0x2 || out[2] != 0x3 {
+		t.Errorf("wrong memory: %v", out)
+	}
+
+	<-sendDone
+}
+
 func TestWriteMemory(t *testing.T) {
 	client := NewClient()
 	err := client.LaunchProcess(testutils.ProgramInfloop)
@@ -287,6 +435,36 @@ func TestContinueAndWait_Trapped(t *testing.T) {
 	}
 }
 
+func TestSetHardwareBreakpoint(t *testing.T) {
+	client := NewClient()
+	err := client.LaunchProcess(testutils.ProgramInfloop)
+	if err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	defer client.DetachProcess()
+
+	if err := client.SetHardwareBreakpoint(1, testutils.InfloopAddrMain); err == ErrNotSupported {
+		t.Skip("the debugserver doesn't support the hardware breakpoint")
+	} else if err != nil {
+		t.Fatalf("failed to set hardware breakpoint: %v", err)
+	}
+
+	event, err := client.ContinueAndWait()
+	if err != nil {
+		t.Fatalf("failed to continue and wait: %v", err)
+	}
+	if event.Type != EventTypeTrapped {
+		t.Fatalf("wrong event type: %v", event.Type)
+	}
+	slot, hit, err := client.HardwareBreakpointHit(event.Data.([]int)[0])
+	if err != nil {
+		t.Fatalf("failed to check hardware breakpoint: %v", err)
+	}
+	if !hit || slot != 1 {
+		t.Errorf("unexpected hit: %v, %d", hit, slot)
+	}
+}
+
 func TestContinueAndWait_Exited(t *testing.T) {
 	client := NewClient()
 	err := client.LaunchProcess(testutils.ProgramHelloworld)
@@ -531,6 +709,132 @@ func TestQSupported(t *testing.T) {
 	if err := client.qSupported(); err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
+	if client.packetSize != 0x20000 {
+		t.Errorf("wrong packet size: %#x", client.packetSize)
+	}
+
+	<-sendDone
+}
+
+func TestQSupported_HWBreak(t *testing.T) {
+	connForReceive, connForSend := net.Pipe()
+
+	sendDone := make(chan bool)
+	go func(conn net.Conn, ch chan bool) {
+		defer close(ch)
+
+		client := newTestClient(conn, true)
+		if _, err := client.receive(); err != nil {
+			t.Errorf("failed to receive command: %v", err)
+			return
+		}
+
+		if err := client.send("qXfer:features:read+;PacketSize=20000;hwbreak+"); err != nil {
+			t.Errorf("failed to send command: %v", err)
+		}
+	}(connForSend, sendDone)
+
+	client := newTestClient(connForReceive, true)
+
+	if err := client.qSupported(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !client.hwBreakSupported {
+		t.Errorf("hwbreak is not supported")
+	}
+
+	<-sendDone
+}
+
+func TestSetHardwareBreakpoint_NotSupported(t *testing.T) {
+	client := newTestClient(nil, true)
+	if err := client.SetHardwareBreakpoint(0, 0x1000); err != ErrNotSupported {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestSetWatchpoint(t *testing.T) {
+	connForReceive, connForSend := net.Pipe()
+
+	sendDone := make(chan bool)
+	go func(conn net.Conn, ch chan bool) {
+		defer close(ch)
+
+		client := newTestClient(conn, true)
+		if data, err := client.receive(); err != nil {
+			t.Errorf("failed to receive command: %v", err)
+			return
+		} else if data != "Z2,1000,8" {
+			t.Errorf("unexpected data: %s", data)
+		}
+
+		if err := client.send("OK"); err != nil {
+			t.Errorf("failed to send command: %v", err)
+		}
+	}(connForSend, sendDone)
+
+	client := newTestClient(connForReceive, true)
+
+	if err := client.SetWatchpoint(0, 0x1000, 8, WatchWrite); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if client.hardwareBreakpoints[0] != (hardwareBreakpoint{addr: 0x1000, mode: WatchWrite, size: 8}) {
+		t.Errorf("unexpected watchpoint: %v", client.hardwareBreakpoints[0])
+	}
+
+	<-sendDone
+}
+
+func TestParseWatchpointStopInfo(t *testing.T) {
+	client := newTestClient(nil, true)
+	for i, testdata := range []struct {
+		data         string
+		expectedAddr uint64
+		expectedOK   bool
+	}{
+		{data: "T05thread:1;watch:c000010000;", expectedAddr: 0xc000010000, expectedOK: true},
+		{data: "T05thread:1;awatch:1000;", expectedAddr: 0x1000, expectedOK: true},
+		// the hex-encoded '4096 0 4096'
+		{data: "T05thread:1;reason:watchpoint;description:3430393620302034303936;", expectedAddr: 0x1000, expectedOK: true},
+		{data: "T05thread:1;reason:breakpoint;", expectedOK: false},
+	} {
+		addr, ok := client.parseWatchpointStopInfo(testdata.data)
+		if addr != testdata.expectedAddr || ok != testdata.expectedOK {
+			t.Errorf("[%d] unexpected result: %#x, %v", i, addr, ok)
+		}
+	}
+}
+
+func TestQueryVContActions(t *testing.T) {
+	connForReceive, connForSend := net.Pipe()
+
+	sendDone := make(chan bool)
+	go func(conn net.Conn, ch chan bool) {
+		defer close(ch)
+
+		client := newTestClient(conn, true)
+		if data, err := client.receive(); err != nil {
+			t.Errorf("failed to receive command: %v", err)
+			return
+		} else if data != "vCont?" {
+			t.Errorf("unexpected data: %s", data)
+		}
+
+		if err := client.send("vCont;c;C;s;S"); err != nil {
+			t.Errorf("failed to send command: %v", err)
+		}
+	}(connForSend, sendDone)
+
+	client := newTestClient(connForReceive, true)
+
+	actions, err := client.queryVContActions()
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	client.vContActions = actions
+	if !client.vContSupported() {
+		t.Errorf("vCont is not supported: %v", actions)
+	}
 
 	<-sendDone
 }
@@ -565,6 +869,116 @@ func TestCollectRegisterMetadata(t *testing.T) {
 	<-sendDone
 }
 
+func TestMemoryRegionInfo(t *testing.T) {
+	connForReceive, connForSend := net.Pipe()
+
+	sendDone := make(chan bool)
+	go func(conn net.Conn, ch chan bool) {
+		defer close(ch)
+
+		client := newTestClient(conn, true)
+		if data, err := client.receive(); err != nil {
+			t.Errorf("failed to receive command: %v", err)
+			return
+		} else if data != "qMemoryRegionInfo:1000" {
+			t.Errorf("unexpected data: %s", data)
+		}
+
+		// the name is the hex-encoded '/bin/a'
+		if err := client.send("start:1000;size:2000;permissions:rx;name:2f62696e2f61;"); err != nil {
+			t.Errorf("failed to send command: %v", err)
+		}
+	}(connForSend, sendDone)
+
+	client := newTestClient(connForReceive, true)
+
+	region, err := client.MemoryRegionInfo(0x1000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := MemoryRegion{Start: 0x1000, End: 0x3000, Readable: true, Executable: true, Name: "/bin/a"}
+	if region != expected {
+		t.Errorf("unexpected region: %#v", region)
+	}
+
+	<-sendDone
+}
+
+func TestMemoryRegionInfo_NotMapped(t *testing.T) {
+	connForReceive, connForSend := net.Pipe()
+
+	sendDone := make(chan bool)
+	go func(conn net.Conn, ch chan bool) {
+		defer close(ch)
+
+		client := newTestClient(conn, true)
+		if _, err := client.receive(); err != nil {
+			t.Errorf("failed to receive command: %v", err)
+			return
+		}
+
+		if err := client.send("start:0;size:1000;"); err != nil {
+			t.Errorf("failed to send command: %v", err)
+		}
+	}(connForSend, sendDone)
+
+	client := newTestClient(connForReceive, true)
+
+	if _, err := client.MemoryRegionInfo(0x0); err != ErrNotMapped {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	<-sendDone
+}
+
+func TestQueryProcessInfo(t *testing.T) {
+	connForReceive, connForSend := net.Pipe()
+
+	sendDone := make(chan bool)
+	go func(conn net.Conn, ch chan bool) {
+		defer close(ch)
+
+		client := newTestClient(conn, true)
+		if data, err := client.receive(); err != nil {
+			t.Errorf("failed to receive command: %v", err)
+			return
+		} else if data != "qProcessInfo" {
+			t.Errorf("unexpected data: %s", data)
+		}
+
+		if err := client.send("pid:1a2b;parent-pid:1;real-uid:1f5;cputype:1000007;cpusubtype:3;ostype:macosx;vendor:apple;endian:little;ptrsize:8;"); err != nil {
+			t.Errorf("failed to send command: %v", err)
+		}
+	}(connForSend, sendDone)
+
+	client := newTestClient(connForReceive, true)
+
+	info, err := client.QueryProcessInfo()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := ProcessInfo{PID: 0x1a2b, CPUType: 0x1000007, OSType: "macosx", Vendor: "apple", Endian: "little", PtrSize: 8}
+	if info != expected {
+		t.Errorf("unexpected info: %#v", info)
+	}
+	if info.Architecture() != "x86_64" {
+		t.Errorf("unexpected architecture: %s", info.Architecture())
+	}
+
+	<-sendDone
+}
+
+func TestProcessInfo_Architecture(t *testing.T) {
+	// the triple is hex-encoded 'arm64-apple-macosx'
+	info, err := newTestClient(nil, true).parseProcessInfo("triple:61726d36342d6170706c652d6d61636f7378;")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Architecture() != "arm64" {
+		t.Errorf("unexpected architecture: %s", info.Architecture())
+	}
+}
+
 func TestQRegisterInfo(t *testing.T) {
 	connForReceive, connForSend := net.Pipe()
 
@@ -685,6 +1099,95 @@ func TestQfThreadInfo(t *testing.T) {
 	<-sendDone
 }
 
+func TestQsThreadInfo_NoMoreThreads(t *testing.T) {
+	connForReceive, connForSend := net.Pipe()
+
+	sendDone := make(chan bool)
+	go func(conn net.Conn, ch chan bool) {
+		defer close(ch)
+
+		client := newTestClient(conn, true)
+		if data, err := client.receive(); err != nil {
+			t.Errorf("failed to receive command: %v", err)
+			return
+		} else if data != "qsThreadInfo" {
+			t.Errorf("unexpected data: %s", data)
+		}
+
+		if err := client.send("l"); err != nil {
+			t.Errorf("failed to send command: %v", err)
+		}
+	}(connForSend, sendDone)
+
+	client := newTestClient(connForReceive, true)
+
+	threadID, err := client.qsThreadInfo()
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if threadID != "" {
+		t.Errorf("unexpected threadID: %v", threadID)
+	}
+
+	<-sendDone
+}
+
+func TestReceive_RetryAfterTimeout(t *testing.T) {
+	connForReceive, connForSend := net.Pipe()
+
+	sendDone := make(chan bool)
+	go func(conn net.Conn, ch chan bool) {
+		defer close(ch)
+
+		time.Sleep(150 * time.Millisecond)
+		client := newTestClient(conn, true)
+		if err := client.send("OK"); err != nil {
+			t.Errorf("failed to send command: %v", err)
+		}
+	}(connForSend, sendDone)
+
+	client := newTestClient(connForReceive, true)
+	client.maxRetries = 2
+	client.SetTimeout(100 * time.Millisecond)
+
+	if data, err := client.receive(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if data != "OK" {
+		t.Errorf("unexpected data: %s", data)
+	}
+
+	<-sendDone
+}
+
+func TestPing(t *testing.T) {
+	connForReceive, connForSend := net.Pipe()
+
+	sendDone := make(chan bool)
+	go func(conn net.Conn, ch chan bool) {
+		defer close(ch)
+
+		client := newTestClient(conn, true)
+		if data, err := client.receive(); err != nil {
+			t.Errorf("failed to receive command: %v", err)
+			return
+		} else if data != "qC" {
+			t.Errorf("unexpected data: %s", data)
+		}
+
+		if err := client.send("QC1a2b"); err != nil {
+			t.Errorf("failed to send command: %v", err)
+		}
+	}(connForSend, sendDone)
+
+	client := newTestClient(connForReceive, true)
+
+	if err := client.ping(time.Second); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	<-sendDone
+}
+
 func TestSendAndReceive(t *testing.T) {
 	connForReceive, connForSend := net.Pipe()
 	cmd := "command"
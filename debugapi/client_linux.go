@@ -1,7 +1,6 @@
 package debugapi
 
 import (
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -9,6 +8,7 @@ import (
 	"os/exec"
 	"runtime"
 	"strconv"
+	"strings"
 	"syscall"
 
 	"github.com/ks888/tgo/log"
@@ -62,18 +62,83 @@ func (c *Client) ReadMemory(addr uint64, out []byte) (err error) {
 	return
 }
 
+func (c *Client) ReadMemoryBatch(reads []MemoryRead) (err error) {
+	c.reqCh <- func() { err = c.raw.ReadMemoryBatch(reads) }
+	_ = <-c.doneCh
+	return
+}
+
+func (c *Client) SetSignalForwarding(sig syscall.Signal, forward bool) {
+	c.reqCh <- func() { c.raw.SetSignalForwarding(sig, forward) }
+	_ = <-c.doneCh
+}
+
+func (c *Client) MemoryRegions() (regions []MemoryRegion, err error) {
+	c.reqCh <- func() { regions, err = c.raw.MemoryRegions() }
+	_ = <-c.doneCh
+	return
+}
+
+func (c *Client) Architecture() (arch string) {
+	c.reqCh <- func() { arch = c.raw.Architecture() }
+	_ = <-c.doneCh
+	return
+}
+
+func (c *Client) MemoryRegionInfo(addr uint64) (region MemoryRegion, err error) {
+	c.reqCh <- func() { region, err = c.raw.MemoryRegionInfo(addr) }
+	_ = <-c.doneCh
+	return
+}
+
+func (c *Client) SetHardwareBreakpoint(slot int, addr uint64) (err error) {
+	c.reqCh <- func() { err = c.raw.SetHardwareBreakpoint(slot, addr) }
+	_ = <-c.doneCh
+	return
+}
+
+func (c *Client) ClearHardwareBreakpoint(slot int) (err error) {
+	c.reqCh <- func() { err = c.raw.ClearHardwareBreakpoint(slot) }
+	_ = <-c.doneCh
+	return
+}
+
+func (c *Client) SetWatchpoint(slot int, addr uint64, size int, mode WatchMode) (err error) {
+	c.reqCh <- func() { err = c.raw.SetWatchpoint(slot, addr, size, mode) }
+	_ = <-c.doneCh
+	return
+}
+
+func (c *Client) HardwareBreakpointHit(threadID int) (slot int, hit bool, err error) {
+	c.reqCh <- func() { slot, hit, err = c.raw.HardwareBreakpointHit(threadID) }
+	_ = <-c.doneCh
+	return
+}
+
 func (c *Client) WriteMemory(addr uint64, data []byte) (err error) {
 	c.reqCh <- func() { err = c.raw.WriteMemory(addr, data) }
 	_ = <-c.doneCh
 	return
 }
 
+func (c *Client) ThreadIDs() (threadIDs []int, err error) {
+	c.reqCh <- func() { threadIDs, err = c.raw.ThreadIDs() }
+	_ = <-c.doneCh
+	return
+}
+
 func (c *Client) ReadRegisters(threadID int) (regs Registers, err error) {
 	c.reqCh <- func() { regs, err = c.raw.ReadRegisters(threadID) }
 	_ = <-c.doneCh
 	return
 }
 
+func (c *Client) ReadXMMRegisters(threadID int, regs *Registers) (err error) {
+	c.reqCh <- func() { err = c.raw.ReadXMMRegisters(threadID, regs) }
+	_ = <-c.doneCh
+	return
+}
+
 func (c *Client) WriteRegisters(threadID int, regs Registers) (err error) {
 	c.reqCh <- func() { err = c.raw.WriteRegisters(threadID, regs) }
 	_ = <-c.doneCh
@@ -105,11 +170,22 @@ type rawClient struct {
 	trappedThreadIDs []int
 
 	killOnDetach bool
+	signalFilter
+
+	// hardwareBreakpoints holds the breakpoints and watchpoints set to DR0-DR3.
+	hardwareBreakpoints [MaxHardwareBreakpoints]hardwareBreakpoint
+	// appliedHardwareBreakpoints holds the hardware breakpoints each thread's debug registers have.
+	appliedHardwareBreakpoints map[int][MaxHardwareBreakpoints]hardwareBreakpoint
+	// pendingSignals holds the signals the thread received while single-stepping. They are delivered when the thread continues.
+	pendingSignals map[int]int
 }
 
 // newRawClient returns the new debug api client which depends on linux ptrace.
 func newRawClient() *rawClient {
-	return &rawClient{}
+	return &rawClient{
+		appliedHardwareBreakpoints: make(map[int][MaxHardwareBreakpoints]hardwareBreakpoint),
+		pendingSignals:             make(map[int]int),
+	}
 }
 
 // LaunchProcess launches the new prcoess with ptrace enabled.
@@ -199,6 +275,12 @@ func (c *rawClient) DetachProcess() error {
 	// detach the processes even when we will kill them soon, because
 	// next wait call may receive the terminated event of these processes.
 	for _, pid := range c.tracingThreadIDs {
+		if c.appliedHardwareBreakpoints[pid] != ([MaxHardwareBreakpoints]hardwareBreakpoint{}) {
+			// the debug registers remain after detached.
+			if err := c.disableHardwareBreakpoints(pid); err != nil {
+				log.Debugf("failed to disable the hardware breakpoints of %d: %v", pid, err)
+			}
+		}
 		if err := unix.PtraceDetach(pid); err != nil {
 			// the process may have exited already
 			log.Debugf("failed to detach %d: %v", pid, err)
@@ -235,13 +317,91 @@ func (c *rawClient) ReadMemory(addr uint64, out []byte) error {
 
 	count, err := unix.PtracePeekData(c.trappedThreadIDs[0], uintptr(addr), out)
 	if err != nil {
-		return err
+		return MemoryReadError{Addr: addr, Size: len(out), Err: err}
 	} else if count != len(out) {
-		return fmt.Errorf("the number of data read is invalid: expect: %d, actual %d", len(out), count)
+		return MemoryReadError{Addr: addr, Size: len(out), Err: fmt.Errorf("the number of data read is invalid: expect: %d, actual %d", len(out), count)}
 	}
 	return nil
 }
 
+// ReadMemoryBatch reads the multiple memory regions. The nearby regions are read at once.
+func (c *rawClient) ReadMemoryBatch(reads []MemoryRead) error {
+	return readMemoryBatch(c.ReadMemory, reads, defaultMaxMemoryReadSize)
+}
+
+// SetSignalForwarding sets whether the signal the tracee received is delivered to the tracee.
+func (c *rawClient) SetSignalForwarding(sig syscall.Signal, forward bool) {
+	c.setForwarding(sig, forward)
+}
+
+// MemoryRegions returns the mapped memory regions of the process. The list is read from /proc/<pid>/maps.
+func (c *rawClient) MemoryRegions() ([]MemoryRegion, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/maps", c.tracingProcessID))
+	if err != nil {
+		return nil, err
+	}
+	return parseProcMaps(string(data))
+}
+
+// Architecture returns the architecture of the process. Ptrace can trace only the process which has the same architecture as the tracer.
+func (c *rawClient) Architecture() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x86_64"
+	default:
+		return runtime.GOARCH
+	}
+}
+
+// MemoryRegionInfo returns the mapped memory region which contains the address.
+func (c *rawClient) MemoryRegionInfo(addr uint64) (MemoryRegion, error) {
+	regions, err := c.MemoryRegions()
+	if err != nil {
+		return MemoryRegion{}, err
+	}
+
+	for _, region := range regions {
+		if region.Contains(addr) {
+			return region, nil
+		}
+	}
+	return MemoryRegion{}, ErrNotMapped
+}
+
+// parseProcMaps parses the content of /proc/<pid>/maps. Each line is like:
+//
+//	00400000-00452000 r-xp 00000000 08:02 173521      /usr/bin/dbus-daemon
+func parseProcMaps(data string) ([]MemoryRegion, error) {
+	var regions []MemoryRegion
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+
+		addrs := strings.SplitN(fields[0], "-", 2)
+		if len(addrs) != 2 {
+			return nil, fmt.Errorf("invalid address range: %s", fields[0])
+		}
+		start, err := strconv.ParseUint(addrs[0], 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		end, err := strconv.ParseUint(addrs[1], 16, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		perms := fields[1]
+		region := MemoryRegion{Start: start, End: end, Readable: perms[0] == 'r', Writable: perms[1] == 'w', Executable: perms[2] == 'x'}
+		if len(fields) >= 6 {
+			region.Name = strings.Join(fields[5:], " ")
+		}
+		regions = append(regions, region)
+	}
+	return regions, nil
+}
+
 // WriteMemory write the data to the specified memory region in the prcoess.
 func (c *rawClient) WriteMemory(addr uint64, data []byte) error {
 	if len(c.trappedThreadIDs) == 0 {
@@ -257,44 +417,79 @@ func (c *rawClient) WriteMemory(addr uint64, data []byte) error {
 	return nil
 }
 
-// ReadRegisters reads the registers of the prcoess.
-func (c *rawClient) ReadRegisters(threadID int) (regs Registers, err error) {
-	var rawRegs unix.PtraceRegs
-	if err = unix.PtraceGetRegs(threadID, &rawRegs); err != nil {
-		return regs, err
+// ThreadIDs returns the ids of the tracing threads which still exist.
+func (c *rawClient) ThreadIDs() ([]int, error) {
+	taskDir := fmt.Sprintf("/proc/%d/task", c.tracingProcessID)
+	files, err := ioutil.ReadDir(taskDir)
+	if err != nil {
+		return nil, err
+	}
+
+	existingThreadIDs := make(map[int]bool)
+	for _, file := range files {
+		if threadID, err := strconv.Atoi(file.Name()); err == nil {
+			existingThreadIDs[threadID] = true
+		}
 	}
 
-	regs.Rip = rawRegs.Rip
-	regs.Rsp = rawRegs.Rsp
-	regs.Rcx = rawRegs.Rcx
-	return regs, nil
+	var threadIDs []int
+	for _, threadID := range c.tracingThreadIDs {
+		if existingThreadIDs[threadID] {
+			threadIDs = append(threadIDs, threadID)
+		}
+	}
+	return threadIDs, nil
 }
 
-// WriteRegisters change the registers of the prcoess.
-func (c *rawClient) WriteRegisters(threadID int, regs Registers) error {
-	var rawRegs unix.PtraceRegs
-	if err := unix.PtraceGetRegs(threadID, &rawRegs); err != nil {
+// SetHardwareBreakpoint sets the hardware breakpoint using the debug register specified by the slot.
+// The debug registers are per-thread. So the breakpoint takes effect when each thread is resumed next time.
+func (c *rawClient) SetHardwareBreakpoint(slot int, addr uint64) error {
+	if !hardwareBreakpointSupported {
+		return ErrNotSupported
+	}
+	if err := checkHardwareBreakpointSlot(slot); err != nil {
 		return err
 	}
 
-	rawRegs.Rip = regs.Rip
-	rawRegs.Rsp = regs.Rsp
-	rawRegs.Rcx = regs.Rcx
-	return unix.PtraceSetRegs(threadID, &rawRegs)
+	c.hardwareBreakpoints[slot] = hardwareBreakpoint{addr: addr}
+	return c.applyHardwareBreakpointsToTrappedThreads()
 }
 
-// ReadTLS reads the offset from the beginning of the TLS block.
-func (c *rawClient) ReadTLS(threadID int, offset int32) (uint64, error) {
-	var rawRegs unix.PtraceRegs
-	if err := unix.PtraceGetRegs(threadID, &rawRegs); err != nil {
-		return 0, err
+// SetWatchpoint sets the watchpoint using the debug register specified by the slot.
+// Same as the SetHardwareBreakpoint, it takes effect when each thread is resumed next time.
+func (c *rawClient) SetWatchpoint(slot int, addr uint64, size int, mode WatchMode) error {
+	if !hardwareBreakpointSupported {
+		return ErrNotSupported
+	}
+	if err := checkHardwareBreakpointSlot(slot); err != nil {
+		return err
+	}
+	if err := checkWatchpoint(addr, size, mode); err != nil {
+		return err
 	}
 
-	buff := make([]byte, 8)
-	if err := c.ReadMemory(rawRegs.Fs_base+uint64(offset), buff); err != nil {
-		return 0, err
+	c.hardwareBreakpoints[slot] = hardwareBreakpoint{addr: addr, mode: mode, size: size}
+	return c.applyHardwareBreakpointsToTrappedThreads()
+}
+
+// ClearHardwareBreakpoint clears the hardware breakpoint or watchpoint. Same as the SetHardwareBreakpoint,
+// it takes effect when each thread is resumed next time.
+func (c *rawClient) ClearHardwareBreakpoint(slot int) error {
+	if err := checkHardwareBreakpointSlot(slot); err != nil {
+		return err
 	}
-	return binary.LittleEndian.Uint64(buff), nil
+
+	c.hardwareBreakpoints[slot] = hardwareBreakpoint{}
+	return c.applyHardwareBreakpointsToTrappedThreads()
+}
+
+func (c *rawClient) applyHardwareBreakpointsToTrappedThreads() error {
+	for _, threadID := range c.trappedThreadIDs {
+		if err := c.applyHardwareBreakpoints(threadID); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // ContinueAndWait resumes the list of processes and waits until an event happens.
@@ -304,7 +499,15 @@ func (c *rawClient) ContinueAndWait() (Event, error) {
 
 func (c *rawClient) continueAndWait(sig int) (Event, error) {
 	for _, threadID := range c.trappedThreadIDs {
-		if err := unix.PtraceCont(threadID, sig); err != nil {
+		if err := c.applyHardwareBreakpoints(threadID); err != nil {
+			return Event{}, err
+		}
+		threadSig := sig
+		if pendingSig, ok := c.pendingSignals[threadID]; ok {
+			threadSig = pendingSig
+			delete(c.pendingSignals, threadID)
+		}
+		if err := unix.PtraceCont(threadID, threadSig); err != nil {
 			return Event{}, err
 		}
 	}
@@ -322,6 +525,9 @@ func (c *rawClient) continueAndWait(sig int) (Event, error) {
 // StepAndWait executes the single instruction of the specified process and waits until an event happens.
 // Note that an event happens to any children of the current process is reported.
 func (c *rawClient) StepAndWait(threadID int) (Event, error) {
+	if err := c.applyHardwareBreakpoints(threadID); err != nil {
+		return Event{}, err
+	}
 	if err := unix.PtraceSingleStep(threadID); err != nil {
 		return Event{}, err
 	}
@@ -338,6 +544,20 @@ func (c *rawClient) StepAndWait(threadID int) (Event, error) {
 		return Event{}, err
 	}
 
+	// The signal (e.g. SIGURG for the preemption) may stop the thread before the instruction is executed.
+	// Continuing here skips the single step, so the signal is held until the thread continues and the step is retried.
+	for status.Stopped() && status.StopSignal() != unix.SIGTRAP {
+		if sig := c.filter(int(status.StopSignal())); sig != 0 {
+			c.pendingSignals[threadID] = sig
+		}
+		if err := unix.PtraceSingleStep(threadID); err != nil {
+			return Event{}, err
+		}
+		if waitedThreadID, err = unix.Wait4(threadID, &status, unix.WNOTHREAD, nil); err != nil {
+			return Event{}, err
+		}
+	}
+
 	return c.handleWaitStatus(status, waitedThreadID)
 }
 
@@ -356,7 +576,7 @@ func (c *rawClient) handleWaitStatus(status unix.WaitStatus, threadID int) (even
 
 			event = Event{Type: EventTypeTrapped, Data: []int{threadID}}
 		} else {
-			return c.continueAndWait(int(status.StopSignal()))
+			return c.continueAndWait(c.filter(int(status.StopSignal())))
 		}
 	} else if status.Exited() {
 		event = Event{Type: EventTypeExited, Data: status.ExitStatus()}
@@ -379,6 +599,10 @@ func (c *rawClient) continueClone(parentThreadID int) (int, error) {
 	if _, err := unix.Wait4(int(clonedThreadID), nil, 0, nil); err != nil {
 		return 0, err
 	}
+	// the debug registers are not inherited.
+	if err := c.applyHardwareBreakpoints(int(clonedThreadID)); err != nil {
+		return 0, err
+	}
 	err = unix.PtraceCont(int(clonedThreadID), 0)
 	return int(clonedThreadID), err
 }
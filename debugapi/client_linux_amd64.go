@@ -0,0 +1,204 @@
+package debugapi
+
+import (
+	"encoding/binary"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// hardwareBreakpointSupported is true because the debug registers are available via the user struct.
+const hardwareBreakpointSupported = true
+
+// ReadRegisters reads the registers of the prcoess. The xmm registers are not read.
+func (c *rawClient) ReadRegisters(threadID int) (regs Registers, err error) {
+	var rawRegs unix.PtraceRegs
+	if err = unix.PtraceGetRegs(threadID, &rawRegs); err != nil {
+		return regs, err
+	}
+
+	regs = Registers{
+		Rip: rawRegs.Rip, Rsp: rawRegs.Rsp, Rcx: rawRegs.Rcx, Rax: rawRegs.Rax, Rbx: rawRegs.Rbx, Rdx: rawRegs.Rdx,
+		Rsi: rawRegs.Rsi, Rdi: rawRegs.Rdi, Rbp: rawRegs.Rbp, R8: rawRegs.R8, R9: rawRegs.R9, R10: rawRegs.R10,
+		R11: rawRegs.R11, R12: rawRegs.R12, R13: rawRegs.R13, R14: rawRegs.R14, R15: rawRegs.R15,
+	}
+	return regs, nil
+}
+
+// ReadXMMRegisters reads the xmm registers of the process.
+func (c *rawClient) ReadXMMRegisters(threadID int, regs *Registers) error {
+	var fpRegs [fpRegsSize]byte
+	if err := ptraceFpRegs(unix.PTRACE_GETFPREGS, threadID, &fpRegs); err != nil {
+		return err
+	}
+	for i, xmmReg := range regs.xmmRegisters() {
+		copy(xmmReg[:], fpRegs[xmmSpaceOffset+i*len(xmmReg):])
+	}
+	regs.xmmRead = true
+	return nil
+}
+
+// WriteRegisters change the registers of the prcoess.
+func (c *rawClient) WriteRegisters(threadID int, regs Registers) error {
+	var rawRegs unix.PtraceRegs
+	if err := unix.PtraceGetRegs(threadID, &rawRegs); err != nil {
+		return err
+	}
+
+	rawRegs.Rip, rawRegs.Rsp, rawRegs.Rcx = regs.Rip, regs.Rsp, regs.Rcx
+	rawRegs.Rax, rawRegs.Rbx, rawRegs.Rdx = regs.Rax, regs.Rbx, regs.Rdx
+	rawRegs.Rsi, rawRegs.Rdi, rawRegs.Rbp = regs.Rsi, regs.Rdi, regs.Rbp
+	rawRegs.R8, rawRegs.R9, rawRegs.R10, rawRegs.R11 = regs.R8, regs.R9, regs.R10, regs.R11
+	rawRegs.R12, rawRegs.R13, rawRegs.R14, rawRegs.R15 = regs.R12, regs.R13, regs.R14, regs.R15
+	if err := unix.PtraceSetRegs(threadID, &rawRegs); err != nil {
+		return err
+	}
+	if !regs.xmmRead {
+		return nil
+	}
+
+	var fpRegs [fpRegsSize]byte
+	if err := ptraceFpRegs(unix.PTRACE_GETFPREGS, threadID, &fpRegs); err != nil {
+		return err
+	}
+	for i, xmmReg := range regs.xmmRegisters() {
+		copy(fpRegs[xmmSpaceOffset+i*len(xmmReg):], xmmReg[:])
+	}
+	return ptraceFpRegs(unix.PTRACE_SETFPREGS, threadID, &fpRegs)
+}
+
+const (
+	// fpRegsSize is the size of the user_fpregs_struct (see sys/user.h).
+	fpRegsSize = 512
+	// xmmSpaceOffset is the offset of the xmm_space field in the user_fpregs_struct.
+	xmmSpaceOffset = 160
+)
+
+// ptraceFpRegs gets or sets the floating point registers. The x/sys/unix package doesn't have the wrapper of these requests.
+func ptraceFpRegs(request, threadID int, fpRegs *[fpRegsSize]byte) error {
+	_, _, errno := unix.Syscall6(unix.SYS_PTRACE, uintptr(request), uintptr(threadID), 0, uintptr(unsafe.Pointer(fpRegs)), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+const (
+	// debugRegisterOffset is the offset of the u_debugreg field in the user struct (see sys/user.h).
+	debugRegisterOffset = 848
+	dr6                 = 6 // debug status register
+	dr7                 = 7 // debug control register
+)
+
+// HardwareBreakpointHit returns the slot of the hardware breakpoint or watchpoint which traps the thread.
+// The debug status register is cleared so that the next trap is not confused.
+func (c *rawClient) HardwareBreakpointHit(threadID int) (int, bool, error) {
+	status, err := c.readDebugRegister(threadID, dr6)
+	if err != nil {
+		return 0, false, err
+	}
+	if err := c.writeDebugRegister(threadID, dr6, 0); err != nil {
+		return 0, false, err
+	}
+
+	for slot := 0; slot < MaxHardwareBreakpoints; slot++ {
+		// the status bit may be set even if the slot is not enabled.
+		if status&(1<<uint(slot)) != 0 && c.hardwareBreakpoints[slot].used() {
+			return slot, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// applyHardwareBreakpoints updates the debug registers of the thread if they are out of date. The thread must be stopped.
+func (c *rawClient) applyHardwareBreakpoints(threadID int) error {
+	if c.appliedHardwareBreakpoints[threadID] == c.hardwareBreakpoints {
+		return nil
+	}
+
+	// disable all first so that no breakpoint is enabled with the stale address.
+	if err := c.writeDebugRegister(threadID, dr7, 0); err != nil {
+		return err
+	}
+
+	var control uint64
+	for slot, bp := range c.hardwareBreakpoints {
+		if !bp.used() {
+			continue
+		}
+		if err := c.writeDebugRegister(threadID, slot, bp.addr); err != nil {
+			return err
+		}
+		control |= debugControlBits(slot, bp)
+	}
+	if err := c.writeDebugRegister(threadID, dr7, control); err != nil {
+		return err
+	}
+
+	c.appliedHardwareBreakpoints[threadID] = c.hardwareBreakpoints
+	return nil
+}
+
+// disableHardwareBreakpoints disables all the hardware breakpoints of the thread.
+func (c *rawClient) disableHardwareBreakpoints(threadID int) error {
+	return c.writeDebugRegister(threadID, dr7, 0)
+}
+
+// debugControlBits returns the DR7 bits to enable the slot. See 'Debug Control Register' section in the Intel SDM vol.3.
+func debugControlBits(slot int, bp hardwareBreakpoint) uint64 {
+	// the local enable bit.
+	bits := uint64(1) << uint(2*slot)
+	if bp.mode == 0 {
+		// the condition and length bits are 0, which means the instruction execution.
+		return bits
+	}
+
+	var condition, length uint64
+	switch bp.mode {
+	case WatchWrite:
+		condition = 0x1
+	case WatchRead, WatchReadWrite:
+		// x86-64 doesn't support the read-only condition.
+		condition = 0x3
+	}
+	switch bp.size {
+	case 1:
+		length = 0x0
+	case 2:
+		length = 0x1
+	case 4:
+		length = 0x3
+	case 8:
+		length = 0x2
+	}
+	return bits | condition<<uint(16+4*slot) | length<<uint(18+4*slot)
+}
+
+func (c *rawClient) readDebugRegister(threadID, index int) (uint64, error) {
+	buff := make([]byte, 8)
+	if _, err := unix.PtracePeekUser(threadID, uintptr(debugRegisterOffset+index*8), buff); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(buff), nil
+}
+
+func (c *rawClient) writeDebugRegister(threadID, index int, value uint64) error {
+	buff := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buff, value)
+	_, err := unix.PtracePokeUser(threadID, uintptr(debugRegisterOffset+index*8), buff)
+	return err
+}
+
+// ReadTLS reads the offset from the beginning of the TLS block.
+func (c *rawClient) ReadTLS(threadID int, offset int32) (uint64, error) {
+	var rawRegs unix.PtraceRegs
+	if err := unix.PtraceGetRegs(threadID, &rawRegs); err != nil {
+		return 0, err
+	}
+
+	buff := make([]byte, 8)
+	if err := c.ReadMemory(rawRegs.Fs_base+uint64(offset), buff); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(buff), nil
+}
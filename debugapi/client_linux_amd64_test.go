@@ -0,0 +1,117 @@
+package debugapi
+
+import (
+	"testing"
+
+	"github.com/ks888/tgo/testutils"
+)
+
+func TestWriteRegisters_AllRegisters(t *testing.T) {
+	client := newRawClient()
+	_ = client.LaunchProcess(testutils.ProgramInfloop)
+	defer client.DetachProcess()
+
+	pid := client.tracingThreadIDs[0]
+	regs, _ := client.ReadRegisters(pid)
+	if err := client.ReadXMMRegisters(pid, &regs); err != nil {
+		t.Fatalf("failed to read xmm registers: %v", err)
+	}
+	regs.Rax, regs.R15 = 0x1, 0x2
+	regs.Xmm0[0], regs.Xmm15[15] = 0x3, 0x4
+	if err := client.WriteRegisters(pid, regs); err != nil {
+		t.Fatalf("failed to write registers: %v", err)
+	}
+
+	actual, _ := client.ReadRegisters(pid)
+	_ = client.ReadXMMRegisters(pid, &actual)
+	if actual != regs {
+		t.Errorf("wrong registers: %#v", actual)
+	}
+}
+
+func TestReadTLS(t *testing.T) {
+	client := newRawClient()
+	err := client.LaunchProcess(testutils.ProgramInfloop)
+	if err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	defer client.DetachProcess()
+
+	_ = client.WriteMemory(testutils.InfloopAddrMain, []byte{0xcc})
+	_, _ = client.ContinueAndWait()
+
+	gAddr, err := client.ReadTLS(client.trappedThreadIDs[0], -8)
+	if err != nil {
+		t.Fatalf("failed to read tls: %v", err)
+	}
+	if gAddr == 0 {
+		t.Errorf("empty addr")
+	}
+}
+
+func TestSetHardwareBreakpoint(t *testing.T) {
+	client := newRawClient()
+	_ = client.LaunchProcess(testutils.ProgramInfloop)
+	defer client.DetachProcess()
+
+	if err := client.SetHardwareBreakpoint(1, testutils.InfloopAddrMain); err != nil {
+		t.Fatalf("failed to set hardware breakpoint: %v", err)
+	}
+	event, err := client.ContinueAndWait()
+	if err != nil {
+		t.Fatalf("failed to continue and wait: %v", err)
+	}
+	if event.Type != EventTypeTrapped {
+		t.Fatalf("unexpected event: %#v", event.Type)
+	}
+
+	threadID := event.Data.([]int)[0]
+	regs, _ := client.ReadRegisters(threadID)
+	if regs.Rip != testutils.InfloopAddrMain {
+		t.Errorf("unexpected pc: %#x", regs.Rip)
+	}
+	slot, hit, err := client.HardwareBreakpointHit(threadID)
+	if err != nil {
+		t.Fatalf("failed to check hardware breakpoint: %v", err)
+	}
+	if !hit || slot != 1 {
+		t.Errorf("unexpected hit: %v, %d", hit, slot)
+	}
+	if _, hit, _ := client.HardwareBreakpointHit(threadID); hit {
+		t.Errorf("the status is not cleared")
+	}
+}
+
+func TestSetHardwareBreakpoint_InvalidSlot(t *testing.T) {
+	client := newRawClient()
+	if err := client.SetHardwareBreakpoint(MaxHardwareBreakpoints, testutils.InfloopAddrMain); err == nil {
+		t.Errorf("error not returned")
+	}
+}
+
+func TestSetWatchpoint_InvalidSize(t *testing.T) {
+	client := newRawClient()
+	if err := client.SetWatchpoint(0, 0x1000, 3, WatchWrite); err == nil {
+		t.Errorf("error not returned")
+	}
+	if err := client.SetWatchpoint(0, 0x1001, 8, WatchWrite); err == nil {
+		t.Errorf("error not returned when the address is not aligned")
+	}
+}
+
+func TestDebugControlBits(t *testing.T) {
+	for i, testdata := range []struct {
+		slot     int
+		bp       hardwareBreakpoint
+		expected uint64
+	}{
+		{slot: 0, bp: hardwareBreakpoint{addr: 0x1000}, expected: 0x1},
+		{slot: 1, bp: hardwareBreakpoint{addr: 0x1000, mode: WatchWrite, size: 1}, expected: 0x4 | 0x1<<20},
+		{slot: 2, bp: hardwareBreakpoint{addr: 0x1000, mode: WatchRead, size: 4}, expected: 0x10 | 0x3<<24 | 0x3<<26},
+		{slot: 3, bp: hardwareBreakpoint{addr: 0x1000, mode: WatchReadWrite, size: 8}, expected: 0x40 | 0x3<<28 | 0x2<<30},
+	} {
+		if actual := debugControlBits(testdata.slot, testdata.bp); actual != testdata.expected {
+			t.Errorf("[%d] wrong bits: %#x", i, actual)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package debugapi
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// hardwareBreakpointSupported is false because the hardware breakpoints of arm64 are not implemented yet.
+const hardwareBreakpointSupported = false
+
+// ReadRegisters reads the registers of the prcoess. Rip, Rsp, X30 and X28 hold the pc, sp, lr and x28 respectively.
+func (c *rawClient) ReadRegisters(threadID int) (regs Registers, err error) {
+	var rawRegs unix.PtraceRegs
+	if err = unix.PtraceGetRegs(threadID, &rawRegs); err != nil {
+		return regs, err
+	}
+
+	regs = Registers{Rip: rawRegs.Pc, Rsp: rawRegs.Sp, X30: rawRegs.Regs[30], X28: rawRegs.Regs[28]}
+	return regs, nil
+}
+
+// ReadXMMRegisters returns ErrNotSupported because arm64 has no xmm registers.
+func (c *rawClient) ReadXMMRegisters(threadID int, regs *Registers) error {
+	return ErrNotSupported
+}
+
+// WriteRegisters change the registers of the prcoess.
+func (c *rawClient) WriteRegisters(threadID int, regs Registers) error {
+	var rawRegs unix.PtraceRegs
+	if err := unix.PtraceGetRegs(threadID, &rawRegs); err != nil {
+		return err
+	}
+
+	rawRegs.Pc, rawRegs.Sp, rawRegs.Regs[30], rawRegs.Regs[28] = regs.Rip, regs.Rsp, regs.X30, regs.X28
+	return unix.PtraceSetRegs(threadID, &rawRegs)
+}
+
+// HardwareBreakpointHit always returns false because the hardware breakpoints are not supported.
+func (c *rawClient) HardwareBreakpointHit(threadID int) (int, bool, error) {
+	return 0, false, nil
+}
+
+// applyHardwareBreakpoints does nothing because the hardware breakpoints are not supported.
+func (c *rawClient) applyHardwareBreakpoints(threadID int) error {
+	return nil
+}
+
+// disableHardwareBreakpoints does nothing because the hardware breakpoints are not supported.
+func (c *rawClient) disableHardwareBreakpoints(threadID int) error {
+	return nil
+}
+
+// ReadTLS returns the value of the x28 register regardless of the offset, because the go runtime keeps
+// the current g in the x28 register rather than in the TLS on arm64.
+func (c *rawClient) ReadTLS(threadID int, offset int32) (uint64, error) {
+	regs, err := c.ReadRegisters(threadID)
+	return regs.X28, err
+}
@@ -1,6 +1,7 @@
 package debugapi
 
 import (
+	"errors"
 	"os"
 	"os/exec"
 	"reflect"
@@ -18,8 +19,8 @@ func TestMain(m *testing.M) {
 }
 
 func TestCheckInterface(t *testing.T) {
-	var _ client = newRawClient()
-	var _ client = NewClient()
+	var _ Debugger = newRawClient()
+	var _ Debugger = NewClient()
 }
 
 func TestClientProxy(t *testing.T) {
@@ -154,6 +155,110 @@ func TestReadMemory(t *testing.T) {
 	}
 }
 
+func TestReadMemory_InvalidAddress(t *testing.T) {
+	client := newRawClient()
+	_ = client.LaunchProcess(testutils.ProgramInfloop)
+	defer client.DetachProcess()
+
+	err := client.ReadMemory(0x0, make([]byte, 8))
+	var memoryReadErr MemoryReadError
+	if !errors.As(err, &memoryReadErr) {
+		t.Fatalf("not MemoryReadError: %#v", err)
+	}
+	if memoryReadErr.Addr != 0x0 || memoryReadErr.Size != 8 {
+		t.Errorf("wrong error: %#v", memoryReadErr)
+	}
+}
+
+func TestReadMemoryBatch(t *testing.T) {
+	client := newRawClient()
+	_ = client.LaunchProcess(testutils.ProgramInfloop)
+	defer client.DetachProcess()
+
+	expected := make([]byte, 3)
+	if err := client.ReadMemory(testutils.InfloopAddrMain, expected); err != nil {
+		t.Fatalf("failed to read memory: %v", err)
+	}
+
+	reads := []MemoryRead{
+		{Addr: testutils.InfloopAddrMain + 1, Buf: make([]byte, 2)},
+		{Addr: testutils.InfloopAddrMain, Buf: make([]byte, 1)},
+	}
+	if err := client.ReadMemoryBatch(reads); err != nil {
+		t.Fatalf("failed to read memory: %v", err)
+	}
+
+	if !reflect.DeepEqual(reads[0].Buf, expected[1:]) || !reflect.DeepEqual(reads[1].Buf, expected[:1]) {
+		t.Errorf("Unexpected content: %v", reads)
+	}
+}
+
+func TestMemoryRegions(t *testing.T) {
+	client := newRawClient()
+	_ = client.LaunchProcess(testutils.ProgramInfloop)
+	defer client.DetachProcess()
+
+	regions, err := client.MemoryRegions()
+	if err != nil {
+		t.Fatalf("failed to get memory regions: %v", err)
+	}
+
+	for _, region := range regions {
+		if region.Contains(testutils.InfloopAddrMain) {
+			if !region.Readable || !region.Executable {
+				t.Errorf("wrong permissions: %#v", region)
+			}
+			return
+		}
+	}
+	t.Errorf("no region contains the main function: %v", regions)
+}
+
+func TestMemoryRegionInfo(t *testing.T) {
+	client := newRawClient()
+	_ = client.LaunchProcess(testutils.ProgramInfloop)
+	defer client.DetachProcess()
+
+	region, err := client.MemoryRegionInfo(testutils.InfloopAddrMain)
+	if err != nil {
+		t.Fatalf("failed to get memory region: %v", err)
+	}
+	if !region.Contains(testutils.InfloopAddrMain) || !region.Executable {
+		t.Errorf("wrong region: %#v", region)
+	}
+
+	if _, err := client.MemoryRegionInfo(0); err != ErrNotMapped {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestArchitecture(t *testing.T) {
+	client := newRawClient()
+	if arch := client.Architecture(); arch != "x86_64" && arch != "arm64" {
+		t.Errorf("unexpected architecture: %s", arch)
+	}
+}
+
+func TestParseProcMaps(t *testing.T) {
+	data := `00400000-00452000 r-xp 00000000 08:02 173521      /usr/bin/dbus-daemon
+7ffd3c9a0000-7ffd3c9c1000 rw-p 00000000 00:00 0                          [stack]
+7ffd3c9f0000-7ffd3c9f2000 ---p 00000000 00:00 0
+`
+	regions, err := parseProcMaps(data)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	expected := []MemoryRegion{
+		{Start: 0x400000, End: 0x452000, Readable: true, Executable: true, Name: "/usr/bin/dbus-daemon"},
+		{Start: 0x7ffd3c9a0000, End: 0x7ffd3c9c1000, Readable: true, Writable: true, Name: "[stack]"},
+		{Start: 0x7ffd3c9f0000, End: 0x7ffd3c9f2000},
+	}
+	if !reflect.DeepEqual(regions, expected) {
+		t.Errorf("wrong regions: %#v", regions)
+	}
+}
+
 func TestWriteMemory(t *testing.T) {
 	client := newRawClient()
 	_ = client.LaunchProcess(testutils.ProgramInfloop)
@@ -197,23 +302,17 @@ func TestWriteRegisters(t *testing.T) {
 	}
 }
 
-func TestReadTLS(t *testing.T) {
+func TestThreadIDs(t *testing.T) {
 	client := newRawClient()
-	err := client.LaunchProcess(testutils.ProgramInfloop)
-	if err != nil {
-		t.Fatalf("failed to launch process: %v", err)
-	}
+	_ = client.LaunchProcess(testutils.ProgramInfloop)
 	defer client.DetachProcess()
 
-	_ = client.WriteMemory(testutils.InfloopAddrMain, []byte{0xcc})
-	_, _ = client.ContinueAndWait()
-
-	gAddr, err := client.ReadTLS(client.trappedThreadIDs[0], -8)
+	threadIDs, err := client.ThreadIDs()
 	if err != nil {
-		t.Fatalf("failed to read tls: %v", err)
+		t.Fatalf("failed to get thread ids: %v", err)
 	}
-	if gAddr == 0 {
-		t.Errorf("empty addr")
+	if !reflect.DeepEqual(threadIDs, []int{client.tracingProcessID}) {
+		t.Errorf("wrong thread ids: %v", threadIDs)
 	}
 }
 
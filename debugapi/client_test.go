@@ -0,0 +1,119 @@
+package debugapi
+
+import (
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+func TestCoalesceMemoryReads(t *testing.T) {
+	reads := []MemoryRead{
+		{Addr: 0x1010, Buf: make([]byte, 8)},
+		{Addr: 0x1000, Buf: make([]byte, 8)},
+		{Addr: 0x1004, Buf: make([]byte, 4)},
+		{Addr: 0x2000, Buf: make([]byte, 8)},
+		{Addr: 0x3000, Buf: nil},
+	}
+
+	coalescedReads := coalesceMemoryReads(reads, defaultMaxMemoryReadSize)
+	if len(coalescedReads) != 2 {
+		t.Fatalf("wrong number of reads: %d", len(coalescedReads))
+	}
+	if coalescedReads[0].addr != 0x1000 || coalescedReads[0].size != 0x18 || len(coalescedReads[0].reads) != 3 {
+		t.Errorf("wrong coalesced read: %#v", coalescedReads[0])
+	}
+	if coalescedReads[1].addr != 0x2000 || coalescedReads[1].size != 8 || len(coalescedReads[1].reads) != 1 {
+		t.Errorf("wrong coalesced read: %#v", coalescedReads[1])
+	}
+}
+
+func TestCoalesceMemoryReads_MaxSize(t *testing.T) {
+	reads := []MemoryRead{
+		{Addr: 0x1000, Buf: make([]byte, 8)},
+		{Addr: 0x1008, Buf: make([]byte, 8)},
+		{Addr: 0x1004, Buf: make([]byte, 4)},
+		{Addr: 0x1010, Buf: make([]byte, 8)},
+		{Addr: 0x1018, Buf: make([]byte, 0x20)},
+	}
+
+	coalescedReads := coalesceMemoryReads(reads, 0x10)
+	if len(coalescedReads) != 3 {
+		t.Fatalf("wrong number of reads: %d", len(coalescedReads))
+	}
+	if coalescedReads[0].addr != 0x1000 || coalescedReads[0].size != 0x10 || len(coalescedReads[0].reads) != 3 {
+		t.Errorf("wrong coalesced read: %#v", coalescedReads[0])
+	}
+	if coalescedReads[1].addr != 0x1010 || coalescedReads[1].size != 8 || len(coalescedReads[1].reads) != 1 {
+		t.Errorf("wrong coalesced read: %#v", coalescedReads[1])
+	}
+	if coalescedReads[2].addr != 0x1018 || coalescedReads[2].size != 0x20 || len(coalescedReads[2].reads) != 1 {
+		t.Errorf("wrong coalesced read: %#v", coalescedReads[2])
+	}
+}
+
+func TestReadMemoryBatch_CopyToEachBuffer(t *testing.T) {
+	numCalls := 0
+	readMemory := func(addr uint64, out []byte) error {
+		numCalls++
+		for i := range out {
+			out[i] = byte(addr) + byte(i)
+		}
+		return nil
+	}
+
+	reads := []MemoryRead{{Addr: 0x12, Buf: make([]byte, 2)}, {Addr: 0x10, Buf: make([]byte, 3)}}
+	if err := readMemoryBatch(readMemory, reads, defaultMaxMemoryReadSize); err != nil {
+		t.Fatalf("failed to read memory: %v", err)
+	}
+
+	if numCalls != 1 {
+		t.Errorf("wrong number of calls: %d", numCalls)
+	}
+	if !reflect.DeepEqual(reads[0].Buf, []byte{0x12, 0x13}) || !reflect.DeepEqual(reads[1].Buf, []byte{0x10, 0x11, 0x12}) {
+		t.Errorf("wrong buffer: %v", reads)
+	}
+}
+
+func TestSignalFilter(t *testing.T) {
+	var filter signalFilter
+	if filter.filter(int(syscall.SIGUSR1)) != int(syscall.SIGUSR1) {
+		t.Errorf("signal is suppressed by default")
+	}
+
+	filter.setForwarding(syscall.SIGUSR1, false)
+	if filter.filter(int(syscall.SIGUSR1)) != 0 {
+		t.Errorf("signal is not suppressed")
+	}
+	if filter.filter(int(syscall.SIGUSR2)) != int(syscall.SIGUSR2) {
+		t.Errorf("unrelated signal is suppressed")
+	}
+
+	filter.setForwarding(syscall.SIGUSR1, true)
+	if filter.filter(int(syscall.SIGUSR1)) != int(syscall.SIGUSR1) {
+		t.Errorf("signal is not forwarded")
+	}
+}
+
+func TestRegisterByName(t *testing.T) {
+	regs := Registers{Rip: 1, Rsp: 2, X30: 3, X28: 4}
+	for _, testdata := range []struct {
+		name     string
+		expected uint64
+	}{
+		{name: "rip", expected: 1},
+		{name: "pc", expected: 1},
+		{name: "sp", expected: 2},
+		{name: "lr", expected: 3},
+		{name: "x30", expected: 3},
+		{name: "x28", expected: 4},
+	} {
+		reg := regs.registerByName(testdata.name)
+		if reg == nil || *reg != testdata.expected {
+			t.Errorf("wrong register for %s: %v", testdata.name, reg)
+		}
+	}
+
+	if regs.registerByName("cpsr") != nil {
+		t.Errorf("unknown register is found")
+	}
+}
@@ -0,0 +1,302 @@
+// Package mock provides the mock debug api client. It allows the code which depends on the debug api, such as the tracer,
+// to be tested without the tracee process.
+//
+// The expected calls are scripted in advance:
+//
+//	client := mock.NewClient()
+//	client.Expect(mock.ContinueAndWait).Return(debugapi.Event{Type: debugapi.EventTypeTrapped, Data: []int{1}}, nil)
+//	client.Expect(mock.ContinueAndWait).Return(debugapi.Event{Type: debugapi.EventTypeExited, Data: 0}, nil)
+package mock
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"syscall"
+
+	"github.com/ks888/tgo/debugapi"
+)
+
+// Method identifies the method of the debugapi.Debugger interface.
+type Method string
+
+// The methods of the debugapi.Debugger interface.
+const (
+	LaunchProcess           Method = "LaunchProcess"
+	AttachProcess           Method = "AttachProcess"
+	DetachProcess           Method = "DetachProcess"
+	ReadMemory              Method = "ReadMemory"
+	ReadMemoryBatch         Method = "ReadMemoryBatch"
+	WriteMemory             Method = "WriteMemory"
+	ThreadIDs               Method = "ThreadIDs"
+	ReadRegisters           Method = "ReadRegisters"
+	ReadXMMRegisters        Method = "ReadXMMRegisters"
+	WriteRegisters          Method = "WriteRegisters"
+	ReadTLS                 Method = "ReadTLS"
+	ContinueAndWait         Method = "ContinueAndWait"
+	StepAndWait             Method = "StepAndWait"
+	SetSignalForwarding     Method = "SetSignalForwarding"
+	MemoryRegions           Method = "MemoryRegions"
+	Architecture            Method = "Architecture"
+	MemoryRegionInfo        Method = "MemoryRegionInfo"
+	SetHardwareBreakpoint   Method = "SetHardwareBreakpoint"
+	ClearHardwareBreakpoint Method = "ClearHardwareBreakpoint"
+	SetWatchpoint           Method = "SetWatchpoint"
+	HardwareBreakpointHit   Method = "HardwareBreakpointHit"
+)
+
+// ErrUnexpectedCall is returned when the method is called but no call is expected.
+var ErrUnexpectedCall = errors.New("unexpected call")
+
+// Call is the expected call of the method.
+type Call struct {
+	method  Method
+	returns []interface{}
+	run     func(args ...interface{})
+}
+
+// Return sets the values the call returns. The values must be in the same order and types as the method's results.
+// Nil can be used for the zero value. The zero values are returned if not set.
+func (c *Call) Return(values ...interface{}) *Call {
+	c.returns = values
+	return c
+}
+
+// Run sets the function which is called with the arguments of the call. For example, it can fill the buffer passed to ReadMemory.
+func (c *Call) Run(fn func(args ...interface{})) *Call {
+	c.run = fn
+	return c
+}
+
+func (c *Call) value(index int) interface{} {
+	if index >= len(c.returns) {
+		return nil
+	}
+	return c.returns[index]
+}
+
+func (c *Call) errorValue(index int) error {
+	v := c.value(index)
+	if v == nil {
+		return nil
+	}
+	err, ok := v.(error)
+	if !ok {
+		panic(fmt.Sprintf("%s: the return value %d is not error: %#v", c.method, index, v))
+	}
+	return err
+}
+
+func (c *Call) intValue(index int) int {
+	v, _ := c.value(index).(int)
+	return v
+}
+
+func (c *Call) uint64Value(index int) uint64 {
+	v, _ := c.value(index).(uint64)
+	return v
+}
+
+func (c *Call) boolValue(index int) bool {
+	v, _ := c.value(index).(bool)
+	return v
+}
+
+// Client is the mock of debugapi.Debugger. The expected calls of each method are consumed in the order they are added.
+// When no expected call remains, the method returns the zero values and ErrUnexpectedCall if it returns the error.
+type Client struct {
+	mu       sync.Mutex
+	expected map[Method][]*Call
+	calls    map[Method]int
+}
+
+// NewClient returns the new mock client which expects no calls.
+func NewClient() *Client {
+	return &Client{expected: make(map[Method][]*Call), calls: make(map[Method]int)}
+}
+
+// Expect adds the expected call of the method. Use the returned Call to specify the return values.
+func (c *Client) Expect(method Method) *Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	call := &Call{method: method}
+	c.expected[method] = append(c.expected[method], call)
+	return call
+}
+
+// Calls returns the number of times the method is called, including the unexpected calls.
+func (c *Client) Calls(method Method) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.calls[method]
+}
+
+// Remaining returns the number of the expected calls of the method which are not called yet.
+func (c *Client) Remaining(method Method) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.expected[method])
+}
+
+// call consumes the next expected call of the method and runs it. Returns false if no call is expected.
+func (c *Client) call(method Method, args ...interface{}) (*Call, bool) {
+	c.mu.Lock()
+	c.calls[method]++
+	calls := c.expected[method]
+	if len(calls) == 0 {
+		c.mu.Unlock()
+		return &Call{method: method}, false
+	}
+	call := calls[0]
+	c.expected[method] = calls[1:]
+	c.mu.Unlock()
+
+	if call.run != nil {
+		call.run(args...)
+	}
+	return call, true
+}
+
+// callWithError is same as call, but the error is ErrUnexpectedCall if no call is expected.
+func (c *Client) callWithError(method Method, errIndex int, args ...interface{}) (*Call, error) {
+	call, ok := c.call(method, args...)
+	if !ok {
+		return call, fmt.Errorf("%s: %w", method, ErrUnexpectedCall)
+	}
+	return call, call.errorValue(errIndex)
+}
+
+// LaunchProcess returns the error of the expected call.
+func (c *Client) LaunchProcess(name string, arg ...string) error {
+	_, err := c.callWithError(LaunchProcess, 0, name, arg)
+	return err
+}
+
+// AttachProcess returns the error of the expected call.
+func (c *Client) AttachProcess(pid int) error {
+	_, err := c.callWithError(AttachProcess, 0, pid)
+	return err
+}
+
+// DetachProcess returns the error of the expected call.
+func (c *Client) DetachProcess() error {
+	_, err := c.callWithError(DetachProcess, 0)
+	return err
+}
+
+// ReadMemory returns the error of the expected call. Use Run to fill `out`.
+func (c *Client) ReadMemory(addr uint64, out []byte) error {
+	_, err := c.callWithError(ReadMemory, 0, addr, out)
+	return err
+}
+
+// ReadMemoryBatch returns the error of the expected call. Use Run to fill the buffers.
+func (c *Client) ReadMemoryBatch(reads []debugapi.MemoryRead) error {
+	_, err := c.callWithError(ReadMemoryBatch, 0, reads)
+	return err
+}
+
+// WriteMemory returns the error of the expected call.
+func (c *Client) WriteMemory(addr uint64, data []byte) error {
+	_, err := c.callWithError(WriteMemory, 0, addr, data)
+	return err
+}
+
+// ThreadIDs returns the thread ids and error of the expected call.
+func (c *Client) ThreadIDs() ([]int, error) {
+	call, err := c.callWithError(ThreadIDs, 1)
+	threadIDs, _ := call.value(0).([]int)
+	return threadIDs, err
+}
+
+// ReadRegisters returns the registers and error of the expected call.
+func (c *Client) ReadRegisters(threadID int) (debugapi.Registers, error) {
+	call, err := c.callWithError(ReadRegisters, 1, threadID)
+	regs, _ := call.value(0).(debugapi.Registers)
+	return regs, err
+}
+
+// ReadXMMRegisters returns the error of the expected call. The registers are not changed.
+func (c *Client) ReadXMMRegisters(threadID int, regs *debugapi.Registers) error {
+	_, err := c.callWithError(ReadXMMRegisters, 0, threadID, regs)
+	return err
+}
+
+// WriteRegisters returns the error of the expected call.
+func (c *Client) WriteRegisters(threadID int, regs debugapi.Registers) error {
+	_, err := c.callWithError(WriteRegisters, 0, threadID, regs)
+	return err
+}
+
+// ReadTLS returns the value and error of the expected call.
+func (c *Client) ReadTLS(threadID int, offset int32) (uint64, error) {
+	call, err := c.callWithError(ReadTLS, 1, threadID, offset)
+	return call.uint64Value(0), err
+}
+
+// ContinueAndWait returns the event and error of the expected call.
+func (c *Client) ContinueAndWait() (debugapi.Event, error) {
+	call, err := c.callWithError(ContinueAndWait, 1)
+	event, _ := call.value(0).(debugapi.Event)
+	return event, err
+}
+
+// StepAndWait returns the event and error of the expected call.
+func (c *Client) StepAndWait(threadID int) (debugapi.Event, error) {
+	call, err := c.callWithError(StepAndWait, 1, threadID)
+	event, _ := call.value(0).(debugapi.Event)
+	return event, err
+}
+
+// SetSignalForwarding records the call. It's not an error to call it without the expected call.
+func (c *Client) SetSignalForwarding(sig syscall.Signal, forward bool) {
+	c.call(SetSignalForwarding, sig, forward)
+}
+
+// MemoryRegions returns the memory regions and error of the expected call.
+func (c *Client) MemoryRegions() ([]debugapi.MemoryRegion, error) {
+	call, err := c.callWithError(MemoryRegions, 1)
+	regions, _ := call.value(0).([]debugapi.MemoryRegion)
+	return regions, err
+}
+
+// Architecture returns the architecture of the expected call. It's empty if no call is expected.
+func (c *Client) Architecture() string {
+	call, _ := c.call(Architecture)
+	arch, _ := call.value(0).(string)
+	return arch
+}
+
+// MemoryRegionInfo returns the memory region and error of the expected call.
+func (c *Client) MemoryRegionInfo(addr uint64) (debugapi.MemoryRegion, error) {
+	call, err := c.callWithError(MemoryRegionInfo, 1, addr)
+	region, _ := call.value(0).(debugapi.MemoryRegion)
+	return region, err
+}
+
+// SetHardwareBreakpoint returns the error of the expected call.
+func (c *Client) SetHardwareBreakpoint(slot int, addr uint64) error {
+	_, err := c.callWithError(SetHardwareBreakpoint, 0, slot, addr)
+	return err
+}
+
+// ClearHardwareBreakpoint returns the error of the expected call.
+func (c *Client) ClearHardwareBreakpoint(slot int) error {
+	_, err := c.callWithError(ClearHardwareBreakpoint, 0, slot)
+	return err
+}
+
+// SetWatchpoint returns the error of the expected call.
+func (c *Client) SetWatchpoint(slot int, addr uint64, size int, mode debugapi.WatchMode) error {
+	_, err := c.callWithError(SetWatchpoint, 0, slot, addr, size, mode)
+	return err
+}
+
+// HardwareBreakpointHit returns the slot, hit flag and error of the expected call.
+func (c *Client) HardwareBreakpointHit(threadID int) (slot int, hit bool, err error) {
+	call, err := c.callWithError(HardwareBreakpointHit, 2, threadID)
+	return call.intValue(0), call.boolValue(1), err
+}
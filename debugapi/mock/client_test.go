@@ -0,0 +1,77 @@
+package mock
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ks888/tgo/debugapi"
+)
+
+func TestCheckInterface(t *testing.T) {
+	var _ debugapi.Debugger = NewClient()
+}
+
+func TestExpect(t *testing.T) {
+	client := NewClient()
+	client.Expect(ContinueAndWait).Return(debugapi.Event{Type: debugapi.EventTypeTrapped, Data: []int{1}}, nil)
+	client.Expect(ContinueAndWait).Return(debugapi.Event{Type: debugapi.EventTypeExited, Data: 0}, nil)
+
+	event, err := client.ContinueAndWait()
+	if err != nil {
+		t.Fatalf("failed to continue: %v", err)
+	}
+	if event.Type != debugapi.EventTypeTrapped || event.Data.([]int)[0] != 1 {
+		t.Errorf("wrong event: %v", event)
+	}
+
+	event, err = client.ContinueAndWait()
+	if err != nil {
+		t.Fatalf("failed to continue: %v", err)
+	}
+	if event.Type != debugapi.EventTypeExited {
+		t.Errorf("wrong event: %v", event)
+	}
+
+	if client.Calls(ContinueAndWait) != 2 || client.Remaining(ContinueAndWait) != 0 {
+		t.Errorf("wrong number of calls: %d, %d", client.Calls(ContinueAndWait), client.Remaining(ContinueAndWait))
+	}
+}
+
+func TestExpect_Error(t *testing.T) {
+	client := NewClient()
+	expectedErr := errors.New("test")
+	client.Expect(HardwareBreakpointHit).Return(0, false, expectedErr)
+
+	if _, _, err := client.HardwareBreakpointHit(1); err != expectedErr {
+		t.Errorf("wrong error: %v", err)
+	}
+}
+
+func TestExpect_Run(t *testing.T) {
+	client := NewClient()
+	client.Expect(ReadMemory).Run(func(args ...interface{}) {
+		copy(args[1].([]byte), []byte{0xcc})
+	})
+
+	buff := make([]byte, 1)
+	if err := client.ReadMemory(0x1000, buff); err != nil {
+		t.Fatalf("failed to read memory: %v", err)
+	}
+	if buff[0] != 0xcc {
+		t.Errorf("wrong data: %v", buff)
+	}
+}
+
+func TestUnexpectedCall(t *testing.T) {
+	client := NewClient()
+
+	if err := client.DetachProcess(); !errors.Is(err, ErrUnexpectedCall) {
+		t.Errorf("wrong error: %v", err)
+	}
+	if arch := client.Architecture(); arch != "" {
+		t.Errorf("wrong architecture: %s", arch)
+	}
+	if client.Calls(DetachProcess) != 1 {
+		t.Errorf("wrong number of calls: %d", client.Calls(DetachProcess))
+	}
+}
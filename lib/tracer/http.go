@@ -0,0 +1,143 @@
+package tracer
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// minStreamInterval is the minimum interval between the streams. Each stream starts the new tracer server.
+const minStreamInterval = time.Second
+
+// NewHTTPHandler returns the handler which streams the tracing log as Server-Sent Events.
+// The handler accepts the GET request with the query parameters below:
+//
+//	func:  the function to start tracing, like 'main.f'. Required.
+//	depth: the trace level. The default is 1.
+//
+// Each line of the tracing log is sent as one 'data:' message. The tracer is terminated when the client disconnects.
+// Only one stream is served at a time, because this process can't be traced by multiple tracers.
+func NewHTTPHandler() http.Handler {
+	return &httpHandler{}
+}
+
+type httpHandler struct {
+	mtx       sync.Mutex
+	streaming bool
+	lastStart time.Time
+}
+
+func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	funcName := r.URL.Query().Get("func")
+	if funcName == "" {
+		http.Error(w, "func parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	depth := 1
+	if rawDepth := r.URL.Query().Get("depth"); rawDepth != "" {
+		var err error
+		depth, err = strconv.Atoi(rawDepth)
+		if err != nil || depth <= 0 {
+			http.Error(w, "depth parameter must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	if !h.acquire() {
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return
+	}
+	defer h.release()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	sw := &sseWriter{w: w, flusher: flusher}
+	s := NewSession()
+	s.SetTraceLevel(depth)
+	s.SetWriter(sw)
+	s.SetErrorWriter(defaultSession.errorWriter)
+	if err := s.StartFunction(funcName); err != nil {
+		sw.writeEvent("error", err.Error())
+		return
+	}
+
+	<-r.Context().Done()
+	if err := s.Terminate(); err != nil {
+		fmt.Fprintf(defaultSession.errorWriter, "failed to terminate tracer: %v\n", err)
+	}
+}
+
+// acquire returns true if the new stream can start.
+func (h *httpHandler) acquire() bool {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if h.streaming || time.Since(h.lastStart) < minStreamInterval {
+		return false
+	}
+	h.streaming = true
+	h.lastStart = time.Now()
+	return true
+}
+
+func (h *httpHandler) release() {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.streaming = false
+}
+
+// sseWriter writes each line as the Server-Sent Events' data message.
+type sseWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	// partialLine holds the data not terminated by the new line yet.
+	partialLine []byte
+	mtx         sync.Mutex
+}
+
+func (s *sseWriter) Write(p []byte) (int, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.partialLine = append(s.partialLine, p...)
+	for {
+		i := bytes.IndexByte(s.partialLine, '\n')
+		if i < 0 {
+			break
+		}
+		if _, err := fmt.Fprintf(s.w, "data: %s\n\n", s.partialLine[:i]); err != nil {
+			return 0, err
+		}
+		s.partialLine = s.partialLine[i+1:]
+	}
+	s.flusher.Flush()
+	return len(p), nil
+}
+
+func (s *sseWriter) writeEvent(event, data string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, data)
+	s.flusher.Flush()
+}
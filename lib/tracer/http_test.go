@@ -0,0 +1,55 @@
+package tracer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPHandler_InvalidRequest(t *testing.T) {
+	for i, testdata := range []struct {
+		method, url    string
+		expectedStatus int
+	}{
+		{method: http.MethodPost, url: "/?func=main.f", expectedStatus: http.StatusMethodNotAllowed},
+		{method: http.MethodGet, url: "/", expectedStatus: http.StatusBadRequest},
+		{method: http.MethodGet, url: "/?func=main.f&depth=0", expectedStatus: http.StatusBadRequest},
+		{method: http.MethodGet, url: "/?func=main.f&depth=a", expectedStatus: http.StatusBadRequest},
+	} {
+		rec := httptest.NewRecorder()
+		NewHTTPHandler().ServeHTTP(rec, httptest.NewRequest(testdata.method, testdata.url, nil))
+		if rec.Code != testdata.expectedStatus {
+			t.Errorf("[%d] wrong status code: %d", i, rec.Code)
+		}
+	}
+}
+
+func TestHTTPHandler_RateLimit(t *testing.T) {
+	handler := NewHTTPHandler().(*httpHandler)
+	if !handler.acquire() {
+		t.Fatalf("failed to acquire")
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?func=main.f", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("wrong status code: %d", rec.Code)
+	}
+
+	handler.release()
+	if handler.acquire() {
+		t.Errorf("acquired within the minimum interval")
+	}
+}
+
+func TestSSEWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writer := &sseWriter{w: rec, flusher: rec}
+	_, _ = writer.Write([]byte("\\ (#01) main.f()\n/ (#0"))
+	_, _ = writer.Write([]byte("1) main.f()\n"))
+
+	expected := "data: \\ (#01) main.f()\n\ndata: / (#01) main.f()\n\n"
+	if rec.Body.String() != expected {
+		t.Errorf("unexpected body: %q", rec.Body.String())
+	}
+}
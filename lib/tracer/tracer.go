@@ -3,6 +3,9 @@
 package tracer
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -19,86 +22,348 @@ import (
 	"github.com/ks888/tgo/service"
 )
 
-const expectedVersion = 1
+const expectedVersion = 3
 
 var (
-	client            *rpc.Client
-	serverCmd         *exec.Cmd
-	tracerProgramName           = "tgo"
-	traceLevel                  = 1
-	parseLevel                  = 1
-	verbose                     = false
-	writer            io.Writer = os.Stdout
-	errorWriter       io.Writer = os.Stderr
-	// Protects the server command and its rpc client
-	serverMtx sync.Mutex
+	tracerProgramName = "tgo"
+	// defaultSession is the session the package-level functions operate on.
+	defaultSession = NewSession()
 )
 
 //go:linkname firstModuleData runtime.firstmoduledata
 var firstModuleData interface{}
 
+// Session is the tracing session which has its own tracer server.
+// Note that only one session can trace this process at a time, because the process can't be traced by multiple tracers.
+type Session struct {
+	client     *rpc.Client
+	serverCmd  *exec.Cmd
+	traceLevel int
+	parseLevel int
+	sampleRate float64
+	// outputFormat is either "text", "json" or "chrome". Empty means the server's default.
+	outputFormat        string
+	printSourceLocation bool
+	showLocals          bool
+	verbose             bool
+	writer              io.Writer
+	errorWriter         io.Writer
+	// captured holds the tracing log if the capture option is enabled. Nil otherwise.
+	captured *syncBuffer
+	// Protects the server command and its rpc client
+	serverMtx sync.Mutex
+	// serverTerminated is closed when the server is terminated. Nil if no one waits for the termination.
+	serverTerminated chan struct{}
+}
+
+// NewSession returns the new session. The server is not started until Start is called.
+func NewSession() *Session {
+	return &Session{traceLevel: 1, parseLevel: 1, sampleRate: 1, writer: os.Stdout, errorWriter: os.Stderr}
+}
+
 // SetTraceLevel sets the trace level. Functions are traced if the stack depth is within this trace level. The stack depth here is based on the point tracing is enabled. The default is 1.
 func SetTraceLevel(option int) {
-	traceLevel = option
+	defaultSession.SetTraceLevel(option)
 }
 
 // SetParseLevel sets the parse level. The trace log includes the function's args. The parselevel option determines how detailed these values should be. The default is 1.
 func SetParseLevel(option int) {
-	parseLevel = option
+	defaultSession.SetParseLevel(option)
+}
+
+// SetSampleRate sets the fraction of the function calls to be traced, from 0.0 to 1.0. Sampling reduces the overhead when
+// the function is called very frequently. The sampling decision is made per call. The default is 1.0 and 0 is regarded as 1.0.
+func SetSampleRate(option float64) {
+	defaultSession.SetSampleRate(option)
+}
+
+// SetOutputFormat sets the format of the tracing log, either "text", "json" or "chrome". In the json format, each traced call is written as one JSON object per line.
+// In the chrome format, the traced calls are written as the JSON array of the Chrome Trace Event format. The default is "text".
+func SetOutputFormat(option string) {
+	defaultSession.SetOutputFormat(option)
+}
+
+// SetPrintSourceLocation sets whether to print the source location (e.g. [main.go:10]) of the traced functions. The default is false.
+func SetPrintSourceLocation(option bool) {
+	defaultSession.SetPrintSourceLocation(option)
+}
+
+// SetShowLocals sets whether to print the local variables of the caller in scope at the call of the traced functions. The default is false.
+func SetShowLocals(option bool) {
+	defaultSession.SetShowLocals(option)
 }
 
 // SetVerboseOption sets the verbose option. It true, the debug-level messages are written as well as the normal tracing log. The default is false.
 func SetVerboseOption(option bool) {
-	verbose = option
+	defaultSession.SetVerboseOption(option)
 }
 
 // SetWriter sets the writer for the tracing log. The default is os.Stdout.
 func SetWriter(option io.Writer) {
-	writer = option
+	defaultSession.SetWriter(option)
 }
 
 // SetErrorWriter sets the writer for the error log. The default is os.Stderrr.
 func SetErrorWriter(option io.Writer) {
-	errorWriter = option
+	defaultSession.SetErrorWriter(option)
+}
+
+// SetTraceLevel sets the trace level of the session. See the package-level SetTraceLevel for the details.
+func (s *Session) SetTraceLevel(option int) {
+	s.traceLevel = option
+}
+
+// SetParseLevel sets the parse level of the session. See the package-level SetParseLevel for the details.
+func (s *Session) SetParseLevel(option int) {
+	s.parseLevel = option
+}
+
+// SetSampleRate sets the sample rate of the session. See the package-level SetSampleRate for the details.
+func (s *Session) SetSampleRate(option float64) {
+	s.sampleRate = option
+}
+
+// SetOutputFormat sets the format of the tracing log of the session.
+func (s *Session) SetOutputFormat(option string) {
+	s.outputFormat = option
+}
+
+// SetPrintSourceLocation sets whether to print the source location of the traced functions in the session.
+func (s *Session) SetPrintSourceLocation(option bool) {
+	s.printSourceLocation = option
+}
+
+// SetShowLocals sets whether to print the local variables of the caller of the traced functions in the session.
+func (s *Session) SetShowLocals(option bool) {
+	s.showLocals = option
+}
+
+// SetVerboseOption sets the verbose option of the session.
+func (s *Session) SetVerboseOption(option bool) {
+	s.verbose = option
+}
+
+// SetWriter sets the writer for the tracing log of the session.
+func (s *Session) SetWriter(option io.Writer) {
+	s.writer = option
+}
+
+// SetErrorWriter sets the writer for the error log of the session.
+func (s *Session) SetErrorWriter(option io.Writer) {
+	s.errorWriter = option
+}
+
+// SetCaptureOutput sets the capture option of the session. If true, the tracing log is kept in memory
+// instead of written to the writer, and can be read by CapturedOutput. It's useful to check the log in tests.
+func (s *Session) SetCaptureOutput(option bool) {
+	if option {
+		s.captured = &syncBuffer{}
+	} else {
+		s.captured = nil
+	}
+}
+
+// CapturedOutput returns the tracing log captured so far. It's empty if the capture option is not enabled.
+// Call it after Terminate to get the complete log.
+func (s *Session) CapturedOutput() string {
+	if s.captured == nil {
+		return ""
+	}
+	return s.captured.String()
+}
+
+// syncBuffer is the bytes.Buffer which can be written and read concurrently.
+type syncBuffer struct {
+	buff bytes.Buffer
+	mtx  sync.Mutex
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.buff.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.buff.String()
 }
 
 // Start enables tracing.
 func Start() error {
-	serverMtx.Lock()
-	defer serverMtx.Unlock()
+	pcs := make([]uintptr, 2)
+	_ = runtime.Callers(2, pcs)
+	return defaultSession.start(pcs[0], pcs[1])
+}
 
+// Start enables tracing in this session.
+func (s *Session) Start() error {
 	pcs := make([]uintptr, 2)
 	_ = runtime.Callers(2, pcs)
-	startTracePoint, endTracePoint := pcs[0], pcs[1]
+	return s.start(pcs[0], pcs[1])
+}
+
+func (s *Session) start(startTracePoint, endTracePoint uintptr) error {
+	s.serverMtx.Lock()
+	defer s.serverMtx.Unlock()
 
-	if serverCmd == nil {
-		err := initialize(startTracePoint, endTracePoint)
+	if s.serverCmd == nil {
+		err := s.initialize(startTracePoint, "", endTracePoint)
 		if err != nil {
-			_ = terminateServer()
-			return fmt.Errorf("failed to start tracer: %v", err)
+			_ = s.terminate() // the server may be attached already.
+			return fmt.Errorf("failed to start tracer: %w", err)
 		}
 		return nil
 	}
 
 	reply := &struct{}{} // sometimes the nil reply value causes panic even if the reply is not written.
-	if err := client.Call("Tracer.AddStartTracePoint", startTracePoint, reply); err != nil {
+	if err := s.client.Call("Tracer.AddStartTracePoint", startTracePoint, reply); err != nil {
 		return err
 	}
-	return client.Call("Tracer.AddEndTracePoint", endTracePoint, reply)
+	return s.client.Call("Tracer.AddEndTracePoint", endTracePoint, reply)
 }
 
-func initialize(startTracePoint, endTracePoint uintptr) error {
-	addr, err := startServer()
+// StartFunction enables tracing in this session when any go routine calls the specified function, like 'main.f'.
+func (s *Session) StartFunction(funcName string) error {
+	s.serverMtx.Lock()
+	defer s.serverMtx.Unlock()
+
+	if s.serverCmd == nil {
+		err := s.initialize(0, funcName, 0)
+		if err != nil {
+			_ = s.terminate() // the server may be attached already.
+			return fmt.Errorf("failed to start tracer: %w", err)
+		}
+		return nil
+	}
+
+	reply := &struct{}{}
+	return s.client.Call("Tracer.AddStartTracePointByName", funcName, reply)
+}
+
+// StartWithContext enables tracing same as Start. In addition, the tracer is terminated when the context is done.
+// It's useful when the tracing should be scoped to some lifetime, like the request's one.
+func StartWithContext(ctx context.Context) error {
+	pcs := make([]uintptr, 2)
+	_ = runtime.Callers(2, pcs)
+	return defaultSession.startWithContext(ctx, pcs[0], pcs[1])
+}
+
+// StartWithContext enables tracing in this session same as Start, and terminates the session's tracer when the context is done.
+func (s *Session) StartWithContext(ctx context.Context) error {
+	pcs := make([]uintptr, 2)
+	_ = runtime.Callers(2, pcs)
+	return s.startWithContext(ctx, pcs[0], pcs[1])
+}
+
+func (s *Session) startWithContext(ctx context.Context, startTracePoint, endTracePoint uintptr) error {
+	if err := s.start(startTracePoint, endTracePoint); err != nil {
+		return err
+	}
+
+	s.serverMtx.Lock()
+	defer s.serverMtx.Unlock()
+	s.terminateOnDone(ctx)
+	return nil
+}
+
+// terminateOnDone starts the go routine which terminates the tracer when the context is done.
+// The go routine exits without doing anything if the tracer is terminated before that.
+func (s *Session) terminateOnDone(ctx context.Context) {
+	if s.serverTerminated == nil {
+		s.serverTerminated = make(chan struct{})
+	}
+
+	go func(terminated chan struct{}) {
+		select {
+		case <-ctx.Done():
+			if err := s.Terminate(); err != nil {
+				fmt.Fprintf(s.errorWriter, "failed to terminate tracer: %v\n", err)
+			}
+		case <-terminated:
+		}
+	}(s.serverTerminated)
+}
+
+// Pause pauses printing the tracing log. The tracing itself continues, so call Resume to print the log again.
+// It does nothing if the tracer is not started.
+func Pause() error {
+	return defaultSession.Pause()
+}
+
+// Pause pauses printing the tracing log of this session.
+func (s *Session) Pause() error {
+	return s.call("Tracer.Pause")
+}
+
+// Resume resumes printing the tracing log paused by Pause.
+// It does nothing if the tracer is not started.
+func Resume() error {
+	return defaultSession.Resume()
+}
+
+// Resume resumes printing the tracing log of this session.
+func (s *Session) Resume() error {
+	return s.call("Tracer.Resume")
+}
+
+// call calls the service method which takes no args. It does nothing if the tracer is not started.
+func (s *Session) call(serviceMethod string) error {
+	s.serverMtx.Lock()
+	defer s.serverMtx.Unlock()
+
+	if s.serverCmd == nil || s.client == nil {
+		return nil
+	}
+
+	reply := &struct{}{}
+	return s.client.Call(serviceMethod, struct{}{}, reply)
+}
+
+// Terminate lets the tracer detach from this process and terminates the tracer.
+// It does nothing if the tracer is not started or already terminated.
+func Terminate() error {
+	return defaultSession.Terminate()
+}
+
+// Terminate lets the session's tracer detach from this process and terminates the tracer.
+// It does nothing if the tracer is not started or already terminated.
+func (s *Session) Terminate() error {
+	s.serverMtx.Lock()
+	defer s.serverMtx.Unlock()
+
+	return s.terminate()
+}
+
+// terminate lets the tracer detach from this process if attached, and terminates the tracer.
+func (s *Session) terminate() error {
+	if s.serverCmd == nil {
+		return nil
+	}
+
+	if s.client != nil {
+		reply := &struct{}{}
+		if err := s.client.Call("Tracer.Detach", struct{}{}, reply); err != nil {
+			fmt.Fprintf(s.errorWriter, "failed to detach: %v\n", err)
+		}
+	}
+	return s.terminateServer()
+}
+
+// initialize starts the server and attaches to this process. The startFunction is used if the startTracePoint is 0.
+func (s *Session) initialize(startTracePoint uintptr, startFunction string, endTracePoint uintptr) error {
+	addr, err := s.startServer()
 	if err != nil {
 		return err
 	}
 
-	client, err = connectServer(addr)
+	s.client, err = connectServer(addr)
 	if err != nil {
 		return err
 	}
 
-	if err := checkVersion(); err != nil {
+	if err := s.checkVersion(); err != nil {
 		return err
 	}
 
@@ -109,29 +374,37 @@ func initialize(startTracePoint, endTracePoint uintptr) error {
 
 	attachArgs := &service.AttachArgs{
 		Pid:                    os.Getpid(),
-		TraceLevel:             traceLevel,
-		ParseLevel:             parseLevel,
+		TraceLevel:             s.traceLevel,
+		ParseLevel:             s.parseLevel,
+		SampleRate:             s.sampleRate,
+		OutputFormat:           s.outputFormat,
+		PrintSourceLocation:    s.printSourceLocation,
+		ShowLocals:             s.showLocals,
+		Verbose:                s.verbose,
 		InitialStartTracePoint: startTracePoint,
+		InitialStartFunction:   startFunction,
 		GoVersion:              runtime.Version(),
 		ProgramPath:            programPath,
 		FirstModuleDataAddr:    uintptr(unsafe.Pointer(&firstModuleData)),
 	}
 	reply := &struct{}{}
-	if err := client.Call("Tracer.Attach", attachArgs, reply); err != nil {
+	if err := s.client.Call("Tracer.Attach", attachArgs, reply); err != nil {
 		return err
 	}
 
-	if err := client.Call("Tracer.AddEndTracePoint", endTracePoint, reply); err != nil {
-		return err
+	if endTracePoint != 0 {
+		if err := s.client.Call("Tracer.AddEndTracePoint", endTracePoint, reply); err != nil {
+			return err
+		}
 	}
 
 	stopFuncAddr := reflect.ValueOf(Stop).Pointer()
-	return client.Call("Tracer.AddEndTracePoint", stopFuncAddr, reply)
+	return s.client.Call("Tracer.AddEndTracePoint", stopFuncAddr, reply)
 }
 
-func checkVersion() error {
+func (s *Session) checkVersion() error {
 	var serverVersion int
-	if err := client.Call("Tracer.Version", struct{}{}, &serverVersion); err != nil {
+	if err := s.client.Call("Tracer.Version", struct{}{}, &serverVersion); err != nil {
 		return err
 	}
 	if expectedVersion != serverVersion {
@@ -147,24 +420,27 @@ func Stop() {
 	return
 }
 
-func startServer() (string, error) {
+func (s *Session) startServer() (string, error) {
 	unusedPort, err := findUnusedPort()
 	if err != nil {
-		return "", fmt.Errorf("failed to find unused port: %v", err)
+		return "", fmt.Errorf("failed to find unused port: %w", err)
 	}
 	addr := fmt.Sprintf(":%d", unusedPort)
 
 	args := []string{"server"}
-	if verbose {
+	if s.verbose {
 		args = append(args, "-verbose")
 	}
 	args = append(args, addr)
-	serverCmd = exec.Command(tracerProgramName, args...)
-	serverCmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true} // Otherwise, tracer may receive the signal to this process.
-	serverCmd.Stdout = writer
-	serverCmd.Stderr = errorWriter
-	if err := serverCmd.Start(); err != nil {
-		return "", fmt.Errorf("failed to start server: %v", err)
+	s.serverCmd = exec.Command(tracerProgramName, args...)
+	s.serverCmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true} // Otherwise, tracer may receive the signal to this process.
+	s.serverCmd.Stdout = s.writer
+	if s.captured != nil {
+		s.serverCmd.Stdout = s.captured
+	}
+	s.serverCmd.Stderr = s.errorWriter
+	if err := s.serverCmd.Start(); err != nil {
+		return "", fmt.Errorf("failed to start server: %w", err)
 	}
 	return addr, nil
 }
@@ -184,6 +460,7 @@ func connectServer(addr string) (*rpc.Client, error) {
 	interval := 100 * time.Millisecond
 	var err error
 	for i := 0; i < numRetries; i++ {
+		var client *rpc.Client
 		client, err = rpc.Dial("tcp", addr)
 		if err == nil {
 			return client, nil
@@ -192,24 +469,35 @@ func connectServer(addr string) (*rpc.Client, error) {
 		time.Sleep(interval)
 		interval *= 2
 	}
-	return nil, fmt.Errorf("can't connect to the server (addr: %s): %v", addr, err)
+	return nil, fmt.Errorf("can't connect to the server (addr: %s): %w", addr, err)
 }
 
-func terminateServer() error {
-	defer func() { serverCmd = nil }()
+func (s *Session) terminateServer() error {
+	defer func() { s.serverCmd = nil }()
+	if s.serverTerminated != nil {
+		close(s.serverTerminated)
+		s.serverTerminated = nil
+	}
 
-	if client != nil {
-		if err := client.Close(); err != nil {
+	if s.client != nil {
+		err := s.client.Close()
+		s.client = nil
+		if err != nil {
 			return err
 		}
 	}
 
-	if serverCmd != nil && serverCmd.Process != nil {
-		if err := serverCmd.Process.Kill(); err != nil {
-			return err
+	if s.serverCmd != nil && s.serverCmd.Process != nil {
+		// The server may exit already. Wait anyway because the output is copied until then.
+		killErr := s.serverCmd.Process.Kill()
+		if err := s.serverCmd.Wait(); err != nil {
+			if _, ok := err.(*exec.ExitError); !ok {
+				return err
+			}
+		}
+		if killErr != nil && !errors.Is(killErr, os.ErrProcessDone) {
+			return killErr
 		}
-		_, err := serverCmd.Process.Wait()
-		return err
 	}
 	return nil
 }
@@ -1,13 +1,16 @@
 package tracer
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/ks888/tgo/testutils"
 )
@@ -43,6 +46,98 @@ func TestStart_NoTracerBinary(t *testing.T) {
 	}
 }
 
+func TestTerminateOnDone(t *testing.T) {
+	s := NewSession()
+	s.serverMtx.Lock()
+	s.serverCmd = exec.Command("sleep", "10")
+	if err := s.serverCmd.Start(); err != nil {
+		s.serverMtx.Unlock()
+		t.Fatalf("failed to start command: %v", err)
+	}
+	proc := s.serverCmd.Process
+	ctx, cancel := context.WithCancel(context.Background())
+	s.terminateOnDone(ctx)
+	s.serverMtx.Unlock()
+	cancel()
+
+	terminated := false
+	for i := 0; i < 10; i++ {
+		s.serverMtx.Lock()
+		terminated = s.serverCmd == nil
+		s.serverMtx.Unlock()
+		if terminated {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if !terminated {
+		t.Fatalf("the server is not terminated")
+	}
+	if err := proc.Signal(syscall.Signal(0)); err == nil {
+		t.Errorf("the server process still exists")
+	}
+}
+
+func TestTerminate_NotStarted(t *testing.T) {
+	if err := Terminate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := Terminate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestPauseResume_NotStarted(t *testing.T) {
+	if err := Pause(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := Resume(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewSession(t *testing.T) {
+	s := NewSession()
+	s.SetTraceLevel(3)
+	if s.traceLevel != 3 {
+		t.Errorf("wrong trace level: %d", s.traceLevel)
+	}
+	if defaultSession.traceLevel == 3 {
+		t.Errorf("the default session is changed")
+	}
+}
+
+func TestSession_StartTerminate_NoTracerBinary(t *testing.T) {
+	origTracerName := tracerProgramName
+	tracerProgramName = "not-exist-tracer"
+	defer func() { tracerProgramName = origTracerName }()
+
+	s := NewSession()
+	if err := s.Start(); err == nil {
+		t.Fatalf("should return error")
+	}
+	if err := s.Terminate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestSession_CaptureOutput(t *testing.T) {
+	origTracerName := tracerProgramName
+	tracerProgramName = "echo" // prints the args to the stdout
+	defer func() { tracerProgramName = origTracerName }()
+
+	s := NewSession()
+	s.SetCaptureOutput(true)
+	// fails to connect to the server. The echo command exits before the server is terminated.
+	if err := s.Start(); err == nil {
+		t.Fatalf("no error")
+	}
+	// the output is copied completely because the server is waited even if it exits already.
+	if output := s.CapturedOutput(); !strings.HasPrefix(output, "server :") || !strings.HasSuffix(output, "\n") {
+		t.Errorf("unexpected captured output: %s", output)
+	}
+}
+
 func TestMain(m *testing.M) {
 	_, srcFilename, _, _ := runtime.Caller(0)
 	srcDirname := filepath.Dir(srcFilename)
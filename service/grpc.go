@@ -0,0 +1,216 @@
+//go:build grpc
+
+package service
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/ks888/tgo/service/tracerpb"
+	"github.com/ks888/tgo/tracer"
+)
+
+const eventBufferSize = 256
+
+// grpcTracer serves the Tracer via gRPC. The traced data is sent as the stream of the trace events instead of written to stdout.
+type grpcTracer struct {
+	tracerpb.UnimplementedTracerServer
+
+	tracer   *Tracer
+	detached chan struct{}
+
+	mtx         sync.Mutex // protects subscribers and pendingEvents
+	subscribers map[*subscriber]struct{}
+	// pendingEvents holds the events traced while no stream subscribes. They are sent to the next subscriber.
+	pendingEvents []*tracerpb.TraceEvent
+}
+
+// subscriber is the stream receiving the trace events.
+type subscriber struct {
+	events chan *tracerpb.TraceEvent
+	// done is closed when the stream ends.
+	done chan struct{}
+}
+
+func newGRPCTracer() *grpcTracer {
+	t := &grpcTracer{detached: make(chan struct{}), subscribers: make(map[*subscriber]struct{})}
+	t.tracer = &Tracer{errCh: make(chan error), eventHandler: t.handleEvent}
+	return t
+}
+
+// Attach lets the server attach to the specified process.
+func (t *grpcTracer) Attach(ctx context.Context, req *tracerpb.AttachRequest) (*tracerpb.AttachResponse, error) {
+	args := AttachArgs{
+		Pid:                    int(req.Pid),
+		TraceLevel:             int(req.TraceLevel),
+		ParseLevel:             int(req.ParseLevel),
+		InitialStartTracePoint: uintptr(req.InitialStartTracePoint),
+		InitialStartFunction:   req.InitialStartFunction,
+		Verbose:                req.Verbose,
+		PrintSourceLocation:    req.PrintSourceLocation,
+		ShowLocals:             req.ShowLocals,
+		SampleRate:             req.SampleRate,
+		GoVersion:              req.GoVersion,
+		ProgramPath:            req.ProgramPath,
+		FirstModuleDataAddr:    uintptr(req.FirstModuleDataAddr),
+	}
+	if err := t.tracer.Attach(args, &struct{}{}); err != nil {
+		return nil, err
+	}
+	return &tracerpb.AttachResponse{}, nil
+}
+
+// Detach lets the server detach from the attached process. The event streams end after that.
+func (t *grpcTracer) Detach(ctx context.Context, req *tracerpb.DetachRequest) (*tracerpb.DetachResponse, error) {
+	if err := t.tracer.Detach(struct{}{}, &struct{}{}); err != nil {
+		return nil, err
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	select {
+	case <-t.detached:
+	default:
+		close(t.detached)
+	}
+	return &tracerpb.DetachResponse{}, nil
+}
+
+// StreamEvents sends the traced events until the server is detached or the client cancels the stream.
+// The events traced while no stream subscribes are sent first.
+func (t *grpcTracer) StreamEvents(req *tracerpb.StreamRequest, stream tracerpb.Tracer_StreamEventsServer) error {
+	sub, pendingEvents := t.subscribe()
+	defer t.unsubscribe(sub)
+
+	for _, event := range pendingEvents {
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case event := <-sub.events:
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		case <-t.detached:
+			// send the events already received.
+			for {
+				select {
+				case event := <-sub.events:
+					if err := stream.Send(event); err != nil {
+						return err
+					}
+				default:
+					return nil
+				}
+			}
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+func (t *grpcTracer) subscribe() (*subscriber, []*tracerpb.TraceEvent) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	sub := &subscriber{events: make(chan *tracerpb.TraceEvent, eventBufferSize), done: make(chan struct{})}
+	t.subscribers[sub] = struct{}{}
+	pendingEvents := t.pendingEvents
+	t.pendingEvents = nil
+	return sub, pendingEvents
+}
+
+func (t *grpcTracer) unsubscribe(sub *subscriber) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	delete(t.subscribers, sub)
+	close(sub.done)
+}
+
+// handleEvent sends the traced event to the subscribers. It waits for the slow subscriber rather than drops the event,
+// so the tracee is slowed down instead. The event is kept until the next subscriber if no stream subscribes.
+func (t *grpcTracer) handleEvent(event tracer.Event) {
+	pbEvent := &tracerpb.TraceEvent{
+		Type:          event.Type,
+		GoroutineId:   event.GoRoutineID,
+		Depth:         int32(event.Depth),
+		Function:      event.Function,
+		Args:          event.Args,
+		Locals:        event.Locals,
+		File:          event.File,
+		Line:          int32(event.Line),
+		RepeatedCount: int32(event.Repeated),
+	}
+
+	t.mtx.Lock()
+	if len(t.subscribers) == 0 {
+		t.pendingEvents = append(t.pendingEvents, pbEvent)
+		t.mtx.Unlock()
+		return
+	}
+	subscribers := make([]*subscriber, 0, len(t.subscribers))
+	for sub := range t.subscribers {
+		subscribers = append(subscribers, sub)
+	}
+	t.mtx.Unlock()
+
+	for _, sub := range subscribers {
+		select {
+		case sub.events <- pbEvent:
+		case <-sub.done:
+		case <-t.detached:
+		}
+	}
+}
+
+// ServeGRPC serves the tracer service via gRPC. It returns after the client detaches the process.
+func ServeGRPC(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	return serveGRPC(listener, newGRPCTracer())
+}
+
+func serveGRPC(listener net.Listener, t *grpcTracer) error {
+	server := grpc.NewServer()
+	tracerpb.RegisterTracerServer(server, t)
+
+	go func() {
+		<-t.detached
+		// wait until the detach completes.
+		t.tracer.mtx.Lock()
+		t.tracer.mtx.Unlock()
+		server.GracefulStop()
+	}()
+	return server.Serve(listener)
+}
+
+// GRPCClient is the client of the tracer service served via gRPC.
+type GRPCClient struct {
+	tracerpb.TracerClient
+	conn *grpc.ClientConn
+}
+
+// DialGRPC connects to the tracer service at the address.
+func DialGRPC(address string) (*GRPCClient, error) {
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	return &GRPCClient{TracerClient: tracerpb.NewTracerClient(conn), conn: conn}, nil
+}
+
+// Close closes the connection.
+func (c *GRPCClient) Close() error {
+	return c.conn.Close()
+}
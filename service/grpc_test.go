@@ -0,0 +1,64 @@
+//go:build grpc
+
+package service
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/ks888/tgo/service/tracerpb"
+	"github.com/ks888/tgo/tracer"
+)
+
+func TestGRPCTracer_HandleEvent(t *testing.T) {
+	grpcTracer := newGRPCTracer()
+	grpcTracer.handleEvent(tracer.Event{Type: "call", GoRoutineID: 1, Depth: 1, Function: "main.f", Args: []string{"a = 1"}})
+
+	sub, pendingEvents := grpcTracer.subscribe()
+	defer grpcTracer.unsubscribe(sub)
+	if len(pendingEvents) != 1 || pendingEvents[0].Type != "call" || pendingEvents[0].Args[0] != "a = 1" {
+		t.Errorf("unexpected pending events: %v", pendingEvents)
+	}
+
+	grpcTracer.handleEvent(tracer.Event{Type: "return", GoRoutineID: 1, Depth: 1, Function: "main.f"})
+	if event := <-sub.events; event.Type != "return" || event.Function != "main.f" || event.GoroutineId != 1 {
+		t.Errorf("unexpected event: %v", event)
+	}
+}
+
+func TestServeGRPC(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	grpcTracer := newGRPCTracer()
+	errCh := make(chan error)
+	go func() { errCh <- serveGRPC(listener, grpcTracer) }()
+
+	client, err := DialGRPC(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	grpcTracer.handleEvent(tracer.Event{Type: "call", GoRoutineID: 1, Depth: 1, Function: "main.f"})
+	stream, err := client.StreamEvents(context.Background(), &tracerpb.StreamRequest{})
+	if err != nil {
+		t.Fatalf("failed to stream: %v", err)
+	}
+	if event, err := stream.Recv(); err != nil || event.Function != "main.f" {
+		t.Errorf("unexpected event: %v, %v", event, err)
+	}
+
+	if _, err := client.Detach(context.Background(), &tracerpb.DetachRequest{}); err != nil {
+		t.Fatalf("failed to detach: %v", err)
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("stream not ended: %v", err)
+	}
+	if err := <-errCh; err != nil {
+		t.Errorf("failed to serve: %v", err)
+	}
+}
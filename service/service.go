@@ -2,6 +2,7 @@ package service
 
 import (
 	"errors"
+	"io/ioutil"
 	"net"
 	"net/rpc"
 	"sync"
@@ -10,7 +11,7 @@ import (
 	"github.com/ks888/tgo/tracer"
 )
 
-const serviceVersion = 1 // increment whenever any changes are aded to service methods.
+const serviceVersion = 3 // increment whenever any changes are aded to service methods.
 
 // Tracer is the wrapper of the actual tracer in tgo/tracer package.
 //
@@ -20,6 +21,8 @@ type Tracer struct {
 	controller *tracer.Controller
 	errCh      chan error
 	mtx        sync.Mutex // protects controller
+	// eventHandler is called with each traced event if not nil. The traced data is not written to the output in that case.
+	eventHandler func(tracer.Event)
 }
 
 // AttachArgs is the input argument of the service method 'Tracer.Attach'
@@ -29,7 +32,17 @@ type AttachArgs struct {
 	// This parameter is required because the tracer may not have a chance to set the new trace points
 	// after the attached tracee starts running without trace points.
 	InitialStartTracePoint uintptr
-	Verbose                bool
+	// InitialStartFunction is used instead of InitialStartTracePoint if the trace point is 0.
+	InitialStartFunction string
+	Verbose              bool
+	PrintSourceLocation  bool
+	// ShowLocals prints the local variables of the caller in scope at the call of the traced functions.
+	ShowLocals bool
+	// OutputFormat is either "text", "json" or "chrome". Empty is regarded as "text".
+	OutputFormat string
+	// SampleRate is the fraction of the function calls to be traced. 0 is regarded as 1 (all the calls are traced).
+	SampleRate float64
+	// GoVersion and ProgramPath are found from the process if empty.
 	GoVersion, ProgramPath string
 	FirstModuleDataAddr    uintptr
 }
@@ -48,18 +61,43 @@ func (t *Tracer) Attach(args AttachArgs, reply *struct{}) error {
 		return errors.New("already attached")
 	}
 
-	t.controller = tracer.NewController()
+	controller := tracer.NewController()
+	if args.OutputFormat != "" {
+		if err := controller.SetOutputFormat(args.OutputFormat); err != nil {
+			return err
+		}
+	}
+	if t.eventHandler != nil {
+		controller.SetEventHandler(t.eventHandler)
+		controller.SetOutputWriter(ioutil.Discard)
+	}
+	t.controller = controller
 	attrs := tracer.Attributes{
 		ProgramPath:         args.ProgramPath,
 		CompiledGoVersion:   args.GoVersion,
 		FirstModuleDataAddr: uint64(args.FirstModuleDataAddr),
 	}
 	if err := t.controller.AttachTracee(args.Pid, attrs); err != nil {
+		t.controller = nil // nothing to detach
 		return err
 	}
 	t.controller.SetTraceLevel(args.TraceLevel)
 	t.controller.SetParseLevel(args.ParseLevel)
-	t.controller.AddStartTracePoint(uint64(args.InitialStartTracePoint))
+	t.controller.SetPrintSourceLocation(args.PrintSourceLocation)
+	t.controller.SetShowLocals(args.ShowLocals)
+	if args.Verbose {
+		log.EnableDebugLog = true
+	}
+	if args.SampleRate > 0 {
+		t.controller.SetSampleRate(args.SampleRate)
+	}
+	// The main loop starts even if the function is not found so that the client can detach the process as usual.
+	var startErr error
+	if args.InitialStartTracePoint == 0 && args.InitialStartFunction != "" {
+		startErr = t.controller.AddStartTracePointByName(args.InitialStartFunction)
+	} else {
+		t.controller.AddStartTracePoint(uint64(args.InitialStartTracePoint))
+	}
 
 	go func() {
 		err := t.controller.MainLoop()
@@ -68,7 +106,7 @@ func (t *Tracer) Attach(args AttachArgs, reply *struct{}) error {
 		}
 		t.errCh <- err
 	}()
-	return nil
+	return startErr
 }
 
 // Detach lets the server detach from the attached process.
@@ -104,6 +142,17 @@ func (t *Tracer) AddStartTracePoint(args uintptr, reply *struct{}) error {
 	return t.controller.AddStartTracePoint(uint64(args))
 }
 
+// AddStartTracePointByName adds a new start trace point using the function name.
+func (t *Tracer) AddStartTracePointByName(args string, reply *struct{}) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.controller == nil {
+		return nil
+	}
+	return t.controller.AddStartTracePointByName(args)
+}
+
 // AddEndTracePoint adds a new end trace point.
 func (t *Tracer) AddEndTracePoint(args uintptr, reply *struct{}) error {
 	t.mtx.Lock()
@@ -115,6 +164,30 @@ func (t *Tracer) AddEndTracePoint(args uintptr, reply *struct{}) error {
 	return t.controller.AddEndTracePoint(uint64(args))
 }
 
+// Pause pauses printing the traced data.
+func (t *Tracer) Pause(args struct{}, reply *struct{}) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.controller == nil {
+		return nil
+	}
+	t.controller.Pause()
+	return nil
+}
+
+// Resume resumes printing the traced data.
+func (t *Tracer) Resume(args struct{}, reply *struct{}) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.controller == nil {
+		return nil
+	}
+	t.controller.Resume()
+	return nil
+}
+
 // Serve serves the tracer service.
 func Serve(address string) error {
 	tracer := &Tracer{errCh: make(chan error)}
@@ -34,6 +34,30 @@ func TestAttachAndDetach(t *testing.T) {
 	cmd.Process.Wait()
 }
 
+func TestAttach_StartFunction(t *testing.T) {
+	cmd := exec.Command(testutils.ProgramInfloop)
+	_ = cmd.Start()
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Process.Wait()
+	}()
+
+	tracer := &Tracer{errCh: make(chan error)}
+	args := AttachArgs{
+		Pid:                  cmd.Process.Pid,
+		InitialStartFunction: "main.main",
+		ProgramPath:          testutils.ProgramInfloop,
+		GoVersion:            runtime.Version(),
+	}
+	if err := tracer.Attach(args, nil); err != nil {
+		t.Errorf("failed to attach: %v", err)
+	}
+
+	if err := tracer.Detach(struct{}{}, nil); err != nil {
+		t.Errorf("failed to detach: %v", err)
+	}
+}
+
 func TestServe(t *testing.T) {
 	unusedPort, err := findUnusedPort()
 	if err != nil {
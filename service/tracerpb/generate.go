@@ -0,0 +1,8 @@
+//go:build grpc
+
+// Package tracerpb is the gRPC service of the tracer generated from tracer.proto.
+// It's built only with the grpc build tag because it depends on google.golang.org/grpc and google.golang.org/protobuf.
+package tracerpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative tracer.proto
+//go:generate perl -0pi -e "s|^|//go:build grpc\\n\\n|" tracer.pb.go tracer_grpc.pb.go
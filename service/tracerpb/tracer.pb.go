@@ -0,0 +1,521 @@
+//go:build grpc
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: tracer.proto
+
+package tracerpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// AttachRequest has the same options as the AttachArgs of the net/rpc service, except the output format.
+type AttachRequest struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Pid        int64                  `protobuf:"varint,1,opt,name=pid,proto3" json:"pid,omitempty"`
+	TraceLevel int32                  `protobuf:"varint,2,opt,name=trace_level,json=traceLevel,proto3" json:"trace_level,omitempty"`
+	ParseLevel int32                  `protobuf:"varint,3,opt,name=parse_level,json=parseLevel,proto3" json:"parse_level,omitempty"`
+	// initial_start_trace_point is required because the tracer may not have a chance to set the new trace points
+	// after the attached tracee starts running without trace points.
+	InitialStartTracePoint uint64 `protobuf:"varint,4,opt,name=initial_start_trace_point,json=initialStartTracePoint,proto3" json:"initial_start_trace_point,omitempty"`
+	// initial_start_function is used instead of initial_start_trace_point if the trace point is 0.
+	InitialStartFunction string `protobuf:"bytes,5,opt,name=initial_start_function,json=initialStartFunction,proto3" json:"initial_start_function,omitempty"`
+	Verbose              bool   `protobuf:"varint,6,opt,name=verbose,proto3" json:"verbose,omitempty"`
+	PrintSourceLocation  bool   `protobuf:"varint,7,opt,name=print_source_location,json=printSourceLocation,proto3" json:"print_source_location,omitempty"`
+	ShowLocals           bool   `protobuf:"varint,8,opt,name=show_locals,json=showLocals,proto3" json:"show_locals,omitempty"`
+	// sample_rate is the fraction of the function calls to be traced. 0 is regarded as 1.
+	SampleRate float64 `protobuf:"fixed64,9,opt,name=sample_rate,json=sampleRate,proto3" json:"sample_rate,omitempty"`
+	// go_version and program_path are found from the process if empty.
+	GoVersion           string `protobuf:"bytes,10,opt,name=go_version,json=goVersion,proto3" json:"go_version,omitempty"`
+	ProgramPath         string `protobuf:"bytes,11,opt,name=program_path,json=programPath,proto3" json:"program_path,omitempty"`
+	FirstModuleDataAddr uint64 `protobuf:"varint,12,opt,name=first_module_data_addr,json=firstModuleDataAddr,proto3" json:"first_module_data_addr,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *AttachRequest) Reset() {
+	*x = AttachRequest{}
+	mi := &file_tracer_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AttachRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AttachRequest) ProtoMessage() {}
+
+func (x *AttachRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tracer_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AttachRequest.ProtoReflect.Descriptor instead.
+func (*AttachRequest) Descriptor() ([]byte, []int) {
+	return file_tracer_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *AttachRequest) GetPid() int64 {
+	if x != nil {
+		return x.Pid
+	}
+	return 0
+}
+
+func (x *AttachRequest) GetTraceLevel() int32 {
+	if x != nil {
+		return x.TraceLevel
+	}
+	return 0
+}
+
+func (x *AttachRequest) GetParseLevel() int32 {
+	if x != nil {
+		return x.ParseLevel
+	}
+	return 0
+}
+
+func (x *AttachRequest) GetInitialStartTracePoint() uint64 {
+	if x != nil {
+		return x.InitialStartTracePoint
+	}
+	return 0
+}
+
+func (x *AttachRequest) GetInitialStartFunction() string {
+	if x != nil {
+		return x.InitialStartFunction
+	}
+	return ""
+}
+
+func (x *AttachRequest) GetVerbose() bool {
+	if x != nil {
+		return x.Verbose
+	}
+	return false
+}
+
+func (x *AttachRequest) GetPrintSourceLocation() bool {
+	if x != nil {
+		return x.PrintSourceLocation
+	}
+	return false
+}
+
+func (x *AttachRequest) GetShowLocals() bool {
+	if x != nil {
+		return x.ShowLocals
+	}
+	return false
+}
+
+func (x *AttachRequest) GetSampleRate() float64 {
+	if x != nil {
+		return x.SampleRate
+	}
+	return 0
+}
+
+func (x *AttachRequest) GetGoVersion() string {
+	if x != nil {
+		return x.GoVersion
+	}
+	return ""
+}
+
+func (x *AttachRequest) GetProgramPath() string {
+	if x != nil {
+		return x.ProgramPath
+	}
+	return ""
+}
+
+func (x *AttachRequest) GetFirstModuleDataAddr() uint64 {
+	if x != nil {
+		return x.FirstModuleDataAddr
+	}
+	return 0
+}
+
+type AttachResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AttachResponse) Reset() {
+	*x = AttachResponse{}
+	mi := &file_tracer_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AttachResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AttachResponse) ProtoMessage() {}
+
+func (x *AttachResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tracer_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AttachResponse.ProtoReflect.Descriptor instead.
+func (*AttachResponse) Descriptor() ([]byte, []int) {
+	return file_tracer_proto_rawDescGZIP(), []int{1}
+}
+
+type DetachRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DetachRequest) Reset() {
+	*x = DetachRequest{}
+	mi := &file_tracer_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DetachRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DetachRequest) ProtoMessage() {}
+
+func (x *DetachRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tracer_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DetachRequest.ProtoReflect.Descriptor instead.
+func (*DetachRequest) Descriptor() ([]byte, []int) {
+	return file_tracer_proto_rawDescGZIP(), []int{2}
+}
+
+type DetachResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DetachResponse) Reset() {
+	*x = DetachResponse{}
+	mi := &file_tracer_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DetachResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DetachResponse) ProtoMessage() {}
+
+func (x *DetachResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tracer_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DetachResponse.ProtoReflect.Descriptor instead.
+func (*DetachResponse) Descriptor() ([]byte, []int) {
+	return file_tracer_proto_rawDescGZIP(), []int{3}
+}
+
+type StreamRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamRequest) Reset() {
+	*x = StreamRequest{}
+	mi := &file_tracer_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamRequest) ProtoMessage() {}
+
+func (x *StreamRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tracer_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamRequest.ProtoReflect.Descriptor instead.
+func (*StreamRequest) Descriptor() ([]byte, []int) {
+	return file_tracer_proto_rawDescGZIP(), []int{4}
+}
+
+// TraceEvent is the traced call, return, or the repeated calls suppressed.
+type TraceEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// type is either "call", "return" or "repeated".
+	Type        string   `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	GoroutineId int64    `protobuf:"varint,2,opt,name=goroutine_id,json=goroutineId,proto3" json:"goroutine_id,omitempty"`
+	Depth       int32    `protobuf:"varint,3,opt,name=depth,proto3" json:"depth,omitempty"`
+	Function    string   `protobuf:"bytes,4,opt,name=function,proto3" json:"function,omitempty"`
+	Args        []string `protobuf:"bytes,5,rep,name=args,proto3" json:"args,omitempty"`
+	Locals      []string `protobuf:"bytes,6,rep,name=locals,proto3" json:"locals,omitempty"`
+	// file and line are set only when the source location is printed.
+	File string `protobuf:"bytes,7,opt,name=file,proto3" json:"file,omitempty"`
+	Line int32  `protobuf:"varint,8,opt,name=line,proto3" json:"line,omitempty"`
+	// repeated_count is the number of the suppressed calls. Set only when the type is "repeated".
+	RepeatedCount int32 `protobuf:"varint,9,opt,name=repeated_count,json=repeatedCount,proto3" json:"repeated_count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TraceEvent) Reset() {
+	*x = TraceEvent{}
+	mi := &file_tracer_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TraceEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TraceEvent) ProtoMessage() {}
+
+func (x *TraceEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_tracer_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TraceEvent.ProtoReflect.Descriptor instead.
+func (*TraceEvent) Descriptor() ([]byte, []int) {
+	return file_tracer_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *TraceEvent) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *TraceEvent) GetGoroutineId() int64 {
+	if x != nil {
+		return x.GoroutineId
+	}
+	return 0
+}
+
+func (x *TraceEvent) GetDepth() int32 {
+	if x != nil {
+		return x.Depth
+	}
+	return 0
+}
+
+func (x *TraceEvent) GetFunction() string {
+	if x != nil {
+		return x.Function
+	}
+	return ""
+}
+
+func (x *TraceEvent) GetArgs() []string {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+func (x *TraceEvent) GetLocals() []string {
+	if x != nil {
+		return x.Locals
+	}
+	return nil
+}
+
+func (x *TraceEvent) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+func (x *TraceEvent) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *TraceEvent) GetRepeatedCount() int32 {
+	if x != nil {
+		return x.RepeatedCount
+	}
+	return 0
+}
+
+var File_tracer_proto protoreflect.FileDescriptor
+
+const file_tracer_proto_rawDesc = "" +
+	"\n" +
+	"\ftracer.proto\x12\x03tgo\"\xdb\x03\n" +
+	"\rAttachRequest\x12\x10\n" +
+	"\x03pid\x18\x01 \x01(\x03R\x03pid\x12\x1f\n" +
+	"\vtrace_level\x18\x02 \x01(\x05R\n" +
+	"traceLevel\x12\x1f\n" +
+	"\vparse_level\x18\x03 \x01(\x05R\n" +
+	"parseLevel\x129\n" +
+	"\x19initial_start_trace_point\x18\x04 \x01(\x04R\x16initialStartTracePoint\x124\n" +
+	"\x16initial_start_function\x18\x05 \x01(\tR\x14initialStartFunction\x12\x18\n" +
+	"\averbose\x18\x06 \x01(\bR\averbose\x122\n" +
+	"\x15print_source_location\x18\a \x01(\bR\x13printSourceLocation\x12\x1f\n" +
+	"\vshow_locals\x18\b \x01(\bR\n" +
+	"showLocals\x12\x1f\n" +
+	"\vsample_rate\x18\t \x01(\x01R\n" +
+	"sampleRate\x12\x1d\n" +
+	"\n" +
+	"go_version\x18\n" +
+	" \x01(\tR\tgoVersion\x12!\n" +
+	"\fprogram_path\x18\v \x01(\tR\vprogramPath\x123\n" +
+	"\x16first_module_data_addr\x18\f \x01(\x04R\x13firstModuleDataAddr\"\x10\n" +
+	"\x0eAttachResponse\"\x0f\n" +
+	"\rDetachRequest\"\x10\n" +
+	"\x0eDetachResponse\"\x0f\n" +
+	"\rStreamRequest\"\xf0\x01\n" +
+	"\n" +
+	"TraceEvent\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12!\n" +
+	"\fgoroutine_id\x18\x02 \x01(\x03R\vgoroutineId\x12\x14\n" +
+	"\x05depth\x18\x03 \x01(\x05R\x05depth\x12\x1a\n" +
+	"\bfunction\x18\x04 \x01(\tR\bfunction\x12\x12\n" +
+	"\x04args\x18\x05 \x03(\tR\x04args\x12\x16\n" +
+	"\x06locals\x18\x06 \x03(\tR\x06locals\x12\x12\n" +
+	"\x04file\x18\a \x01(\tR\x04file\x12\x12\n" +
+	"\x04line\x18\b \x01(\x05R\x04line\x12%\n" +
+	"\x0erepeated_count\x18\t \x01(\x05R\rrepeatedCount2\xa5\x01\n" +
+	"\x06Tracer\x121\n" +
+	"\x06Attach\x12\x12.tgo.AttachRequest\x1a\x13.tgo.AttachResponse\x121\n" +
+	"\x06Detach\x12\x12.tgo.DetachRequest\x1a\x13.tgo.DetachResponse\x125\n" +
+	"\fStreamEvents\x12\x12.tgo.StreamRequest\x1a\x0f.tgo.TraceEvent0\x01B'Z%github.com/ks888/tgo/service/tracerpbb\x06proto3"
+
+var (
+	file_tracer_proto_rawDescOnce sync.Once
+	file_tracer_proto_rawDescData []byte
+)
+
+func file_tracer_proto_rawDescGZIP() []byte {
+	file_tracer_proto_rawDescOnce.Do(func() {
+		file_tracer_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_tracer_proto_rawDesc), len(file_tracer_proto_rawDesc)))
+	})
+	return file_tracer_proto_rawDescData
+}
+
+var file_tracer_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_tracer_proto_goTypes = []any{
+	(*AttachRequest)(nil),  // 0: tgo.AttachRequest
+	(*AttachResponse)(nil), // 1: tgo.AttachResponse
+	(*DetachRequest)(nil),  // 2: tgo.DetachRequest
+	(*DetachResponse)(nil), // 3: tgo.DetachResponse
+	(*StreamRequest)(nil),  // 4: tgo.StreamRequest
+	(*TraceEvent)(nil),     // 5: tgo.TraceEvent
+}
+var file_tracer_proto_depIdxs = []int32{
+	0, // 0: tgo.Tracer.Attach:input_type -> tgo.AttachRequest
+	2, // 1: tgo.Tracer.Detach:input_type -> tgo.DetachRequest
+	4, // 2: tgo.Tracer.StreamEvents:input_type -> tgo.StreamRequest
+	1, // 3: tgo.Tracer.Attach:output_type -> tgo.AttachResponse
+	3, // 4: tgo.Tracer.Detach:output_type -> tgo.DetachResponse
+	5, // 5: tgo.Tracer.StreamEvents:output_type -> tgo.TraceEvent
+	3, // [3:6] is the sub-list for method output_type
+	0, // [0:3] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_tracer_proto_init() }
+func file_tracer_proto_init() {
+	if File_tracer_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_tracer_proto_rawDesc), len(file_tracer_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_tracer_proto_goTypes,
+		DependencyIndexes: file_tracer_proto_depIdxs,
+		MessageInfos:      file_tracer_proto_msgTypes,
+	}.Build()
+	File_tracer_proto = out.File
+	file_tracer_proto_goTypes = nil
+	file_tracer_proto_depIdxs = nil
+}
@@ -0,0 +1,215 @@
+//go:build grpc
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: tracer.proto
+
+package tracerpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Tracer_Attach_FullMethodName       = "/tgo.Tracer/Attach"
+	Tracer_Detach_FullMethodName       = "/tgo.Tracer/Detach"
+	Tracer_StreamEvents_FullMethodName = "/tgo.Tracer/StreamEvents"
+)
+
+// TracerClient is the client API for Tracer service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Tracer is the tracer service. Unlike the net/rpc service, the traced data is sent as the stream of the structured
+// events rather than written to the server's stdout.
+type TracerClient interface {
+	// Attach lets the server attach to the specified process.
+	Attach(ctx context.Context, in *AttachRequest, opts ...grpc.CallOption) (*AttachResponse, error)
+	// Detach lets the server detach from the attached process. The event streams end after that.
+	Detach(ctx context.Context, in *DetachRequest, opts ...grpc.CallOption) (*DetachResponse, error)
+	// StreamEvents sends the traced events. The events traced before the first stream starts are buffered and sent to it.
+	StreamEvents(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TraceEvent], error)
+}
+
+type tracerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTracerClient(cc grpc.ClientConnInterface) TracerClient {
+	return &tracerClient{cc}
+}
+
+func (c *tracerClient) Attach(ctx context.Context, in *AttachRequest, opts ...grpc.CallOption) (*AttachResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AttachResponse)
+	err := c.cc.Invoke(ctx, Tracer_Attach_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tracerClient) Detach(ctx context.Context, in *DetachRequest, opts ...grpc.CallOption) (*DetachResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DetachResponse)
+	err := c.cc.Invoke(ctx, Tracer_Detach_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tracerClient) StreamEvents(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TraceEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Tracer_ServiceDesc.Streams[0], Tracer_StreamEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamRequest, TraceEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Tracer_StreamEventsClient = grpc.ServerStreamingClient[TraceEvent]
+
+// TracerServer is the server API for Tracer service.
+// All implementations must embed UnimplementedTracerServer
+// for forward compatibility.
+//
+// Tracer is the tracer service. Unlike the net/rpc service, the traced data is sent as the stream of the structured
+// events rather than written to the server's stdout.
+type TracerServer interface {
+	// Attach lets the server attach to the specified process.
+	Attach(context.Context, *AttachRequest) (*AttachResponse, error)
+	// Detach lets the server detach from the attached process. The event streams end after that.
+	Detach(context.Context, *DetachRequest) (*DetachResponse, error)
+	// StreamEvents sends the traced events. The events traced before the first stream starts are buffered and sent to it.
+	StreamEvents(*StreamRequest, grpc.ServerStreamingServer[TraceEvent]) error
+	mustEmbedUnimplementedTracerServer()
+}
+
+// UnimplementedTracerServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedTracerServer struct{}
+
+func (UnimplementedTracerServer) Attach(context.Context, *AttachRequest) (*AttachResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Attach not implemented")
+}
+func (UnimplementedTracerServer) Detach(context.Context, *DetachRequest) (*DetachResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Detach not implemented")
+}
+func (UnimplementedTracerServer) StreamEvents(*StreamRequest, grpc.ServerStreamingServer[TraceEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamEvents not implemented")
+}
+func (UnimplementedTracerServer) mustEmbedUnimplementedTracerServer() {}
+func (UnimplementedTracerServer) testEmbeddedByValue()                {}
+
+// UnsafeTracerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TracerServer will
+// result in compilation errors.
+type UnsafeTracerServer interface {
+	mustEmbedUnimplementedTracerServer()
+}
+
+func RegisterTracerServer(s grpc.ServiceRegistrar, srv TracerServer) {
+	// If the following call pancis, it indicates UnimplementedTracerServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Tracer_ServiceDesc, srv)
+}
+
+func _Tracer_Attach_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AttachRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TracerServer).Attach(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Tracer_Attach_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TracerServer).Attach(ctx, req.(*AttachRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tracer_Detach_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DetachRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TracerServer).Detach(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Tracer_Detach_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TracerServer).Detach(ctx, req.(*DetachRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tracer_StreamEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TracerServer).StreamEvents(m, &grpc.GenericServerStream[StreamRequest, TraceEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Tracer_StreamEventsServer = grpc.ServerStreamingServer[TraceEvent]
+
+// Tracer_ServiceDesc is the grpc.ServiceDesc for Tracer service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Tracer_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "tgo.Tracer",
+	HandlerType: (*TracerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Attach",
+			Handler:    _Tracer_Attach_Handler,
+		},
+		{
+			MethodName: "Detach",
+			Handler:    _Tracer_Detach_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamEvents",
+			Handler:       _Tracer_StreamEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "tracer.proto",
+}
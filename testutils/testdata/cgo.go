@@ -0,0 +1,26 @@
+package main
+
+/*
+extern void goCallback(int);
+
+static void callC(int v) {
+	goCallback(v + 1);
+}
+*/
+import "C"
+
+import "fmt"
+
+//export goCallback
+func goCallback(v C.int) {
+	callback(int(v))
+}
+
+//go:noinline
+func callback(v int) {
+	fmt.Println(v)
+}
+
+func main() {
+	C.callC(1)
+}
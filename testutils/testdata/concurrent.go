@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+const numWorkers = 8
+
+//go:noinline
+func multiply(a, b int) int {
+	return a * b
+}
+
+//go:noinline
+func square(id int) int {
+	return multiply(id, id)
+}
+
+//go:noinline
+func work(id int, ready *sync.WaitGroup, start chan struct{}, results []int) {
+	// wait until all the workers are ready and then call the square function at the same time.
+	ready.Done()
+	<-start
+	results[id-1] = square(id)
+}
+
+func main() {
+	var ready, done sync.WaitGroup
+	ready.Add(numWorkers)
+	done.Add(numWorkers)
+	start := make(chan struct{})
+	results := make([]int, numWorkers)
+	for i := 1; i <= numWorkers; i++ {
+		go func(id int) {
+			defer done.Done()
+			work(id, &ready, start, results)
+		}(i)
+	}
+
+	ready.Wait()
+	close(start)
+	done.Wait()
+	fmt.Println(results)
+}
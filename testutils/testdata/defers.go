@@ -0,0 +1,20 @@
+package main
+
+import "fmt"
+
+//go:noinline
+func cleanup(i int) {
+	fmt.Println("cleanup", i)
+}
+
+//go:noinline
+func deferInLoop() {
+	// the defer statement in the loop is not open-coded and so calls runtime.deferproc.
+	for i := 0; i < 2; i++ {
+		defer cleanup(i)
+	}
+}
+
+func main() {
+	deferInLoop()
+}
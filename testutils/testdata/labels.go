@@ -0,0 +1,18 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime/pprof"
+)
+
+//go:noinline
+func work() {
+	fmt.Println("work")
+}
+
+func main() {
+	pprof.Do(context.Background(), pprof.Labels("role", "worker"), func(context.Context) {
+		work()
+	})
+}
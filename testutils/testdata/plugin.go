@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"plugin"
+)
+
+//go:noinline
+func callPlugin(f func(int) int) int {
+	return f(1)
+}
+
+func main() {
+	p, err := plugin.Open(os.Args[1])
+	if err != nil {
+		panic(err)
+	}
+	sym, err := p.Lookup("Inc")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(callPlugin(sym.(func(int) int)))
+}
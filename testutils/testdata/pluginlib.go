@@ -0,0 +1,11 @@
+package main
+
+//go:noinline
+func inc(v int) int {
+	return v + 1
+}
+
+// Inc is looked up by the plugin program.
+func Inc(v int) int {
+	return inc(v)
+}
@@ -9,7 +9,25 @@ func dec(i, rem int) int {
 	return dec(i-1, rem-1)
 }
 
+// grow uses the large stack frame so that the stack grows while it's called recursively.
+//
+//go:noinline
+func grow(rem int) int {
+	var buff [1024]byte
+	buff[rem%len(buff)] = byte(rem)
+	if rem == 0 {
+		return int(buff[0])
+	}
+	return grow(rem-1) + int(buff[rem%len(buff)])
+}
+
+//go:noinline
+func deep() int {
+	return grow(100)
+}
+
 func main() {
 	val := dec(1, 100)
 	fmt.Println(val)
+	fmt.Println(deep())
 }
@@ -0,0 +1,20 @@
+package testbinary
+
+import "testing"
+
+//go:noinline
+func add(a, b int) int {
+	return a + b
+}
+
+func TestAdd(t *testing.T) {
+	if add(1, 2) != 3 {
+		t.Errorf("wrong result")
+	}
+}
+
+func TestSub(t *testing.T) {
+	if add(1, -2) != -1 {
+		t.Errorf("wrong result")
+	}
+}
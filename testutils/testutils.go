@@ -4,14 +4,19 @@ import (
 	"debug/elf"
 	"debug/macho"
 	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/ks888/tgo/log"
 )
 
+// HelloworldFuncOneParameter is the name of the function which has the []int parameter and the []int result.
+const HelloworldFuncOneParameter = "main.oneParameter"
+
 var (
 	// goBinaryPath is the path to the go binary used to build this test program.
 	// This go binary is used to build the testdata.
@@ -19,6 +24,7 @@ var (
 
 	ProgramHelloworld        string
 	ProgramHelloworldNoDwarf string
+	ProgramHelloworldELF     string // cross-compiled for linux/amd64 regardless of the host OS.
 	// These addresses are retrieved from the dwarf version. Assume they are same as non-dwarf version.
 	HelloworldAddrMain                    uint64
 	HelloworldAddrNoParameter             uint64
@@ -30,6 +36,9 @@ var (
 	HelloworldAddrErrorsNew               uint64
 	HelloworldAddrGoBuildID               uint64
 	HelloworldAddrFirstModuleData         uint64
+	HelloworldAddrMainStarted             uint64 // the global variable the runtime writes once before the main.main is called.
+
+	HelloworldELFAddrMain uint64
 
 	ProgramInfloop             string
 	InfloopAddrMain            uint64
@@ -43,6 +52,7 @@ var (
 
 	ProgramRecursive             string
 	RecursiveAddrMain            uint64
+	RecursiveAddrDec             uint64
 	RecursiveAddrFirstModuleData uint64
 
 	ProgramPanic             string
@@ -94,15 +104,50 @@ var (
 	ProgramSpecialFuncs             string
 	SpecialFuncsAddrMain            uint64
 	SpecialFuncsAddrFirstModuleData uint64
+
+	ProgramDefers             string
+	DefersAddrMain            uint64
+	DefersAddrDeferProc       uint64
+	DefersAddrFirstModuleData uint64
+
+	ProgramLabels             string
+	LabelsAddrWork            uint64
+	LabelsAddrMain            uint64
+	LabelsAddrFirstModuleData uint64
+
+	ProgramTestBinary             string
+	TestBinaryAddrTestAdd         uint64
+	TestBinaryAddrFirstModuleData uint64
+
+	ProgramPlugin             string
+	ProgramPluginLib          string // the plugin the plugin program loads. Give its path as the first argument.
+	PluginAddrCallPlugin      uint64
+	PluginAddrFirstModuleData uint64
+
+	ProgramCgo             string
+	CgoAddrCallback        uint64
+	CgoAddrCFunction       uint64 // the C function cgo generates for the exported go function.
+	CgoAddrFirstModuleData uint64
+
+	ProgramConcurrent             string
+	ConcurrentAddrWork            uint64
+	ConcurrentAddrFirstModuleData uint64
+)
+
+var (
+	buildPluginOnce, buildCgoOnce sync.Once
+	buildPluginErr, buildCgoErr   error
 )
 
 func init() {
-	_, srcFilename, _, _ := runtime.Caller(0)
-	srcDirname := filepath.Dir(srcFilename)
+	srcDirname := sourceDirname()
 
 	if err := buildProgramHelloworld(srcDirname); err != nil {
 		panic(err)
 	}
+	if err := buildProgramHelloworldELF(srcDirname); err != nil {
+		panic(err)
+	}
 	if err := buildProgramInfloop(srcDirname); err != nil {
 		panic(err)
 	}
@@ -127,6 +172,18 @@ func init() {
 	if err := buildProgramSpecialFuncs(srcDirname); err != nil {
 		panic(err)
 	}
+	if err := buildProgramDefers(srcDirname); err != nil {
+		panic(err)
+	}
+	if err := buildProgramLabels(srcDirname); err != nil {
+		panic(err)
+	}
+	if err := buildProgramTestBinary(srcDirname); err != nil {
+		panic(err)
+	}
+	if err := buildProgramConcurrent(srcDirname); err != nil {
+		panic(err)
+	}
 
 	log.EnableDebugLog = true
 }
@@ -146,7 +203,7 @@ func buildProgramHelloworld(srcDirname string) error {
 		switch name {
 		case "main.main":
 			HelloworldAddrMain = value
-		case "main.oneParameter":
+		case HelloworldFuncOneParameter:
 			HelloworldAddrOneParameter = value
 		case "main.oneParameterAndOneVariable":
 			HelloworldAddrOneParameterAndVariable = value
@@ -164,6 +221,8 @@ func buildProgramHelloworld(srcDirname string) error {
 			HelloworldAddrGoBuildID = value
 		case "runtime.firstmoduledata":
 			HelloworldAddrFirstModuleData = value
+		case "runtime.mainStarted":
+			HelloworldAddrMainStarted = value
 		}
 		return nil
 	}
@@ -171,6 +230,24 @@ func buildProgramHelloworld(srcDirname string) error {
 	return walkSymbols(ProgramHelloworld, updateAddressIfMatched)
 }
 
+// buildProgramHelloworldELF cross-compiles the helloworld program for linux so that the ELF binary is available on any OS.
+func buildProgramHelloworldELF(srcDirname string) error {
+	ProgramHelloworldELF = filepath.Join(srcDirname, "testdata", "helloworld.elf")
+	src := filepath.Join(srcDirname, "testdata", "helloworld.go")
+	cmd := exec.Command(goBinaryPath, "build", "-o", ProgramHelloworldELF, src)
+	cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH=amd64", "CGO_ENABLED=0")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to build %s: %v\n%v", src, err, string(out))
+	}
+
+	return walkELFSymbols(ProgramHelloworldELF, func(name string, value uint64) error {
+		if name == "main.main" {
+			HelloworldELFAddrMain = value
+		}
+		return nil
+	})
+}
+
 func buildProgramInfloop(srcDirname string) error {
 	ProgramInfloop = srcDirname + "/testdata/infloop"
 
@@ -228,6 +305,8 @@ func buildProgramRecursive(srcDirname string) error {
 		switch name {
 		case "main.main":
 			RecursiveAddrMain = value
+		case "main.dec":
+			RecursiveAddrDec = value
 		case "runtime.firstmoduledata":
 			RecursiveAddrFirstModuleData = value
 		}
@@ -389,6 +468,160 @@ func buildProgramSpecialFuncs(srcDirname string) error {
 	return walkSymbols(ProgramSpecialFuncs, updateAddressIfMatched)
 }
 
+func buildProgramDefers(srcDirname string) error {
+	ProgramDefers = srcDirname + "/testdata/defers"
+
+	if err := buildProgram(ProgramDefers); err != nil {
+		return err
+	}
+
+	updateAddressIfMatched := func(name string, value uint64) error {
+		switch name {
+		case "main.main":
+			DefersAddrMain = value
+		case "runtime.deferproc":
+			DefersAddrDeferProc = value
+		case "runtime.firstmoduledata":
+			DefersAddrFirstModuleData = value
+		}
+		return nil
+	}
+
+	return walkSymbols(ProgramDefers, updateAddressIfMatched)
+}
+
+func buildProgramLabels(srcDirname string) error {
+	ProgramLabels = srcDirname + "/testdata/labels"
+
+	if err := buildProgram(ProgramLabels); err != nil {
+		return err
+	}
+
+	updateAddressIfMatched := func(name string, value uint64) error {
+		switch name {
+		case "main.work":
+			LabelsAddrWork = value
+		case "main.main":
+			LabelsAddrMain = value
+		case "runtime.firstmoduledata":
+			LabelsAddrFirstModuleData = value
+		}
+		return nil
+	}
+
+	return walkSymbols(ProgramLabels, updateAddressIfMatched)
+}
+
+// buildProgramTestBinary builds the test binary using `go test -c`. The package path is `command-line-arguments`.
+func buildProgramTestBinary(srcDirname string) error {
+	ProgramTestBinary = srcDirname + "/testdata/testbinary.test"
+
+	src := srcDirname + "/testdata/testbinary_test.go"
+	if out, err := exec.Command(goBinaryPath, "test", "-c", "-o", ProgramTestBinary, src).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to build %s: %v\n%v", src, err, string(out))
+	}
+
+	updateAddressIfMatched := func(name string, value uint64) error {
+		switch name {
+		case "command-line-arguments.TestAdd":
+			TestBinaryAddrTestAdd = value
+		case "runtime.firstmoduledata":
+			TestBinaryAddrFirstModuleData = value
+		}
+		return nil
+	}
+
+	return walkSymbols(ProgramTestBinary, updateAddressIfMatched)
+}
+
+// sourceDirname returns the directory of this source file. The testdata directory is there.
+func sourceDirname() string {
+	_, srcFilename, _, _ := runtime.Caller(0)
+	return filepath.Dir(srcFilename)
+}
+
+// BuildProgramPlugin builds ProgramPlugin and ProgramPluginLib at the first call. Unlike the other programs, they are
+// not built at init because the plugin build mode is not supported everywhere. Skip the test if the error is returned.
+func BuildProgramPlugin() error {
+	buildPluginOnce.Do(func() { buildPluginErr = buildProgramPlugin(sourceDirname()) })
+	return buildPluginErr
+}
+
+// BuildProgramCgo builds ProgramCgo at the first call. Unlike the other programs, it's not built at init because
+// cgo requires the C compiler. Skip the test if the error is returned.
+func BuildProgramCgo() error {
+	buildCgoOnce.Do(func() { buildCgoErr = buildProgramCgo(sourceDirname()) })
+	return buildCgoErr
+}
+
+// buildProgramPlugin builds the program which loads the plugin at runtime, and the plugin itself.
+func buildProgramPlugin(srcDirname string) error {
+	ProgramPlugin = srcDirname + "/testdata/plugin"
+	ProgramPluginLib = srcDirname + "/testdata/pluginlib.so"
+
+	src := srcDirname + "/testdata/pluginlib.go"
+	if out, err := exec.Command(goBinaryPath, "build", "-buildmode=plugin", "-o", ProgramPluginLib, src).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to build %s: %v\n%v", src, err, string(out))
+	}
+	if err := buildProgram(ProgramPlugin); err != nil {
+		return err
+	}
+
+	updateAddressIfMatched := func(name string, value uint64) error {
+		switch name {
+		case "main.callPlugin":
+			PluginAddrCallPlugin = value
+		case "runtime.firstmoduledata":
+			PluginAddrFirstModuleData = value
+		}
+		return nil
+	}
+
+	return walkSymbols(ProgramPlugin, updateAddressIfMatched)
+}
+
+func buildProgramCgo(srcDirname string) error {
+	ProgramCgo = srcDirname + "/testdata/cgo"
+
+	if err := buildProgram(ProgramCgo); err != nil {
+		return err
+	}
+
+	updateAddressIfMatched := func(name string, value uint64) error {
+		switch name {
+		case "main.callback":
+			CgoAddrCallback = value
+		case "goCallback":
+			CgoAddrCFunction = value
+		case "runtime.firstmoduledata":
+			CgoAddrFirstModuleData = value
+		}
+		return nil
+	}
+
+	return walkSymbols(ProgramCgo, updateAddressIfMatched)
+}
+
+func buildProgramConcurrent(srcDirname string) error {
+	ProgramConcurrent = srcDirname + "/testdata/concurrent"
+
+	if err := buildProgram(ProgramConcurrent); err != nil {
+		return err
+	}
+
+	updateAddressIfMatched := func(name string, value uint64) error {
+		switch name {
+		case "main.work":
+			ConcurrentAddrWork = value
+		case "runtime.firstmoduledata":
+			ConcurrentAddrFirstModuleData = value
+		}
+		return nil
+	}
+
+	return walkSymbols(ProgramConcurrent, updateAddressIfMatched)
+}
+
 func buildProgram(programName string) error {
 	// Optimization is enabled, because the tool aims to work well even if the binary is optimized.
 	linkOptions := ""
@@ -423,23 +656,29 @@ func walkSymbols(programName string, walkFunc func(name string, value uint64) er
 		}
 
 	case "linux":
-		elfFile, err := elf.Open(programName)
-		if err != nil {
-			return fmt.Errorf("failed to open binary: %v", err)
-		}
-
-		syms, err := elfFile.Symbols()
-		if err != nil {
-			return fmt.Errorf("failed to find symbols: %v", err)
-		}
-		for _, sym := range syms {
-			if err := walkFunc(sym.Name, sym.Value); err != nil {
-				return err
-			}
-		}
+		return walkELFSymbols(programName, walkFunc)
 	default:
 		return fmt.Errorf("unsupported os: %s", runtime.GOOS)
 	}
 
 	return nil
 }
+
+func walkELFSymbols(programName string, walkFunc func(name string, value uint64) error) error {
+	elfFile, err := elf.Open(programName)
+	if err != nil {
+		return fmt.Errorf("failed to open binary: %v", err)
+	}
+	defer elfFile.Close()
+
+	syms, err := elfFile.Symbols()
+	if err != nil {
+		return fmt.Errorf("failed to find symbols: %v", err)
+	}
+	for _, sym := range syms {
+		if err := walkFunc(sym.Name, sym.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
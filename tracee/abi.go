@@ -0,0 +1,176 @@
+package tracee
+
+import (
+	"debug/dwarf"
+	"encoding/binary"
+
+	"github.com/ks888/tgo/debugapi"
+)
+
+// abiRegisters is the sequence of the registers the register-based calling convention (ABIInternal) assigns the args to.
+// See https://github.com/golang/go/blob/master/src/cmd/compile/abi-internal.md
+type abiRegisters struct {
+	ints   []uint64
+	floats [][16]byte
+}
+
+// x86ABIRegisters returns the registers of ABIInternal on x86-64. The integer args are in RAX, RBX, RCX, RDI, RSI and R8-R11
+// and the floating-point args are in X0-X14.
+func x86ABIRegisters(regs debugapi.Registers) abiRegisters {
+	return abiRegisters{
+		ints: []uint64{regs.Rax, regs.Rbx, regs.Rcx, regs.Rdi, regs.Rsi, regs.R8, regs.R9, regs.R10, regs.R11},
+		floats: [][16]byte{regs.Xmm0, regs.Xmm1, regs.Xmm2, regs.Xmm3, regs.Xmm4, regs.Xmm5, regs.Xmm6, regs.Xmm7,
+			regs.Xmm8, regs.Xmm9, regs.Xmm10, regs.Xmm11, regs.Xmm12, regs.Xmm13, regs.Xmm14},
+	}
+}
+
+// abiPiece is the part of the value which is assigned to one register.
+type abiPiece struct {
+	offset, size int
+	float        bool
+}
+
+// abiAssigner assigns the args to the registers or the stack in order. The input args and the results are assigned
+// separately, using the different assigners.
+type abiAssigner struct {
+	regs               abiRegisters
+	nextInt, nextFloat int
+	// stackOffset is the offset of the next stack-assigned arg from the beginning of the args area.
+	stackOffset int
+}
+
+// assign assigns the arg of the `typ` type. If assigned to the registers, its raw value is built from the registers.
+// Otherwise, the returned `onStack` is true and `stackOffset` is the offset of the value from the beginning of the args area.
+func (a *abiAssigner) assign(typ dwarf.Type) (val []byte, stackOffset int, onStack bool) {
+	size := int(typ.Size())
+	if size < 0 {
+		size = 0
+	}
+
+	pieces, ok := abiPieces(typ, 0, nil)
+	if ok && a.fits(pieces) {
+		val = make([]byte, size)
+		for _, piece := range pieces {
+			var reg [16]byte
+			if piece.float {
+				reg = a.regs.floats[a.nextFloat]
+				a.nextFloat++
+			} else {
+				binary.LittleEndian.PutUint64(reg[:8], a.regs.ints[a.nextInt])
+				a.nextInt++
+			}
+			copy(val[piece.offset:piece.offset+piece.size], reg[:piece.size])
+		}
+		return val, 0, false
+	}
+
+	a.stackOffset = alignUp(a.stackOffset, abiAlign(typ))
+	stackOffset = a.stackOffset
+	a.stackOffset += size
+	return nil, stackOffset, true
+}
+
+// fits returns true if the remaining registers are enough for the pieces. Either all the pieces are assigned to the registers
+// or the value is assigned to the stack.
+func (a *abiAssigner) fits(pieces []abiPiece) bool {
+	var numInts, numFloats int
+	for _, piece := range pieces {
+		if piece.float {
+			numFloats++
+		} else {
+			numInts++
+		}
+	}
+	return a.nextInt+numInts <= len(a.regs.ints) && a.nextFloat+numFloats <= len(a.regs.floats)
+}
+
+// finishInputs aligns the stack offset so that the stack-assigned results follow the input args. The registers are
+// assigned from the beginning again for the results.
+func (a *abiAssigner) finishInputs() {
+	a.nextInt, a.nextFloat = 0, 0
+	a.stackOffset = alignUp(a.stackOffset, 8)
+}
+
+// abiPieces appends the pieces of the value to `pieces`. False is returned if the value can't be assigned to the registers,
+// like the array whose length is more than 1.
+func abiPieces(rawTyp dwarf.Type, offset int, pieces []abiPiece) ([]abiPiece, bool) {
+	switch typ := rawTyp.(type) {
+	case *dwarf.IntType, *dwarf.UintType, *dwarf.BoolType, *dwarf.CharType, *dwarf.UcharType, *dwarf.AddrType, *dwarf.PtrType, *dwarf.FuncType:
+		size := int(typ.Size())
+		if size <= 0 || size > 8 {
+			size = 8 // e.g. the func type
+		}
+		return append(pieces, abiPiece{offset: offset, size: size}), true
+	case *dwarf.FloatType:
+		return append(pieces, abiPiece{offset: offset, size: int(typ.Size()), float: true}), true
+	case *dwarf.ComplexType:
+		half := int(typ.Size()) / 2
+		return append(pieces, abiPiece{offset: offset, size: half, float: true}, abiPiece{offset: offset + half, size: half, float: true}), true
+	case *dwarf.StructType:
+		// the string, slice and interface are the structs in DWARF, and their fields are assigned as the ABI says.
+		for _, field := range typ.Field {
+			var ok bool
+			if pieces, ok = abiPieces(field.Type, offset+int(field.ByteOffset), pieces); !ok {
+				return nil, false
+			}
+		}
+		return pieces, true
+	case *dwarf.ArrayType:
+		switch typ.Count {
+		case 0:
+			return pieces, true
+		case 1:
+			return abiPieces(typ.Type, offset, pieces)
+		}
+		return nil, false
+	case *dwarf.TypedefType:
+		return abiPieces(typ.Type, offset, pieces)
+	}
+	return nil, false
+}
+
+// usesFloatRegisters returns true if any of the params may be assigned to the floating-point registers.
+func usesFloatRegisters(params []Parameter) bool {
+	for _, param := range params {
+		pieces, _ := abiPieces(param.Typ, 0, nil)
+		for _, piece := range pieces {
+			if piece.float {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// abiAlign returns the alignment of the type in the memory.
+func abiAlign(rawTyp dwarf.Type) int {
+	switch typ := rawTyp.(type) {
+	case *dwarf.StructType:
+		align := 1
+		for _, field := range typ.Field {
+			if fieldAlign := abiAlign(field.Type); fieldAlign > align {
+				align = fieldAlign
+			}
+		}
+		return align
+	case *dwarf.ArrayType:
+		return abiAlign(typ.Type)
+	case *dwarf.ComplexType:
+		return int(typ.Size()) / 2
+	case *dwarf.TypedefType:
+		return abiAlign(typ.Type)
+	}
+
+	size := int(rawTyp.Size())
+	if size <= 0 || size > 8 {
+		return 8
+	}
+	return size
+}
+
+func alignUp(offset, align int) int {
+	if align <= 1 {
+		return offset
+	}
+	return (offset + align - 1) / align * align
+}
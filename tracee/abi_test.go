@@ -0,0 +1,83 @@
+package tracee
+
+import (
+	"debug/dwarf"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/ks888/tgo/debugapi"
+)
+
+var (
+	abiTestIntType    = &dwarf.IntType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 8, Name: "int"}}}
+	abiTestUint8Type  = &dwarf.UintType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 1, Name: "uint8"}}}
+	abiTestFloatType  = &dwarf.FloatType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 8, Name: "float64"}}}
+	abiTestStringType = &dwarf.StructType{CommonType: dwarf.CommonType{ByteSize: 16}, StructName: "string", Field: []*dwarf.StructField{
+		{Name: "str", Type: &dwarf.PtrType{CommonType: dwarf.CommonType{ByteSize: 8}, Type: abiTestUint8Type}, ByteOffset: 0},
+		{Name: "len", Type: abiTestIntType, ByteOffset: 8},
+	}}
+)
+
+func TestABIAssigner_Assign(t *testing.T) {
+	regs := debugapi.Registers{Rax: 1, Rbx: 2, Rcx: 3}
+	binary.LittleEndian.PutUint64(regs.Xmm0[:], math.Float64bits(1.5))
+	assigner := &abiAssigner{regs: x86ABIRegisters(regs)}
+
+	val, _, onStack := assigner.assign(abiTestIntType)
+	if onStack || binary.LittleEndian.Uint64(val) != 1 {
+		t.Errorf("wrong int value: %v, %v", val, onStack)
+	}
+
+	val, _, onStack = assigner.assign(abiTestFloatType)
+	if onStack || math.Float64frombits(binary.LittleEndian.Uint64(val)) != 1.5 {
+		t.Errorf("wrong float value: %v, %v", val, onStack)
+	}
+
+	val, _, onStack = assigner.assign(abiTestStringType)
+	if onStack || binary.LittleEndian.Uint64(val[0:8]) != 2 || binary.LittleEndian.Uint64(val[8:16]) != 3 {
+		t.Errorf("wrong string value: %v, %v", val, onStack)
+	}
+}
+
+func TestABIAssigner_Assign_Stack(t *testing.T) {
+	assigner := &abiAssigner{regs: x86ABIRegisters(debugapi.Registers{})}
+	array := &dwarf.ArrayType{CommonType: dwarf.CommonType{ByteSize: 16}, Type: abiTestIntType, Count: 2}
+	struc := &dwarf.StructType{CommonType: dwarf.CommonType{ByteSize: 16}, StructName: "main.S", Field: []*dwarf.StructField{
+		{Name: "a", Type: abiTestUint8Type, ByteOffset: 0},
+		{Name: "b", Type: array, ByteOffset: 8},
+	}}
+
+	for i := 0; i < 8; i++ {
+		assigner.assign(abiTestIntType)
+	}
+	// the string needs 2 registers, but only 1 register remains.
+	_, offset, onStack := assigner.assign(abiTestStringType)
+	if !onStack || offset != 0 {
+		t.Errorf("wrong string assignment: %d, %v", offset, onStack)
+	}
+	if _, _, onStack := assigner.assign(abiTestUint8Type); onStack {
+		t.Errorf("uint8 should be in the register")
+	}
+	// the array whose length is more than 1 is always on the stack.
+	_, offset, onStack = assigner.assign(struc)
+	if !onStack || offset != 16 {
+		t.Errorf("wrong struct assignment: %d, %v", offset, onStack)
+	}
+
+	assigner.finishInputs()
+	if _, _, onStack := assigner.assign(abiTestIntType); onStack {
+		t.Errorf("the result should be in the register")
+	}
+}
+
+func TestABIAssigner_Assign_Results(t *testing.T) {
+	assigner := &abiAssigner{regs: x86ABIRegisters(debugapi.Registers{})}
+	assigner.assign(abiTestUint8Type)
+	assigner.stackOffset = 1
+
+	assigner.finishInputs()
+	if assigner.nextInt != 0 || assigner.stackOffset != 8 {
+		t.Errorf("wrong state: %d, %d", assigner.nextInt, assigner.stackOffset)
+	}
+}
@@ -0,0 +1,43 @@
+package tracee
+
+import "strconv"
+
+// The canonical keys of the function annotations. The value is always the string, so the number and bool values are
+// formatted by the strconv package.
+const (
+	// AnnotationABI is the calling convention of the function, such as "ABI0" and "ABIInternal". See ABI.String.
+	AnnotationABI = "abi"
+	// AnnotationInlined is "true" if the function is built from the inlined subroutine.
+	AnnotationInlined = "inlined"
+	// AnnotationTrampoline is "true" if the function is the wrapper of another function.
+	AnnotationTrampoline = "trampoline"
+	// AnnotationParametersGuessed is "true" if the parameters are guessed from the runtime data, not the DWARF.
+	AnnotationParametersGuessed = "parameters_guessed"
+	// AnnotationPrologueSize is the size of the stack growth check at the beginning of the function, in bytes.
+	// Set once the instructions are read by Process.FunctionBodyStarts.
+	AnnotationPrologueSize = "prologue_size"
+)
+
+// Annotate sets the annotation of the function. Use the canonical keys if exists.
+// The map is copied before the write, because the copies of the Function (e.g. the cached one) may share it.
+func (f *Function) Annotate(key, value string) {
+	annotations := make(map[string]string, len(f.Annotations)+1)
+	for k, v := range f.Annotations {
+		annotations[k] = v
+	}
+	annotations[key] = value
+	f.Annotations = annotations
+}
+
+// Annotation returns the annotation of the function. False is returned if not annotated.
+func (f *Function) Annotation(key string) (string, bool) {
+	value, ok := f.Annotations[key]
+	return value, ok
+}
+
+// annotateBool sets the bool annotation only if it's true, so that the annotations stay empty in the typical case.
+func (f *Function) annotateBool(key string, value bool) {
+	if value {
+		f.Annotate(key, strconv.FormatBool(value))
+	}
+}
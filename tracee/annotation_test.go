@@ -0,0 +1,49 @@
+package tracee
+
+import "testing"
+
+func TestFunction_Annotate(t *testing.T) {
+	f := &Function{Name: "main.main"}
+	if _, ok := f.Annotation(AnnotationInlined); ok {
+		t.Errorf("annotated before set")
+	}
+
+	f.Annotate(AnnotationInlined, "true")
+	if value, ok := f.Annotation(AnnotationInlined); !ok || value != "true" {
+		t.Errorf("wrong annotation: %s, %v", value, ok)
+	}
+
+	f.annotateBool(AnnotationTrampoline, false)
+	if _, ok := f.Annotation(AnnotationTrampoline); ok {
+		t.Errorf("false value is annotated")
+	}
+}
+
+func TestFunction_Annotate_Copied(t *testing.T) {
+	f := &Function{Name: "main.main"}
+	f.Annotate(AnnotationABI, "ABIInternal")
+
+	copied := *f
+	copied.Annotate(AnnotationPrologueSize, "10")
+	if _, ok := f.Annotation(AnnotationPrologueSize); ok {
+		t.Errorf("the annotation of the copy is shared")
+	}
+	if value, ok := copied.Annotation(AnnotationABI); !ok || value != "ABIInternal" {
+		t.Errorf("wrong annotation: %s, %v", value, ok)
+	}
+}
+
+func TestABI_String(t *testing.T) {
+	for i, testdata := range []struct {
+		abi    ABI
+		expect string
+	}{
+		{abi: ABIABI0, expect: "ABI0"},
+		{abi: ABIABIInternal, expect: "ABIInternal"},
+		{abi: ABI(2), expect: "ABI(2)"},
+	} {
+		if actual := testdata.abi.String(); actual != testdata.expect {
+			t.Errorf("[%d] wrong name: %s", i, actual)
+		}
+	}
+}
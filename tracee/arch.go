@@ -0,0 +1,173 @@
+package tracee
+
+import (
+	"fmt"
+
+	"golang.org/x/arch/arm64/arm64asm"
+	"golang.org/x/arch/x86/x86asm"
+)
+
+// Arch represents the architecture-dependent part of the tracee.
+type Arch interface {
+	// BreakpointInstruction returns the instruction which traps the process when executed.
+	BreakpointInstruction() []byte
+	// BreakpointAddr returns the address of the breakpoint instruction which trapped the process.
+	// The `pc` is the one reported when the process is trapped.
+	BreakpointAddr(pc uint64) uint64
+	// DecodeInstruction decodes the first instruction in the `buff`.
+	DecodeInstruction(buff []byte) (Inst, error)
+}
+
+// findArchByName returns the Arch which has the name the debug api client reports.
+func findArchByName(name string) (Arch, error) {
+	switch name {
+	case "x86_64":
+		return X86_64Arch{}, nil
+	case "arm64", "aarch64":
+		return ARM64Arch{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported architecture: %s", name)
+	}
+}
+
+// Inst represents the decoded instruction.
+type Inst struct {
+	// Len is the length of the instruction in bytes.
+	Len int
+	// IsCall is true if the instruction calls the function.
+	IsCall bool
+	// Raw is the architecture-specific instruction. x86asm.Inst for X86_64Arch and arm64asm.Inst for ARM64Arch.
+	Raw interface{}
+}
+
+// AsmSyntax is the syntax used to format the instruction.
+type AsmSyntax int
+
+const (
+	// AsmSyntaxIntel is the Intel syntax.
+	AsmSyntaxIntel AsmSyntax = iota
+	// AsmSyntaxATT is the AT&T syntax, which the GNU tools use.
+	AsmSyntaxATT
+)
+
+// Format returns the string representation of the instruction. `pc` is the address of the instruction.
+// The syntax is ignored if the architecture has only one syntax (e.g. arm64).
+func (inst Inst) Format(pc uint64, syntax AsmSyntax) string {
+	switch raw := inst.Raw.(type) {
+	case x86asm.Inst:
+		if syntax == AsmSyntaxATT {
+			return x86asm.GNUSyntax(raw, pc, nil)
+		}
+		return x86asm.IntelSyntax(raw, pc, nil)
+	case arm64asm.Inst:
+		return arm64asm.GNUSyntax(raw)
+	default:
+		return "?"
+	}
+}
+
+// isStackCheck returns true if the instructions compare the stack pointer with the stack guard and branch to
+// the stack growth routine. The go compiler inserts them at the beginning of the function (unless NOSPLIT).
+func isStackCheck(cmp, branch Inst) bool {
+	switch rawCmp := cmp.Raw.(type) {
+	case x86asm.Inst:
+		// e.g. CMP RSP, [R14+0x10]; JBE
+		rawBranch, ok := branch.Raw.(x86asm.Inst)
+		if !ok || rawCmp.Op != x86asm.CMP || (rawBranch.Op != x86asm.JBE && rawBranch.Op != x86asm.JB) {
+			return false
+		}
+		for _, arg := range rawCmp.Args {
+			if mem, ok := arg.(x86asm.Mem); ok && mem.Disp == stackGuardOffset {
+				return true
+			}
+		}
+	case arm64asm.Inst:
+		// e.g. CMP R16, RSP; BLS
+		rawBranch, ok := branch.Raw.(arm64asm.Inst)
+		if !ok || rawCmp.Op != arm64asm.CMP || rawBranch.Op != arm64asm.B {
+			return false
+		}
+		if _, ok := rawBranch.Args[0].(arm64asm.Cond); !ok {
+			return false
+		}
+		for _, arg := range rawCmp.Args {
+			if reg, ok := arg.(arm64asm.RegSP); ok && arm64asm.Reg(reg) == arm64asm.SP {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// directCallTarget returns the address the instruction at `pc` calls. False if the instruction is not the direct call.
+func directCallTarget(inst Inst, pc uint64) (uint64, bool) {
+	if !inst.IsCall {
+		return 0, false
+	}
+
+	switch raw := inst.Raw.(type) {
+	case x86asm.Inst:
+		if rel, ok := raw.Args[0].(x86asm.Rel); ok {
+			return pc + uint64(inst.Len) + uint64(int64(rel)), true
+		}
+	case arm64asm.Inst:
+		if rel, ok := raw.Args[0].(arm64asm.PCRel); ok {
+			return pc + uint64(int64(rel)), true
+		}
+	}
+	return 0, false
+}
+
+// stackGuardOffset is the offset of the g.stackguard0.
+const stackGuardOffset = 0x10
+
+// X86_64Arch is the x86-64 architecture.
+type X86_64Arch struct{}
+
+// BreakpointInstruction returns the int3 instruction.
+func (X86_64Arch) BreakpointInstruction() []byte {
+	return []byte{0xcc}
+}
+
+// BreakpointAddr returns the pc - 1, because the pc points to the next instruction of the int3 when trapped.
+func (X86_64Arch) BreakpointAddr(pc uint64) uint64 {
+	return pc - 1
+}
+
+// DecodeInstruction decodes the instruction in 64-bit mode.
+func (X86_64Arch) DecodeInstruction(buff []byte) (Inst, error) {
+	inst, err := x86asm.Decode(buff, 64)
+	if err != nil {
+		if inst.Len == 0 {
+			// make sure the caller can skip the bad instruction.
+			inst.Len = 1
+		}
+		return Inst{Len: inst.Len}, err
+	}
+	return Inst{Len: inst.Len, IsCall: inst.Op == x86asm.CALL || inst.Op == x86asm.LCALL, Raw: inst}, nil
+}
+
+const arm64InstLen = 4
+
+// ARM64Arch is the arm64 (AArch64) architecture.
+// Note that the return address is held in the link register (x30) at the beginning of the function.
+type ARM64Arch struct{}
+
+// BreakpointInstruction returns the `BRK #0` instruction.
+func (ARM64Arch) BreakpointInstruction() []byte {
+	return []byte{0x00, 0x00, 0x20, 0xd4}
+}
+
+// BreakpointAddr returns the pc as it is, because the pc points to the BRK instruction itself when trapped.
+func (ARM64Arch) BreakpointAddr(pc uint64) uint64 {
+	return pc
+}
+
+// DecodeInstruction decodes the instruction. The length of the instruction is always 4 bytes.
+func (ARM64Arch) DecodeInstruction(buff []byte) (Inst, error) {
+	inst, err := arm64asm.Decode(buff)
+	if err != nil {
+		return Inst{Len: arm64InstLen}, err
+	}
+	return Inst{Len: arm64InstLen, IsCall: inst.Op == arm64asm.BL || inst.Op == arm64asm.BLR, Raw: inst}, nil
+}
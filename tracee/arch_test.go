@@ -0,0 +1,148 @@
+package tracee
+
+import "testing"
+
+func TestX86_64Arch_DecodeInstruction(t *testing.T) {
+	for i, testdata := range []struct {
+		input        []byte
+		expectLen    int
+		expectIsCall bool
+	}{
+		{input: []byte{0xe8, 0x00, 0x00, 0x00, 0x00}, expectLen: 5, expectIsCall: true}, // call
+		{input: []byte{0xc3}, expectLen: 1, expectIsCall: false},                        // ret
+	} {
+		inst, err := X86_64Arch{}.DecodeInstruction(testdata.input)
+		if err != nil {
+			t.Fatalf("[%d] failed to decode: %v", i, err)
+		}
+		if inst.Len != testdata.expectLen || inst.IsCall != testdata.expectIsCall {
+			t.Errorf("[%d] wrong inst: %#v", i, inst)
+		}
+	}
+}
+
+func TestARM64Arch_DecodeInstruction(t *testing.T) {
+	for i, testdata := range []struct {
+		input        []byte
+		expectIsCall bool
+	}{
+		{input: []byte{0x00, 0x00, 0x00, 0x94}, expectIsCall: true},  // bl
+		{input: []byte{0x00, 0x02, 0x3f, 0xd6}, expectIsCall: true},  // blr x16
+		{input: []byte{0xc0, 0x03, 0x5f, 0xd6}, expectIsCall: false}, // ret
+	} {
+		inst, err := ARM64Arch{}.DecodeInstruction(testdata.input)
+		if err != nil {
+			t.Fatalf("[%d] failed to decode: %v", i, err)
+		}
+		if inst.Len != 4 || inst.IsCall != testdata.expectIsCall {
+			t.Errorf("[%d] wrong inst: %#v", i, inst)
+		}
+	}
+}
+
+func TestIsStackCheck(t *testing.T) {
+	for i, testdata := range []struct {
+		arch   Arch
+		cmp    []byte
+		branch []byte
+		expect bool
+	}{
+		{arch: X86_64Arch{}, cmp: []byte{0x49, 0x3b, 0x66, 0x10}, branch: []byte{0x76, 0x10}, expect: true},             // cmp rsp, [r14+0x10]; jbe
+		{arch: X86_64Arch{}, cmp: []byte{0x4d, 0x3b, 0x66, 0x10}, branch: []byte{0x0f, 0x86, 0, 1, 0, 0}, expect: true}, // cmp r12, [r14+0x10]; jbe
+		{arch: X86_64Arch{}, cmp: []byte{0x48, 0x39, 0xc8}, branch: []byte{0x76, 0x10}, expect: false},                  // cmp rax, rcx; jbe
+		{arch: X86_64Arch{}, cmp: []byte{0x49, 0x3b, 0x66, 0x10}, branch: []byte{0xc3}, expect: false},                  // cmp rsp, [r14+0x10]; ret
+		{arch: ARM64Arch{}, cmp: []byte{0xff, 0x63, 0x30, 0xeb}, branch: []byte{0x09, 0x00, 0x00, 0x54}, expect: true},  // cmp sp, x16; b.ls
+		{arch: ARM64Arch{}, cmp: []byte{0x1f, 0x00, 0x01, 0xeb}, branch: []byte{0x09, 0x00, 0x00, 0x54}, expect: false}, // cmp x0, x1; b.ls
+	} {
+		cmp, err := testdata.arch.DecodeInstruction(testdata.cmp)
+		if err != nil {
+			t.Fatalf("[%d] failed to decode: %v", i, err)
+		}
+		branch, err := testdata.arch.DecodeInstruction(testdata.branch)
+		if err != nil {
+			t.Fatalf("[%d] failed to decode: %v", i, err)
+		}
+		if actual := isStackCheck(cmp, branch); actual != testdata.expect {
+			t.Errorf("[%d] wrong result: %v", i, actual)
+		}
+	}
+}
+
+func TestFindArchByName(t *testing.T) {
+	for i, testdata := range []struct {
+		name     string
+		expected Arch
+	}{
+		{name: "x86_64", expected: X86_64Arch{}},
+		{name: "arm64", expected: ARM64Arch{}},
+		{name: "aarch64", expected: ARM64Arch{}},
+	} {
+		arch, err := findArchByName(testdata.name)
+		if err != nil {
+			t.Fatalf("[%d] failed to find arch: %v", i, err)
+		}
+		if arch != testdata.expected {
+			t.Errorf("[%d] wrong arch: %#v", i, arch)
+		}
+	}
+
+	if _, err := findArchByName("i386"); err == nil {
+		t.Errorf("error not returned")
+	}
+}
+
+func TestBreakpointAddr(t *testing.T) {
+	if addr := (X86_64Arch{}).BreakpointAddr(0x1001); addr != 0x1000 {
+		t.Errorf("wrong x86_64 addr: %#x", addr)
+	}
+	if addr := (ARM64Arch{}).BreakpointAddr(0x1000); addr != 0x1000 {
+		t.Errorf("wrong arm64 addr: %#x", addr)
+	}
+}
+
+func TestDirectCallTarget(t *testing.T) {
+	for i, testdata := range []struct {
+		arch     Arch
+		input    []byte
+		pc       uint64
+		expected uint64
+		ok       bool
+	}{
+		{arch: X86_64Arch{}, input: []byte{0xe8, 0x10, 0x00, 0x00, 0x00}, pc: 0x1000, expected: 0x1015, ok: true}, // call +0x10
+		{arch: X86_64Arch{}, input: []byte{0xe8, 0xfb, 0xff, 0xff, 0xff}, pc: 0x1000, expected: 0x1000, ok: true}, // call -0x5
+		{arch: X86_64Arch{}, input: []byte{0xff, 0xd0}, pc: 0x1000, ok: false},                                    // call rax
+		{arch: X86_64Arch{}, input: []byte{0xc3}, pc: 0x1000, ok: false},                                          // ret
+		{arch: ARM64Arch{}, input: []byte{0x04, 0x00, 0x00, 0x94}, pc: 0x1000, expected: 0x1010, ok: true},        // bl +0x10
+		{arch: ARM64Arch{}, input: []byte{0x00, 0x02, 0x3f, 0xd6}, pc: 0x1000, ok: false},                         // blr x16
+	} {
+		inst, err := testdata.arch.DecodeInstruction(testdata.input)
+		if err != nil {
+			t.Fatalf("[%d] failed to decode: %v", i, err)
+		}
+		actual, ok := directCallTarget(inst, testdata.pc)
+		if ok != testdata.ok || actual != testdata.expected {
+			t.Errorf("[%d] wrong result: %x, %v", i, actual, ok)
+		}
+	}
+}
+
+func TestCalledOther(t *testing.T) {
+	function := &Function{Name: "main.f", StartAddr: 0x2000, EndAddr: 0x2100}
+	for i, testdata := range []struct {
+		input    []byte
+		callAddr uint64
+		expected bool
+	}{
+		{input: []byte{0xe8, 0xfb, 0x0f, 0x00, 0x00}, callAddr: 0x1000, expected: false}, // call main.f
+		{input: []byte{0xe8, 0xfb, 0x1f, 0x00, 0x00}, callAddr: 0x1000, expected: true},  // call the other function
+		{input: []byte{0xff, 0xd0}, callAddr: 0x1000, expected: false},                   // call rax
+	} {
+		inst, err := X86_64Arch{}.DecodeInstruction(testdata.input)
+		if err != nil {
+			t.Fatalf("[%d] failed to decode: %v", i, err)
+		}
+		if actual := calledOther(function, inst, testdata.callAddr); actual != testdata.expected {
+			t.Errorf("[%d] wrong result: %v", i, actual)
+		}
+	}
+}
@@ -2,12 +2,17 @@ package tracee
 
 import (
 	"debug/dwarf"
+	"debug/gosym"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 	"unicode"
 
 	"github.com/ks888/tgo/log"
@@ -17,6 +22,7 @@ const (
 	// AttrVariableParameter is the extended DWARF attribute. If true, the parameter is output. Else, it's input.
 	attrVariableParameter = 0x4b
 	attrGoRuntimeType     = 0x2904 // DW_AT_go_runtime_type
+	dwarfOpAddr           = 0x03   // DW_OP_addr
 	dwarfOpCallFrameCFA   = 0x9c   // DW_OP_call_frame_cfa
 	dwarfOpFbreg          = 0x91   // DW_OP_fbreg
 )
@@ -25,25 +31,85 @@ const (
 type BinaryFile interface {
 	// FindFunction returns the function info to which the given pc specifies.
 	FindFunction(pc uint64) (*Function, error)
+	// FindFunctionByName returns the function info which has the specified name.
+	FindFunctionByName(name string) (*Function, error)
+	// PCToFileLine returns the source file and line which the given pc is associated with.
+	PCToFileLine(pc uint64) (file string, line int, err error)
+	// FileLineToPC returns the lowest pc associated with the given source file and line.
+	FileLineToPC(file string, line int) (uint64, error)
+	// InlinedFunctionAt returns the innermost function inlined at the given pc.
+	InlinedFunctionAt(pc uint64) (*Function, error)
+	// ListFunctions returns all the functions in the binary, sorted by the start address. The parameters are not parsed.
+	ListFunctions() ([]*Function, error)
+	// IterateFunctions calls `fn` for each function in the binary as it's parsed, until `fn` returns false.
+	// The order is the one in the debug info (or the pcln table), which is typically but not necessarily the order of
+	// the start address. Unlike ListFunctions, the list of all the functions is not allocated. The parameters are not parsed.
+	IterateFunctions(fn func(*Function) bool) error
+	// ListMethods returns the methods of the named type, like 'http.Transport' or '*http.Transport', sorted by name.
+	// The package of the type can be either its name or path.
+	ListMethods(typeName string) ([]*Function, error)
+	// ListPackages returns the paths of the packages compiled into the binary, sorted alphabetically.
+	ListPackages() ([]string, error)
+	// IsStripped returns true if the binary has no DWARF info. The functions found in such binary have no parameter info.
+	IsStripped() bool
 	// Close closes the binary file.
 	Close() error
+	// Arch returns the architecture for which the binary is built.
+	Arch() Arch
+	// Clone returns the copy of the binary file. The copy shares the parsed data, but has its own file descriptor.
+	Clone() (BinaryFile, error)
+	// BuildID returns the go build ID embedded in the binary, such as 'abc/def/ghi/jkl'.
+	BuildID() (string, error)
+	// ReadProgramData reads the data which is loaded at the address from the program file, e.g. the instructions
+	// in the text section. The data written by the running process is not reflected.
+	ReadProgramData(addr uint64, buff []byte) error
 	// findDwarfTypeByAddr finds the dwarf.Type to which the given address specifies.
 	// The given address must be the address of the type (not value) and need to be adjusted
 	// using the moduledata.
 	findDwarfTypeByAddr(typeAddr uint64) (dwarf.Type, error)
 	// moduleDataType returns the dwarf.Type of runtime.moduledata struct type.
-	moduleDataType() dwarf.Type
+	// The version of the pcln table is used to choose the layout when DWARF is not available.
+	moduleDataType(version pclnTableVersion) dwarf.Type
 	// runtimeGType returns the dwarf.Type of runtime.g struct type.
 	runtimeGType() dwarf.Type
+	// findGlobalVariable returns the address and type of the global variable.
+	findGlobalVariable(name string) (uint64, dwarf.Type, error)
+	// labelMapType returns the dwarf.Type of runtime/pprof.labelMap type. Nil if the program doesn't use the profiler labels.
+	labelMapType() dwarf.Type
+	// inlinedFunctionsAt returns the functions inlined at the given pc, from the outermost to the innermost.
+	inlinedFunctionsAt(pc uint64) ([]*Function, error)
+	// localVariables returns the local variables of the function which are in scope at the given pc.
+	localVariables(pc uint64) ([]localVariable, error)
 }
 
 // debuggableBinaryFile represents the binary file with DWARF sections.
 type debuggableBinaryFile struct {
 	dwarf                dwarfData
+	path                 string
 	closer               io.Closer
+	arch                 Arch
+	registerABI          bool
 	types                map[uint64]dwarf.Offset
 	cachedRuntimeGType   dwarf.Type
 	cachedModuleDataType dwarf.Type
+	cachedLabelMapType   dwarf.Type
+	// functionIndex is built lazily because it requires to read all the subprograms.
+	functionIndex *functionIndex
+}
+
+// functionIndex is the index of the functions to find the function by address or name quickly.
+type functionIndex struct {
+	once sync.Once
+	// entries are sorted by the start address.
+	entries []functionEntry
+	byName  map[string]*functionEntry
+}
+
+type functionEntry struct {
+	StartAddr, EndAddr uint64
+	// Function doesn't have the parameters. They are parsed when the function is found.
+	Function *Function
+	offset   dwarf.Offset
 }
 
 type dwarfData struct {
@@ -60,6 +126,71 @@ type Function struct {
 	EndAddr uint64
 	// Parameters may be empty due to the lack of information.
 	Parameters []Parameter
+	// ABI is the calling convention the function uses.
+	ABI ABI
+	// Annotations holds the metadata found during the analysis, keyed by the canonical key such as AnnotationInlined.
+	// Nil if nothing is found. Use Annotate to set the value.
+	Annotations map[string]string
+}
+
+// Size returns the size of the function's code in bytes. 0 if the end address is unknown.
+func (f Function) Size() uint64 {
+	if f.EndAddr == 0 {
+		return 0
+	}
+	return f.EndAddr - f.StartAddr
+}
+
+// ABI represents the calling convention of the function.
+type ABI int
+
+const (
+	// ABIABI0 is the stack-based calling convention. Used by all the functions before go 1.17
+	// and by the assembly functions and the wrappers after that.
+	ABIABI0 ABI = iota
+	// ABIABIInternal is the register-based calling convention introduced in go 1.17.
+	ABIABIInternal
+)
+
+// String returns the name of the ABI used in the go toolchain.
+func (abi ABI) String() string {
+	switch abi {
+	case ABIABI0:
+		return "ABI0"
+	case ABIABIInternal:
+		return "ABIInternal"
+	}
+	return "ABI(" + strconv.Itoa(int(abi)) + ")"
+}
+
+// abi0Suffix is the suffix the linker adds to the ABI0 wrapper's name.
+const abi0Suffix = ".abi0"
+
+// registerABIAvailable returns true if the register-based calling convention is used by the binary.
+func registerABIAvailable(goVersion GoVersion, arch Arch) bool {
+	switch arch.(type) {
+	case X86_64Arch:
+		return goVersion.AtLeast(GoVersion{MajorVersion: 1, MinorVersion: 17})
+	case ARM64Arch:
+		return goVersion.AtLeast(GoVersion{MajorVersion: 1, MinorVersion: 18})
+	}
+	return false
+}
+
+// detectABI returns the ABI of the function. `isTrampoline` is true if the function is the wrapper of another function.
+func detectABI(name string, isTrampoline, registerABI bool) ABI {
+	if !registerABI || isTrampoline || strings.HasSuffix(name, abi0Suffix) {
+		return ABIABI0
+	}
+	return ABIABIInternal
+}
+
+// localVariable represents a local variable declared in the function.
+type localVariable struct {
+	name string
+	typ  dwarf.Type
+	// location is the DWARF location expression valid at the pc.
+	location []byte
 }
 
 // Parameter represents a parameter given to or the returned from the function.
@@ -71,18 +202,157 @@ type Parameter struct {
 	// Exist is false when the parameter is removed due to the optimization.
 	Exist    bool
 	IsOutput bool
+	// IsReceiver is true if the parameter is the receiver of the method.
+	IsReceiver bool
+	// location is the DWARF location expression valid at the beginning of the function. Nil if unknown.
+	location []byte
+}
+
+var (
+	// binaryCache holds the opened binary files to avoid parsing the same binary again.
+	binaryCache    = make(map[binaryCacheKey]*binaryCacheEntry)
+	binaryCacheMtx sync.Mutex // protects binaryCache and the reference counts of its entries
+)
+
+// The content ID of the build ID identifies the binary. The modification time and size are used only if
+// the build ID is not found, because the modification time is unreliable on some file systems.
+type binaryCacheKey struct {
+	path      string
+	contentID string
+	modTime   time.Time
+	size      int64
+	goVersion GoVersion
+}
+
+type binaryCacheEntry struct {
+	// ready is closed when the binary file is parsed. binaryFile and err are valid after that.
+	ready      chan struct{}
+	binaryFile BinaryFile
+	err        error
+	refCount   int
 }
 
 // OpenBinaryFile opens the specified program file.
+// If the same file is already opened and not modified since then, the cached one is returned.
+// The returned binary file shares the parsed data with the cached one, but has its own file descriptor.
 func OpenBinaryFile(pathToProgram string, goVersion GoVersion) (BinaryFile, error) {
-	return openBinaryFile(pathToProgram, goVersion)
+	key, err := newBinaryCacheKey(pathToProgram, goVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	binaryCacheMtx.Lock()
+	entry, ok := binaryCache[key]
+	if !ok {
+		entry = &binaryCacheEntry{ready: make(chan struct{})}
+		binaryCache[key] = entry
+	}
+	entry.refCount++
+	binaryCacheMtx.Unlock()
+
+	// the parse can take seconds for a large binary. Parse it outside the lock so that other binaries can be opened meanwhile.
+	if !ok {
+		entry.binaryFile, entry.err = openBinaryFile(pathToProgram, goVersion)
+		close(entry.ready)
+	} else {
+		<-entry.ready
+	}
+	if entry.err != nil {
+		releaseBinaryCacheEntry(key, entry)
+		return nil, entry.err
+	}
+
+	return newCachedBinaryFile(key, entry)
+}
+
+func newBinaryCacheKey(pathToProgram string, goVersion GoVersion) (binaryCacheKey, error) {
+	key := binaryCacheKey{path: pathToProgram, goVersion: goVersion}
+	if id, err := readBuildID(pathToProgram); err == nil {
+		_, key.contentID = splitBuildID(id)
+		return key, nil
+	}
+
+	stat, err := os.Stat(pathToProgram)
+	if err != nil {
+		return binaryCacheKey{}, err
+	}
+	key.modTime, key.size = stat.ModTime(), stat.Size()
+	return key, nil
+}
+
+// cachedBinaryFile is the reference to the cached binary file.
+type cachedBinaryFile struct {
+	BinaryFile
+	key    binaryCacheKey
+	entry  *binaryCacheEntry
+	closed bool
+}
+
+func newCachedBinaryFile(key binaryCacheKey, entry *binaryCacheEntry) (*cachedBinaryFile, error) {
+	binaryFile, err := entry.binaryFile.Clone()
+	if err != nil {
+		releaseBinaryCacheEntry(key, entry)
+		return nil, err
+	}
+	return &cachedBinaryFile{BinaryFile: binaryFile, key: key, entry: entry}, nil
+}
+
+// releaseBinaryCacheEntry drops the reference to the cache entry. The entry is evicted and its binary file is closed
+// when the last reference is dropped.
+func releaseBinaryCacheEntry(key binaryCacheKey, entry *binaryCacheEntry) error {
+	binaryCacheMtx.Lock()
+	defer binaryCacheMtx.Unlock()
+
+	entry.refCount--
+	if entry.refCount > 0 {
+		return nil
+	}
+
+	if binaryCache[key] == entry {
+		delete(binaryCache, key)
+	}
+	if entry.binaryFile == nil {
+		return nil
+	}
+	return entry.binaryFile.Close()
+}
+
+// Close closes the file and drops the reference to the cached binary file. The cached one is closed when the last reference is dropped.
+func (b *cachedBinaryFile) Close() error {
+	binaryCacheMtx.Lock()
+	if b.closed {
+		binaryCacheMtx.Unlock()
+		return nil
+	}
+	b.closed = true
+	binaryCacheMtx.Unlock()
+
+	err := b.BinaryFile.Close()
+	if releaseErr := releaseBinaryCacheEntry(b.key, b.entry); err == nil {
+		err = releaseErr
+	}
+	return err
+}
+
+// Clone returns another reference to the cached binary file, which has its own file descriptor.
+func (b *cachedBinaryFile) Clone() (BinaryFile, error) {
+	binaryCacheMtx.Lock()
+	if b.closed {
+		binaryCacheMtx.Unlock()
+		return nil, errors.New("already closed")
+	}
+	b.entry.refCount++
+	binaryCacheMtx.Unlock()
+
+	return newCachedBinaryFile(b.key, b.entry)
 }
 
-func newDebuggableBinaryFile(data dwarfData, goVersion GoVersion, closer io.Closer) (debuggableBinaryFile, error) {
-	binary := debuggableBinaryFile{dwarf: data, closer: closer}
+func newDebuggableBinaryFile(data dwarfData, goVersion GoVersion, closer io.Closer, arch Arch) (debuggableBinaryFile, error) {
+	binary := debuggableBinaryFile{dwarf: data, closer: closer, arch: arch, registerABI: registerABIAvailable(goVersion, arch), functionIndex: &functionIndex{}}
 
 	var err error
-	binary.types, err = binary.buildTypes(goVersion)
+	var labelMapTypeOffset dwarf.Offset
+	binary.types, labelMapTypeOffset, err = binary.buildTypes(goVersion)
 	if err != nil {
 		return debuggableBinaryFile{}, err
 	}
@@ -97,20 +367,41 @@ func newDebuggableBinaryFile(data dwarfData, goVersion GoVersion, closer io.Clos
 		return debuggableBinaryFile{}, err
 	}
 
+	// the type is not found if the program doesn't import the runtime/pprof package.
+	if labelMapTypeOffset != 0 {
+		if binary.cachedLabelMapType, err = binary.dwarf.Type(labelMapTypeOffset); err != nil {
+			log.Debugf("failed to read the labelMap type: %v", err)
+		}
+	}
+
 	return binary, nil
 }
 
-func (b debuggableBinaryFile) buildTypes(goVersion GoVersion) (map[uint64]dwarf.Offset, error) {
-	if !goVersion.LaterThan(GoVersion{MajorVersion: 1, MinorVersion: 11, PatchVersion: 0}) {
-		// attrGoRuntimeType is not supported
-		return nil, nil
+// buildTypes builds the map from the runtime type address to the offset of its DWARF entry.
+// It also finds the offset of the labelMap type in the same pass, because reading all the DWARF entries is costly.
+// The offset is 0 if the type is not found.
+func (b debuggableBinaryFile) buildTypes(goVersion GoVersion) (map[uint64]dwarf.Offset, dwarf.Offset, error) {
+	// attrGoRuntimeType is not supported before go 1.11
+	runtimeTypeSupported := !goVersion.OlderThan(GoVersion{MajorVersion: 1, MinorVersion: 11, PatchVersion: 0})
+	var types map[uint64]dwarf.Offset
+	if runtimeTypeSupported {
+		types = make(map[uint64]dwarf.Offset)
 	}
-	types := make(map[uint64]dwarf.Offset)
+	var labelMapTypeOffset dwarf.Offset
 	reader := b.dwarf.Reader()
 	for {
 		entry, err := reader.Next()
 		if err != nil || entry == nil {
-			return types, err
+			return types, labelMapTypeOffset, err
+		}
+
+		if labelMapTypeOffset == 0 && (entry.Tag == dwarf.TagStructType || entry.Tag == dwarf.TagTypedef) {
+			if name, err := stringClassAttr(entry, dwarf.AttrName); err == nil && name == labelMapTypeName {
+				labelMapTypeOffset = entry.Offset
+			}
+		}
+		if !runtimeTypeSupported {
+			continue
 		}
 
 		switch entry.Tag {
@@ -133,6 +424,8 @@ func (b debuggableBinaryFile) findModuleDataType() (dwarf.Type, error) {
 
 const gTypeName = "runtime.g"
 
+const labelMapTypeName = "runtime/pprof.labelMap"
+
 func (b debuggableBinaryFile) findRuntimeGType() (dwarf.Type, error) {
 	return b.findType(dwarf.TagStructType, gTypeName)
 }
@@ -170,8 +463,540 @@ func (b debuggableBinaryFile) findDWARFEntryByName(match func(*dwarf.Entry) bool
 
 // FindFunction looks up the function info described in the debug info section.
 func (b debuggableBinaryFile) FindFunction(pc uint64) (*Function, error) {
-	reader := subprogramReader{raw: b.dwarf.Reader(), dwarfData: b.dwarf}
-	return reader.Seek(pc)
+	entry, err := b.findFunctionEntry(pc)
+	if err != nil {
+		return nil, err
+	}
+	return b.buildFunctionFromEntry(entry)
+}
+
+func (b debuggableBinaryFile) findFunctionEntry(pc uint64) (functionEntry, error) {
+	entries := b.buildFunctionIndex().entries
+	i := sort.Search(len(entries), func(i int) bool { return pc < entries[i].StartAddr }) - 1
+	if i < 0 || entries[i].EndAddr <= pc {
+		return functionEntry{}, fmt.Errorf("function not found at %#x", pc)
+	}
+	return entries[i], nil
+}
+
+// FindFunctionByName looks up the function info using its name.
+func (b debuggableBinaryFile) FindFunctionByName(name string) (*Function, error) {
+	entry, ok := b.buildFunctionIndex().byName[name]
+	if !ok {
+		return nil, fmt.Errorf("function %s not found", name)
+	}
+	return b.buildFunctionFromEntry(*entry)
+}
+
+// PCToFileLine returns the source file and line using the line table.
+func (b debuggableBinaryFile) PCToFileLine(pc uint64) (string, int, error) {
+	compileUnit, err := b.dwarf.Reader().SeekPC(pc)
+	if err != nil {
+		return "", 0, fmt.Errorf("%#x: %w", pc, err)
+	}
+
+	lineReader, err := b.dwarf.LineReader(compileUnit)
+	if err != nil {
+		return "", 0, fmt.Errorf("%#x: %w", pc, err)
+	} else if lineReader == nil {
+		return "", 0, fmt.Errorf("%#x: no line table", pc)
+	}
+
+	var lineEntry dwarf.LineEntry
+	if err := lineReader.SeekPC(pc, &lineEntry); err != nil {
+		return "", 0, fmt.Errorf("%#x: %w", pc, err)
+	}
+	return lineEntry.File.Name, lineEntry.Line, nil
+}
+
+// FileLineToPC returns the lowest pc associated with the given source file and line.
+// The `file` matches the file in the line table if it's the suffix of the path, e.g. `server.go` or `pkg/server.go`.
+func (b debuggableBinaryFile) FileLineToPC(file string, line int) (uint64, error) {
+	var matchedFile string
+	var pc uint64
+	reader := b.dwarf.Reader()
+	for {
+		compileUnit, err := reader.Next()
+		if err != nil {
+			return 0, err
+		} else if compileUnit == nil {
+			break
+		}
+		if compileUnit.Tag != dwarf.TagCompileUnit {
+			reader.SkipChildren()
+			continue
+		}
+		reader.SkipChildren()
+
+		lineReader, err := b.dwarf.LineReader(compileUnit)
+		if err != nil {
+			return 0, err
+		} else if lineReader == nil {
+			continue
+		}
+
+		var lineEntry dwarf.LineEntry
+		for lineReader.Next(&lineEntry) == nil {
+			if lineEntry.File == nil || !matchFilePath(lineEntry.File.Name, file) {
+				continue
+			}
+
+			if matchedFile == "" {
+				matchedFile = lineEntry.File.Name
+			} else if matchedFile != lineEntry.File.Name {
+				return 0, fmt.Errorf("%s is ambiguous: %s and %s", file, matchedFile, lineEntry.File.Name)
+			}
+
+			if lineEntry.Line == line && lineEntry.IsStmt && (pc == 0 || lineEntry.Address < pc) {
+				pc = lineEntry.Address
+			}
+		}
+	}
+
+	if matchedFile == "" {
+		return 0, fmt.Errorf("file %s not found", file)
+	} else if pc == 0 {
+		return 0, fmt.Errorf("no code at %s:%d. It may be optimized out", matchedFile, line)
+	}
+	return pc, nil
+}
+
+// InlinedFunctionAt returns the innermost function inlined at the given pc.
+func (b debuggableBinaryFile) InlinedFunctionAt(pc uint64) (*Function, error) {
+	functions, err := b.inlinedFunctionsAt(pc)
+	if err != nil {
+		return nil, err
+	} else if len(functions) == 0 {
+		return nil, fmt.Errorf("no inlined function at %#x", pc)
+	}
+	return functions[len(functions)-1], nil
+}
+
+// inlinedFunctionsAt walks the inlined subroutine entries of the function which contains the pc.
+// The inlined functions don't have the parameters because they have no own stack frame.
+func (b debuggableBinaryFile) inlinedFunctionsAt(pc uint64) ([]*Function, error) {
+	functionEntry, err := b.findFunctionEntry(pc)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := b.dwarf.Reader()
+	reader.Seek(functionEntry.offset)
+	subprogram, err := reader.Next()
+	if err != nil {
+		return nil, err
+	} else if !subprogram.Children {
+		return nil, nil
+	}
+
+	var functions []*Function
+	for depth := 1; depth > 0; {
+		entry, err := reader.Next()
+		if err != nil {
+			return nil, err
+		} else if entry == nil {
+			break
+		}
+
+		switch entry.Tag {
+		case 0:
+			depth--
+			continue
+		case dwarf.TagInlinedSubroutine, dwarf.TagLexDwarfBlock:
+			ranges, err := b.dwarf.Ranges(entry)
+			if err != nil || !rangesInclude(ranges, pc) {
+				break
+			}
+
+			if entry.Tag == dwarf.TagInlinedSubroutine {
+				function, err := b.buildInlinedFunction(entry, ranges)
+				if err != nil {
+					return nil, err
+				}
+				functions = append(functions, function)
+			}
+			if entry.Children {
+				depth++
+			}
+			continue
+		}
+
+		if entry.Children {
+			reader.SkipChildren()
+		}
+	}
+	return functions, nil
+}
+
+func (b debuggableBinaryFile) buildInlinedFunction(entry *dwarf.Entry, ranges [][2]uint64) (*Function, error) {
+	var name string
+	err := walkUpOrigins(entry, b.dwarf.Data, func(entry *dwarf.Entry) bool {
+		var err error
+		name, err = stringClassAttr(entry, dwarf.AttrName)
+		return err == nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("name attr of the inlined subroutine at %#x not found", entry.Offset)
+	}
+
+	function := &Function{Name: name, StartAddr: ranges[0][0], EndAddr: ranges[0][1]}
+	function.annotateBool(AnnotationInlined, true)
+	for _, r := range ranges[1:] {
+		if r[0] < function.StartAddr {
+			function.StartAddr = r[0]
+		}
+		if r[1] > function.EndAddr {
+			function.EndAddr = r[1]
+		}
+	}
+	return function, nil
+}
+
+// localVariables walks the variable entries of the function which contains the pc.
+// The variables in the lexical blocks are included only if the block contains the pc. The variables of the inlined
+// functions and the variables whose location is unknown at the pc are excluded.
+func (b debuggableBinaryFile) localVariables(pc uint64) ([]localVariable, error) {
+	functionEntry, err := b.findFunctionEntry(pc)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := b.dwarf.Reader()
+	reader.Seek(functionEntry.offset)
+	subprogram, err := reader.Next()
+	if err != nil {
+		return nil, err
+	} else if !subprogram.Children {
+		return nil, nil
+	}
+
+	var variables []localVariable
+	for depth := 1; depth > 0; {
+		entry, err := reader.Next()
+		if err != nil {
+			return nil, err
+		} else if entry == nil {
+			break
+		}
+
+		switch entry.Tag {
+		case 0:
+			depth--
+			continue
+		case dwarf.TagLexDwarfBlock:
+			ranges, err := b.dwarf.Ranges(entry)
+			if err == nil && rangesInclude(ranges, pc) && entry.Children {
+				depth++
+				continue
+			}
+		case dwarf.TagVariable:
+			variable, err := b.buildLocalVariable(entry, pc)
+			if err != nil {
+				log.Debugf("failed to build the local variable at %#x: %v", entry.Offset, err)
+			} else if variable != nil {
+				variables = append(variables, *variable)
+			}
+		}
+
+		if entry.Children {
+			reader.SkipChildren()
+		}
+	}
+	return variables, nil
+}
+
+// buildLocalVariable returns nil if the location of the variable is unknown at the pc.
+func (b debuggableBinaryFile) buildLocalVariable(entry *dwarf.Entry, pc uint64) (*localVariable, error) {
+	var name string
+	var typeOffset dwarf.Offset
+	err := walkUpOrigins(entry, b.dwarf.Data, func(entry *dwarf.Entry) bool {
+		var err error
+		name, err = stringClassAttr(entry, dwarf.AttrName)
+		if err != nil {
+			return false
+		}
+
+		typeOffset, err = referenceClassAttr(entry, dwarf.AttrType)
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := b.variableLocationDesc(entry, pc)
+	if err != nil || len(loc) == 0 {
+		return nil, err
+	}
+
+	variable := &localVariable{name: name, location: loc}
+	variable.typ, err = b.dwarf.Type(typeOffset)
+	return variable, err
+}
+
+// variableLocationDesc returns the location description of the variable valid at the pc. Returns nil if not found.
+func (b debuggableBinaryFile) variableLocationDesc(entry *dwarf.Entry, pc uint64) ([]byte, error) {
+	if loc, err := locationClassAttr(entry, dwarf.AttrLocation); err == nil {
+		return loc, nil
+	}
+
+	if b.dwarf.locationList == nil {
+		return nil, errors.New("no location list section")
+	}
+	offset, err := locationListClassAttr(entry, dwarf.AttrLocation)
+	if err != nil {
+		return nil, fmt.Errorf("loc attr not found: %w", err)
+	}
+
+	locList := buildLocationList(b.dwarf.locationList, int(offset))
+	return locList.find(pc), nil
+}
+
+// rangesInclude returns true if one of the [low, high) ranges includes the pc.
+func rangesInclude(ranges [][2]uint64, pc uint64) bool {
+	for _, r := range ranges {
+		if r[0] <= pc && pc < r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// matchFilePath returns true if the `path` is same as `suffix` or the `suffix` is its suffix on the path boundary.
+func matchFilePath(path, suffix string) bool {
+	return path == suffix || strings.HasSuffix(path, "/"+suffix)
+}
+
+func (b debuggableBinaryFile) buildFunctionIndex() *functionIndex {
+	b.functionIndex.once.Do(func() {
+		err := b.iterateSubprograms(func(function *Function, offset dwarf.Offset) bool {
+			b.functionIndex.entries = append(b.functionIndex.entries, functionEntry{StartAddr: function.StartAddr, EndAddr: function.EndAddr, Function: function, offset: offset})
+			return true
+		})
+		if err != nil {
+			log.Debugf("failed to read the next entry: %v", err)
+		}
+
+		entries := b.functionIndex.entries
+		sort.Slice(entries, func(i, j int) bool { return entries[i].StartAddr < entries[j].StartAddr })
+
+		b.functionIndex.byName = make(map[string]*functionEntry, len(b.functionIndex.entries))
+		for i, entry := range b.functionIndex.entries {
+			b.functionIndex.byName[entry.Function.Name] = &b.functionIndex.entries[i]
+		}
+	})
+	return b.functionIndex
+}
+
+// iterateSubprograms parses the subprograms one by one and calls `fn` with the function and the offset of its entry,
+// until `fn` returns false. Only the top-level entries of the compile units are read, and the parameters are not parsed.
+func (b debuggableBinaryFile) iterateSubprograms(fn func(function *Function, offset dwarf.Offset) bool) error {
+	reader := b.dwarf.Reader()
+	subprogramReader := subprogramReader{raw: reader, dwarfData: b.dwarf, registerABI: b.registerABI}
+	for {
+		entry, err := reader.Next()
+		if err != nil {
+			return err
+		} else if entry == nil {
+			return nil
+		}
+
+		if entry.Tag == dwarf.TagCompileUnit {
+			continue
+		}
+		// the children are the parameters, variables and inlined subroutines. They are read when the function is found.
+		reader.SkipChildren()
+
+		if entry.Tag != dwarf.TagSubprogram || subprogramReader.isInline(entry) {
+			continue
+		}
+
+		function, err := subprogramReader.buildFunction(entry)
+		if err != nil {
+			// the subprogram may be the declaration only.
+			continue
+		}
+		if !fn(function, entry.Offset) {
+			return nil
+		}
+	}
+}
+
+// buildFunctionFromEntry returns the copy of the indexed function with its parameters.
+func (b debuggableBinaryFile) buildFunctionFromEntry(entry functionEntry) (*Function, error) {
+	reader := subprogramReader{raw: b.dwarf.Reader(), dwarfData: b.dwarf, registerABI: b.registerABI}
+	reader.raw.Seek(entry.offset)
+	subprogram, err := reader.raw.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	function := *entry.Function
+	if subprogram.Children {
+		err = reader.setParameters(&function, subprogram)
+	}
+	return &function, err
+}
+
+// ListPackages lists the packages using the compile units. The go linker creates one compile unit per package
+// and its name is the package path.
+func (b debuggableBinaryFile) ListPackages() ([]string, error) {
+	const dwarfLangGo = 0x16 // DW_LANG_Go
+
+	var pkgs []string
+	reader := b.dwarf.Reader()
+	for {
+		entry, err := reader.Next()
+		if err != nil {
+			return nil, err
+		} else if entry == nil {
+			break
+		}
+		if entry.Tag != dwarf.TagCompileUnit {
+			reader.SkipChildren()
+			continue
+		}
+
+		lang, _ := entry.Val(dwarf.AttrLanguage).(int64)
+		name, _ := entry.Val(dwarf.AttrName).(string)
+		if lang == dwarfLangGo && name != "" {
+			pkgs = append(pkgs, name)
+		}
+		reader.SkipChildren()
+	}
+	return uniqueSortedStrings(pkgs), nil
+}
+
+// ListFunctions lists the functions in the DWARF info. The index is not used, so the returned functions are not shared.
+func (b debuggableBinaryFile) ListFunctions() ([]*Function, error) {
+	var functions []*Function
+	err := b.IterateFunctions(func(f *Function) bool {
+		functions = append(functions, f)
+		return true
+	})
+	sort.Slice(functions, func(i, j int) bool { return functions[i].StartAddr < functions[j].StartAddr })
+	return functions, err
+}
+
+// IterateFunctions parses the functions in the DWARF info one by one, rather than reads the index.
+// So the iteration can stop before all the subprograms are read, and the parsed functions are not kept.
+func (b debuggableBinaryFile) IterateFunctions(fn func(*Function) bool) error {
+	return b.iterateSubprograms(func(function *Function, offset dwarf.Offset) bool {
+		return fn(function)
+	})
+}
+
+// ListMethods lists the methods using the names of the indexed functions. The go compiler doesn't emit
+// DW_AT_object_pointer, but the method name contains its receiver type, like 'net/http.(*Transport).RoundTrip'.
+func (b debuggableBinaryFile) ListMethods(typeName string) ([]*Function, error) {
+	var methods []*Function
+	for _, entry := range b.buildFunctionIndex().entries {
+		if !isMethodOf(entry.Function.Name, typeName) {
+			continue
+		}
+		method, err := b.buildFunctionFromEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, method)
+	}
+	return sortMethods(typeName, methods)
+}
+
+// isMethodOf returns true if the function is the method of the type, whether the receiver is the pointer or not.
+func isMethodOf(funcName, typeName string) bool {
+	typeName = strings.TrimPrefix(typeName, "*")
+	i := strings.LastIndex(typeName, ".")
+	if i < 0 {
+		return false
+	}
+	pkg, typ := typeName[:i], typeName[i+1:]
+
+	funcPkg, receiver, _, ok := parseMethodName(funcName)
+	if !ok || (funcPkg != pkg && !strings.HasSuffix(funcPkg, "/"+pkg)) {
+		return false
+	}
+	return strings.TrimPrefix(receiver, "*") == typ
+}
+
+// parseMethodName splits the method name like 'net/http.(*Transport).RoundTrip' into the package path,
+// the receiver type ('*Transport') and the method name. ok is false if the function is not the method.
+func parseMethodName(funcName string) (pkgPath, receiver, method string, ok bool) {
+	// The package path may contain dots, but its last element doesn't, except the ones in the package name.
+	pkgPathEnd := strings.LastIndex(funcName, "/") + 1
+	i := strings.Index(funcName[pkgPathEnd:], ".")
+	if i < 0 {
+		return "", "", "", false
+	}
+	pkgPath, remaining := funcName[:pkgPathEnd+i], funcName[pkgPathEnd+i+1:]
+
+	if strings.HasPrefix(remaining, "(*") {
+		j := strings.Index(remaining, ").")
+		if j < 0 {
+			return "", "", "", false
+		}
+		receiver, method = "*"+remaining[2:j], remaining[j+2:]
+	} else {
+		j := strings.Index(remaining, ".")
+		if j < 0 {
+			return "", "", "", false // the function, not method
+		}
+		receiver, method = remaining[:j], remaining[j+1:]
+		if isClosureName(method) {
+			return "", "", "", false // the closure in the function, like 'main.main.func1'
+		}
+	}
+
+	// excludes the closures in the method and the method value wrappers.
+	if method == "" || strings.Contains(method, ".") || strings.HasSuffix(method, "-fm") {
+		return "", "", "", false
+	}
+	return pkgPath, receiver, method, true
+}
+
+// isClosureName returns true if the name is the one the compiler gives to the closure, like 'func1'.
+func isClosureName(name string) bool {
+	for _, prefix := range []string{"func", "gowrap", "deferwrap"} {
+		if strings.HasPrefix(name, prefix) {
+			_, err := strconv.Atoi(strings.TrimPrefix(name, prefix))
+			return err == nil
+		}
+	}
+	return false
+}
+
+func sortMethods(typeName string, methods []*Function) ([]*Function, error) {
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("no methods of the type %s found", typeName)
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i].Name < methods[j].Name })
+	return methods, nil
+}
+
+func uniqueSortedStrings(strs []string) []string {
+	sort.Strings(strs)
+	var uniqueStrs []string
+	for i, str := range strs {
+		if i > 0 && strs[i-1] == str {
+			continue
+		}
+		uniqueStrs = append(uniqueStrs, str)
+	}
+	return uniqueStrs
+}
+
+// IsStripped returns false because the binary has the DWARF info.
+func (b debuggableBinaryFile) IsStripped() bool {
+	return false
+}
+
+// BuildID returns the go build ID embedded in the binary.
+func (b debuggableBinaryFile) BuildID() (string, error) {
+	return readBuildID(b.path)
+}
+
+// ReadProgramData reads the data loaded at the address from the program file.
+func (b debuggableBinaryFile) ReadProgramData(addr uint64, buff []byte) error {
+	return readProgramData(b.path, addr, buff)
 }
 
 // Close releases the resources associated with the binary.
@@ -179,12 +1004,27 @@ func (b debuggableBinaryFile) Close() error {
 	return b.closer.Close()
 }
 
+// Arch returns the architecture for which the binary is built.
+func (b debuggableBinaryFile) Arch() Arch {
+	return b.arch
+}
+
+// Clone returns the copy of the binary file. The copy shares the parsed data, but opens the file again to have its own file descriptor.
+func (b debuggableBinaryFile) Clone() (BinaryFile, error) {
+	file, err := os.Open(b.path)
+	if err != nil {
+		return nil, err
+	}
+	b.closer = file
+	return b, nil
+}
+
 func (b debuggableBinaryFile) findDwarfTypeByAddr(typeAddr uint64) (dwarf.Type, error) {
 	implTypOffset := b.types[typeAddr]
 	return b.dwarf.Type(implTypOffset)
 }
 
-func (b debuggableBinaryFile) moduleDataType() dwarf.Type {
+func (b debuggableBinaryFile) moduleDataType(version pclnTableVersion) dwarf.Type {
 	return b.cachedModuleDataType
 }
 
@@ -192,6 +1032,38 @@ func (b debuggableBinaryFile) runtimeGType() dwarf.Type {
 	return b.cachedRuntimeGType
 }
 
+func (b debuggableBinaryFile) labelMapType() dwarf.Type {
+	return b.cachedLabelMapType
+}
+
+func (b debuggableBinaryFile) findGlobalVariable(name string) (uint64, dwarf.Type, error) {
+	entry, err := b.findDWARFEntryByName(func(entry *dwarf.Entry) bool {
+		if entry.Tag != dwarf.TagVariable {
+			return false
+		}
+		entryName, err := stringClassAttr(entry, dwarf.AttrName)
+		return entryName == name && err == nil
+	})
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	loc, err := locationClassAttr(entry, dwarf.AttrLocation)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s: %w", name, err)
+	} else if len(loc) != 9 || loc[0] != dwarfOpAddr {
+		return 0, nil, fmt.Errorf("%s: unexpected location description: %v", name, loc)
+	}
+	addr := binary.LittleEndian.Uint64(loc[1:])
+
+	typeOffset, err := referenceClassAttr(entry, dwarf.AttrType)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s: %w", name, err)
+	}
+	typ, err := b.dwarf.Type(typeOffset)
+	return addr, typ, err
+}
+
 // IsExported returns true if the function is exported.
 // See https://golang.org/ref/spec#Exported_identifiers for the spec.
 func (f Function) IsExported() bool {
@@ -202,9 +1074,31 @@ func (f Function) IsExported() bool {
 	return false
 }
 
+// IsMethod returns true if the function is the method. It's detected from the function name because
+// the go compiler doesn't emit DW_AT_object_pointer.
+func (f Function) IsMethod() bool {
+	_, _, _, ok := parseMethodName(f.Name)
+	return ok
+}
+
+// ReceiverTypeName returns the receiver type name of the method, like '*http.Transport'. Empty if not the method.
+func (f Function) ReceiverTypeName() string {
+	pkgPath, receiver, _, ok := parseMethodName(f.Name)
+	if !ok {
+		return ""
+	}
+	pkgName := pkgPath[strings.LastIndex(pkgPath, "/")+1:]
+	if strings.HasPrefix(receiver, "*") {
+		return "*" + pkgName + "." + receiver[1:]
+	}
+	return pkgName + "." + receiver
+}
+
 type subprogramReader struct {
 	raw       *dwarf.Reader
 	dwarfData dwarfData
+	// registerABI is true if the functions may use the register-based calling convention.
+	registerABI bool
 }
 
 func (r subprogramReader) Next(setParameters bool) (*Function, error) {
@@ -224,7 +1118,7 @@ func (r subprogramReader) Next(setParameters bool) (*Function, error) {
 		}
 
 		if setParameters {
-			function.Parameters, err = r.parameters()
+			err = r.setParameters(function, entry)
 		}
 		return function, err
 
@@ -256,7 +1150,7 @@ func (r subprogramReader) Seek(pc uint64) (*Function, error) {
 			return nil, err
 		}
 
-		function.Parameters, err = r.parameters()
+		err = r.setParameters(function, subprogram)
 		return function, err
 	}
 }
@@ -296,28 +1190,61 @@ func (r subprogramReader) buildFunction(subprogram *dwarf.Entry) (*Function, err
 
 	lowPC, err := addressClassAttr(subprogram, dwarf.AttrLowpc)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %v", name, err)
+		return nil, fmt.Errorf("%s: %w", name, err)
 	}
 
 	highPC, err := addressClassAttr(subprogram, dwarf.AttrHighpc)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %v", name, err)
+		return nil, fmt.Errorf("%s: %w", name, err)
 	}
 
 	frameBase, err := locationClassAttr(subprogram, dwarf.AttrFrameBase)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %v", name, err)
+		return nil, fmt.Errorf("%s: %w", name, err)
 	} else if len(frameBase) != 1 || frameBase[0] != dwarfOpCallFrameCFA {
 		log.Printf("The frame base attribute of %s has the unexpected value. The parameter values may be wrong.", name)
 	}
 
-	return &Function{Name: name, StartAddr: lowPC, EndAddr: highPC}, nil
+	isTrampoline := subprogram.AttrField(dwarf.AttrTrampoline) != nil
+	abi := detectABI(name, isTrampoline, r.registerABI)
+	function := &Function{Name: name, StartAddr: lowPC, EndAddr: highPC, ABI: abi}
+	function.Annotate(AnnotationABI, abi.String())
+	function.annotateBool(AnnotationTrampoline, isTrampoline)
+	return function, nil
 }
 
-func (r subprogramReader) parameters() ([]Parameter, error) {
+// setParameters reads the parameters of the subprogram and marks the receiver.
+func (r subprogramReader) setParameters(function *Function, subprogram *dwarf.Entry) error {
+	objectPointer, _ := referenceClassAttr(subprogram, dwarf.AttrObjectPointer)
+	params, err := r.parameters(objectPointer, function.StartAddr)
+	if err != nil {
+		return err
+	}
+	function.Parameters = params
+
+	for _, param := range params {
+		if param.IsReceiver {
+			return nil
+		}
+	}
+	// The go compiler doesn't emit DW_AT_object_pointer. The receiver is the first input parameter of the method.
+	if function.IsMethod() {
+		for i := range function.Parameters {
+			if !function.Parameters[i].IsOutput {
+				function.Parameters[i].IsReceiver = true
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// parameters reads the parameters. The parameter whose offset is same as the objectPointer is the receiver.
+// The locations are the ones at the `startPC`, the beginning of the function.
+func (r subprogramReader) parameters(objectPointer dwarf.Offset, startPC uint64) ([]Parameter, error) {
 	var params []Parameter
 	for {
-		param, err := r.nextParameter()
+		param, err := r.nextParameter(objectPointer, startPC)
 		if err != nil || param == nil {
 			// the parameters are sorted by the name.
 			sort.Slice(params, func(i, j int) bool { return params[i].Offset < params[j].Offset })
@@ -329,7 +1256,7 @@ func (r subprogramReader) parameters() ([]Parameter, error) {
 	}
 }
 
-func (r subprogramReader) nextParameter() (*Parameter, error) {
+func (r subprogramReader) nextParameter(objectPointer dwarf.Offset, startPC uint64) (*Parameter, error) {
 	for {
 		param, err := r.raw.Next()
 		if err != nil || param.Tag == 0 {
@@ -341,11 +1268,15 @@ func (r subprogramReader) nextParameter() (*Parameter, error) {
 			continue
 		}
 
-		return r.buildParameter(param)
+		parameter, err := r.buildParameter(param, startPC)
+		if parameter != nil && objectPointer != 0 {
+			parameter.IsReceiver = param.Offset == objectPointer
+		}
+		return parameter, err
 	}
 }
 
-func (r subprogramReader) buildParameter(param *dwarf.Entry) (*Parameter, error) {
+func (r subprogramReader) buildParameter(param *dwarf.Entry, startPC uint64) (*Parameter, error) {
 	var name string
 	var typeOffset dwarf.Offset
 	var isOutput bool
@@ -373,73 +1304,62 @@ func (r subprogramReader) buildParameter(param *dwarf.Entry) (*Parameter, error)
 		return nil, err
 	}
 
-	offset, exist, err := r.findLocation(param)
-	return &Parameter{Name: name, Typ: typ, Offset: offset, IsOutput: isOutput, Exist: exist}, err
-}
-
-func (r subprogramReader) findLocation(param *dwarf.Entry) (offset int, exist bool, err error) {
-	offset, exist, err = r.findLocationByLocationDesc(param)
-	if err != nil && r.dwarfData.locationList != nil {
-		offset, exist, err = r.findLocationByLocationList(param)
-	}
-	return
-}
-
-func (r subprogramReader) findLocationByLocationDesc(param *dwarf.Entry) (offset int, exist bool, err error) {
-	loc, err := locationClassAttr(param, dwarf.AttrLocation)
+	loc, err := r.findLocation(param, startPC)
 	if err != nil {
-		return 0, false, fmt.Errorf("loc attr not found: %v", err)
+		return nil, fmt.Errorf("loc attr not found: %w", err)
 	}
 
+	parameter := &Parameter{Name: name, Typ: typ, IsOutput: isOutput}
 	if len(loc) == 0 {
 		// the location description may be empty due to the optimization (see the DWARF spec 2.6.1.1.4)
-		return 0, false, nil
+		return parameter, nil
 	}
 
-	offset, err = parseLocationDesc(loc)
+	parameter.Offset, err = parseLocationDesc(loc)
 	if err != nil {
 		log.Debugf("failed to parse location description at %#x: %v", param.Offset, err)
+		return parameter, nil
 	}
-	return offset, err == nil, nil
+	parameter.Exist, parameter.location = true, loc
+	return parameter, nil
+}
+
+// findLocation returns the location description of the parameter at the beginning of the function.
+// The description is empty if the parameter doesn't exist due to the optimization.
+func (r subprogramReader) findLocation(param *dwarf.Entry, startPC uint64) (loc []byte, err error) {
+	loc, err = locationClassAttr(param, dwarf.AttrLocation)
+	if err != nil && r.dwarfData.locationList != nil {
+		loc, err = r.findLocationByLocationList(param, startPC)
+	}
+	return
 }
 
 // parseLocationDesc returns the offset from the beginning of the parameter list.
-// It assumes the value is present in the memory and not separated.
-// Also, it's supposed the function's frame base always specifies to the CFA.
+// It assumes the value is present in the memory and the function's frame base always specifies to the CFA.
 func parseLocationDesc(loc []byte) (int, error) {
-	if len(loc) == 0 {
-		return 0, errors.New("location description is empty")
+	if len(loc) > 0 && loc[0] == dwarfOpAddr {
+		return 0, errors.New("not the stack-relative location")
 	}
 
-	// TODO: support the value in the register and the separated value.
-	switch loc[0] {
-	case dwarfOpCallFrameCFA:
-		return 0, nil
-	case dwarfOpFbreg:
-		return decodeSignedLEB128(loc[1:]), nil
-	default:
-		return 0, fmt.Errorf("unknown operation: %#x", loc[0])
-	}
+	// the beginning of the parameter list is the CFA.
+	addr, err := dwarfLocationEval{}.eval(loc)
+	return int(addr), err
 }
 
-func (r subprogramReader) findLocationByLocationList(param *dwarf.Entry) (int, bool, error) {
+// findLocationByLocationList returns the location description of the location list entry which covers the pc.
+// The description is empty if no entry covers the pc.
+func (r subprogramReader) findLocationByLocationList(param *dwarf.Entry, pc uint64) ([]byte, error) {
 	loc, err := locationListClassAttr(param, dwarf.AttrLocation)
 	if err != nil {
-		return 0, false, fmt.Errorf("loc list attr not found: %v", err)
+		return nil, fmt.Errorf("loc list attr not found: %w", err)
 	}
 
 	locList := buildLocationList(r.dwarfData.locationList, int(loc))
 	if len(locList.locListEntries) == 0 {
-		return 0, false, errors.New("no location list entry")
+		return nil, errors.New("no location list entry")
 	}
 
-	// TODO: it's more precise to choose the right location list entry using PC and address offsets.
-	//       Usually the first entry specifies to the right location in our use case, though.
-	offset, err := parseLocationDesc(locList.locListEntries[0].locationDesc)
-	if err != nil {
-		log.Debugf("failed to parse location list at %#x: %v", param.Offset, err)
-	}
-	return offset, err == nil, nil
+	return locList.find(pc), nil
 }
 
 type locationList struct {
@@ -452,6 +1372,18 @@ type locationListEntry struct {
 	locationDesc           []byte
 }
 
+// find returns the location description of the entry which covers the pc. Nil if not found.
+func (l locationList) find(pc uint64) []byte {
+	for _, locListEntry := range l.locListEntries {
+		begin := l.baseAddress + uint64(locListEntry.beginOffset)
+		end := l.baseAddress + uint64(locListEntry.endOffset)
+		if begin <= pc && pc < end {
+			return locListEntry.locationDesc
+		}
+	}
+	return nil
+}
+
 func buildLocationList(locSectionData []byte, offset int) (locList locationList) {
 	for {
 		beginOffset := binary.LittleEndian.Uint64(locSectionData[offset : offset+8])
@@ -601,22 +1533,9 @@ func findAbstractOrigin(entry *dwarf.Entry, dwarfData *dwarf.Data) *dwarf.Entry
 	return originEntry
 }
 
-func decodeSignedLEB128(input []byte) (val int) {
-	var i int
-	for {
-		val |= int(input[i]) & 0x7F << (7 * uint(i))
-
-		if input[i]>>7&0x1 == 0x0 {
-			break
-		}
-		i++
-	}
-
-	if input[i]>>6&0x1 == 0x1 {
-		// negative value
-		return (^0)<<((uint(i)+1)*7) + val
-	}
-	return val
+func decodeSignedLEB128(input []byte) int {
+	val, _ := readSLEB128(input)
+	return int(val)
 }
 
 type symbol struct {
@@ -626,26 +1545,178 @@ type symbol struct {
 
 // nonDebuggableBinaryFile represents the binary file WITHOUT DWARF sections.
 type nonDebuggableBinaryFile struct {
-	closer io.Closer
+	path        string
+	closer      io.Closer
+	arch        Arch
+	registerABI bool
+	// symbols is built from the pcln table section. nil if the section is not available.
+	symbols *gosym.Table
 }
 
-func newNonDebuggableBinaryFile(closer io.Closer) (nonDebuggableBinaryFile, error) {
-	return nonDebuggableBinaryFile{closer: closer}, nil
+func newNonDebuggableBinaryFile(symbols *gosym.Table, goVersion GoVersion, closer io.Closer, arch Arch) (nonDebuggableBinaryFile, error) {
+	return nonDebuggableBinaryFile{closer: closer, arch: arch, registerABI: registerABIAvailable(goVersion, arch), symbols: symbols}, nil
 }
 
-// FindFunction always returns error because it's difficult to get function info using non-DWARF binary.
+// FindFunction finds the function using the pcln table section. The parameters are unknown.
 func (b nonDebuggableBinaryFile) FindFunction(pc uint64) (*Function, error) {
+	if b.symbols == nil {
+		return nil, errors.New("no DWARF info")
+	}
+
+	fn := b.symbols.PCToFunc(pc)
+	if fn == nil {
+		return nil, fmt.Errorf("function not found at %#x", pc)
+	}
+	return b.buildFunction(fn), nil
+}
+
+// FindFunctionByName finds the function using the pcln table section. The parameters are unknown.
+func (b nonDebuggableBinaryFile) FindFunctionByName(name string) (*Function, error) {
+	if b.symbols == nil {
+		return nil, errors.New("no DWARF info")
+	}
+
+	fn := b.symbols.LookupFunc(name)
+	if fn == nil {
+		return nil, fmt.Errorf("function %s not found", name)
+	}
+	return b.buildFunction(fn), nil
+}
+
+func (b nonDebuggableBinaryFile) buildFunction(fn *gosym.Func) *Function {
+	abi := detectABI(fn.Name, false, b.registerABI)
+	function := &Function{Name: fn.Name, StartAddr: fn.Entry, EndAddr: fn.End, ABI: abi}
+	function.Annotate(AnnotationABI, abi.String())
+	return function
+}
+
+// PCToFileLine always returns error because the line table is not available in non-DWARF binary.
+func (b nonDebuggableBinaryFile) PCToFileLine(pc uint64) (string, int, error) {
+	return "", 0, errors.New("no DWARF info")
+}
+
+// FileLineToPC always returns error because the line table is not available in non-DWARF binary.
+func (b nonDebuggableBinaryFile) FileLineToPC(file string, line int) (uint64, error) {
+	return 0, errors.New("no DWARF info")
+}
+
+// InlinedFunctionAt always returns error because the inlined subroutines are described only in DWARF.
+func (b nonDebuggableBinaryFile) InlinedFunctionAt(pc uint64) (*Function, error) {
 	return nil, errors.New("no DWARF info")
 }
 
+func (b nonDebuggableBinaryFile) inlinedFunctionsAt(pc uint64) ([]*Function, error) {
+	return nil, errors.New("no DWARF info")
+}
+
+func (b nonDebuggableBinaryFile) localVariables(pc uint64) ([]localVariable, error) {
+	return nil, errors.New("no DWARF info")
+}
+
+// ListFunctions lists the functions in the pcln table section.
+func (b nonDebuggableBinaryFile) ListFunctions() ([]*Function, error) {
+	if b.symbols == nil {
+		return nil, errors.New("no symbols")
+	}
+
+	functions := make([]*Function, 0, len(b.symbols.Funcs))
+	err := b.IterateFunctions(func(f *Function) bool {
+		functions = append(functions, f)
+		return true
+	})
+	return functions, err
+}
+
+// IterateFunctions iterates the functions in the pcln table section.
+func (b nonDebuggableBinaryFile) IterateFunctions(fn func(*Function) bool) error {
+	if b.symbols == nil {
+		return errors.New("no symbols")
+	}
+
+	for i := range b.symbols.Funcs {
+		if !fn(b.buildFunction(&b.symbols.Funcs[i])) {
+			break
+		}
+	}
+	return nil
+}
+
+// ListMethods lists the methods using the function names in the pcln table section. The parameters are unknown.
+func (b nonDebuggableBinaryFile) ListMethods(typeName string) ([]*Function, error) {
+	if b.symbols == nil {
+		return nil, errors.New("no symbols")
+	}
+
+	var methods []*Function
+	for i := range b.symbols.Funcs {
+		if isMethodOf(b.symbols.Funcs[i].Name, typeName) {
+			methods = append(methods, b.buildFunction(&b.symbols.Funcs[i]))
+		}
+	}
+	return sortMethods(typeName, methods)
+}
+
+// ListPackages lists the packages using the function names in the pcln table section.
+func (b nonDebuggableBinaryFile) ListPackages() ([]string, error) {
+	if b.symbols == nil {
+		return nil, errors.New("no symbols")
+	}
+
+	var pkgs []string
+	for _, fn := range b.symbols.Funcs {
+		if pkg := fn.PackageName(); pkg != "" {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return uniqueSortedStrings(pkgs), nil
+}
+
+// IsStripped returns true because the binary has no DWARF info.
+func (b nonDebuggableBinaryFile) IsStripped() bool {
+	return true
+}
+
+// BuildID returns the go build ID embedded in the binary.
+func (b nonDebuggableBinaryFile) BuildID() (string, error) {
+	return readBuildID(b.path)
+}
+
+// ReadProgramData reads the data loaded at the address from the program file.
+func (b nonDebuggableBinaryFile) ReadProgramData(addr uint64, buff []byte) error {
+	return readProgramData(b.path, addr, buff)
+}
+
 func (b nonDebuggableBinaryFile) Close() error {
 	return b.closer.Close()
 }
 
+// Arch returns the architecture for which the binary is built.
+func (b nonDebuggableBinaryFile) Arch() Arch {
+	return b.arch
+}
+
+// Clone returns the copy of the binary file. The copy shares the parsed data, but opens the file again to have its own file descriptor.
+func (b nonDebuggableBinaryFile) Clone() (BinaryFile, error) {
+	file, err := os.Open(b.path)
+	if err != nil {
+		return nil, err
+	}
+	b.closer = file
+	return b, nil
+}
+
 func (b nonDebuggableBinaryFile) findDwarfTypeByAddr(typeAddr uint64) (dwarf.Type, error) {
 	return nil, errors.New("no DWARF info")
 }
 
+func (b nonDebuggableBinaryFile) findGlobalVariable(name string) (uint64, dwarf.Type, error) {
+	return 0, nil, errors.New("no DWARF info")
+}
+
+func (b nonDebuggableBinaryFile) labelMapType() dwarf.Type {
+	return nil
+}
+
 // Assume this dwarf.Type represents a subset of the module data type in the case DWARF is not available.
 var moduleDataType = &dwarf.StructType{
 	StructName: "runtime.moduledata",
@@ -745,8 +1816,77 @@ var moduleDataType = &dwarf.StructType{
 	},
 }
 
-func (b nonDebuggableBinaryFile) moduleDataType() dwarf.Type {
-	return moduleDataType
+// Assume these dwarf.Types represent a subset of the module data type since go1.16 in the case DWARF is not available.
+// The fields after `text` are omitted because their offsets vary among go versions.
+var (
+	moduleDataTypeV2 = newModuleDataTypeWithPcHeader(&dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 16},
+		StructName: "runtime.functab",
+		Field: []*dwarf.StructField{
+			&dwarf.StructField{Name: "entry", Type: newUintType(8), ByteOffset: 0},
+			&dwarf.StructField{Name: "funcoff", Type: newUintType(8), ByteOffset: 8},
+		},
+	})
+	moduleDataTypeV3 = newModuleDataTypeWithPcHeader(&dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 8},
+		StructName: "runtime.functab",
+		Field: []*dwarf.StructField{
+			&dwarf.StructField{Name: "entryoff", Type: newUintType(4), ByteOffset: 0},
+			&dwarf.StructField{Name: "funcoff", Type: newUintType(4), ByteOffset: 4},
+		},
+	})
+)
+
+func newModuleDataTypeWithPcHeader(functabType *dwarf.StructType) *dwarf.StructType {
+	return &dwarf.StructType{
+		StructName: "runtime.moduledata",
+		CommonType: dwarf.CommonType{ByteSize: 184},
+		Field: []*dwarf.StructField{
+			&dwarf.StructField{Name: "pcHeader", Type: &dwarf.PtrType{CommonType: dwarf.CommonType{ByteSize: 8}}, ByteOffset: 0},
+			&dwarf.StructField{Name: "funcnametab", Type: newSliceType("[]uint8", newUintType(1)), ByteOffset: 8},
+			&dwarf.StructField{Name: "pctab", Type: newSliceType("[]uint8", newUintType(1)), ByteOffset: 80},
+			&dwarf.StructField{Name: "pclntable", Type: newSliceType("[]uint8", newUintType(1)), ByteOffset: 104},
+			&dwarf.StructField{Name: "ftab", Type: newSliceType("[]runtime.functab", functabType), ByteOffset: 128},
+			&dwarf.StructField{Name: "findfunctab", Type: newUintType(8), ByteOffset: 152},
+			&dwarf.StructField{Name: "minpc", Type: newUintType(8), ByteOffset: 160},
+			&dwarf.StructField{Name: "maxpc", Type: newUintType(8), ByteOffset: 168},
+			&dwarf.StructField{Name: "text", Type: newUintType(8), ByteOffset: 176},
+		},
+	}
+}
+
+func newUintType(size int64) *dwarf.UintType {
+	return &dwarf.UintType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: size}}}
+}
+
+func newSliceType(name string, elementType dwarf.Type) *dwarf.StructType {
+	return &dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 24},
+		StructName: name,
+		Field: []*dwarf.StructField{
+			&dwarf.StructField{
+				Name:       "array",
+				Type:       &dwarf.PtrType{CommonType: dwarf.CommonType{ByteSize: 8}, Type: elementType},
+				ByteOffset: 0,
+			},
+			&dwarf.StructField{
+				Name:       "len",
+				Type:       &dwarf.IntType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 8}}},
+				ByteOffset: 8,
+			},
+		},
+	}
+}
+
+func (b nonDebuggableBinaryFile) moduleDataType(version pclnTableVersion) dwarf.Type {
+	switch version {
+	case pclnTableVersion2:
+		return moduleDataTypeV2
+	case pclnTableVersion3:
+		return moduleDataTypeV3
+	default:
+		return moduleDataType
+	}
 }
 
 // Assume this dwarf.Type represents a subset of the runtime.g type in the case DWARF is not available.
@@ -4,9 +4,14 @@ import (
 	"bytes"
 	"compress/zlib"
 	"debug/dwarf"
+	"debug/gosym"
 	"debug/macho"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
+
+	"github.com/ks888/tgo/log"
 )
 
 var locationListSectionNames = []string{
@@ -21,22 +26,61 @@ func openBinaryFile(pathToProgram string, goVersion GoVersion) (BinaryFile, erro
 	}
 	var closer io.Closer = machoFile
 
+	arch, err := findArch(machoFile)
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+
 	data, locList, err := findDWARF(machoFile)
 	if err != nil {
-		binaryFile, err := newNonDebuggableBinaryFile(closer)
+		symbols, err := findSymbolTable(machoFile)
+		if err != nil {
+			log.Debugf("failed to read the pcln table section: %v", err)
+		}
+
+		binaryFile, err := newNonDebuggableBinaryFile(symbols, goVersion, closer, arch)
 		if err != nil {
 			closer.Close()
 		}
+		binaryFile.path = pathToProgram
 		return binaryFile, err
 	}
 
-	binaryFile, err := newDebuggableBinaryFile(dwarfData{Data: data, locationList: locList}, goVersion, closer)
+	binaryFile, err := newDebuggableBinaryFile(dwarfData{Data: data, locationList: locList}, goVersion, closer, arch)
 	if err != nil {
 		closer.Close()
 	}
+	binaryFile.path = pathToProgram
 	return binaryFile, err
 }
 
+func findArch(machoFile *macho.File) (Arch, error) {
+	switch machoFile.Cpu {
+	case macho.CpuAmd64:
+		return X86_64Arch{}, nil
+	case macho.CpuArm64:
+		return ARM64Arch{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported architecture: %v", machoFile.Cpu)
+	}
+}
+
+// findSymbolTable builds the symbol table using the pcln table section, which is available even if the binary is stripped.
+func findSymbolTable(machoFile *macho.File) (*gosym.Table, error) {
+	pclnTableSection := machoFile.Section("__gopclntab")
+	textSection := machoFile.Section("__text")
+	if pclnTableSection == nil || textSection == nil {
+		return nil, errors.New("no pcln table or text section")
+	}
+
+	pclnTableData, err := pclnTableSection.Data()
+	if err != nil {
+		return nil, err
+	}
+	return gosym.NewTable(nil, gosym.NewLineTable(pclnTableData, textSection.Addr))
+}
+
 func findDWARF(machoFile *macho.File) (data *dwarf.Data, locList []byte, err error) {
 	var locListSection *macho.Section
 	for _, locListSectionName := range locationListSectionNames {
@@ -0,0 +1,135 @@
+package tracee
+
+import (
+	"bytes"
+	"compress/zlib"
+	"debug/dwarf"
+	"debug/elf"
+	"debug/gosym"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ks888/tgo/log"
+)
+
+var elfLocationListSectionNames = []string{
+	".zdebug_loc",
+	".debug_loc",
+}
+
+// OpenBinaryFileELF opens the ELF program file regardless of the host OS.
+// It's useful to inspect the binary cross-compiled for linux, though such a binary can't be traced on the other OSes.
+// Unlike OpenBinaryFile, the opened file is not cached.
+func OpenBinaryFileELF(pathToProgram string, goVersion GoVersion) (BinaryFile, error) {
+	return openELFBinaryFile(pathToProgram, goVersion)
+}
+
+func openELFBinaryFile(pathToProgram string, goVersion GoVersion) (BinaryFile, error) {
+	elfFile, err := elf.Open(pathToProgram)
+	if err != nil {
+		return nil, err
+	}
+	var closer io.Closer = elfFile
+
+	arch, err := findELFArch(elfFile)
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+
+	data, locList, err := findELFDWARF(elfFile)
+	if err != nil {
+		symbols, err := findELFSymbolTable(elfFile)
+		if err != nil {
+			log.Debugf("failed to read the pcln table section: %v", err)
+		}
+
+		binaryFile, err := newNonDebuggableBinaryFile(symbols, goVersion, closer, arch)
+		if err != nil {
+			closer.Close()
+		}
+		binaryFile.path = pathToProgram
+		return binaryFile, err
+	}
+
+	binaryFile, err := newDebuggableBinaryFile(dwarfData{Data: data, locationList: locList}, goVersion, closer, arch)
+	if err != nil {
+		closer.Close()
+	}
+	binaryFile.path = pathToProgram
+	return binaryFile, err
+}
+
+func findELFArch(elfFile *elf.File) (Arch, error) {
+	switch elfFile.Machine {
+	case elf.EM_X86_64:
+		return X86_64Arch{}, nil
+	case elf.EM_AARCH64:
+		return ARM64Arch{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported architecture: %v", elfFile.Machine)
+	}
+}
+
+// findELFSymbolTable builds the symbol table using the pcln table section, which is available even if the binary is stripped.
+func findELFSymbolTable(elfFile *elf.File) (*gosym.Table, error) {
+	pclnTableSection := elfFile.Section(".gopclntab")
+	textSection := elfFile.Section(".text")
+	if pclnTableSection == nil || textSection == nil {
+		return nil, errors.New("no pcln table or text section")
+	}
+
+	pclnTableData, err := pclnTableSection.Data()
+	if err != nil {
+		return nil, err
+	}
+	return gosym.NewTable(nil, gosym.NewLineTable(pclnTableData, textSection.Addr))
+}
+
+func findELFDWARF(elfFile *elf.File) (data *dwarf.Data, locList []byte, err error) {
+	var locListSection *elf.Section
+	for _, locListSectionName := range elfLocationListSectionNames {
+		locListSection = elfFile.Section(locListSectionName)
+		if locListSection != nil {
+			break
+		}
+	}
+	// older go version doesn't create a location list section.
+
+	locList, err = buildELFLocationListData(locListSection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err = elfFile.DWARF()
+	return data, locList, err
+}
+
+func buildELFLocationListData(locListSection *elf.Section) ([]byte, error) {
+	if locListSection == nil {
+		return nil, nil
+	}
+
+	rawData, err := locListSection.Data()
+	if err != nil {
+		return nil, err
+	}
+
+	if string(rawData[:4]) != "ZLIB" || len(rawData) < 12 {
+		return rawData, nil
+	}
+
+	dlen := binary.BigEndian.Uint64(rawData[4:12])
+	uncompressedData := make([]byte, dlen)
+
+	r, err := zlib.NewReader(bytes.NewBuffer(rawData[12:]))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	_, err = io.ReadFull(r, uncompressedData)
+	return uncompressedData, err
+}
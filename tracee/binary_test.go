@@ -4,8 +4,14 @@ import (
 	"debug/dwarf"
 	"debug/elf"
 	"debug/macho"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/ks888/tgo/testutils"
@@ -17,12 +23,15 @@ func TestOpenBinaryFile(t *testing.T) {
 		t.Fatalf("failed to create new binary: %v", err)
 	}
 
-	if binary.moduleDataType() == nil {
+	if binary.moduleDataType(pclnTableVersion1) == nil {
 		t.Errorf("runtime.moduledata type is nil")
 	}
 	if binary.runtimeGType() == nil {
 		t.Errorf("runtime.g type is nil")
 	}
+	if _, ok := binary.Arch().(X86_64Arch); !ok {
+		t.Errorf("wrong arch: %#v", binary.Arch())
+	}
 }
 
 func TestOpenNonDwarfBinaryFile(t *testing.T) {
@@ -36,7 +45,13 @@ func TestOpenNonDwarfBinaryFile(t *testing.T) {
 	if _, err := binary.findDwarfTypeByAddr(0); err == nil {
 		t.Errorf("findDwarfTypeByAddr doesn't return error")
 	}
-	if binary.moduleDataType() == nil {
+	if _, _, err := binary.findGlobalVariable("runtime.allgs"); err == nil {
+		t.Errorf("findGlobalVariable doesn't return error")
+	}
+	if !binary.IsStripped() {
+		t.Errorf("not stripped")
+	}
+	if binary.moduleDataType(pclnTableVersion1) == nil {
 		t.Errorf("runtime.moduledata type is nil")
 	}
 	if binary.runtimeGType() == nil {
@@ -44,6 +59,171 @@ func TestOpenNonDwarfBinaryFile(t *testing.T) {
 	}
 }
 
+func TestOpenBinaryFileELF(t *testing.T) {
+	binary, err := OpenBinaryFileELF(testutils.ProgramHelloworldELF, GoVersion{})
+	if err != nil {
+		t.Fatalf("failed to open: %v", err)
+	}
+	defer binary.Close()
+
+	if _, ok := binary.Arch().(X86_64Arch); !ok {
+		t.Errorf("wrong arch: %#v", binary.Arch())
+	}
+	function, err := binary.FindFunction(testutils.HelloworldELFAddrMain)
+	if err != nil {
+		t.Fatalf("failed to find function: %v", err)
+	}
+	if function.Name != "main.main" {
+		t.Errorf("wrong name: %s", function.Name)
+	}
+}
+
+func TestOpenBinaryFile_Cached(t *testing.T) {
+	// use the copy of the program so that the cache entry is not shared with other tests.
+	program := copyProgram(t, testutils.ProgramHelloworld)
+	binary1, err := OpenBinaryFile(program, GoVersion{})
+	if err != nil {
+		t.Fatalf("failed to open: %v", err)
+	}
+	binary2, err := OpenBinaryFile(program, GoVersion{})
+	if err != nil {
+		t.Fatalf("failed to open: %v", err)
+	}
+
+	dwarf1 := binary1.(*cachedBinaryFile).BinaryFile.(debuggableBinaryFile).dwarf.Data
+	dwarf2 := binary2.(*cachedBinaryFile).BinaryFile.(debuggableBinaryFile).dwarf.Data
+	if dwarf1 != dwarf2 {
+		t.Errorf("dwarf data is not shared")
+	}
+
+	key := binary1.(*cachedBinaryFile).key
+	binary1.Close()
+	if !isCached(key) {
+		t.Errorf("evicted before the last reference is dropped")
+	}
+	binary2.Close()
+	if isCached(key) {
+		t.Errorf("not evicted after the last reference is dropped")
+	}
+}
+
+func TestOpenBinaryFile_Concurrent(t *testing.T) {
+	program := copyProgram(t, testutils.ProgramHelloworld)
+
+	const numOpens = 4
+	binaries := make([]BinaryFile, numOpens)
+	errs := make([]error, numOpens)
+	var wg sync.WaitGroup
+	for i := 0; i < numOpens; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			binaries[i], errs[i] = OpenBinaryFile(program, GoVersion{})
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("failed to open: %v", err)
+		}
+		defer binaries[i].Close()
+	}
+
+	dwarfData := binaries[0].(*cachedBinaryFile).BinaryFile.(debuggableBinaryFile).dwarf.Data
+	for _, binary := range binaries[1:] {
+		if binary.(*cachedBinaryFile).BinaryFile.(debuggableBinaryFile).dwarf.Data != dwarfData {
+			t.Errorf("dwarf data is not shared")
+		}
+	}
+}
+
+func copyProgram(t *testing.T, program string) string {
+	data, err := ioutil.ReadFile(program)
+	if err != nil {
+		t.Fatalf("failed to read the program: %v", err)
+	}
+	copied := filepath.Join(t.TempDir(), filepath.Base(program))
+	if err := ioutil.WriteFile(copied, data, 0755); err != nil {
+		t.Fatalf("failed to copy the program: %v", err)
+	}
+	return copied
+}
+
+func isCached(key binaryCacheKey) bool {
+	binaryCacheMtx.Lock()
+	defer binaryCacheMtx.Unlock()
+	_, ok := binaryCache[key]
+	return ok
+}
+
+func TestClone(t *testing.T) {
+	binary, err := OpenBinaryFile(copyProgram(t, testutils.ProgramHelloworld), GoVersion{})
+	if err != nil {
+		t.Fatalf("failed to open: %v", err)
+	}
+
+	clonedBinary, err := binary.Clone()
+	if err != nil {
+		t.Fatalf("failed to clone: %v", err)
+	}
+	original, cloned := binary.(*cachedBinaryFile).BinaryFile.(debuggableBinaryFile), clonedBinary.(*cachedBinaryFile).BinaryFile.(debuggableBinaryFile)
+	if cloned.dwarf.Data != original.dwarf.Data {
+		t.Errorf("dwarf data is not shared")
+	}
+	if cloned.closer == original.closer {
+		t.Errorf("file descriptor is shared")
+	}
+
+	key := binary.(*cachedBinaryFile).key
+	if err := binary.Close(); err != nil {
+		t.Errorf("failed to close: %v", err)
+	}
+	if !isCached(key) {
+		t.Errorf("evicted before the clone is closed")
+	}
+	if err := clonedBinary.Close(); err != nil {
+		t.Errorf("failed to close: %v", err)
+	}
+	if isCached(key) {
+		t.Errorf("not evicted after the clone is closed")
+	}
+}
+
+func BenchmarkFindFunction(b *testing.B) {
+	binary, err := OpenBinaryFile(benchmarkProgram(), GoVersion{})
+	if err != nil {
+		b.Fatalf("failed to open: %v", err)
+	}
+	defer binary.Close()
+
+	mainFunction, err := binary.FindFunctionByName("main.main")
+	if err != nil {
+		b.Fatalf("failed to find main.main: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := binary.FindFunction(mainFunction.StartAddr); err != nil {
+			b.Fatalf("failed to find function: %v", err)
+		}
+	}
+}
+
+func TestFunction_Size(t *testing.T) {
+	for i, testdata := range []struct {
+		function Function
+		expected uint64
+	}{
+		{function: Function{StartAddr: 0x100, EndAddr: 0x180}, expected: 0x80},
+		{function: Function{StartAddr: 0x100}, expected: 0},
+	} {
+		if actual := testdata.function.Size(); actual != testdata.expected {
+			t.Errorf("[%d] wrong size: %#x", i, actual)
+		}
+	}
+}
+
 func TestOpenBinaryFile_ProgramNotFound(t *testing.T) {
 	_, err := OpenBinaryFile("./notexist", GoVersion{})
 	if err == nil {
@@ -67,6 +247,413 @@ func TestFindFunction(t *testing.T) {
 	}
 }
 
+func TestFindFunction_StrippedBinary(t *testing.T) {
+	binary, _ := OpenBinaryFile(testutils.ProgramHelloworldNoDwarf, GoVersion{})
+	function, err := binary.FindFunction(testutils.HelloworldAddrOneParameterAndVariable + 1)
+	if err != nil {
+		t.Fatalf("failed to find function: %v", err)
+	}
+	if function.Name != "main.oneParameterAndOneVariable" {
+		t.Errorf("wrong name: %s", function.Name)
+	}
+	if function.StartAddr != testutils.HelloworldAddrOneParameterAndVariable || function.EndAddr <= function.StartAddr {
+		t.Errorf("wrong range: %#x-%#x", function.StartAddr, function.EndAddr)
+	}
+	if abi, _ := function.Annotation(AnnotationABI); abi != ABIABI0.String() {
+		t.Errorf("wrong abi annotation: %s", abi)
+	}
+
+	function, err = binary.FindFunctionByName("main.oneParameterAndOneVariable")
+	if err != nil {
+		t.Fatalf("failed to find function by name: %v", err)
+	}
+	if function.StartAddr != testutils.HelloworldAddrOneParameterAndVariable {
+		t.Errorf("wrong start addr: %#x", function.StartAddr)
+	}
+}
+
+func TestFindFunctionByName(t *testing.T) {
+	binary, _ := OpenBinaryFile(testutils.ProgramHelloworld, GoVersion{})
+	function, err := binary.FindFunctionByName("main.oneParameterAndOneVariable")
+	if err != nil {
+		t.Fatalf("failed to find function: %v", err)
+	}
+
+	if function.StartAddr != testutils.HelloworldAddrOneParameterAndVariable {
+		t.Errorf("wrong start addr: %#x", function.StartAddr)
+	}
+	if function.Parameters == nil {
+		t.Fatal("parameters field is nil")
+	}
+}
+
+func TestIterateFunctions(t *testing.T) {
+	for i, program := range []string{testutils.ProgramHelloworld, testutils.ProgramHelloworldNoDwarf} {
+		binary, _ := OpenBinaryFile(program, GoVersion{})
+		functions, err := binary.ListFunctions()
+		if err != nil {
+			t.Fatalf("[%d] failed to list functions: %v", i, err)
+		}
+		type key struct {
+			name      string
+			startAddr uint64
+		}
+		listed := make(map[key]bool)
+		for _, f := range functions {
+			listed[key{f.Name, f.StartAddr}] = true
+		}
+
+		var all []*Function
+		err = binary.IterateFunctions(func(f *Function) bool {
+			all = append(all, f)
+			return true
+		})
+		if err != nil {
+			t.Fatalf("[%d] failed to iterate functions: %v", i, err)
+		}
+		if len(all) != len(functions) {
+			t.Fatalf("[%d] wrong number of functions: %d, %d", i, len(all), len(functions))
+		}
+		for _, f := range all {
+			if !listed[key{f.Name, f.StartAddr}] {
+				t.Errorf("[%d] wrong function: %#v", i, f)
+			}
+		}
+
+		const maxFuncs = 10
+		var iterated []*Function
+		err = binary.IterateFunctions(func(f *Function) bool {
+			iterated = append(iterated, f)
+			return len(iterated) < maxFuncs
+		})
+		if err != nil {
+			t.Fatalf("[%d] failed to iterate functions: %v", i, err)
+		}
+
+		expectedLen := len(functions)
+		if expectedLen > maxFuncs {
+			expectedLen = maxFuncs
+		}
+		if len(iterated) != expectedLen {
+			t.Fatalf("[%d] not stopped: %d", i, len(iterated))
+		}
+		for j := range iterated {
+			if iterated[j].Name != all[j].Name || iterated[j].StartAddr != all[j].StartAddr {
+				t.Errorf("[%d] wrong function: %#v", i, iterated[j])
+			}
+		}
+	}
+}
+
+func TestListFunctions_Sorted(t *testing.T) {
+	binary, _ := OpenBinaryFile(testutils.ProgramHelloworld, GoVersion{})
+	functions, err := binary.ListFunctions()
+	if err != nil {
+		t.Fatalf("failed to list functions: %v", err)
+	}
+	if !sort.SliceIsSorted(functions, func(i, j int) bool { return functions[i].StartAddr < functions[j].StartAddr }) {
+		t.Errorf("not sorted")
+	}
+}
+
+// benchmarkProgram returns the program the function lookup and listing are benchmarked against. The large binary (e.g. 100MB)
+// can be specified by the TGO_BENCH_PROGRAM environment variable. The largest test program is used otherwise.
+func benchmarkProgram() string {
+	if program := os.Getenv("TGO_BENCH_PROGRAM"); program != "" {
+		return program
+	}
+	return testutils.ProgramStartStop
+}
+
+func BenchmarkListFunctions(b *testing.B) {
+	binary, err := OpenBinaryFile(benchmarkProgram(), GoVersion{})
+	if err != nil {
+		b.Fatalf("failed to open: %v", err)
+	}
+	defer binary.Close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := binary.ListFunctions(); err != nil {
+			b.Fatalf("failed to list functions: %v", err)
+		}
+	}
+	reportRetainedHeap(b, func() interface{} {
+		functions, _ := binary.ListFunctions()
+		return functions
+	})
+}
+
+func BenchmarkIterateFunctions(b *testing.B) {
+	binary, err := OpenBinaryFile(benchmarkProgram(), GoVersion{})
+	if err != nil {
+		b.Fatalf("failed to open: %v", err)
+	}
+	defer binary.Close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := binary.IterateFunctions(func(*Function) bool { return true }); err != nil {
+			b.Fatalf("failed to iterate functions: %v", err)
+		}
+	}
+	reportRetainedHeap(b, func() interface{} {
+		_ = binary.IterateFunctions(func(*Function) bool { return true })
+		return nil
+	})
+}
+
+// BenchmarkBuildFunctionIndex loads all the functions at once, which is what FindFunction and FindFunctionByName do.
+func BenchmarkBuildFunctionIndex(b *testing.B) {
+	binary, err := openBinaryFile(benchmarkProgram(), GoVersion{})
+	if err != nil {
+		b.Fatalf("failed to open: %v", err)
+	}
+	defer binary.Close()
+	debuggableBinary, ok := binary.(debuggableBinaryFile)
+	if !ok {
+		b.Skip("no DWARF info")
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		debuggableBinary.functionIndex = &functionIndex{}
+		debuggableBinary.buildFunctionIndex()
+	}
+	debuggableBinary.functionIndex = nil
+	reportRetainedHeap(b, func() interface{} {
+		debuggableBinary.functionIndex = &functionIndex{}
+		return debuggableBinary.buildFunctionIndex()
+	})
+}
+
+// reportRetainedHeap reports the heap size the result of `fn` holds. B/op is not enough to see the memory savings,
+// because most of it is the garbage created while parsing the DWARF info.
+func reportRetainedHeap(b *testing.B, fn func() interface{}) {
+	b.StopTimer()
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	result := fn()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(result)
+	b.ReportMetric(float64(int64(after.HeapAlloc)-int64(before.HeapAlloc)), "retained-B")
+}
+
+func TestListPackages(t *testing.T) {
+	binary, _ := OpenBinaryFile(testutils.ProgramHelloworld, GoVersion{})
+	pkgs, err := binary.ListPackages()
+	if err != nil {
+		t.Fatalf("failed to list packages: %v", err)
+	}
+
+	if !sort.StringsAreSorted(pkgs) {
+		t.Errorf("not sorted: %v", pkgs)
+	}
+	for _, expected := range []string{"main", "fmt"} {
+		i := sort.SearchStrings(pkgs, expected)
+		if i == len(pkgs) || pkgs[i] != expected {
+			t.Errorf("%s not found: %v", expected, pkgs)
+		}
+	}
+}
+
+func TestListMethods(t *testing.T) {
+	binary, _ := OpenBinaryFile(testutils.ProgramHelloworld, GoVersion{})
+	for _, typeName := range []string{"fmt.pp", "*fmt.pp"} {
+		methods, err := binary.ListMethods(typeName)
+		if err != nil {
+			t.Fatalf("failed to list methods: %v", err)
+		}
+
+		found := false
+		for _, method := range methods {
+			if method.Name == "fmt.(*pp).printArg" {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("fmt.(*pp).printArg not found in %d methods", len(methods))
+		}
+	}
+}
+
+func TestListMethods_NoType(t *testing.T) {
+	binary, _ := OpenBinaryFile(testutils.ProgramHelloworld, GoVersion{})
+	if _, err := binary.ListMethods("main.NotExist"); err == nil {
+		t.Errorf("should return error")
+	}
+}
+
+func TestIsMethodOf(t *testing.T) {
+	for i, testdata := range []struct {
+		funcName, typeName string
+		expected           bool
+	}{
+		{funcName: "net/http.(*Transport).RoundTrip", typeName: "http.Transport", expected: true},
+		{funcName: "net/http.(*Transport).RoundTrip", typeName: "*http.Transport", expected: true},
+		{funcName: "net/http.(*Transport).RoundTrip", typeName: "net/http.Transport", expected: true},
+		{funcName: "net/http.Header.Get", typeName: "http.Header", expected: true},
+		{funcName: "github.com/ks888/tgo/tracee.(*Process).Detach", typeName: "tracee.Process", expected: true},
+		{funcName: "net/http.(*Transport).RoundTrip.func1", typeName: "http.Transport", expected: false},
+		{funcName: "net/http.(*Transport).RoundTrip-fm", typeName: "http.Transport", expected: false},
+		{funcName: "net/http.(*Transport).RoundTrip", typeName: "http.Trans", expected: false},
+		{funcName: "net/http.Get", typeName: "http.Get", expected: false},
+		{funcName: "net/httptest.(*Server).Close", typeName: "http.Server", expected: false},
+	} {
+		if actual := isMethodOf(testdata.funcName, testdata.typeName); actual != testdata.expected {
+			t.Errorf("[%d] wrong result: %v", i, actual)
+		}
+	}
+}
+
+func TestFunction_IsMethod(t *testing.T) {
+	for i, testdata := range []struct {
+		name             string
+		isMethod         bool
+		receiverTypeName string
+	}{
+		{name: "net/http.(*Transport).RoundTrip", isMethod: true, receiverTypeName: "*http.Transport"},
+		{name: "net/http.Header.Get", isMethod: true, receiverTypeName: "http.Header"},
+		{name: "main.S.f", isMethod: true, receiverTypeName: "main.S"},
+		{name: "main.main", isMethod: false},
+		{name: "main.main.func1", isMethod: false},
+		{name: "net/http.(*Transport).RoundTrip.func1", isMethod: false},
+		{name: "net/http.(*Transport).RoundTrip-fm", isMethod: false},
+	} {
+		f := Function{Name: testdata.name}
+		if f.IsMethod() != testdata.isMethod {
+			t.Errorf("[%d] wrong result: %v", i, f.IsMethod())
+		}
+		if f.ReceiverTypeName() != testdata.receiverTypeName {
+			t.Errorf("[%d] wrong receiver type name: %s", i, f.ReceiverTypeName())
+		}
+	}
+}
+
+func TestFindFunctionByName_Receiver(t *testing.T) {
+	binary, _ := OpenBinaryFile(testutils.ProgramHelloworld, GoVersion{})
+	function, err := binary.FindFunctionByName("fmt.(*pp).printArg")
+	if err != nil {
+		t.Fatalf("failed to find function: %v", err)
+	}
+
+	for i, param := range function.Parameters {
+		if param.IsReceiver != (i == 0) {
+			t.Errorf("wrong IsReceiver of %s: %v", param.Name, param.IsReceiver)
+		}
+	}
+}
+
+func TestLocalVariables(t *testing.T) {
+	binary, _ := OpenBinaryFile(testutils.ProgramHelloworld, GoVersion{})
+	pc, err := binary.FileLineToPC("helloworld.go", 23)
+	if err != nil {
+		t.Fatalf("failed to find pc: %v", err)
+	}
+
+	variables, err := binary.localVariables(pc)
+	if err != nil {
+		t.Fatalf("failed to find local variables: %v", err)
+	}
+	if len(variables) != 1 || variables[0].name != "a" {
+		t.Fatalf("wrong variables: %#v", variables)
+	}
+	if variables[0].typ.String() != "int" {
+		t.Errorf("wrong variable: %#v", variables[0])
+	}
+}
+
+func TestPCToFileLine(t *testing.T) {
+	binary, _ := OpenBinaryFile(testutils.ProgramHelloworld, GoVersion{})
+	file, line, err := binary.PCToFileLine(testutils.HelloworldAddrOneParameterAndVariable)
+	if err != nil {
+		t.Fatalf("failed to find file and line: %v", err)
+	}
+
+	if !strings.HasSuffix(file, "helloworld.go") {
+		t.Errorf("wrong file: %s", file)
+	}
+	if line != 20 {
+		t.Errorf("wrong line: %d", line)
+	}
+}
+
+func TestFileLineToPC(t *testing.T) {
+	binary, _ := OpenBinaryFile(testutils.ProgramHelloworld, GoVersion{})
+	for i, testdata := range []struct {
+		file      string
+		line      int
+		expectErr bool
+	}{
+		{file: "helloworld.go", line: 20},
+		{file: "testdata/helloworld.go", line: 20},
+		{file: "world.go", line: 20, expectErr: true},
+		{file: "helloworld.go", line: 1, expectErr: true},
+	} {
+		pc, err := binary.FileLineToPC(testdata.file, testdata.line)
+		if testdata.expectErr {
+			if err == nil {
+				t.Errorf("[%d] error not returned", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("[%d] failed to find pc: %v", i, err)
+		}
+		if pc != testutils.HelloworldAddrOneParameterAndVariable {
+			t.Errorf("[%d] wrong pc: %#x", i, pc)
+		}
+	}
+}
+
+func TestInlinedFunctionAt(t *testing.T) {
+	binary, _ := OpenBinaryFile(testutils.ProgramHelloworld, GoVersion{})
+	function, err := binary.FindFunction(testutils.HelloworldAddrNoParameter)
+	if err != nil {
+		t.Fatalf("failed to find function: %v", err)
+	}
+
+	if _, err := binary.InlinedFunctionAt(function.StartAddr); err == nil {
+		t.Errorf("error not returned")
+	}
+
+	// fmt.Println is inlined.
+	found := false
+	for pc := function.StartAddr; pc < function.EndAddr; pc++ {
+		inlinedFunction, err := binary.InlinedFunctionAt(pc)
+		if err == nil && inlinedFunction.Name == "fmt.Println" {
+			if inlinedFunction.StartAddr > pc || pc >= inlinedFunction.EndAddr {
+				t.Errorf("wrong range: %#x-%#x", inlinedFunction.StartAddr, inlinedFunction.EndAddr)
+			}
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Errorf("inlined function not found")
+	}
+}
+
+func TestFindGlobalVariable(t *testing.T) {
+	binary, _ := OpenBinaryFile(testutils.ProgramHelloworld, GoVersion{})
+	addr, typ, err := binary.findGlobalVariable("runtime.allgs")
+	if err != nil {
+		t.Fatalf("failed to find global variable: %v", err)
+	}
+	if addr == 0 {
+		t.Errorf("addr is 0")
+	}
+	if sliceType, ok := typ.(*dwarf.StructType); !ok || sliceType.StructName != "[]*runtime.g" {
+		t.Errorf("wrong type: %v", typ)
+	}
+}
+
 func TestIsExported(t *testing.T) {
 	for i, testdata := range []struct {
 		name     string
@@ -398,7 +985,7 @@ func TestDebugFrameSection(t *testing.T) {
 
 func TestModuleDataOffsets(t *testing.T) {
 	binary, _ := OpenBinaryFile(testutils.ProgramHelloworld, GoVersion{})
-	debuggableBinary, _ := binary.(debuggableBinaryFile)
+	debuggableBinary, _ := binary.(*cachedBinaryFile).BinaryFile.(debuggableBinaryFile)
 
 	entry, err := debuggableBinary.findDWARFEntryByName(func(entry *dwarf.Entry) bool {
 		if entry.Tag != dwarf.TagStructType {
@@ -416,8 +1003,15 @@ func TestModuleDataOffsets(t *testing.T) {
 		t.Fatalf("no moduledata type: %v", err)
 	}
 
+	actualModuleDataType := moduleDataType
+	if goVersion := ParseGoVersion(runtime.Version()); goVersion.AtLeast(GoVersion{MajorVersion: 1, MinorVersion: 18}) {
+		actualModuleDataType = moduleDataTypeV3
+	} else if goVersion.AtLeast(GoVersion{MajorVersion: 1, MinorVersion: 16}) {
+		actualModuleDataType = moduleDataTypeV2
+	}
+
 	expectedFields := expectedModuleDataType.(*dwarf.StructType).Field
-	for _, actualField := range moduleDataType.Field {
+	for _, actualField := range actualModuleDataType.Field {
 		for _, expectedField := range expectedFields {
 			if actualField.Name == expectedField.Name {
 				if actualField.ByteOffset != expectedField.ByteOffset {
@@ -480,7 +1074,7 @@ func TestModuleDataOffsets(t *testing.T) {
 
 func TestRuntimeGOffsets(t *testing.T) {
 	binary, _ := OpenBinaryFile(testutils.ProgramHelloworld, GoVersion{})
-	debuggableBinary, _ := binary.(debuggableBinaryFile)
+	debuggableBinary, _ := binary.(*cachedBinaryFile).BinaryFile.(debuggableBinaryFile)
 
 	entry, err := debuggableBinary.findDWARFEntryByName(func(entry *dwarf.Entry) bool {
 		if entry.Tag != dwarf.TagStructType {
@@ -525,3 +1119,40 @@ func findDwarfData(t *testing.T, pathToProgram string) dwarfData {
 	}
 	return dwarfData{}
 }
+
+func TestDetectABI(t *testing.T) {
+	for i, testdata := range []struct {
+		name         string
+		isTrampoline bool
+		registerABI  bool
+		expect       ABI
+	}{
+		{name: "main.f", registerABI: false, expect: ABIABI0},
+		{name: "main.f", registerABI: true, expect: ABIABIInternal},
+		{name: "main.f", isTrampoline: true, registerABI: true, expect: ABIABI0},
+		{name: "runtime.memmove.abi0", registerABI: true, expect: ABIABI0},
+	} {
+		actual := detectABI(testdata.name, testdata.isTrampoline, testdata.registerABI)
+		if actual != testdata.expect {
+			t.Errorf("[%d] wrong abi: %v", i, actual)
+		}
+	}
+}
+
+func TestRegisterABIAvailable(t *testing.T) {
+	for i, testdata := range []struct {
+		goVersion GoVersion
+		arch      Arch
+		expect    bool
+	}{
+		{goVersion: GoVersion{MajorVersion: 1, MinorVersion: 16}, arch: X86_64Arch{}, expect: false},
+		{goVersion: GoVersion{MajorVersion: 1, MinorVersion: 17}, arch: X86_64Arch{}, expect: true},
+		{goVersion: GoVersion{MajorVersion: 1, MinorVersion: 17}, arch: ARM64Arch{}, expect: false},
+		{goVersion: GoVersion{MajorVersion: 1, MinorVersion: 18}, arch: ARM64Arch{}, expect: true},
+	} {
+		actual := registerABIAvailable(testdata.goVersion, testdata.arch)
+		if actual != testdata.expect {
+			t.Errorf("[%d] wrong result: %v", i, actual)
+		}
+	}
+}
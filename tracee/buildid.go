@@ -0,0 +1,102 @@
+package tracee
+
+import (
+	"bytes"
+	"debug/elf"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The go linker embeds the build ID at the beginning of the text section (the go.buildid symbol) in this form.
+// The ELF binary has the same ID in the .note.go.buildid section too. See cmd/internal/buildid for the detail.
+var (
+	goBuildIDPrefix = []byte("\xff Go build ID: \"")
+	goBuildIDEnd    = []byte("\"\n \xff")
+)
+
+const (
+	// buildIDReadSize is the size of the file head searched for the build ID. Same as the go tool.
+	buildIDReadSize = 32 * 1024
+	// buildIDNoteName and buildIDNoteType identify the build ID note in the ELF binary.
+	buildIDNoteName = "Go\x00\x00"
+	buildIDNoteType = 4
+	// buildIDSeparator separates the hashes in the build ID.
+	buildIDSeparator = "/"
+)
+
+// readBuildID reads the go build ID of the program, such as 'abc/def/ghi/jkl'.
+func readBuildID(pathToProgram string) (string, error) {
+	if elfFile, err := elf.Open(pathToProgram); err == nil {
+		defer elfFile.Close()
+		if id, err := readELFBuildIDNote(elfFile); err == nil {
+			return id, nil
+		}
+	}
+
+	f, err := os.Open(pathToProgram)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	buff := make([]byte, buildIDReadSize)
+	n, err := io.ReadFull(f, buff)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	return findRawBuildID(buff[:n])
+}
+
+// readELFBuildIDNote reads the build ID in the .note.go.buildid section.
+func readELFBuildIDNote(elfFile *elf.File) (string, error) {
+	section := elfFile.Section(".note.go.buildid")
+	if section == nil {
+		return "", errors.New("no build ID note")
+	}
+	data, err := section.Data()
+	if err != nil {
+		return "", err
+	}
+
+	// the note has the name size, desc size, type, name and desc in this order.
+	if len(data) < 12+len(buildIDNoteName) {
+		return "", errors.New("too short build ID note")
+	}
+	nameSize := elfFile.ByteOrder.Uint32(data[0:4])
+	descSize := elfFile.ByteOrder.Uint32(data[4:8])
+	noteType := elfFile.ByteOrder.Uint32(data[8:12])
+	name := string(data[12 : 12+len(buildIDNoteName)])
+	if nameSize != 4 || noteType != buildIDNoteType || name != buildIDNoteName || uint32(len(data)) < 16+descSize {
+		return "", errors.New("invalid build ID note")
+	}
+	return string(data[16 : 16+descSize]), nil
+}
+
+// findRawBuildID finds the build ID embedded in the data.
+func findRawBuildID(data []byte) (string, error) {
+	start := bytes.Index(data, goBuildIDPrefix)
+	if start < 0 {
+		return "", errors.New("build ID not found")
+	}
+	data = data[start+len(goBuildIDPrefix):]
+
+	end := bytes.Index(data, goBuildIDEnd)
+	if end < 0 {
+		return "", errors.New("build ID not terminated")
+	}
+	return strconv.Unquote("\"" + string(data[:end]) + "\"")
+}
+
+// splitBuildID returns the action ID, which is the hash of the build inputs, and the content ID, which is
+// the hash of the build output. They are the first and last hashes respectively, like the go tool does.
+func splitBuildID(id string) (actionID, contentID string) {
+	actionID = id
+	if i := strings.Index(id, buildIDSeparator); i >= 0 {
+		actionID = id[:i]
+	}
+	contentID = id[strings.LastIndex(id, buildIDSeparator)+1:]
+	return
+}
@@ -0,0 +1,100 @@
+package tracee
+
+import (
+	"debug/elf"
+	"strings"
+	"testing"
+
+	"github.com/ks888/tgo/testutils"
+)
+
+func TestReadBuildID(t *testing.T) {
+	id, err := readBuildID(testutils.ProgramHelloworld)
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	if len(strings.Split(id, buildIDSeparator)) < 2 {
+		t.Errorf("wrong build ID: %s", id)
+	}
+
+	sameID, err := readBuildID(testutils.ProgramHelloworld)
+	if err != nil || sameID != id {
+		t.Errorf("not stable: %s, %s, %v", id, sameID, err)
+	}
+
+	// the same source, but compiled separately with the different flags.
+	otherID, err := readBuildID(testutils.ProgramHelloworldNoDwarf)
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	_, contentID := splitBuildID(id)
+	_, otherContentID := splitBuildID(otherID)
+	if contentID == otherContentID {
+		t.Errorf("same content ID: %s", contentID)
+	}
+}
+
+func TestReadBuildID_ELFNote(t *testing.T) {
+	elfFile, err := elf.Open(testutils.ProgramHelloworldELF)
+	if err != nil {
+		t.Fatalf("failed to open: %v", err)
+	}
+	defer elfFile.Close()
+
+	noteID, err := readELFBuildIDNote(elfFile)
+	if err != nil {
+		t.Fatalf("failed to read the note: %v", err)
+	}
+
+	if len(strings.Split(noteID, buildIDSeparator)) < 2 {
+		t.Errorf("wrong build ID: %s", noteID)
+	}
+
+	id, err := readBuildID(testutils.ProgramHelloworldELF)
+	if err != nil || id != noteID {
+		t.Errorf("wrong build ID: %s, %v", id, err)
+	}
+}
+
+func TestReadBuildID_NotGoBinary(t *testing.T) {
+	if _, err := readBuildID(testutils.ProgramHelloworld + ".go"); err == nil {
+		t.Errorf("no error")
+	}
+}
+
+func TestFindRawBuildID(t *testing.T) {
+	for i, testdata := range []struct {
+		data      string
+		expect    string
+		expectErr bool
+	}{
+		{data: "\x00\xff Go build ID: \"a/b/c/d\"\n \xff\x00", expect: "a/b/c/d"},
+		{data: "\xff Go build ID: \"a/b\"\n \xff", expect: "a/b"},
+		{data: "\xff Go build ID: \"a/b", expectErr: true},
+		{data: "no build ID", expectErr: true},
+	} {
+		actual, err := findRawBuildID([]byte(testdata.data))
+		if (err != nil) != testdata.expectErr {
+			t.Errorf("[%d] unexpected error: %v", i, err)
+		}
+		if actual != testdata.expect {
+			t.Errorf("[%d] wrong build ID: %s", i, actual)
+		}
+	}
+}
+
+func TestSplitBuildID(t *testing.T) {
+	for i, testdata := range []struct {
+		id                  string
+		actionID, contentID string
+	}{
+		{id: "a/b/c/d", actionID: "a", contentID: "d"},
+		{id: "a/b", actionID: "a", contentID: "b"},
+		{id: "a", actionID: "a", contentID: "a"},
+	} {
+		actionID, contentID := splitBuildID(testdata.id)
+		if actionID != testdata.actionID || contentID != testdata.contentID {
+			t.Errorf("[%d] wrong result: %s, %s", i, actionID, contentID)
+		}
+	}
+}
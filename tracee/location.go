@@ -0,0 +1,263 @@
+package tracee
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ks888/tgo/debugapi"
+)
+
+// The DWARF operations dwarfLocationEval supports, in addition to DW_OP_addr, DW_OP_fbreg and DW_OP_call_frame_cfa.
+const (
+	dwarfOpConst1u    = 0x08 // DW_OP_const1u
+	dwarfOpConst1s    = 0x09 // DW_OP_const1s
+	dwarfOpConst2u    = 0x0a // DW_OP_const2u
+	dwarfOpConst2s    = 0x0b // DW_OP_const2s
+	dwarfOpConst4u    = 0x0c // DW_OP_const4u
+	dwarfOpConst4s    = 0x0d // DW_OP_const4s
+	dwarfOpConst8u    = 0x0e // DW_OP_const8u
+	dwarfOpConst8s    = 0x0f // DW_OP_const8s
+	dwarfOpConstu     = 0x10 // DW_OP_constu
+	dwarfOpConsts     = 0x11 // DW_OP_consts
+	dwarfOpMinus      = 0x1c // DW_OP_minus
+	dwarfOpPlus       = 0x22 // DW_OP_plus
+	dwarfOpPlusUconst = 0x23 // DW_OP_plus_uconst
+	dwarfOpLit0       = 0x30 // DW_OP_lit0
+	dwarfOpLit31      = 0x4f // DW_OP_lit31
+	dwarfOpReg0       = 0x50 // DW_OP_reg0
+	dwarfOpReg31      = 0x6f // DW_OP_reg31
+	dwarfOpBreg0      = 0x70 // DW_OP_breg0
+	dwarfOpBreg31     = 0x8f // DW_OP_breg31
+	dwarfOpRegx       = 0x90 // DW_OP_regx
+	dwarfOpBregx      = 0x92 // DW_OP_bregx
+	dwarfOpPiece      = 0x93 // DW_OP_piece
+	dwarfOpStackValue = 0x9f // DW_OP_stack_value
+)
+
+// errValueInRegister is returned when the value is not in the memory and so has no address.
+var errValueInRegister = errors.New("the value is in the register")
+
+// dwarfLocationEval evaluates the DWARF location expression and returns the address of the variable.
+// Go's frame base is always the CFA (DW_OP_call_frame_cfa), so the DW_OP_fbreg operation is relative to the cfa.
+type dwarfLocationEval struct {
+	// regs is the snapshot of the registers. The registers not in the snapshot are 0.
+	regs debugapi.Registers
+	// hasAllRegs is true if the snapshot holds all the registers. Otherwise, only the rip and rsp are in the snapshot.
+	// Even if true, the xmm registers are not in the snapshot. Read them from the `threadID` thread if necessary.
+	hasAllRegs bool
+	threadID   int
+	cfa        uint64
+}
+
+// eval returns the address of the variable the location expression describes.
+// It returns errValueInRegister if the value is in the register. The value separated into pieces is not supported.
+func (e dwarfLocationEval) eval(expr []byte) (uint64, error) {
+	if len(expr) == 0 {
+		return 0, errors.New("location expression is empty")
+	}
+
+	var stack []uint64
+	pop := func() (uint64, error) {
+		if len(stack) == 0 {
+			return 0, errors.New("stack is empty")
+		}
+		val := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return val, nil
+	}
+
+	for i := 0; i < len(expr); {
+		op := expr[i]
+		i++
+		operand := expr[i:]
+		switch {
+		case op == dwarfOpAddr:
+			if len(operand) < 8 {
+				return 0, errors.New("too short operand")
+			}
+			stack = append(stack, binary.LittleEndian.Uint64(operand))
+			i += 8
+		case op >= dwarfOpConst1u && op <= dwarfOpConst8s:
+			val, n, err := readFixedConst(op, operand)
+			if err != nil {
+				return 0, err
+			}
+			stack = append(stack, val)
+			i += n
+		case op == dwarfOpConstu:
+			val, n := readULEB128(operand)
+			stack = append(stack, val)
+			i += n
+		case op == dwarfOpConsts:
+			val, n := readSLEB128(operand)
+			stack = append(stack, uint64(val))
+			i += n
+		case op >= dwarfOpLit0 && op <= dwarfOpLit31:
+			stack = append(stack, uint64(op-dwarfOpLit0))
+		case op == dwarfOpPlus || op == dwarfOpMinus:
+			rhs, err := pop()
+			if err != nil {
+				return 0, err
+			}
+			lhs, err := pop()
+			if err != nil {
+				return 0, err
+			}
+			if op == dwarfOpPlus {
+				stack = append(stack, lhs+rhs)
+			} else {
+				stack = append(stack, lhs-rhs)
+			}
+		case op == dwarfOpPlusUconst:
+			top, err := pop()
+			if err != nil {
+				return 0, err
+			}
+			val, n := readULEB128(operand)
+			stack = append(stack, top+val)
+			i += n
+		case op == dwarfOpCallFrameCFA:
+			stack = append(stack, e.cfa)
+		case op == dwarfOpFbreg:
+			val, n := readSLEB128(operand)
+			stack = append(stack, e.cfa+uint64(val))
+			i += n
+		case op >= dwarfOpBreg0 && op <= dwarfOpBreg31:
+			reg, err := e.register(int(op - dwarfOpBreg0))
+			if err != nil {
+				return 0, err
+			}
+			val, n := readSLEB128(operand)
+			stack = append(stack, reg+uint64(val))
+			i += n
+		case op == dwarfOpBregx:
+			regNum, n := readULEB128(operand)
+			reg, err := e.register(int(regNum))
+			if err != nil {
+				return 0, err
+			}
+			val, m := readSLEB128(operand[n:])
+			stack = append(stack, reg+uint64(val))
+			i += n + m
+		case op >= dwarfOpReg0 && op <= dwarfOpReg31, op == dwarfOpRegx:
+			return 0, errValueInRegister
+		case op == dwarfOpPiece:
+			return 0, errors.New("the separated value is not supported")
+		case op == dwarfOpStackValue:
+			return 0, errors.New("the value is not in the memory")
+		default:
+			return 0, fmt.Errorf("unknown operation: %#x", op)
+		}
+	}
+
+	return pop()
+}
+
+// register returns the value of the register. The register number is the one defined in the System V AMD64 ABI.
+// It returns the error if the register is not in the snapshot.
+func (e dwarfLocationEval) register(regNum int) (uint64, error) {
+	if !e.hasAllRegs && !(regNum == 7 && e.regs.Rsp != 0) && !(regNum == 16 && e.regs.Rip != 0) {
+		return 0, fmt.Errorf("register %d is not available", regNum)
+	}
+
+	switch regNum {
+	case 0:
+		return e.regs.Rax, nil
+	case 1:
+		return e.regs.Rdx, nil
+	case 2:
+		return e.regs.Rcx, nil
+	case 3:
+		return e.regs.Rbx, nil
+	case 4:
+		return e.regs.Rsi, nil
+	case 5:
+		return e.regs.Rdi, nil
+	case 6:
+		return e.regs.Rbp, nil
+	case 7:
+		return e.regs.Rsp, nil
+	case 8:
+		return e.regs.R8, nil
+	case 9:
+		return e.regs.R9, nil
+	case 10:
+		return e.regs.R10, nil
+	case 11:
+		return e.regs.R11, nil
+	case 12:
+		return e.regs.R12, nil
+	case 13:
+		return e.regs.R13, nil
+	case 14:
+		return e.regs.R14, nil
+	case 15:
+		return e.regs.R15, nil
+	case 16:
+		return e.regs.Rip, nil
+	default:
+		return 0, fmt.Errorf("unknown register: %d", regNum)
+	}
+}
+
+// readFixedConst reads the operand of the DW_OP_constNu and DW_OP_constNs operations.
+func readFixedConst(op byte, operand []byte) (uint64, int, error) {
+	size := 1 << ((op - dwarfOpConst1u) / 2)
+	if len(operand) < size {
+		return 0, 0, errors.New("too short operand")
+	}
+
+	signed := (op-dwarfOpConst1u)%2 == 1
+	switch size {
+	case 1:
+		if signed {
+			return uint64(int8(operand[0])), size, nil
+		}
+		return uint64(operand[0]), size, nil
+	case 2:
+		val := binary.LittleEndian.Uint16(operand)
+		if signed {
+			return uint64(int16(val)), size, nil
+		}
+		return uint64(val), size, nil
+	case 4:
+		val := binary.LittleEndian.Uint32(operand)
+		if signed {
+			return uint64(int32(val)), size, nil
+		}
+		return uint64(val), size, nil
+	default:
+		return binary.LittleEndian.Uint64(operand), size, nil
+	}
+}
+
+// readULEB128 returns the decoded value and the number of bytes read.
+func readULEB128(input []byte) (val uint64, n int) {
+	for n < len(input) {
+		b := input[n]
+		val |= uint64(b&0x7f) << (7 * uint(n))
+		n++
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	return
+}
+
+// readSLEB128 returns the decoded value and the number of bytes read.
+func readSLEB128(input []byte) (val int64, n int) {
+	var b byte
+	for n < len(input) {
+		b = input[n]
+		val |= int64(b&0x7f) << (7 * uint(n))
+		n++
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	if shift := 7 * uint(n); shift < 64 && b&0x40 != 0 {
+		val |= -1 << shift
+	}
+	return
+}
@@ -0,0 +1,63 @@
+package tracee
+
+import (
+	"testing"
+
+	"github.com/ks888/tgo/debugapi"
+)
+
+func TestDWARFLocationEval(t *testing.T) {
+	eval := dwarfLocationEval{regs: debugapi.Registers{Rsp: 0x1000, Rbp: 0x2000}, hasAllRegs: true, cfa: 0x1008}
+	for i, testdata := range []struct {
+		expr     []byte
+		expected uint64
+	}{
+		{expr: []byte{dwarfOpCallFrameCFA}, expected: 0x1008},
+		{expr: []byte{dwarfOpFbreg, 0x08}, expected: 0x1010},
+		{expr: []byte{dwarfOpFbreg, 0x78}, expected: 0x1000},
+		{expr: []byte{dwarfOpAddr, 0x10, 0x20, 0x30, 0x40, 0, 0, 0, 0}, expected: 0x40302010},
+		{expr: []byte{dwarfOpBreg0 + 7, 0x10}, expected: 0x1010},
+		{expr: []byte{dwarfOpBregx, 6, 0x70}, expected: 0x1ff0},
+		{expr: []byte{dwarfOpCallFrameCFA, dwarfOpConsts, 0x78, dwarfOpPlus}, expected: 0x1000},
+		{expr: []byte{dwarfOpCallFrameCFA, dwarfOpPlusUconst, 0x80, 0x01}, expected: 0x1088},
+		{expr: []byte{dwarfOpCallFrameCFA, dwarfOpLit0 + 8, dwarfOpMinus}, expected: 0x1000},
+		{expr: []byte{dwarfOpConst2u, 0x00, 0x10, dwarfOpConst1s, 0xff, dwarfOpPlus}, expected: 0xfff},
+	} {
+		actual, err := eval.eval(testdata.expr)
+		if err != nil {
+			t.Errorf("[%d] failed to eval: %v", i, err)
+		} else if actual != testdata.expected {
+			t.Errorf("[%d] wrong address: %#x", i, actual)
+		}
+	}
+}
+
+func TestDWARFLocationEval_Error(t *testing.T) {
+	eval := dwarfLocationEval{cfa: 0x1008}
+	if _, err := eval.eval([]byte{dwarfOpReg0}); err != errValueInRegister {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	for i, expr := range [][]byte{
+		nil,
+		{dwarfOpPlus},
+		{dwarfOpAddr, 0x01},
+		{dwarfOpFbreg, 0x08, dwarfOpPiece, 0x08},
+		{0xff},
+		{dwarfOpBreg0 + 7, 0x10}, // the rsp is not in the snapshot
+	} {
+		if _, err := eval.eval(expr); err == nil {
+			t.Errorf("[%d] should return error", i)
+		}
+	}
+}
+
+func TestDWARFLocationEval_PartialRegisters(t *testing.T) {
+	eval := dwarfLocationEval{regs: debugapi.Registers{Rsp: 0x1000, Rip: 0x2000}, cfa: 0x1008}
+	if actual, err := eval.eval([]byte{dwarfOpBreg0 + 7, 0x10}); err != nil || actual != 0x1010 {
+		t.Errorf("wrong address: %#x, %v", actual, err)
+	}
+	if _, err := eval.eval([]byte{dwarfOpBreg0 + 6, 0x10}); err == nil {
+		t.Errorf("the rbp is not in the snapshot, but no error")
+	}
+}
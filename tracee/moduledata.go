@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"fmt"
 
+	"github.com/ks888/tgo/debugapi"
 	"github.com/ks888/tgo/log"
 )
 
@@ -16,6 +17,7 @@ type moduleData struct {
 	moduleDataAddr uint64
 	moduleDataType dwarf.Type
 	fields         map[string]*dwarf.StructField
+	pcln           pclnTable
 }
 
 func newModuleData(moduleDataAddr uint64, moduleDataType dwarf.Type) *moduleData {
@@ -27,17 +29,44 @@ func newModuleData(moduleDataAddr uint64, moduleDataType dwarf.Type) *moduleData
 	return &moduleData{moduleDataAddr: moduleDataAddr, moduleDataType: moduleDataType, fields: fields}
 }
 
-// pclntable retrieves the pclntable data specified by `index` because retrieving all the ftab data can be heavy.
+// pclntable retrieves the address of the pclntable data specified by `index` because retrieving all the pclntable data can be heavy.
 func (md *moduleData) pclntable(reader memoryReader, index int) uint64 {
-	ptrToArrayType, ptrToArray := md.retrieveArrayInSlice(reader, "pclntable")
+	return md.retrieveElementAddrInSlice(reader, "pclntable", index)
+}
+
+// pclntableAndText is same as pclntable, except it also returns the text. The text is read in the same batch as the pclntable address.
+func (md *moduleData) pclntableAndText(reader memoryBatchReader, index int) (addr, text uint64) {
+	ptrToArrayType, ptrToArray, text := md.retrieveArrayInSliceAndText(reader, "pclntable")
 	elementType := ptrToArrayType.(*dwarf.PtrType).Type
 
-	return ptrToArray + uint64(index)*uint64(elementType.Size())
+	return ptrToArray + uint64(index)*uint64(elementType.Size()), text
+}
+
+// funcnametab retrieves the address of the funcnametab data specified by `index`. Available since go1.16.
+func (md *moduleData) funcnametab(reader memoryReader, index int) uint64 {
+	return md.retrieveElementAddrInSlice(reader, "funcnametab", index)
+}
+
+// pctab retrieves the address of the pctab data specified by `index`. Available since go1.16.
+func (md *moduleData) pctab(reader memoryReader, index int) uint64 {
+	return md.retrieveElementAddrInSlice(reader, "pctab", index)
 }
 
 // functab retrieves the functab data specified by `index` because retrieving all the ftab data can be heavy.
+// The `entry` is the offset from the text section since go1.18.
 func (md *moduleData) functab(reader memoryReader, index int) (entry, funcoff uint64) {
 	ptrToFtabType, ptrToArray := md.retrieveArrayInSlice(reader, "ftab")
+	return md.readFunctab(reader, ptrToFtabType, ptrToArray, index)
+}
+
+// functabAndText is same as functab, except it also returns the text. The text is read in the same batch as the ftab address.
+func (md *moduleData) functabAndText(reader moduleDataReader, index int) (entry, funcoff, text uint64) {
+	ptrToFtabType, ptrToArray, text := md.retrieveArrayInSliceAndText(reader, "ftab")
+	entry, funcoff = md.readFunctab(reader, ptrToFtabType, ptrToArray, index)
+	return entry, funcoff, text
+}
+
+func (md *moduleData) readFunctab(reader memoryReader, ptrToFtabType dwarf.Type, ptrToArray uint64, index int) (entry, funcoff uint64) {
 	ftabType := ptrToFtabType.(*dwarf.PtrType).Type
 	functabSize := uint64(ftabType.Size())
 
@@ -53,9 +82,16 @@ func (md *moduleData) functab(reader memoryReader, index int) (entry, funcoff ui
 	}
 
 	for _, field := range ftabType.(*dwarf.StructType).Field {
-		val := binary.LittleEndian.Uint64(buff[field.ByteOffset : field.ByteOffset+field.Type.Size()])
+		var val uint64
+		rawData := buff[field.ByteOffset : field.ByteOffset+field.Type.Size()]
+		if len(rawData) == 4 {
+			// the fields are uint32 since go1.18
+			val = uint64(binary.LittleEndian.Uint32(rawData))
+		} else {
+			val = binary.LittleEndian.Uint64(rawData)
+		}
 		switch field.Name {
-		case "entry":
+		case "entry", "entryoff":
 			entry = val
 		case "funcoff":
 			funcoff = val
@@ -68,30 +104,43 @@ func (md *moduleData) ftabLen(reader memoryReader) int {
 	return md.retrieveSliceLen(reader, "ftab")
 }
 
-func (md *moduleData) findfunctab(reader memoryReader) uint64 {
-	return md.retrieveUint64(reader, "findfunctab")
-}
-
-func (md *moduleData) minpc(reader memoryReader) uint64 {
-	return md.retrieveUint64(reader, "minpc")
+// pcRange returns the minpc and maxpc. Both are read in one batch.
+func (md *moduleData) pcRange(reader memoryBatchReader) (minpc, maxpc uint64) {
+	vals := md.retrieveUint64s(reader, "minpc", "maxpc")
+	return vals[0], vals[1]
 }
 
-func (md *moduleData) maxpc(reader memoryReader) uint64 {
-	return md.retrieveUint64(reader, "maxpc")
+// findfunctabAndMinpc returns the findfunctab and minpc, which are used together to find the ftab index. Both are read in one batch.
+func (md *moduleData) findfunctabAndMinpc(reader memoryBatchReader) (findfunctab, minpc uint64) {
+	vals := md.retrieveUint64s(reader, "findfunctab", "minpc")
+	return vals[0], vals[1]
 }
 
 func (md *moduleData) types(reader memoryReader) uint64 {
 	return md.retrieveUint64(reader, "types")
 }
 
-func (md *moduleData) etypes(reader memoryReader) uint64 {
-	return md.retrieveUint64(reader, "etypes")
+// typeRange returns the types and etypes. Both are read in one batch.
+func (md *moduleData) typeRange(reader memoryBatchReader) (types, etypes uint64) {
+	vals := md.retrieveUint64s(reader, "types", "etypes")
+	return vals[0], vals[1]
 }
 
+// next returns the address of the next moduledata. It's 0 if the address is unknown.
 func (md *moduleData) next(reader memoryReader) uint64 {
+	if _, ok := md.fields["next"]; !ok {
+		return 0
+	}
 	return md.retrieveUint64(reader, "next")
 }
 
+func (md *moduleData) retrieveElementAddrInSlice(reader memoryReader, fieldName string, index int) uint64 {
+	ptrToArrayType, ptrToArray := md.retrieveArrayInSlice(reader, fieldName)
+	elementType := ptrToArrayType.(*dwarf.PtrType).Type
+
+	return ptrToArray + uint64(index)*uint64(elementType.Size())
+}
+
 func (md *moduleData) retrieveArrayInSlice(reader memoryReader, fieldName string) (dwarf.Type, uint64) {
 	typ, buff := md.retrieveFieldOfStruct(reader, md.fields[fieldName], "array")
 	if buff == nil {
@@ -101,6 +150,23 @@ func (md *moduleData) retrieveArrayInSlice(reader memoryReader, fieldName string
 	return typ, binary.LittleEndian.Uint64(buff)
 }
 
+// retrieveArrayInSliceAndText is same as retrieveArrayInSlice, except it also returns the text. Both are read in one batch.
+func (md *moduleData) retrieveArrayInSliceAndText(reader memoryBatchReader, fieldName string) (dwarf.Type, uint64, uint64) {
+	sliceField := md.fields[fieldName]
+	arrayField := findFieldOfStruct(sliceField.Type.(*dwarf.StructType), "array")
+	textField := md.fields["text"]
+	reads := []debugapi.MemoryRead{
+		{Addr: md.moduleDataAddr + uint64(sliceField.ByteOffset) + uint64(arrayField.ByteOffset), Buf: make([]byte, 8)},
+		{Addr: md.moduleDataAddr + uint64(textField.ByteOffset), Buf: make([]byte, 8)},
+	}
+	if err := reader.ReadMemoryBatch(reads); err != nil {
+		log.Debugf("failed to read memory: %v", err)
+		return nil, 0, 0
+	}
+
+	return arrayField.Type, binary.LittleEndian.Uint64(reads[0].Buf), binary.LittleEndian.Uint64(reads[1].Buf)
+}
+
 func (md *moduleData) retrieveSliceLen(reader memoryReader, fieldName string) int {
 	_, buff := md.retrieveFieldOfStruct(reader, md.fields[fieldName], "len")
 	if buff == nil {
@@ -117,17 +183,7 @@ func (md *moduleData) retrieveFieldOfStruct(reader memoryReader, strct *dwarf.St
 		return nil, nil
 	}
 
-	var field *dwarf.StructField
-	for _, candidate := range strctType.Field {
-		if candidate.Name == fieldName {
-			field = candidate
-			break
-		}
-	}
-	if field == nil {
-		panic(fmt.Sprintf("%s field not found", fieldName))
-	}
-
+	field := findFieldOfStruct(strctType, fieldName)
 	buff := make([]byte, field.Type.Size())
 	addr := md.moduleDataAddr + uint64(strct.ByteOffset) + uint64(field.ByteOffset)
 	if err := reader.ReadMemory(addr, buff); err != nil {
@@ -137,6 +193,15 @@ func (md *moduleData) retrieveFieldOfStruct(reader memoryReader, strct *dwarf.St
 	return field.Type, buff
 }
 
+func findFieldOfStruct(strctType *dwarf.StructType, fieldName string) *dwarf.StructField {
+	for _, candidate := range strctType.Field {
+		if candidate.Name == fieldName {
+			return candidate
+		}
+	}
+	panic(fmt.Sprintf("%s field not found", fieldName))
+}
+
 func (md *moduleData) retrieveUint64(reader memoryReader, fieldName string) uint64 {
 	field := md.fields[fieldName]
 	if field.Type.Size() != 8 {
@@ -150,3 +215,36 @@ func (md *moduleData) retrieveUint64(reader memoryReader, fieldName string) uint
 	}
 	return binary.LittleEndian.Uint64(buff)
 }
+
+type memoryBatchReader interface {
+	ReadMemoryBatch(reads []debugapi.MemoryRead) error
+}
+
+// moduleDataReader reads the memory either one by one or in batch.
+type moduleDataReader interface {
+	memoryReader
+	memoryBatchReader
+}
+
+// retrieveUint64s is same as retrieveUint64, except it reads the multiple fields in one batch.
+func (md *moduleData) retrieveUint64s(reader memoryBatchReader, fieldNames ...string) []uint64 {
+	reads := make([]debugapi.MemoryRead, len(fieldNames))
+	for i, fieldName := range fieldNames {
+		field := md.fields[fieldName]
+		if field.Type.Size() != 8 {
+			log.Printf("the type size is not expected value: %d", field.Type.Size())
+		}
+		reads[i] = debugapi.MemoryRead{Addr: md.moduleDataAddr + uint64(field.ByteOffset), Buf: make([]byte, 8)}
+	}
+
+	vals := make([]uint64, len(fieldNames))
+	if err := reader.ReadMemoryBatch(reads); err != nil {
+		log.Debugf("failed to read memory: %v", err)
+		return vals
+	}
+
+	for i, read := range reads {
+		vals[i] = binary.LittleEndian.Uint64(read.Buf)
+	}
+	return vals
+}
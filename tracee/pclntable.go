@@ -0,0 +1,248 @@
+package tracee
+
+import (
+	"debug/dwarf"
+	"encoding/binary"
+	"fmt"
+)
+
+// pclnTableVersion is the version of the pcln table format. The format changes as the go version changes.
+type pclnTableVersion int
+
+const (
+	// pclnTableVersion1 is used in go1.2 - go1.15.
+	pclnTableVersion1 pclnTableVersion = iota + 1
+	// pclnTableVersion2 is used in go1.16 - go1.17. The function names and pc-value tables are moved to the separate tables.
+	pclnTableVersion2
+	// pclnTableVersion3 is used in go1.18 and later. The entry addresses are the offsets from the text section.
+	pclnTableVersion3
+)
+
+const (
+	// must be same as the values defined in the debug/gosym package
+	go12PclnTableMagic  = 0xfffffffb
+	go116PclnTableMagic = 0xfffffffa
+	go118PclnTableMagic = 0xfffffff0
+	go120PclnTableMagic = 0xfffffff1
+)
+
+// pclnTableHeaderSize is the size of the header part all the versions share: magic (4 bytes), 2 pads, pc quantum and pointer size.
+const pclnTableHeaderSize = 8
+
+// readPclnTableHeader reads the header of the pcln table. In all the versions, the first field of the moduledata points to the header.
+func readPclnTableHeader(reader memoryReader, moduleDataAddr uint64) (version pclnTableVersion, pcQuantum int, err error) {
+	buff := make([]byte, 8)
+	if err := reader.ReadMemory(moduleDataAddr, buff); err != nil {
+		return 0, 0, err
+	}
+
+	header := make([]byte, pclnTableHeaderSize)
+	if err := reader.ReadMemory(binary.LittleEndian.Uint64(buff), header); err != nil {
+		return 0, 0, err
+	}
+
+	switch magic := binary.LittleEndian.Uint32(header); magic {
+	case go12PclnTableMagic:
+		version = pclnTableVersion1
+	case go116PclnTableMagic:
+		version = pclnTableVersion2
+	case go118PclnTableMagic, go120PclnTableMagic:
+		version = pclnTableVersion3
+	default:
+		return 0, 0, fmt.Errorf("unknown pcln table magic: %#x", magic)
+	}
+
+	if ptrSize := header[7]; ptrSize != 8 {
+		return 0, 0, fmt.Errorf("unsupported pointer size: %d", ptrSize)
+	}
+	return version, int(header[6]), nil
+}
+
+// funcInfo is the subset of the runtime._func struct.
+type funcInfo struct {
+	entry   uint64
+	nameoff int32
+	args    int32
+	pcsp    int32
+}
+
+// pclnTable reads the pcln table, which the runtime uses to find the function info by pc.
+// The implementation depends on the format version.
+type pclnTable interface {
+	// functab returns the entry address of the function specified by `index` and the offset of its _func struct in the pclntable.
+	functab(reader moduleDataReader, index int) (entry, funcoff uint64)
+	// funcInfo reads the _func struct at the `funcoff`.
+	funcInfo(reader moduleDataReader, funcoff uint64) (funcInfo, error)
+	// funcNameAddr returns the address of the function name specified by the _func's nameoff.
+	funcNameAddr(reader memoryReader, nameoff int) uint64
+	// pcvalueTableAddr returns the address of the pc-value table specified by the _func's offset like pcsp.
+	pcvalueTableAddr(reader memoryReader, offset int) uint64
+	// pcQuantum returns the unit of the pc delta in the pc-value table.
+	pcQuantum() int
+}
+
+func newPclnTable(version pclnTableVersion, md *moduleData, pcQuantum int) pclnTable {
+	switch version {
+	case pclnTableVersion2:
+		return pclnTableV2{md: md, quantum: pcQuantum}
+	case pclnTableVersion3:
+		return pclnTableV3{pclnTableV2{md: md, quantum: pcQuantum}}
+	default:
+		return pclnTableV1{md: md, quantum: pcQuantum}
+	}
+}
+
+// pclnTableV1 reads the pcln table of the version 1. All the data is in the pclntable.
+type pclnTableV1 struct {
+	md      *moduleData
+	quantum int
+}
+
+func (t pclnTableV1) functab(reader moduleDataReader, index int) (entry, funcoff uint64) {
+	return t.md.functab(reader, index)
+}
+
+func (t pclnTableV1) funcInfo(reader moduleDataReader, funcoff uint64) (funcInfo, error) {
+	return readFuncInfo(reader, t.md.pclntable(reader, int(funcoff)), _funcType)
+}
+
+func (t pclnTableV1) funcNameAddr(reader memoryReader, nameoff int) uint64 {
+	return t.md.pclntable(reader, nameoff)
+}
+
+func (t pclnTableV1) pcvalueTableAddr(reader memoryReader, offset int) uint64 {
+	return t.md.pclntable(reader, offset)
+}
+
+func (t pclnTableV1) pcQuantum() int {
+	return t.quantum
+}
+
+// pclnTableV2 reads the pcln table of the version 2. The function names are in the funcnametab
+// and the pc-value tables are in the pctab.
+type pclnTableV2 struct {
+	md      *moduleData
+	quantum int
+}
+
+func (t pclnTableV2) functab(reader moduleDataReader, index int) (entry, funcoff uint64) {
+	return t.md.functab(reader, index)
+}
+
+func (t pclnTableV2) funcInfo(reader moduleDataReader, funcoff uint64) (funcInfo, error) {
+	return readFuncInfo(reader, t.md.pclntable(reader, int(funcoff)), _funcType)
+}
+
+func (t pclnTableV2) funcNameAddr(reader memoryReader, nameoff int) uint64 {
+	return t.md.funcnametab(reader, nameoff)
+}
+
+func (t pclnTableV2) pcvalueTableAddr(reader memoryReader, offset int) uint64 {
+	return t.md.pctab(reader, offset)
+}
+
+func (t pclnTableV2) pcQuantum() int {
+	return t.quantum
+}
+
+// pclnTableV3 reads the pcln table of the version 3. Same as the version 2 except
+// the entry addresses in the functab and _func are the offsets from the text section.
+type pclnTableV3 struct {
+	pclnTableV2
+}
+
+func (t pclnTableV3) functab(reader moduleDataReader, index int) (entry, funcoff uint64) {
+	entryoff, funcoff, text := t.md.functabAndText(reader, index)
+	return text + entryoff, funcoff
+}
+
+func (t pclnTableV3) funcInfo(reader moduleDataReader, funcoff uint64) (funcInfo, error) {
+	addr, text := t.md.pclntableAndText(reader, int(funcoff))
+	info, err := readFuncInfo(reader, addr, _funcTypeV3)
+	if err != nil {
+		return funcInfo{}, err
+	}
+	info.entry += text
+	return info, nil
+}
+
+func readFuncInfo(reader memoryReader, addr uint64, funcType *dwarf.StructType) (funcInfo, error) {
+	buff := make([]byte, funcType.Size())
+	if err := reader.ReadMemory(addr, buff); err != nil {
+		return funcInfo{}, err
+	}
+
+	var info funcInfo
+	for _, field := range funcType.Field {
+		rawData := buff[field.ByteOffset : field.ByteOffset+field.Type.Size()]
+		switch field.Name {
+		case "entry":
+			info.entry = binary.LittleEndian.Uint64(rawData)
+		case "entryoff":
+			info.entry = uint64(binary.LittleEndian.Uint32(rawData))
+		case "nameoff":
+			info.nameoff = int32(binary.LittleEndian.Uint32(rawData))
+		case "args":
+			info.args = int32(binary.LittleEndian.Uint32(rawData))
+		case "pcsp":
+			info.pcsp = int32(binary.LittleEndian.Uint32(rawData))
+		}
+	}
+	return info, nil
+}
+
+// _funcType is the subset of the runtime._func type used in the pcln table version 1 and 2.
+var _funcType = &dwarf.StructType{
+	StructName: "runtime._func",
+	CommonType: dwarf.CommonType{ByteSize: 40},
+	Field: []*dwarf.StructField{
+		&dwarf.StructField{
+			Name:       "entry",
+			Type:       &dwarf.UintType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 8}}},
+			ByteOffset: 0,
+		},
+		&dwarf.StructField{
+			Name:       "nameoff",
+			Type:       &dwarf.IntType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 4}}},
+			ByteOffset: 8,
+		},
+		&dwarf.StructField{
+			Name:       "args",
+			Type:       &dwarf.IntType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 4}}},
+			ByteOffset: 12,
+		},
+		&dwarf.StructField{
+			Name:       "pcsp",
+			Type:       &dwarf.IntType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 4}}},
+			ByteOffset: 20,
+		},
+	},
+}
+
+// _funcTypeV3 is the subset of the runtime._func type used in the pcln table version 3.
+var _funcTypeV3 = &dwarf.StructType{
+	StructName: "runtime._func",
+	CommonType: dwarf.CommonType{ByteSize: 20},
+	Field: []*dwarf.StructField{
+		&dwarf.StructField{
+			Name:       "entryoff",
+			Type:       &dwarf.UintType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 4}}},
+			ByteOffset: 0,
+		},
+		&dwarf.StructField{
+			Name:       "nameoff",
+			Type:       &dwarf.IntType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 4}}},
+			ByteOffset: 4,
+		},
+		&dwarf.StructField{
+			Name:       "args",
+			Type:       &dwarf.IntType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 4}}},
+			ByteOffset: 8,
+		},
+		&dwarf.StructField{
+			Name:       "pcsp",
+			Type:       &dwarf.UintType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 4}}},
+			ByteOffset: 16,
+		},
+	},
+}
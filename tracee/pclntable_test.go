@@ -0,0 +1,65 @@
+package tracee
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/ks888/tgo/debugapi"
+	"github.com/ks888/tgo/testutils"
+)
+
+func TestReadPclnTableHeader(t *testing.T) {
+	client := debugapi.NewClient()
+	if err := client.LaunchProcess(testutils.ProgramHelloworld); err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	defer client.DetachProcess()
+
+	version, pcQuantum, err := readPclnTableHeader(client, testutils.HelloworldAddrFirstModuleData)
+	if err != nil {
+		t.Fatalf("failed to read header: %v", err)
+	}
+
+	expectedVersion := pclnTableVersion1
+	if goVersion := ParseGoVersion(runtime.Version()); goVersion.AtLeast(GoVersion{MajorVersion: 1, MinorVersion: 18}) {
+		expectedVersion = pclnTableVersion3
+	} else if goVersion.AtLeast(GoVersion{MajorVersion: 1, MinorVersion: 16}) {
+		expectedVersion = pclnTableVersion2
+	}
+	if version != expectedVersion {
+		t.Errorf("wrong version: %d", version)
+	}
+	if pcQuantum != 1 && pcQuantum != 4 {
+		t.Errorf("wrong pc quantum: %d", pcQuantum)
+	}
+}
+
+func TestPclnTable_FunctabAndFuncInfo(t *testing.T) {
+	proc, err := LaunchProcess(testutils.ProgramHelloworld, nil, helloworldAttr)
+	if err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	defer proc.Detach()
+
+	md := proc.findModuleDataByPC(testutils.HelloworldAddrMain)
+	if md == nil {
+		t.Fatalf("moduledata not found")
+	}
+	ftabIdx, err := proc.findFtabIndex(md, testutils.HelloworldAddrMain)
+	if err != nil {
+		t.Fatalf("failed to find ftab index: %v", err)
+	}
+	ftabIdx = proc.adjustFtabIndex(md, testutils.HelloworldAddrMain, ftabIdx)
+
+	entry, funcoff := md.pcln.functab(proc.debugapiClient, ftabIdx)
+	if entry != testutils.HelloworldAddrMain {
+		t.Errorf("wrong entry: %#x", entry)
+	}
+	info, err := md.pcln.funcInfo(proc.debugapiClient, funcoff)
+	if err != nil {
+		t.Fatalf("failed to read func info: %v", err)
+	}
+	if info.entry != entry {
+		t.Errorf("wrong entry in func info: %#x", info.entry)
+	}
+}
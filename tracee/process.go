@@ -3,17 +3,17 @@ package tracee
 import (
 	"debug/dwarf"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
+	"syscall"
 
 	"github.com/ks888/tgo/debugapi"
 	"github.com/ks888/tgo/log"
-	"golang.org/x/arch/x86/x86asm"
 )
 
-var breakpointInsts = []byte{0xcc}
-
 type breakpoint struct {
 	addr     uint64
 	orgInsts []byte
@@ -21,12 +21,37 @@ type breakpoint struct {
 
 // Process represents the tracee process launched by or attached to this tracer.
 type Process struct {
-	debugapiClient *debugapi.Client
+	debugapiClient debugapi.Debugger
 	breakpoints    map[uint64]breakpoint
 	Binary         BinaryFile
 	GoVersion      GoVersion
 	moduleDataList []*moduleData
 	valueParser    valueParser
+	arch           Arch
+	// hwBreakpoints holds the hardware breakpoints and watchpoints. The index is the slot.
+	hwBreakpoints [debugapi.MaxHardwareBreakpoints]hardwareBreakpoint
+	// hwBreakpointHits holds the address of the hardware breakpoint each thread hit at the last stop.
+	hwBreakpointHits map[int]uint64
+	// watchpointHits holds the address of the watchpoint each thread hit at the last stop.
+	watchpointHits map[int]uint64
+	// tlsOffsetToG is the offset of the g in the thread local storage.
+	tlsOffsetToG int32
+	// goRoutineDetails is true if CurrentGoRoutineInfo fills the details of the go routine. See SetGoRoutineDetails.
+	goRoutineDetails bool
+	// sourceLocations caches the source locations of the pcs. The stack frames are found at the same pcs again and again.
+	sourceLocations map[uint64]sourceLocation
+}
+
+type sourceLocation struct {
+	file string
+	line int
+}
+
+// hardwareBreakpoint represents the breakpoint or watchpoint which uses the debug register.
+type hardwareBreakpoint struct {
+	// addr is 0 if the slot is free.
+	addr       uint64
+	watchpoint bool
 }
 
 const countDisabled = -1
@@ -37,6 +62,39 @@ type StackFrame struct {
 	InputArguments  []Argument
 	OutputArguments []Argument
 	ReturnAddress   uint64
+	// File and Line are the source location of the pc. The File is empty if unknown.
+	File string
+	Line int
+	// isTailCall lazily checks if the function is tail called. See IsTailCall.
+	isTailCall func() bool
+	// pc and binary are used to find the inlined functions lazily.
+	pc     uint64
+	binary BinaryFile
+}
+
+// IsTailCall returns true if the function is jumped to from the function the caller called (e.g. the wrapper function).
+// The function reuses the stack frame of that function in this case.
+// It's checked lazily because the call instruction is read and decoded.
+func (f *StackFrame) IsTailCall() bool {
+	if f.isTailCall == nil {
+		return false
+	}
+	return f.isTailCall()
+}
+
+// InlinedFunctions returns the functions inlined at the pc, from the outermost to the innermost. Empty if none.
+// They are found lazily because walking the DWARF entries on every trap is costly.
+func (f *StackFrame) InlinedFunctions() []*Function {
+	if f.binary == nil {
+		return nil
+	}
+
+	functions, err := f.binary.inlinedFunctionsAt(f.pc)
+	if err != nil {
+		log.Debugf("failed to find the inlined functions: %v", err)
+		return nil
+	}
+	return functions
 }
 
 // Attributes specifies the set of tracee's attributes.
@@ -48,7 +106,11 @@ type Attributes struct {
 
 // LaunchProcess launches new tracee process.
 func LaunchProcess(name string, arg []string, attrs Attributes) (*Process, error) {
-	debugapiClient := debugapi.NewClient()
+	return LaunchProcessWithClient(debugapi.NewClient(), name, arg, attrs)
+}
+
+// LaunchProcessWithClient launches new tracee process using the specified debug api client.
+func LaunchProcessWithClient(debugapiClient debugapi.Debugger, name string, arg []string, attrs Attributes) (*Process, error) {
 	if err := debugapiClient.LaunchProcess(name, arg...); err != nil {
 		return nil, err
 	}
@@ -64,8 +126,28 @@ func LaunchProcess(name string, arg []string, attrs Attributes) (*Process, error
 }
 
 // AttachProcess attaches to the existing tracee process.
+// The program path and the go version are found from the process if not specified.
 func AttachProcess(pid int, attrs Attributes) (*Process, error) {
-	debugapiClient := debugapi.NewClient()
+	return AttachProcessWithClient(debugapi.NewClient(), pid, attrs)
+}
+
+// AttachProcessWithClient attaches to the existing tracee process using the specified debug api client.
+func AttachProcessWithClient(debugapiClient debugapi.Debugger, pid int, attrs Attributes) (*Process, error) {
+	if attrs.ProgramPath == "" {
+		programPath, err := programPathOf(pid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find the program path of the process %d: %w", pid, err)
+		}
+		attrs.ProgramPath = programPath
+	}
+	if attrs.CompiledGoVersion == "" {
+		goVersion, err := readGoVersion(attrs.ProgramPath)
+		if err != nil {
+			log.Debugf("failed to read the go version: %v", err)
+		}
+		attrs.CompiledGoVersion = goVersion
+	}
+
 	err := debugapiClient.AttachProcess(pid)
 	if err != nil {
 		return nil, err
@@ -78,24 +160,44 @@ func AttachProcess(pid int, attrs Attributes) (*Process, error) {
 	return proc, err
 }
 
-func newProcess(debugapiClient *debugapi.Client, attrs Attributes) (*Process, error) {
-	proc := &Process{debugapiClient: debugapiClient, breakpoints: make(map[uint64]breakpoint)}
+func newProcess(debugapiClient debugapi.Debugger, attrs Attributes) (*Process, error) {
+	proc := &Process{debugapiClient: debugapiClient, breakpoints: make(map[uint64]breakpoint), hwBreakpointHits: make(map[int]uint64), watchpointHits: make(map[int]uint64), sourceLocations: make(map[uint64]sourceLocation)}
 
-	proc.GoVersion = ParseGoVersion(attrs.CompiledGoVersion)
 	var err error
+	if proc.GoVersion, err = ParseGoVersionE(attrs.CompiledGoVersion); err != nil {
+		if attrs.CompiledGoVersion != "" {
+			return nil, err
+		}
+		// the go version may be unknown when attached. Assume the oldest version.
+		log.Debugf("the go version is unknown: %v", err)
+	}
+	proc.tlsOffsetToG = findOffsetToG(attrs.ProgramPath, proc.GoVersion)
 	proc.Binary, err = OpenBinaryFile(attrs.ProgramPath, proc.GoVersion)
 	if err != nil {
 		return nil, err
 	}
-	proc.moduleDataList = parseModuleDataList(attrs.FirstModuleDataAddr, proc.Binary.moduleDataType(), debugapiClient)
-	proc.valueParser = valueParser{reader: debugapiClient, mapRuntimeType: proc.mapRuntimeType}
+	// the architecture of the process may be different from the host's one (e.g. the process runs under Rosetta).
+	if proc.arch, err = findArchByName(debugapiClient.Architecture()); err != nil {
+		log.Debugf("use the architecture of the binary: %v", err)
+		proc.arch = proc.Binary.Arch()
+	}
+	proc.moduleDataList = parseModuleDataList(attrs.FirstModuleDataAddr, proc.Binary, debugapiClient)
+	proc.valueParser = valueParser{reader: debugapiClient, mapRuntimeType: proc.mapRuntimeType, findFunction: proc.Binary.FindFunction}
 	return proc, nil
 }
 
-func parseModuleDataList(firstModuleDataAddr uint64, moduleDataType dwarf.Type, reader memoryReader) (moduleDataList []*moduleData) {
+// parseModuleDataList follows the list of the moduledata. The format of the pcln table each moduledata has is detected here.
+func parseModuleDataList(firstModuleDataAddr uint64, binary BinaryFile, reader memoryReader) (moduleDataList []*moduleData) {
 	moduleDataAddr := firstModuleDataAddr
 	for moduleDataAddr != 0 {
-		md := newModuleData(moduleDataAddr, moduleDataType)
+		version, pcQuantum, err := readPclnTableHeader(reader, moduleDataAddr)
+		if err != nil {
+			log.Debugf("failed to read the pcln table header. Assume the oldest format: %v", err)
+			version, pcQuantum = pclnTableVersion1, 1
+		}
+
+		md := newModuleData(moduleDataAddr, binary.moduleDataType(version))
+		md.pcln = newPclnTable(version, md, pcQuantum)
 		moduleDataList = append(moduleDataList, md)
 
 		moduleDataAddr = md.next(reader)
@@ -103,17 +205,45 @@ func parseModuleDataList(firstModuleDataAddr uint64, moduleDataType dwarf.Type,
 	return
 }
 
+// RefreshModuleData appends the moduledata linked to the list after the process started, such as the one of
+// the plugin loaded by plugin.Open. The new moduledata is always linked at the end of the list, so only the
+// moduledata after the last known one is parsed.
+func (p *Process) RefreshModuleData() {
+	if len(p.moduleDataList) == 0 {
+		return
+	}
+
+	lastModuleData := p.moduleDataList[len(p.moduleDataList)-1]
+	newModuleDataList := parseModuleDataList(lastModuleData.next(p.debugapiClient), p.Binary, p.debugapiClient)
+	if len(newModuleDataList) > 0 {
+		log.Debugf("found %d new moduledata", len(newModuleDataList))
+		p.moduleDataList = append(p.moduleDataList, newModuleDataList...)
+	}
+}
+
 func (p *Process) mapRuntimeType(runtimeTypeAddr uint64) (dwarf.Type, error) {
-	var md *moduleData
-	var reader memoryReader = p.debugapiClient
-	for _, candidate := range p.moduleDataList {
-		if candidate.types(reader) <= runtimeTypeAddr && runtimeTypeAddr < candidate.etypes(reader) {
-			md = candidate
-			break
+	md := p.findModuleDataByTypeAddr(runtimeTypeAddr)
+	if md == nil {
+		p.RefreshModuleData()
+		if md = p.findModuleDataByTypeAddr(runtimeTypeAddr); md == nil {
+			return nil, fmt.Errorf("no moduledata found for type %#x", runtimeTypeAddr)
 		}
 	}
+	if md != p.moduleDataList[0] {
+		// the type belongs to the other module like the plugin, but only the DWARF of the program is loaded.
+		return nil, fmt.Errorf("the DWARF of the module which defines type %#x is not loaded", runtimeTypeAddr)
+	}
+
+	return p.Binary.findDwarfTypeByAddr(runtimeTypeAddr - md.types(p.debugapiClient))
+}
 
-	return p.Binary.findDwarfTypeByAddr(runtimeTypeAddr - md.types(reader))
+func (p *Process) findModuleDataByTypeAddr(runtimeTypeAddr uint64) *moduleData {
+	for _, candidate := range p.moduleDataList {
+		if types, etypes := candidate.typeRange(p.debugapiClient); types <= runtimeTypeAddr && runtimeTypeAddr < etypes {
+			return candidate
+		}
+	}
+	return nil
 }
 
 // Detach detaches from the tracee process. All breakpoints are cleared.
@@ -124,6 +254,14 @@ func (p *Process) Detach() error {
 			log.Debugf("failed to clear breakpoint at %#x: %v", breakpointAddr, err)
 		}
 	}
+	for _, bp := range p.hwBreakpoints {
+		if bp.addr == 0 {
+			continue
+		}
+		if err := p.clearHardwareBreakpoint(bp); err != nil {
+			log.Debugf("failed to clear hardware breakpoint at %#x: %v", bp.addr, err)
+		}
+	}
 
 	if err := p.debugapiClient.DetachProcess(); err != nil {
 		return err
@@ -138,14 +276,60 @@ func (p *Process) close() error {
 
 // ContinueAndWait continues the execution and waits until an event happens.
 // Note that the id of the stopped thread may be different from the id of the continued thread.
+// If some thread hits the watchpoint, the EventTypeWatched event is returned instead of the EventTypeTrapped event.
 func (p *Process) ContinueAndWait() (debugapi.Event, error) {
 	event, err := p.debugapiClient.ContinueAndWait()
 	if debugapi.IsExitEvent(event.Type) {
 		err = p.close()
 	}
+	if err == nil && (event.Type == debugapi.EventTypeTrapped || event.Type == debugapi.EventTypeWatched) {
+		// some debug api client tells the watchpoint hit by itself.
+		threadIDs, ok := event.Data.([]int)
+		if !ok {
+			threadIDs = event.Data.(debugapi.WatchedData).ThreadIDs
+		}
+		if err = p.checkHardwareBreakpointHits(threadIDs); err == nil {
+			for _, threadID := range threadIDs {
+				if addr, ok := p.watchpointHits[threadID]; ok {
+					event = debugapi.Event{Type: debugapi.EventTypeWatched, Data: debugapi.WatchedData{Addr: addr, ThreadIDs: threadIDs}}
+					break
+				}
+			}
+		}
+	}
 	return event, err
 }
 
+func (p *Process) checkHardwareBreakpointHits(threadIDs []int) error {
+	p.hwBreakpointHits = make(map[int]uint64)
+	p.watchpointHits = make(map[int]uint64)
+	if p.hwBreakpoints == ([debugapi.MaxHardwareBreakpoints]hardwareBreakpoint{}) {
+		return nil
+	}
+
+	for _, threadID := range threadIDs {
+		slot, hit, err := p.debugapiClient.HardwareBreakpointHit(threadID)
+		if err != nil {
+			return err
+		} else if !hit {
+			continue
+		}
+
+		if bp := p.hwBreakpoints[slot]; bp.watchpoint {
+			p.watchpointHits[threadID] = bp.addr
+		} else {
+			p.hwBreakpointHits[threadID] = bp.addr
+		}
+	}
+	return nil
+}
+
+// SetSignalForwarding sets whether the signal the tracee received is delivered to the tracee.
+// All the signals are delivered by default.
+func (p *Process) SetSignalForwarding(sig syscall.Signal, forward bool) {
+	p.debugapiClient.SetSignalForwarding(sig, forward)
+}
+
 // SingleStep executes one instruction while clearing and setting breakpoints.
 // If not all the threads are stopped, there is some possibility that another thread
 // passes through the breakpoint while single-stepping.
@@ -170,11 +354,14 @@ func (p *Process) SingleStep(threadID int, trappedAddr uint64) error {
 		if err := p.singleStepUnspecifiedThreads(threadID, unspecifiedError); err != nil {
 			return err
 		}
-		return p.SingleStep(threadID, trappedAddr)
+		if !containsThread(unspecifiedError.ThreadIDs, threadID) {
+			return p.SingleStep(threadID, trappedAddr)
+		}
+		// the specified thread is stepped as well. Do not step again, or the instruction is executed twice.
 	}
 
 	if bpSet {
-		return p.debugapiClient.WriteMemory(trappedAddr, breakpointInsts)
+		return p.debugapiClient.WriteMemory(trappedAddr, p.arch.BreakpointInstruction())
 	}
 	return nil
 }
@@ -204,6 +391,7 @@ func (p *Process) SetBreakpoint(addr uint64) error {
 		return nil
 	}
 
+	breakpointInsts := p.arch.BreakpointInstruction()
 	originalInsts := make([]byte, len(breakpointInsts))
 	if err := p.debugapiClient.ReadMemory(addr, originalInsts); err != nil {
 		return err
@@ -216,6 +404,133 @@ func (p *Process) SetBreakpoint(addr uint64) error {
 	return nil
 }
 
+// SetBreakpoints sets the breakpoints at the specified addresses. Unlike calling SetBreakpoint repeatedly,
+// the original instructions are read at once, which reduces the round trips to the debug server.
+func (p *Process) SetBreakpoints(addrs []uint64) error {
+	breakpointInsts := p.arch.BreakpointInstruction()
+	var reads []debugapi.MemoryRead
+	for _, addr := range addrs {
+		if _, ok := p.breakpoints[addr]; ok {
+			continue
+		}
+		reads = append(reads, debugapi.MemoryRead{Addr: addr, Buf: make([]byte, len(breakpointInsts))})
+	}
+	if err := p.debugapiClient.ReadMemoryBatch(reads); err != nil {
+		return err
+	}
+
+	for _, read := range reads {
+		if _, ok := p.breakpoints[read.Addr]; ok {
+			continue // duplicate address
+		}
+		if err := p.debugapiClient.WriteMemory(read.Addr, breakpointInsts); err != nil {
+			return err
+		}
+		p.breakpoints[read.Addr] = breakpoint{read.Addr, read.Buf}
+	}
+	return nil
+}
+
+// SetHardwareBreakpoint sets the hardware breakpoint at the specified address.
+// Unlike the software breakpoint, it doesn't modify the text, but at most 4 breakpoints can be set.
+// The watchpoints share the limit.
+// Note that the pc is not advanced when the thread is trapped by the hardware breakpoint.
+func (p *Process) SetHardwareBreakpoint(addr uint64) error {
+	slot, err := p.findFreeHardwareBreakpointSlot(hardwareBreakpoint{addr: addr})
+	if err != nil || slot == -1 {
+		return err
+	}
+
+	if err := p.debugapiClient.SetHardwareBreakpoint(slot, addr); err != nil {
+		return err
+	}
+	p.hwBreakpoints[slot] = hardwareBreakpoint{addr: addr}
+	return nil
+}
+
+// SetWatchpoint sets the watchpoint which watches the `size` bytes from the specified address.
+// The size must be 1, 2, 4 or 8 and the address must be aligned to the size.
+// The watchpoints share the limit of the hardware breakpoints.
+func (p *Process) SetWatchpoint(addr uint64, size int, mode debugapi.WatchMode) error {
+	slot, err := p.findFreeHardwareBreakpointSlot(hardwareBreakpoint{addr: addr, watchpoint: true})
+	if err != nil {
+		return err
+	} else if slot == -1 {
+		// updates the existing watchpoint in case the size or mode is changed.
+		for i, bp := range p.hwBreakpoints {
+			if bp.addr == addr && bp.watchpoint {
+				slot = i
+			}
+		}
+	}
+
+	if err := p.debugapiClient.SetWatchpoint(slot, addr, size, mode); err != nil {
+		return err
+	}
+	p.hwBreakpoints[slot] = hardwareBreakpoint{addr: addr, watchpoint: true}
+	return nil
+}
+
+// findFreeHardwareBreakpointSlot returns the free slot. It returns -1 if the breakpoint is set already.
+func (p *Process) findFreeHardwareBreakpointSlot(newBp hardwareBreakpoint) (int, error) {
+	if _, ok := p.arch.(X86_64Arch); !ok {
+		return 0, errors.New("hardware breakpoint is supported only on x86-64")
+	}
+
+	freeSlot := -1
+	for slot, bp := range p.hwBreakpoints {
+		if bp == newBp {
+			return -1, nil
+		} else if bp.addr == 0 && freeSlot == -1 {
+			freeSlot = slot
+		}
+	}
+	if freeSlot == -1 {
+		return 0, fmt.Errorf("all %d hardware breakpoints are in use", debugapi.MaxHardwareBreakpoints)
+	}
+	return freeSlot, nil
+}
+
+// ClearHardwareBreakpoint clears the hardware breakpoint at the specified address.
+func (p *Process) ClearHardwareBreakpoint(addr uint64) error {
+	return p.clearHardwareBreakpoint(hardwareBreakpoint{addr: addr})
+}
+
+// ClearWatchpoint clears the watchpoint at the specified address.
+func (p *Process) ClearWatchpoint(addr uint64) error {
+	return p.clearHardwareBreakpoint(hardwareBreakpoint{addr: addr, watchpoint: true})
+}
+
+func (p *Process) clearHardwareBreakpoint(target hardwareBreakpoint) error {
+	for slot, bp := range p.hwBreakpoints {
+		if bp != target {
+			continue
+		}
+
+		if err := p.debugapiClient.ClearHardwareBreakpoint(slot); err != nil {
+			return err
+		}
+		p.hwBreakpoints[slot] = hardwareBreakpoint{}
+	}
+	return nil
+}
+
+// HardwareBreakpointHit returns the address of the hardware breakpoint which trapped the thread at the last stop.
+func (p *Process) HardwareBreakpointHit(threadID int) (uint64, bool) {
+	addr, ok := p.hwBreakpointHits[threadID]
+	return addr, ok
+}
+
+// SetBreakpointByFileLine sets the breakpoint at the beginning of the code associated with the source file and line.
+// The `file` can be the suffix of the path, like `server.go` or `pkg/server.go`.
+func (p *Process) SetBreakpointByFileLine(file string, line int) error {
+	addr, err := p.Binary.FileLineToPC(file, line)
+	if err != nil {
+		return err
+	}
+	return p.SetBreakpoint(addr)
+}
+
 // ClearBreakpoint clears the breakpoint at the specified address.
 func (p *Process) ClearBreakpoint(addr uint64) error {
 	bp, ok := p.breakpoints[addr]
@@ -237,141 +552,445 @@ func (p *Process) ExistBreakpoint(addr uint64) bool {
 	return ok
 }
 
+// Arch returns the architecture of the process.
+func (p *Process) Arch() Arch {
+	return p.arch
+}
+
 // StackFrameAt returns the stack frame to which the given rbp specified.
 // To get the correct stack frame, it assumes:
 // * rsp points to the return address.
 // * rsp+8 points to the beginning of the args list.
 //
+// Go has no hidden pointer to the return area even if the function returns the large struct. The results are located
+// after the args (or in the registers), and the offsets of both are given by the DWARF location of each parameter.
+//
 // To be accurate, we need to check the .debug_frame section to find the CFA and return address.
 // But we omit the check here because this function is called at only the beginning or end of the tracee's function call.
+//
+// On ARM64, the return address is held in the link register (x30) rather than the stack, so the ReturnAddress is 0.
+// Use StackFrameWithRegisters to read it.
+//
+// The values of the args passed by the registers are not available. Use StackFrameWithRegisters to read them.
 func (p *Process) StackFrameAt(rsp, rip uint64) (*StackFrame, error) {
+	return p.stackFrameAt(dwarfLocationEval{regs: debugapi.Registers{Rip: rip, Rsp: rsp}, cfa: p.cfaAtEntry(rsp)})
+}
+
+// StackFrameWithRegisters is same as StackFrameAt, but the args passed by the registers are read from the `regs`.
+// The rsp and rip are regs.Rsp and regs.Rip. At the return address, the results are in the registers, but the input args are not.
+// The xmm registers are read from the thread only if the function may have the floating-point args in them.
+// On ARM64, the return address is regs.X30.
+func (p *Process) StackFrameWithRegisters(threadID int, regs debugapi.Registers) (*StackFrame, error) {
+	return p.stackFrameAt(dwarfLocationEval{regs: regs, hasAllRegs: true, threadID: threadID, cfa: p.cfaAtEntry(regs.Rsp)})
+}
+
+// cfaAtEntry returns the CFA at the beginning of the function. On x86-64, the call instruction pushed the return address.
+// On ARM64, the stack pointer is not changed by the call instruction.
+func (p *Process) cfaAtEntry(rsp uint64) uint64 {
+	if _, isARM64 := p.arch.(ARM64Arch); isARM64 {
+		return rsp
+	}
+	return rsp + 8
+}
+
+// stackFrameAt returns the stack frame. The function is at its beginning, so only the return address is pushed since the CFA.
+func (p *Process) stackFrameAt(eval dwarfLocationEval) (*StackFrame, error) {
+	rsp, rip := eval.regs.Rsp, eval.regs.Rip
 	function, err := p.FindFunction(rip)
 	if err != nil {
 		return nil, err
 	}
 
-	buff := make([]byte, 8)
-	if err := p.debugapiClient.ReadMemory(rsp, buff); err != nil {
-		return nil, err
+	retAddr := eval.regs.X30
+	if _, isARM64 := p.arch.(ARM64Arch); !isARM64 {
+		buff := make([]byte, 8)
+		if err := p.debugapiClient.ReadMemory(rsp, buff); err != nil {
+			if checkErr := p.checkReadable(rsp); checkErr != nil {
+				return nil, fmt.Errorf("invalid stack address: %w", checkErr)
+			}
+			return nil, fmt.Errorf("failed to read the return address of %s: %w", function.Name, err)
+		}
+		retAddr = binary.LittleEndian.Uint64(buff)
 	}
-	retAddr := binary.LittleEndian.Uint64(buff)
 
-	inputArgs, outputArgs, err := p.currentArgs(function.Parameters, rsp+8)
+	inputArgs, outputArgs, err := p.currentArgs(function, eval)
 	if err != nil {
 		return nil, err
 	}
 
+	file, line := p.sourceLocation(rip)
 	return &StackFrame{
 		Function:        function,
 		ReturnAddress:   retAddr,
 		InputArguments:  inputArgs,
 		OutputArguments: outputArgs,
+		File:            file,
+		Line:            line,
+		isTailCall:      func() bool { return p.isTailCall(function, retAddr) },
+		pc:              rip,
+		binary:          p.Binary,
 	}, nil
 }
 
-// FindFunction finds the function to which pc specifies.
-func (p *Process) FindFunction(pc uint64) (*Function, error) {
-	function, err := p.Binary.FindFunction(pc)
-	if err == nil {
-		p.fillInOutputParameters(pc, function.Parameters)
-		p.fillInUnknownParameter(pc, function.Parameters)
-		return function, err
+// sourceLocation returns the source file and line of the pc. The file is empty if unknown.
+func (p *Process) sourceLocation(pc uint64) (string, int) {
+	if location, ok := p.sourceLocations[pc]; ok {
+		return location.file, location.line
 	}
 
-	return p.findFunctionByModuleData(pc)
+	file, line, err := p.Binary.PCToFileLine(pc)
+	if err != nil {
+		log.Debugf("failed to find the source location: %v", err)
+	}
+	p.sourceLocations[pc] = sourceLocation{file: file, line: line}
+	return file, line
 }
 
-func (p *Process) fillInOutputParameters(pc uint64, params []Parameter) {
-	if !p.canFillInOutputParameters(pc, params) {
-		return
+// x86CallInstLen is the length of the `CALL rel32` instruction.
+const x86CallInstLen = 5
+
+// isTailCall returns true if the call instruction just before the return address calls the function other than `function`.
+// Only the direct call is checked because the target of the indirect call is unknown.
+func (p *Process) isTailCall(function *Function, retAddr uint64) bool {
+	callInstLen := x86CallInstLen
+	if _, isARM64 := p.arch.(ARM64Arch); isARM64 {
+		callInstLen = arm64InstLen // the `BL` instruction.
+	}
+	if retAddr < uint64(callInstLen) {
+		return false
 	}
 
-	p.doFillInOutputParameters(pc, params)
+	callAddr := retAddr - uint64(callInstLen)
+	buff := make([]byte, callInstLen)
+	if err := p.debugapiClient.ReadMemory(callAddr, buff); err != nil {
+		log.Debugf("failed to read the call instruction (addr: %x): %v", callAddr, err)
+		return false
+	}
+	p.restoreOriginalInsts(callAddr, buff)
 
-	sort.Slice(params, func(i, j int) bool { return params[i].Offset < params[j].Offset })
-	return
+	inst, err := p.arch.DecodeInstruction(buff)
+	if err != nil || inst.Len != callInstLen {
+		return false
+	}
+	return calledOther(function, inst, callAddr)
 }
 
-func (p *Process) canFillInOutputParameters(pc uint64, params []Parameter) bool {
-	for _, param := range params {
-		if param.IsOutput {
-			if param.Exist || !strings.HasPrefix(param.Name, "~r") {
-				return false
-			}
-		}
+// calledOther returns true if the `inst` at `callAddr` directly calls the function other than `function`.
+func calledOther(function *Function, inst Inst, callAddr uint64) bool {
+	target, ok := directCallTarget(inst, callAddr)
+	if !ok {
+		return false
 	}
-
-	if !p.noPadding(pc, params) {
-		// It may be dangerous to fill in the parameter's location due to the alignment.
+	if target == function.StartAddr {
 		return false
 	}
-	return true
+	return function.EndAddr == 0 || target < function.StartAddr || function.EndAddr <= target
 }
 
-func (p *Process) doFillInOutputParameters(pc uint64, params []Parameter) {
-	var outputIndexes []int
-	var totalSize, totalOutputSize int
-	for i, param := range params {
-		if param.IsOutput {
-			outputIndexes = append(outputIndexes, i)
-			totalOutputSize += int(param.Typ.Size())
-		}
-		totalSize += int(param.Typ.Size())
-	}
+// cgoFunctionName is the name of the pseudo function which represents the C frames in the stack trace.
+const cgoFunctionName = "<cgo>"
 
-	sort.Slice(outputIndexes, func(i, j int) bool { return params[outputIndexes[i]].Name < params[outputIndexes[j]].Name })
+// StackTrace returns the list of the stack frames of the go routine. The first frame is the innermost one.
+// It follows the return addresses until it reaches the runtime.goexit function or the top of the stack.
+//
+// The size of each frame is found by the pcsp table, which the runtime uses for the same purpose.
+// Unlike StackFrameAt, the go routine does not need to be at the beginning of the function.
+//
+// The C frames have no pcsp table. If the go routine is running the C function called via cgo, the C frames are
+// represented by the one frame of the <cgo> function, and then the frames of the go routine which called it follow.
+func (p *Process) StackTrace(goRoutineInfo GoRoutineInfo) ([]*StackFrame, error) {
+	stackHi := goRoutineInfo.CurrentStackAddr + goRoutineInfo.UsedStackSize
+	rsp, pc := goRoutineInfo.CurrentStackAddr, goRoutineInfo.CurrentPC
+
+	var stackFrames []*StackFrame
+	skippedCgoFrames := false
+	for i := 0; rsp < stackHi; i++ {
+		tracePC := pc
+		if i > 0 {
+			// pc is the return address. Use the address of the call instruction instead, because the call
+			// instruction may be the last instruction of the function.
+			tracePC--
+		}
 
-	currOffset := totalSize - totalOutputSize
-	for _, outputIndex := range outputIndexes {
-		params[outputIndex].Exist = true
-		params[outputIndex].Offset = currOffset
-		currOffset += int(params[outputIndex].Typ.Size())
-	}
-	return
-}
+		if !skippedCgoFrames && p.isForeignPC(tracePC) {
+			callerPC, callerSP, callerStackHi, err := p.cgoCaller(goRoutineInfo.gAddr)
+			if err != nil {
+				return nil, fmt.Errorf("failed to skip the C frames at %#x: %w", tracePC, err)
+			}
+			stackFrames = append(stackFrames, &StackFrame{Function: &Function{Name: cgoFunctionName}, ReturnAddress: callerPC})
+			// the frames of the caller are on its own stack.
+			rsp, pc, stackHi = callerSP, callerPC, callerStackHi
+			skippedCgoFrames = true
+			continue
+		}
 
-func (p *Process) fillInUnknownParameter(pc uint64, params []Parameter) {
-	if !p.canFillInUnknownParameter(pc, params) {
-		return
-	}
+		function, err := p.FindFunction(tracePC)
+		if err != nil {
+			return nil, err
+		}
 
-	unknownParamIndex := -1
-	for i, param := range params {
-		if !param.Exist {
-			unknownParamIndex = i
-			break
+		frameSize, err := p.findFrameSize(tracePC)
+		if err != nil {
+			return nil, err
 		}
-	}
 
-	offset := p.calculateUnknownParameterOffset(params)
-	params[unknownParamIndex].Exist = true
-	params[unknownParamIndex].Offset = offset
+		retAddrAddr := rsp + uint64(frameSize)
+		buff := make([]byte, 8)
+		if err := p.debugapiClient.ReadMemory(retAddrAddr, buff); err != nil {
+			return nil, fmt.Errorf("failed to read the return address of %s in the goroutine stack: %w", function.Name, err)
+		}
+		retAddr := binary.LittleEndian.Uint64(buff)
 
-	sort.Slice(params, func(i, j int) bool { return params[i].Offset < params[j].Offset })
-	return
-}
+		eval := dwarfLocationEval{regs: debugapi.Registers{Rip: pc, Rsp: rsp}, cfa: retAddrAddr + 8}
+		inputArgs, outputArgs, err := p.currentArgs(function, eval)
+		if err != nil {
+			return nil, err
+		}
 
-func (p *Process) canFillInUnknownParameter(pc uint64, params []Parameter) bool {
-	numNonExistParams := 0
-	for _, param := range params {
-		if !param.Exist {
-			numNonExistParams++
+		file, line := p.sourceLocation(tracePC)
+		stackFrames = append(stackFrames, &StackFrame{
+			Function:        function,
+			ReturnAddress:   retAddr,
+			InputArguments:  inputArgs,
+			OutputArguments: outputArgs,
+			File:            file,
+			Line:            line,
+			pc:              tracePC,
+			binary:          p.Binary,
+		})
+		if function.Name == "runtime.goexit" || retAddr == 0 {
+			break
 		}
-	}
-	if numNonExistParams != 1 {
-		// 0: no need to fill in.
-		// 1>: we can not fill in the parameter's location in decisive way.
-		return false
-	}
 
-	if !p.noPadding(pc, params) {
-		// It may be dangerous to fill in the parameter's location due to the alignment.
-		return false
+		rsp, pc = retAddrAddr+8, retAddr
 	}
-	return true
+	return stackFrames, nil
 }
 
-func (p *Process) noPadding(pc uint64, params []Parameter) bool {
+// ReturnAddress returns the return address of the function which is about to execute the instruction at `pc`.
+// Unlike StackFrameAt, the function does not need to be at its beginning.
+func (p *Process) ReturnAddress(rsp, pc uint64) (uint64, error) {
+	frameSize, err := p.findFrameSize(pc)
+	if err != nil {
+		return 0, err
+	}
+
+	buff := make([]byte, 8)
+	if err := p.debugapiClient.ReadMemory(rsp+uint64(frameSize), buff); err != nil {
+		return 0, fmt.Errorf("failed to read the return address: %w", err)
+	}
+	return binary.LittleEndian.Uint64(buff), nil
+}
+
+// LocalsAt returns the local variables in scope at `pc` of the function which is about to execute the instruction at `pc`.
+// Like ReturnAddress, the function does not need to be at its beginning, but its stack frame must be live.
+func (p *Process) LocalsAt(rsp, pc uint64) ([]Argument, error) {
+	frameSize, err := p.findFrameSize(pc)
+	if err != nil {
+		return nil, err
+	}
+
+	eval := dwarfLocationEval{regs: debugapi.Registers{Rip: pc, Rsp: rsp}, cfa: p.cfaAtEntry(rsp + uint64(frameSize))}
+	return p.currentLocals(pc, eval)
+}
+
+// isForeignPC returns true if the pc is out of all the go functions, typically in the C function. It's unknown
+// if the moduledata list is not available.
+func (p *Process) isForeignPC(pc uint64) bool {
+	if len(p.moduleDataList) == 0 || p.findModuleDataByPC(pc) != nil {
+		return false
+	}
+	p.RefreshModuleData()
+	return p.findModuleDataByPC(pc) == nil
+}
+
+// cgoCaller returns the pc and stack address the go routine saved when it called the C function via cgo
+// (the g's syscallpc and syscallsp fields), and the top of its stack.
+// The C function runs on the g0 stack of the thread, so the go routine is the curg of the g0's m.
+func (p *Process) cgoCaller(gAddr uint64) (pc, sp, stackHi uint64, err error) {
+	rawPtrToMType, rawVal, err := p.findFieldInStruct(gAddr, p.Binary.runtimeGType(), "m")
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	mAddr := binary.LittleEndian.Uint64(rawVal)
+	if mAddr == 0x0 {
+		return 0, 0, 0, errors.New("no m is associated")
+	}
+
+	ptrToMType, ok := rawPtrToMType.(*dwarf.PtrType)
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("unexpected m type: %T", rawPtrToMType)
+	}
+	_, rawVal, err = p.findFieldInStruct(mAddr, ptrToMType.Type, "curg")
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	curgAddr := binary.LittleEndian.Uint64(rawVal)
+	if curgAddr == 0x0 || curgAddr == gAddr {
+		return 0, 0, 0, errors.New("no go routine calls the C function")
+	}
+
+	types, rawVals, err := p.findFieldsInStruct(curgAddr, p.Binary.runtimeGType(), "syscallpc", "syscallsp", "stack")
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	pc = binary.LittleEndian.Uint64(rawVals[0])
+	sp = binary.LittleEndian.Uint64(rawVals[1])
+	if pc == 0 || sp == 0 {
+		return 0, 0, 0, errors.New("the go routine is not in the cgo call")
+	}
+	stackVal, ok := p.valueParser.parseValue(types[2], rawVals[2], 1).(structValue)
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("unexpected stack type: %#v", types[2])
+	}
+	stackHiVal, ok := stackVal.fields["hi"].(uint64Value)
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("unexpected stack.hi value: %#v", stackVal.fields["hi"])
+	}
+	return pc, sp, stackHiVal.val, nil
+}
+
+// CallStack returns the list of the stack frames of the go routine the thread is running. The first frame is the innermost one.
+func (p *Process) CallStack(threadID int) ([]*StackFrame, error) {
+	goRoutineInfo, err := p.CurrentGoRoutineInfo(threadID)
+	if err != nil {
+		return nil, err
+	}
+	return p.StackTrace(goRoutineInfo)
+}
+
+// AllThreadRegisters returns the registers of all the threads. The key is the thread id.
+// The thread whose registers are not readable, such as the running thread on linux, is not included.
+func (p *Process) AllThreadRegisters() (map[int]debugapi.Registers, error) {
+	threadIDs, err := p.debugapiClient.ThreadIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	allRegs := make(map[int]debugapi.Registers)
+	for _, threadID := range threadIDs {
+		regs, err := p.debugapiClient.ReadRegisters(threadID)
+		if err != nil {
+			log.Debugf("failed to read the registers of the thread %d: %v", threadID, err)
+			continue
+		}
+		allRegs[threadID] = regs
+	}
+	return allRegs, nil
+}
+
+// FindFunction finds the function to which pc specifies.
+func (p *Process) FindFunction(pc uint64) (*Function, error) {
+	if p.Binary.IsStripped() {
+		function, err := p.findFunctionByModuleData(pc)
+		if err == nil {
+			return function, nil
+		}
+		// the moduledata may be unknown. Still, the stripped binary has the function names in its pcln table section.
+		return p.Binary.FindFunction(pc)
+	}
+
+	function, err := p.Binary.FindFunction(pc)
+	if err == nil {
+		p.fillInOutputParameters(pc, function.Parameters)
+		p.fillInUnknownParameter(pc, function.Parameters)
+		return function, err
+	}
+
+	return p.findFunctionByModuleData(pc)
+}
+
+func (p *Process) fillInOutputParameters(pc uint64, params []Parameter) {
+	if !p.canFillInOutputParameters(pc, params) {
+		return
+	}
+
+	p.doFillInOutputParameters(pc, params)
+
+	sort.Slice(params, func(i, j int) bool { return params[i].Offset < params[j].Offset })
+	return
+}
+
+func (p *Process) canFillInOutputParameters(pc uint64, params []Parameter) bool {
+	for _, param := range params {
+		if param.IsOutput {
+			if param.Exist || !strings.HasPrefix(param.Name, "~r") {
+				return false
+			}
+		}
+	}
+
+	if !p.noPadding(pc, params) {
+		// It may be dangerous to fill in the parameter's location due to the alignment.
+		return false
+	}
+	return true
+}
+
+func (p *Process) doFillInOutputParameters(pc uint64, params []Parameter) {
+	var outputIndexes []int
+	var totalSize, totalOutputSize int
+	for i, param := range params {
+		if param.IsOutput {
+			outputIndexes = append(outputIndexes, i)
+			totalOutputSize += int(param.Typ.Size())
+		}
+		totalSize += int(param.Typ.Size())
+	}
+
+	sort.Slice(outputIndexes, func(i, j int) bool { return params[outputIndexes[i]].Name < params[outputIndexes[j]].Name })
+
+	currOffset := totalSize - totalOutputSize
+	for _, outputIndex := range outputIndexes {
+		params[outputIndex].Exist = true
+		params[outputIndex].Offset = currOffset
+		currOffset += int(params[outputIndex].Typ.Size())
+	}
+	return
+}
+
+func (p *Process) fillInUnknownParameter(pc uint64, params []Parameter) {
+	if !p.canFillInUnknownParameter(pc, params) {
+		return
+	}
+
+	unknownParamIndex := -1
+	for i, param := range params {
+		if !param.Exist {
+			unknownParamIndex = i
+			break
+		}
+	}
+
+	offset := p.calculateUnknownParameterOffset(params)
+	params[unknownParamIndex].Exist = true
+	params[unknownParamIndex].Offset = offset
+
+	sort.Slice(params, func(i, j int) bool { return params[i].Offset < params[j].Offset })
+	return
+}
+
+func (p *Process) canFillInUnknownParameter(pc uint64, params []Parameter) bool {
+	numNonExistParams := 0
+	for _, param := range params {
+		if !param.Exist {
+			numNonExistParams++
+		}
+	}
+	if numNonExistParams != 1 {
+		// 0: no need to fill in.
+		// 1>: we can not fill in the parameter's location in decisive way.
+		return false
+	}
+
+	if !p.noPadding(pc, params) {
+		// It may be dangerous to fill in the parameter's location due to the alignment.
+		return false
+	}
+	return true
+}
+
+func (p *Process) noPadding(pc uint64, params []Parameter) bool {
 	expectedArgsSize, err := p.findFunctionArgsSize(pc)
 	if err != nil {
 		log.Debugf("failed to find function args size: %v", err)
@@ -391,18 +1010,11 @@ func (p *Process) findFunctionArgsSize(pc uint64) (int, error) {
 		return 0, fmt.Errorf("no moduledata found for pc %#x", pc)
 	}
 
-	funcTypeVal, _, err := p.findFuncType(md, pc)
+	info, _, err := p.findFuncInfo(md, pc)
 	if err != nil {
 		return 0, err
 	}
-
-	for _, field := range _funcType.Field {
-		if field.Name == "args" {
-			rawData := funcTypeVal[field.ByteOffset : field.ByteOffset+field.Type.Size()]
-			return int(binary.LittleEndian.Uint32(rawData)), nil
-		}
-	}
-	return 0, fmt.Errorf("failed to find args size at %#x", pc)
+	return int(info.args), nil
 }
 
 func (p *Process) calculateUnknownParameterOffset(params []Parameter) int {
@@ -451,62 +1063,30 @@ var findfuncbucketType = &dwarf.StructType{
 	},
 }
 
-// Assume this dwarf.Type represents a subset of the _func type in the case DWARF is not available.
-var _funcType = &dwarf.StructType{
-	StructName: "runtime._func",
-	CommonType: dwarf.CommonType{ByteSize: 40},
-	Field: []*dwarf.StructField{
-		&dwarf.StructField{
-			Name:       "entry",
-			Type:       &dwarf.UintType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 8}}},
-			ByteOffset: 0,
-		},
-		&dwarf.StructField{
-			Name:       "nameoff",
-			Type:       &dwarf.IntType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 4}}},
-			ByteOffset: 8,
-		},
-		&dwarf.StructField{
-			Name:       "args",
-			Type:       &dwarf.IntType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 4}}},
-			ByteOffset: 12,
-		},
-	},
-}
-
 // findFunctionByModuleData has the same logic as the runtime.findfunc.
 func (p *Process) findFunctionByModuleData(pc uint64) (*Function, error) {
 	md := p.findModuleDataByPC(pc)
 	if md == nil {
-		return nil, fmt.Errorf("no moduledata found for pc %#x", pc)
+		// the function may be in the plugin loaded after the moduledata list is parsed.
+		p.RefreshModuleData()
+		if md = p.findModuleDataByPC(pc); md == nil {
+			return nil, fmt.Errorf("no moduledata found for pc %#x", pc)
+		}
 	}
 
-	funcTypeVal, endAddr, err := p.findFuncType(md, pc)
+	info, endAddr, err := p.findFuncInfo(md, pc)
 	if err != nil {
 		return nil, err
 	}
 
-	var entry uint64
-	var nameoff int32
-	var args int32
-	for _, field := range _funcType.Field {
-		rawData := funcTypeVal[field.ByteOffset : field.ByteOffset+field.Type.Size()]
-		switch field.Name {
-		case "entry":
-			entry = binary.LittleEndian.Uint64(rawData)
-		case "nameoff":
-			nameoff = int32(binary.LittleEndian.Uint32(rawData))
-		case "args":
-			args = int32(binary.LittleEndian.Uint32(rawData))
-			if args < 0 {
-				// In Go's Assembler, the args size declared in the TEXT directive can be omitted.
-				// In that case, `args` here may be negative.
-				args = 0
-			}
-		}
+	args := info.args
+	if args < 0 {
+		// In Go's Assembler, the args size declared in the TEXT directive can be omitted.
+		// In that case, `args` here may be negative.
+		args = 0
 	}
 
-	funcName, err := p.resolveNameoff(md, int(nameoff))
+	funcName, err := p.resolveNameoff(md, int(info.nameoff))
 	if err != nil {
 		return nil, err
 	}
@@ -524,12 +1104,16 @@ func (p *Process) findFunctionByModuleData(pc uint64) (*Function, error) {
 		params = append(params, param)
 	}
 
-	return &Function{Name: funcName, StartAddr: entry, EndAddr: endAddr, Parameters: params}, nil
+	abi := detectABI(funcName, false, registerABIAvailable(p.GoVersion, p.arch))
+	function := &Function{Name: funcName, StartAddr: info.entry, EndAddr: endAddr, Parameters: params, ABI: abi}
+	function.Annotate(AnnotationABI, abi.String())
+	function.annotateBool(AnnotationParametersGuessed, true)
+	return function, nil
 }
 
 func (p *Process) findModuleDataByPC(pc uint64) *moduleData {
 	for _, moduleData := range p.moduleDataList {
-		if moduleData.minpc(p.debugapiClient) <= pc && pc < moduleData.maxpc(p.debugapiClient) {
+		if minpc, maxpc := moduleData.pcRange(p.debugapiClient); minpc <= pc && pc < maxpc {
 			return moduleData
 		}
 	}
@@ -542,7 +1126,7 @@ const (
 	pcbucketsize = 256 * minfunc // size of bucket in the pc->func lookup table
 )
 
-// findFuncType implements the core logic to find the func type using pc.
+// findFuncInfo implements the core logic to find the func type using pc.
 // The logic is essentially same as the one used in the runtime.findfunc().
 // It involves 2 tables and linear search and has 4 steps (if the only 1 table is there, it must be huge!).
 // (1) Find the bucket. `findfunctab` points to the array of the buckets.
@@ -555,25 +1139,20 @@ const (
 //     We can find out the rough index using the index the bucket holds + sub-index the subbucket holds.
 //     But it may not be correct, because 1 subbucket region is typically 256 and may contain multiple functions.
 //     So do the linear search to find the correct index.
-// (4) Finally, get the func type using the funcoff field in functab, the pointer to the func type embedded in the pcln table.
+// (4) Finally, get the func type using the funcoff field in functab, the offset to the func type embedded in the pcln table.
 //     Note that the pcln table contains not only func type, but other data like function name.
-func (p *Process) findFuncType(md *moduleData, pc uint64) ([]byte, uint64, error) {
+func (p *Process) findFuncInfo(md *moduleData, pc uint64) (funcInfo, uint64, error) {
 	ftabIdx, err := p.findFtabIndex(md, pc)
 	if err != nil {
-		return nil, 0, err
+		return funcInfo{}, 0, err
 	}
 
 	ftabIdx = p.adjustFtabIndex(md, pc, ftabIdx)
 	endAddr := p.findEndAddr(md, ftabIdx)
-	_, funcoff := md.functab(p.debugapiClient, ftabIdx)
-
-	funcTypePtr := md.pclntable(p.debugapiClient, int(funcoff))
-	buff := make([]byte, _funcType.Size())
-	if err := p.debugapiClient.ReadMemory(funcTypePtr, buff); err != nil {
-		return nil, 0, err
-	}
+	_, funcoff := md.pcln.functab(p.debugapiClient, ftabIdx)
 
-	return buff, endAddr, nil
+	info, err := md.pcln.funcInfo(p.debugapiClient, funcoff)
+	return info, endAddr, err
 }
 
 func (p *Process) findFtabIndex(md *moduleData, pc uint64) (int, error) {
@@ -587,14 +1166,15 @@ func (p *Process) findFtabIndex(md *moduleData, pc uint64) (int, error) {
 		}
 	}
 
-	x := pc - md.minpc(p.debugapiClient)
+	findfunctab, minpc := md.findfunctabAndMinpc(p.debugapiClient)
+	x := pc - minpc
 	bucketIndex := x / pcbucketsize
 	subbucketIndex := int(x % pcbucketsize / (pcbucketsize / uint64(subbucketsField.Type.Size())))
 
-	ptrToFindFuncBucket := md.findfunctab(p.debugapiClient) + bucketIndex*uint64(findfuncbucketType.Size())
+	ptrToFindFuncBucket := findfunctab + bucketIndex*uint64(findfuncbucketType.Size())
 	buff := make([]byte, findfuncbucketType.Size())
 	if err := p.debugapiClient.ReadMemory(ptrToFindFuncBucket, buff); err != nil {
-		return 0, err
+		return 0, fmt.Errorf("failed to read the findfuncbucket for %#x: %w", pc, err)
 	}
 
 	ftabIdx := int(binary.LittleEndian.Uint32(buff[idxField.ByteOffset : idxField.ByteOffset+idxField.Type.Size()]))
@@ -608,21 +1188,21 @@ func (p *Process) adjustFtabIndex(md *moduleData, pc uint64, ftabIdx int) int {
 		ftabIdx = ftabLen - 1
 	}
 
-	entry, _ := md.functab(p.debugapiClient, ftabIdx)
+	entry, _ := md.pcln.functab(p.debugapiClient, ftabIdx)
 	if pc < entry {
 		for entry > pc && ftabIdx > 0 {
 			ftabIdx--
-			entry, _ = md.functab(p.debugapiClient, ftabIdx)
+			entry, _ = md.pcln.functab(p.debugapiClient, ftabIdx)
 		}
 		if ftabIdx == 0 {
 			panic("bad findfunctab entry idx")
 		}
 	} else {
 		// linear search to find func with pc >= entry.
-		nextEntry, _ := md.functab(p.debugapiClient, ftabIdx+1)
+		nextEntry, _ := md.pcln.functab(p.debugapiClient, ftabIdx+1)
 		for nextEntry <= pc {
 			ftabIdx++
-			nextEntry, _ = md.functab(p.debugapiClient, ftabIdx+1)
+			nextEntry, _ = md.pcln.functab(p.debugapiClient, ftabIdx+1)
 		}
 	}
 	return ftabIdx
@@ -633,12 +1213,89 @@ func (p *Process) findEndAddr(md *moduleData, ftabIdx int) uint64 {
 	if ftabIdx+1 >= ftabLen {
 		return 0
 	}
-	entry, _ := md.functab(p.debugapiClient, ftabIdx+1)
+	entry, _ := md.pcln.functab(p.debugapiClient, ftabIdx+1)
 	return entry
 }
 
+// findFrameSize returns the size of the stack frame (excluding the return address) at the specified pc.
+// The logic is same as the one used in the runtime.funcspdelta().
+func (p *Process) findFrameSize(pc uint64) (int, error) {
+	md := p.findModuleDataByPC(pc)
+	if md == nil {
+		return 0, fmt.Errorf("no moduledata found for pc %#x", pc)
+	}
+
+	info, _, err := p.findFuncInfo(md, pc)
+	if err != nil {
+		return 0, err
+	}
+
+	frameSize, err := p.pcvalue(md, int(info.pcsp), info.entry, pc)
+	if err != nil {
+		return 0, err
+	} else if frameSize < 0 {
+		return 0, fmt.Errorf("invalid frame size at %#x: %d", pc, frameSize)
+	}
+	return frameSize, nil
+}
+
+// pcvalue looks up the pc-value table (e.g. pcsp table) starting at the `offset` in the pclntable.
+// The logic is same as the one used in the runtime.pcvalue().
+// The table is the sequence of (value delta, pc delta) pairs. The value delta is zig-zag encoded.
+func (p *Process) pcvalue(md *moduleData, offset int, entry, targetPC uint64) (int, error) {
+	if offset == 0 {
+		return 0, fmt.Errorf("no pc-value table for pc %#x", targetPC)
+	}
+
+	reader := &memoryByteReader{reader: p.debugapiClient, addr: md.pcln.pcvalueTableAddr(p.debugapiClient, offset)}
+	val := int32(-1)
+	pc := entry
+	for first := true; ; first = false {
+		uvdelta, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return 0, err
+		} else if uvdelta == 0 && !first {
+			break
+		}
+		val += int32(-(uvdelta & 1) ^ (uvdelta >> 1))
+
+		pcdelta, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return 0, err
+		}
+		pc += pcdelta * uint64(md.pcln.pcQuantum())
+
+		if targetPC < pc {
+			return int(val), nil
+		}
+	}
+	return 0, fmt.Errorf("pc %#x not found in pc-value table", targetPC)
+}
+
+// memoryByteReader reads the memory of the tracee process byte by byte.
+// It caches the chunk of the memory to avoid frequent memory reads.
+type memoryByteReader struct {
+	reader memoryReader
+	addr   uint64
+	buff   []byte
+}
+
+func (r *memoryByteReader) ReadByte() (byte, error) {
+	if len(r.buff) == 0 {
+		r.buff = make([]byte, 64)
+		if err := r.reader.ReadMemory(r.addr, r.buff); err != nil {
+			return 0, err
+		}
+		r.addr += uint64(len(r.buff))
+	}
+
+	b := r.buff[0]
+	r.buff = r.buff[1:]
+	return b, nil
+}
+
 func (p *Process) resolveNameoff(md *moduleData, nameoff int) (string, error) {
-	ptrToFuncname := md.pclntable(p.debugapiClient, nameoff)
+	ptrToFuncname := md.pcln.funcNameAddr(p.debugapiClient, nameoff)
 	var rawFuncname []byte
 	for {
 		buff := make([]byte, 16)
@@ -657,7 +1314,73 @@ func (p *Process) resolveNameoff(md *moduleData, nameoff int) (string, error) {
 	}
 }
 
-func (p *Process) currentArgs(params []Parameter, addrBeginningOfArgs uint64) (inputArgs []Argument, outputArgs []Argument, err error) {
+// currentArgs returns the args of the function. The way to read the args depends on the function's ABI.
+func (p *Process) currentArgs(function *Function, eval dwarfLocationEval) (inputArgs []Argument, outputArgs []Argument, err error) {
+	if function.ABI == ABIABIInternal {
+		inputArgs, outputArgs = p.argsInRegisters(function.Parameters, eval)
+		return
+	}
+	return p.argsOnStack(function.Parameters, eval)
+}
+
+// argsInRegisters returns the args assigned by the register-based calling convention. The values are read from the register
+// snapshot in `eval`, so the input args are valid at the beginning of the function and the results at the return address.
+// The args which don't fit in the registers are on the stack, following the return address.
+func (p *Process) argsInRegisters(params []Parameter, eval dwarfLocationEval) (inputArgs []Argument, outputArgs []Argument) {
+	if _, isX86 := p.arch.(X86_64Arch); !isX86 || !eval.hasAllRegs {
+		// the registers used by the calling convention are not available.
+		for _, param := range params {
+			arg := Argument{Name: param.Name, Typ: param.Typ, parseValue: func(depth int) value { return nil }}
+			if param.IsOutput {
+				outputArgs = append(outputArgs, arg)
+			} else {
+				inputArgs = append(inputArgs, arg)
+			}
+		}
+		return
+	}
+
+	regs := eval.regs
+	if usesFloatRegisters(params) {
+		if err := p.debugapiClient.ReadXMMRegisters(eval.threadID, &regs); err != nil {
+			log.Debugf("failed to read the xmm registers: %v", err)
+		}
+	}
+	assigner := &abiAssigner{regs: x86ABIRegisters(regs)}
+	for _, param := range params {
+		if !param.IsOutput {
+			inputArgs = append(inputArgs, p.argInRegisters(param, assigner, eval.cfa))
+		}
+	}
+	assigner.finishInputs()
+	for _, param := range params {
+		if param.IsOutput {
+			outputArgs = append(outputArgs, p.argInRegisters(param, assigner, eval.cfa))
+		}
+	}
+	return
+}
+
+func (p *Process) argInRegisters(param Parameter, assigner *abiAssigner, cfa uint64) Argument {
+	val, stackOffset, onStack := assigner.assign(param.Typ)
+	parseValue := func(depth int) value {
+		if !onStack {
+			return p.valueParser.parseValue(param.Typ, val, depth)
+		}
+
+		buff := make([]byte, param.Typ.Size())
+		if err := p.debugapiClient.ReadMemory(cfa+uint64(stackOffset), buff); err != nil {
+			log.Debugf("failed to read the '%s' value: %v", param.Name, err)
+			return nil
+		}
+		return p.valueParser.parseValue(param.Typ, buff, depth)
+	}
+	return Argument{Name: param.Name, Typ: param.Typ, parseValue: parseValue}
+}
+
+// argsOnStack returns the args on the stack. The address of the arg is evaluated using its location expression if
+// available. Otherwise, the offset is used, which is the case when the parameters are guessed.
+func (p *Process) argsOnStack(params []Parameter, eval dwarfLocationEval) (inputArgs []Argument, outputArgs []Argument, err error) {
 	for _, param := range params {
 		param := param // without this, all the closures point to the last param.
 		parseValue := func(depth int) value {
@@ -665,9 +1388,19 @@ func (p *Process) currentArgs(params []Parameter, addrBeginningOfArgs uint64) (i
 				return nil
 			}
 
+			addr := eval.cfa + uint64(param.Offset)
+			if param.location != nil {
+				evaluated, err := eval.eval(param.location)
+				if err != nil {
+					log.Debugf("failed to evaluate the location of '%s': %v", param.Name, err)
+					return nil
+				}
+				addr = evaluated
+			}
+
 			size := param.Typ.Size()
 			buff := make([]byte, size)
-			if err = p.debugapiClient.ReadMemory(addrBeginningOfArgs+uint64(param.Offset), buff); err != nil {
+			if err = p.debugapiClient.ReadMemory(addr, buff); err != nil {
 				log.Debugf("failed to read the '%s' value: %v", param.Name, err)
 				return nil
 			}
@@ -684,37 +1417,243 @@ func (p *Process) currentArgs(params []Parameter, addrBeginningOfArgs uint64) (i
 	return
 }
 
-// ReadInstructions reads the instructions of the specified function from memory.
-func (p *Process) ReadInstructions(f *Function) ([]x86asm.Inst, error) {
-	if f.EndAddr == 0 {
-		return nil, fmt.Errorf("the end address of the function %s is unknown", f.Name)
-	}
-
-	buff := make([]byte, f.EndAddr-f.StartAddr)
-	if err := p.debugapiClient.ReadMemory(f.StartAddr, buff); err != nil {
+// currentLocals returns the local variables in scope at the pc. The variables not in the memory are excluded.
+func (p *Process) currentLocals(pc uint64, eval dwarfLocationEval) ([]Argument, error) {
+	variables, err := p.Binary.localVariables(pc)
+	if err != nil {
 		return nil, err
 	}
 
-	for addr, bp := range p.breakpoints {
-		if f.StartAddr <= addr && addr < f.EndAddr {
-			copy(buff[addr-f.StartAddr:], bp.orgInsts)
-		}
-	}
-
-	var pos int
-	var insts []x86asm.Inst
-	for pos < len(buff) {
-		inst, err := x86asm.Decode(buff[pos:len(buff)], 64)
+	var locals []Argument
+	for _, variable := range variables {
+		variable := variable
+		addr, err := eval.eval(variable.location)
 		if err != nil {
-			log.Debugf("decode error at %#x: %v", pos, err)
+			log.Debugf("failed to evaluate the location of '%s': %v", variable.name, err)
+			continue
+		}
+		parseValue := func(depth int) value {
+			buff := make([]byte, variable.typ.Size())
+			if err := p.debugapiClient.ReadMemory(addr, buff); err != nil {
+				log.Debugf("failed to read the '%s' value: %v", variable.name, err)
+				return nil
+			}
+			return p.valueParser.parseValue(variable.typ, buff, depth)
+		}
+
+		locals = append(locals, Argument{Name: variable.name, Typ: variable.typ, parseValue: parseValue})
+	}
+	return locals, nil
+}
+
+// ReadMemory reads the memory of the tracee process. The breakpoint instructions may be included.
+func (p *Process) ReadMemory(addr uint64, out []byte) error {
+	return p.debugapiClient.ReadMemory(addr, out)
+}
+
+// WriteMemory writes the data to the memory of the tracee process.
+func (p *Process) WriteMemory(addr uint64, data []byte) error {
+	return p.debugapiClient.WriteMemory(addr, data)
+}
+
+// MemoryRegions returns the mapped memory regions of the tracee process.
+func (p *Process) MemoryRegions() ([]debugapi.MemoryRegion, error) {
+	return p.debugapiClient.MemoryRegions()
+}
+
+// checkReadable returns the error describing why the address is not readable, or nil if it is readable.
+func (p *Process) checkReadable(addr uint64) error {
+	region, err := p.debugapiClient.MemoryRegionInfo(addr)
+	if err == debugapi.ErrNotMapped {
+		return fmt.Errorf("%#x is not mapped", addr)
+	} else if err != nil {
+		return err
+	}
+
+	if !region.Readable {
+		return fmt.Errorf("%#x is not readable", addr)
+	}
+	return nil
+}
+
+// ReadMemoryBatch reads the multiple memory regions. The nearby regions are read at once
+// to reduce the round trips to the debug server.
+func (p *Process) ReadMemoryBatch(reads []debugapi.MemoryRead) error {
+	return p.debugapiClient.ReadMemoryBatch(reads)
+}
+
+// ReadInstructions reads the instructions of the specified function from memory.
+// The instructions are decoded using the tracee's architecture.
+func (p *Process) ReadInstructions(f *Function) ([]Inst, error) {
+	if f.EndAddr == 0 {
+		return nil, fmt.Errorf("the end address of the function %s is unknown", f.Name)
+	}
+
+	annotatedInsts, err := p.disassemble(f.StartAddr, f.EndAddr, false)
+	if err != nil {
+		return nil, err
+	}
+
+	insts := make([]Inst, 0, len(annotatedInsts))
+	for _, annotatedInst := range annotatedInsts {
+		insts = append(insts, annotatedInst.Inst)
+	}
+	return insts, nil
+}
+
+// maxStackCheckLen is the max length of the instructions to check the stack growth at the beginning of the function.
+const maxStackCheckLen = 32
+
+// FunctionBodyStart returns the address next to the stack growth check at the beginning of the function.
+// The stack pointer is not changed yet at the address, so StackFrameAt works there. Unlike the function's start
+// address, the address is not executed again when the go routine returns from the stack growth routine.
+// Returns the start address if the function doesn't check the stack growth.
+func (p *Process) FunctionBodyStart(f *Function) (uint64, error) {
+	addrs, err := p.FunctionBodyStarts([]*Function{f})
+	if err != nil {
+		return 0, err
+	}
+	return addrs[0], nil
+}
+
+// FunctionBodyStarts is the batch version of FunctionBodyStart. The beginnings of the functions are read at once.
+func (p *Process) FunctionBodyStarts(functions []*Function) ([]uint64, error) {
+	reads := make([]debugapi.MemoryRead, len(functions))
+	for i, f := range functions {
+		size := uint64(maxStackCheckLen)
+		if f.Size() != 0 && f.Size() < size {
+			size = f.Size()
+		}
+		reads[i] = debugapi.MemoryRead{Addr: f.StartAddr, Buf: make([]byte, size)}
+	}
+	if err := p.debugapiClient.ReadMemoryBatch(reads); err != nil {
+		return nil, err
+	}
+
+	addrs := make([]uint64, len(functions))
+	for i, f := range functions {
+		addrs[i] = p.bodyStart(f.StartAddr, reads[i].Buf)
+		f.Annotate(AnnotationPrologueSize, strconv.FormatUint(addrs[i]-f.StartAddr, 10))
+	}
+	return addrs, nil
+}
+
+// bodyStart decodes the instructions in the buff and returns the address next to the stack growth check.
+func (p *Process) bodyStart(startAddr uint64, buff []byte) uint64 {
+	p.restoreOriginalInsts(startAddr, buff)
+
+	var prevInst Inst
+	for pos := 0; pos < len(buff); {
+		inst, err := p.arch.DecodeInstruction(buff[pos:])
+		pos += inst.Len
+		if err != nil {
+			prevInst = Inst{}
+			continue
+		}
+		if isStackCheck(prevInst, inst) {
+			return startAddr + uint64(pos)
+		}
+		prevInst = inst
+	}
+	return startAddr
+}
+
+// restoreOriginalInsts replaces the breakpoint instructions in the buff, which is read from the startAddr, with the original ones.
+func (p *Process) restoreOriginalInsts(startAddr uint64, buff []byte) {
+	endAddr := startAddr + uint64(len(buff))
+	for addr, bp := range p.breakpoints {
+		if startAddr <= addr && addr < endAddr {
+			copy(buff[addr-startAddr:], bp.orgInsts)
+		}
+	}
+}
+
+// AnnotatedInst is the instruction with the info useful to debug.
+type AnnotatedInst struct {
+	Addr uint64
+	Inst Inst
+	// HasBreakpoint is true if the breakpoint is set at the address. Note that the Inst is the original instruction.
+	HasBreakpoint bool
+	// SourceLine is the source location like `/path/to/main.go:10`. Empty if unknown.
+	SourceLine string
+}
+
+// Text returns the string representation of the instruction using the specified syntax.
+func (inst AnnotatedInst) Text(syntax AsmSyntax) string {
+	return inst.Inst.Format(inst.Addr, syntax)
+}
+
+// Disassemble decodes the instructions in the specified region [start, end).
+// The instructions replaced by the breakpoints are restored before decoding.
+func (p *Process) Disassemble(start, end uint64) ([]AnnotatedInst, error) {
+	return p.disassemble(start, end, true)
+}
+
+// disassemble decodes the instructions. The source line is not set unless `withSourceLine` is true because it's costly.
+func (p *Process) disassemble(start, end uint64, withSourceLine bool) ([]AnnotatedInst, error) {
+	if end <= start {
+		return nil, fmt.Errorf("invalid region: %#x-%#x", start, end)
+	}
+
+	buff := make([]byte, end-start)
+	if err := p.debugapiClient.ReadMemory(start, buff); err != nil {
+		return nil, err
+	}
+	p.restoreOriginalInsts(start, buff)
+
+	return decodeInsts(p.arch, start, buff, func(inst *AnnotatedInst) {
+		inst.HasBreakpoint = p.ExistBreakpoint(inst.Addr)
+		if withSourceLine {
+			inst.SourceLine = sourceLineAt(p.Binary, inst.Addr)
+		}
+	}), nil
+}
+
+// DisassembleBinary decodes the instructions in the specified region [start, end) of the binary file.
+// Unlike Process.Disassemble, the instructions are read from the program file, so the program needn't be launched.
+func DisassembleBinary(binaryFile BinaryFile, start, end uint64) ([]AnnotatedInst, error) {
+	if end <= start {
+		return nil, fmt.Errorf("invalid region: %#x-%#x", start, end)
+	}
+
+	buff := make([]byte, end-start)
+	if err := binaryFile.ReadProgramData(start, buff); err != nil {
+		return nil, err
+	}
+
+	return decodeInsts(binaryFile.Arch(), start, buff, func(inst *AnnotatedInst) {
+		inst.SourceLine = sourceLineAt(binaryFile, inst.Addr)
+	}), nil
+}
+
+// decodeInsts decodes the instructions in the buff, which is read from the start address. `annotate` is called for each instruction.
+func decodeInsts(arch Arch, start uint64, buff []byte, annotate func(inst *AnnotatedInst)) []AnnotatedInst {
+	var pos int
+	var insts []AnnotatedInst
+	for pos < len(buff) {
+		addr := start + uint64(pos)
+		inst, err := arch.DecodeInstruction(buff[pos:len(buff)])
+		if err != nil {
+			log.Debugf("decode error at %#x: %v", pos, err)
 		} else {
-			insts = append(insts, inst)
+			annotatedInst := AnnotatedInst{Addr: addr, Inst: inst}
+			annotate(&annotatedInst)
+			insts = append(insts, annotatedInst)
 		}
 
 		pos += inst.Len
 	}
 
-	return insts, nil
+	return insts
+}
+
+// sourceLineAt returns the source location like `/path/to/main.go:10`. Empty if unknown.
+func sourceLineAt(binaryFile BinaryFile, addr uint64) string {
+	file, line, err := binaryFile.PCToFileLine(addr)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", file, line)
 }
 
 // GoRoutineInfo describes the various info of the go routine like pc.
@@ -726,6 +1665,20 @@ type GoRoutineInfo struct {
 	NextDeferFuncAddr uint64
 	Panicking         bool
 	PanicHandler      *PanicHandler
+	// Status is the raw value of the g's atomicstatus field. See the _G* constants in the runtime package.
+	Status int
+	// WatchpointAddr is the address of the watchpoint the go routine hit at the last stop. 0 if not hit.
+	WatchpointAddr uint64
+	// Registers are the registers of the thread at the stop. Only CurrentGoRoutineInfo sets them. Nil otherwise.
+	Registers *debugapi.Registers
+	// ThreadID is the id of the OS thread running the go routine. 0 if unknown.
+	// Only CurrentGoRoutineInfo sets it if enabled by SetGoRoutineDetails. See also GoRoutineThreadID.
+	ThreadID int
+	// Labels are the profiler labels set by pprof.Do or pprof.SetGoroutineLabels. Nil if no labels or unknown.
+	// Only CurrentGoRoutineInfo sets them if enabled by SetGoRoutineDetails. See also GoRoutineLabels.
+	Labels map[string]string
+	// gAddr is the address of the g struct.
+	gAddr uint64
 }
 
 // PanicHandler holds the function info which (will) handles panic.
@@ -735,6 +1688,120 @@ type PanicHandler struct {
 	PCAtDefer            uint64
 }
 
+// DeferredCall is the function call registered by the defer statement.
+type DeferredCall struct {
+	Function *Function
+	// Arguments are the args the function is registered with. Empty if the args are captured by the closure,
+	// which is always the case since go 1.17.
+	Arguments []Argument
+}
+
+// DeferredCallAt returns the function call being registered by the defer statement.
+// The thread must be at the beginning of runtime.deferproc or runtime.deferprocStack.
+func (p *Process) DeferredCallAt(threadID int) (*DeferredCall, error) {
+	// deferproc(siz int32, fn *funcval) or deferprocStack(d *_defer) before go 1.18, and
+	// deferproc(fn func()) or deferprocStack(d *_defer) after that.
+	deferProc, firstArg, argsAddr, err := p.funcValArgAt(threadID, "runtime.deferproc")
+	if err != nil {
+		return nil, err
+	}
+
+	funcValAddr := firstArg
+	if deferProc.Name == "runtime.deferprocStack" {
+		ptrToDeferType, err := structFieldType(p.Binary.runtimeGType(), "_defer")
+		if err != nil {
+			return nil, err
+		}
+		ptrType, ok := ptrToDeferType.(*dwarf.PtrType)
+		if !ok {
+			return nil, fmt.Errorf("unexpected _defer type: %T", ptrToDeferType)
+		}
+		deferType := ptrType.Type
+		_, rawVal, err := p.findFieldInStruct(firstArg, deferType, "fn")
+		if err != nil {
+			return nil, err
+		}
+		funcValAddr = binary.LittleEndian.Uint64(rawVal)
+		if argsAddr != 0 {
+			argsAddr = firstArg + uint64(deferType.Size()) // the args follow the _defer struct.
+		}
+	}
+
+	function, err := p.funcValFunction(funcValAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	deferredCall := &DeferredCall{Function: function}
+	if argsAddr != 0 && function.ABI != ABIABIInternal {
+		// the args are copied in the same layout as the args of the function call.
+		deferredCall.Arguments, _, err = p.argsOnStack(function.Parameters, dwarfLocationEval{cfa: argsAddr})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return deferredCall, nil
+}
+
+// SpawnedFunctionAt returns the function the go statement is spawning. The thread must be at the beginning of runtime.newproc.
+func (p *Process) SpawnedFunctionAt(threadID int) (*Function, error) {
+	// newproc(siz int32, fn *funcval) before go 1.18, and newproc(fn *funcval) after that.
+	_, funcValAddr, _, err := p.funcValArgAt(threadID, "runtime.newproc")
+	if err != nil {
+		return nil, err
+	}
+	return p.funcValFunction(funcValAddr)
+}
+
+// funcValArgAt returns the runtime function the thread is at the beginning of, and its funcval arg (or the first arg if the
+// function has no funcval arg). The function named `funcWithSize` has the siz arg before the funcval arg before go 1.18.
+// argsAddr is the address of the stack after the funcval arg, which is available only when the args are on the stack.
+func (p *Process) funcValArgAt(threadID int, funcWithSize string) (runtimeFunc *Function, funcValArg, argsAddr uint64, err error) {
+	regs, err := p.debugapiClient.ReadRegisters(threadID)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	runtimeFunc, err = p.FindFunction(regs.Rip)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	hasSize := runtimeFunc.Name == funcWithSize && p.GoVersion.OlderThan(GoVersion{MajorVersion: 1, MinorVersion: 18})
+	if runtimeFunc.ABI == ABIABIInternal {
+		if hasSize {
+			return runtimeFunc, regs.Rbx, 0, nil
+		}
+		return runtimeFunc, regs.Rax, 0, nil
+	}
+
+	argAddr := regs.Rsp + 8
+	if hasSize {
+		argAddr += 8
+	}
+	buff := make([]byte, 8)
+	if err := p.debugapiClient.ReadMemory(argAddr, buff); err != nil {
+		return nil, 0, 0, err
+	}
+	return runtimeFunc, binary.LittleEndian.Uint64(buff), argAddr + 8, nil
+}
+
+// funcValFunction returns the function the funcval points to.
+func (p *Process) funcValFunction(funcValAddr uint64) (*Function, error) {
+	buff := make([]byte, 8)
+	if err := p.debugapiClient.ReadMemory(funcValAddr, buff); err != nil {
+		return nil, fmt.Errorf("failed to read the funcval at %#x: %w", funcValAddr, err)
+	}
+	return p.FindFunction(binary.LittleEndian.Uint64(buff))
+}
+
+const (
+	// must be same as the values defined in runtime package
+	goRoutineStatusRunning = 2      // _Grunning
+	goRoutineStatusWaiting = 4      // _Gwaiting
+	goRoutineStatusDead    = 6      // _Gdead
+	goRoutineStatusScan    = 0x1000 // _Gscan
+)
+
 // CurrentGoRoutineInfo returns the go routine info associated with the go routine which hits the breakpoint.
 func (p *Process) CurrentGoRoutineInfo(threadID int) (GoRoutineInfo, error) {
 	gAddr, err := p.debugapiClient.ReadTLS(threadID, p.offsetToG())
@@ -750,30 +1817,126 @@ func (p *Process) CurrentGoRoutineInfo(threadID int) (GoRoutineInfo, error) {
 		return p.CurrentGoRoutineInfo(threadID)
 	}
 
-	_, idRawVal, err := p.findFieldInStruct(gAddr, p.Binary.runtimeGType(), "goid")
+	regs, err := p.debugapiClient.ReadRegisters(threadID)
 	if err != nil {
 		return GoRoutineInfo{}, err
 	}
-	id := int64(binary.LittleEndian.Uint64(idRawVal))
 
-	stackType, stackRawVal, err := p.findFieldInStruct(gAddr, p.Binary.runtimeGType(), "stack")
+	info, err := p.goRoutineInfo(gAddr, regs.Rip, regs.Rsp)
 	if err != nil {
 		return GoRoutineInfo{}, err
 	}
-	stackVal := p.valueParser.parseValue(stackType, stackRawVal, 1)
-	stackHi := stackVal.(structValue).fields["hi"].(uint64Value).val
+	info.Status = goRoutineStatusRunning
+	info.WatchpointAddr = p.watchpointHits[threadID]
+	info.Registers = &regs
+	if p.goRoutineDetails {
+		info.ThreadID = p.GoRoutineThreadID(info)
+		info.Labels = p.GoRoutineLabels(info)
+	}
+	return info, nil
+}
 
-	regs, err := p.debugapiClient.ReadRegisters(threadID)
+// SetGoRoutineDetails sets whether CurrentGoRoutineInfo fills the ThreadID and Labels of the go routine.
+// It's disabled by default because the details require extra memory reads on every call.
+func (p *Process) SetGoRoutineDetails(enabled bool) {
+	p.goRoutineDetails = enabled
+}
+
+// ListGoRoutines returns the info of all the go routines which are not dead.
+// For the go routine which is not running, the CurrentPC and CurrentStackAddr are the values saved when the go routine
+// was descheduled last time. It requires the DWARF info.
+func (p *Process) ListGoRoutines() ([]GoRoutineInfo, error) {
+	gAddrs, err := p.allGAddrs()
 	if err != nil {
-		return GoRoutineInfo{}, err
+		return nil, err
 	}
-	usedStackSize := stackHi - regs.Rsp
 
-	_, panicRawVal, err := p.findFieldInStruct(gAddr, p.Binary.runtimeGType(), "_panic")
+	var goRoutines []GoRoutineInfo
+	for _, gAddr := range gAddrs {
+		if gAddr == 0 {
+			continue
+		}
+
+		types, rawVals, err := p.findFieldsInStruct(gAddr, p.Binary.runtimeGType(), "sched", "atomicstatus")
+		if err != nil {
+			return nil, err
+		}
+		status := int(binary.LittleEndian.Uint32(rawVals[1]))
+		if status&^goRoutineStatusScan == goRoutineStatusDead {
+			continue
+		}
+
+		pc, sp, err := p.goRoutinePCAndSP(gAddr, status, p.valueParser.parseValue(types[0], rawVals[0], 1).(structValue))
+		if err != nil {
+			return nil, err
+		}
+
+		goRoutine, err := p.goRoutineInfo(gAddr, pc, sp)
+		if err != nil {
+			return nil, err
+		}
+		goRoutine.Status = status
+		goRoutines = append(goRoutines, goRoutine)
+	}
+	return goRoutines, nil
+}
+
+// goRoutinePCAndSP returns the current pc and stack address of the go routine.
+// The values saved in the sched field are stale if the go routine is running, so the registers of its thread are used instead.
+func (p *Process) goRoutinePCAndSP(gAddr uint64, status int, schedVal structValue) (uint64, uint64, error) {
+	if status&^goRoutineStatusScan == goRoutineStatusRunning {
+		if threadID := p.GoRoutineThreadID(GoRoutineInfo{gAddr: gAddr}); threadID != 0 {
+			regs, err := p.debugapiClient.ReadRegisters(threadID)
+			if err != nil {
+				return 0, 0, err
+			}
+			return regs.Rip, regs.Rsp, nil
+		}
+	}
+	return schedVal.fields["pc"].(uint64Value).val, schedVal.fields["sp"].(uint64Value).val, nil
+}
+
+// allGAddrs returns the addresses of the g structs held by the runtime.allgs slice.
+func (p *Process) allGAddrs() ([]uint64, error) {
+	allgsAddr, allgsType, err := p.Binary.findGlobalVariable("runtime.allgs")
+	if err != nil {
+		return nil, err
+	}
+
+	buff := make([]byte, allgsType.Size())
+	if err := p.debugapiClient.ReadMemory(allgsAddr, buff); err != nil {
+		return nil, fmt.Errorf("failed to read runtime.allgs: %w", err)
+	}
+	arrayAddr := binary.LittleEndian.Uint64(buff[0:8])
+	length := int(binary.LittleEndian.Uint64(buff[8:16]))
+	if length == 0 {
+		return nil, nil
+	}
+
+	buff = make([]byte, 8*length)
+	if err := p.debugapiClient.ReadMemory(arrayAddr, buff); err != nil {
+		return nil, fmt.Errorf("failed to read the %d elements of runtime.allgs: %w", length, err)
+	}
+
+	gAddrs := make([]uint64, length)
+	for i := range gAddrs {
+		gAddrs[i] = binary.LittleEndian.Uint64(buff[i*8 : (i+1)*8])
+	}
+	return gAddrs, nil
+}
+
+func (p *Process) goRoutineInfo(gAddr, pc, sp uint64) (GoRoutineInfo, error) {
+	types, rawVals, err := p.findFieldsInStruct(gAddr, p.Binary.runtimeGType(), "goid", "stack", "_panic")
 	if err != nil {
 		return GoRoutineInfo{}, err
 	}
-	panicAddr := binary.LittleEndian.Uint64(panicRawVal)
+	id := int64(binary.LittleEndian.Uint64(rawVals[0]))
+
+	stackVal := p.valueParser.parseValue(types[1], rawVals[1], 1)
+	stackHi := stackVal.(structValue).fields["hi"].(uint64Value).val
+	usedStackSize := stackHi - sp
+
+	panicAddr := binary.LittleEndian.Uint64(rawVals[2])
 	panicking := panicAddr != 0
 
 	panicHandler, err := p.findPanicHandler(gAddr, panicAddr, stackHi)
@@ -786,9 +1949,196 @@ func (p *Process) CurrentGoRoutineInfo(threadID int) (GoRoutineInfo, error) {
 		return GoRoutineInfo{}, err
 	}
 
-	return GoRoutineInfo{ID: id, UsedStackSize: usedStackSize, CurrentPC: regs.Rip, CurrentStackAddr: regs.Rsp, NextDeferFuncAddr: nextDeferFuncAddr, Panicking: panicking, PanicHandler: panicHandler}, nil
+	return GoRoutineInfo{ID: id, UsedStackSize: usedStackSize, CurrentPC: pc, CurrentStackAddr: sp, NextDeferFuncAddr: nextDeferFuncAddr, Panicking: panicking, PanicHandler: panicHandler, gAddr: gAddr}, nil
+}
+
+// GoRoutineThreadID returns the id of the OS thread running the go routine (i.e. the procid field of the g's m).
+// 0 if the go routine is not running or unknown, e.g. the binary has no DWARF info. It's read from the tracee's memory each time.
+func (p *Process) GoRoutineThreadID(goRoutineInfo GoRoutineInfo) int {
+	rawPtrToMType, rawVal, err := p.findFieldInStruct(goRoutineInfo.gAddr, p.Binary.runtimeGType(), "m")
+	if err != nil {
+		// the field is not available if the binary has no DWARF info.
+		log.Debugf("failed to find m: %v", err)
+		return 0
+	}
+	mAddr := binary.LittleEndian.Uint64(rawVal)
+	if mAddr == 0x0 {
+		// the go routine is not running
+		return 0
+	}
+
+	ptrToMType, ok := rawPtrToMType.(*dwarf.PtrType)
+	if !ok {
+		log.Debugf("unexpected m type: %T", rawPtrToMType)
+		return 0
+	}
+	_, rawVal, err = p.findFieldInStruct(mAddr, ptrToMType.Type, "procid")
+	if err != nil {
+		log.Debugf("failed to find procid: %v", err)
+		return 0
+	}
+	return int(binary.LittleEndian.Uint64(rawVal))
+}
+
+// GoStatementPC returns the pc of the go statement which created the go routine (i.e. the g's gopc field).
+// 0 if unknown, e.g. the binary has no DWARF info. It's read from the tracee's memory each time.
+func (p *Process) GoStatementPC(goRoutineInfo GoRoutineInfo) uint64 {
+	_, rawVal, err := p.findFieldInStruct(goRoutineInfo.gAddr, p.Binary.runtimeGType(), "gopc")
+	if err != nil {
+		// the field is not available if the binary has no DWARF info.
+		log.Debugf("failed to find gopc: %v", err)
+		return 0
+	}
+	return binary.LittleEndian.Uint64(rawVal)
+}
+
+// GoRoutineCreator returns the name of the function which created the go routine by the go statement and the pc of
+// the go statement. Empty and 0 if unknown.
+func (p *Process) GoRoutineCreator(goRoutineInfo GoRoutineInfo) (string, uint64) {
+	gopc := p.GoStatementPC(goRoutineInfo)
+	if gopc == 0 {
+		return "", 0
+	}
+
+	// gopc is the return address of the newproc call. Use the address of the call instruction as the runtime does.
+	function, err := p.FindFunction(gopc - 1)
+	if err != nil {
+		log.Debugf("failed to find the function at gopc %#x: %v", gopc, err)
+		return "", gopc
+	}
+	return function.Name, gopc
+}
+
+// GoRoutineWaitReason returns why the go routine is waiting, e.g. "chan receive". Empty if unknown.
+// It's read from the tracee's memory each time. The g's waitreason field is the string before go 1.11 and
+// the index of the runtime.waitReasonStrings array after that.
+func (p *Process) GoRoutineWaitReason(goRoutineInfo GoRoutineInfo) string {
+	waitReasonType, rawVal, err := p.findFieldInStruct(goRoutineInfo.gAddr, p.Binary.runtimeGType(), "waitreason")
+	if err != nil {
+		// the field is not available if the binary has no DWARF info.
+		log.Debugf("failed to find waitreason: %v", err)
+		return ""
+	}
+
+	switch val := p.valueParser.parseValue(waitReasonType, rawVal, 1).(type) {
+	case stringValue:
+		return val.val
+	case uint8Value:
+		waitReason, err := p.findWaitReasonString(int(val.val))
+		if err != nil {
+			log.Debugf("failed to find the wait reason string: %v", err)
+			return fmt.Sprintf("waitreason(%d)", val.val)
+		}
+		return waitReason
+	default:
+		log.Debugf("unexpected waitreason type: %#v", waitReasonType)
+		return ""
+	}
+}
+
+// findWaitReasonString returns the element of the runtime.waitReasonStrings array.
+func (p *Process) findWaitReasonString(index int) (string, error) {
+	addr, typ, err := p.Binary.findGlobalVariable("runtime.waitReasonStrings")
+	if err != nil {
+		return "", err
+	}
+	arrayType, ok := typ.(*dwarf.ArrayType)
+	if !ok {
+		return "", fmt.Errorf("unexpected runtime.waitReasonStrings type: %#v", typ)
+	} else if int64(index) >= arrayType.Count {
+		return "", fmt.Errorf("index out of range: %d", index)
+	}
+
+	elemSize := arrayType.Type.Size()
+	buff := make([]byte, elemSize)
+	if err := p.debugapiClient.ReadMemory(addr+uint64(int64(index)*elemSize), buff); err != nil {
+		return "", fmt.Errorf("failed to read runtime.waitReasonStrings[%d]: %w", index, err)
+	}
+	val, ok := p.valueParser.parseValue(arrayType.Type, buff, 1).(stringValue)
+	if !ok {
+		return "", fmt.Errorf("unexpected element type: %#v", arrayType.Type)
+	}
+	return val.val, nil
+}
+
+// labelsParseDepth is enough to parse the labels wrapped by the structs and slice.
+const labelsParseDepth = 4
+
+// GoRoutineLabels returns the profiler labels set by pprof.Do or pprof.SetGoroutineLabels. Nil if no labels or unknown.
+// The labels are read from the tracee's memory each time, so call it only when necessary.
+func (p *Process) GoRoutineLabels(goRoutineInfo GoRoutineInfo) map[string]string {
+	return p.findLabels(goRoutineInfo.gAddr)
+}
+
+// findLabels returns the profiler labels of the go routine. The g's labels field points to the labelMap
+// of the runtime/pprof package. Nil if no labels.
+func (p *Process) findLabels(gAddr uint64) map[string]string {
+	_, rawVal, err := p.findFieldInStruct(gAddr, p.Binary.runtimeGType(), "labels")
+	if err != nil {
+		// the field is not available if the binary has no DWARF info.
+		log.Debugf("failed to find labels: %v", err)
+		return nil
+	}
+	labelsAddr := binary.LittleEndian.Uint64(rawVal)
+	labelMapType := p.Binary.labelMapType()
+	if labelsAddr == 0 || labelMapType == nil {
+		return nil
+	}
+
+	buff := make([]byte, labelMapType.Size())
+	if err := p.debugapiClient.ReadMemory(labelsAddr, buff); err != nil {
+		log.Debugf("failed to read memory (addr: %x): %v", labelsAddr, err)
+		return nil
+	}
+
+	labels := make(map[string]string)
+	collectLabels(p.valueParser.parseValue(labelMapType, buff, labelsParseDepth), labels)
+	return labels
+}
+
+// collectLabels collects the key and value pairs in the labelMap. The labelMap is the map[string]string
+// in the older go versions, and the struct which has the slice of the key and value pairs in the newer ones.
+func collectLabels(val value, labels map[string]string) {
+	switch val := val.(type) {
+	case mapValue:
+		for k, v := range val.val {
+			key, keyOK := k.(stringValue)
+			labelValue, valueOK := v.(stringValue)
+			if keyOK && valueOK {
+				labels[key.val] = labelValue.val
+			}
+		}
+	case sliceValue:
+		for _, elem := range val.val {
+			collectLabels(elem, labels)
+		}
+	case structValue:
+		key, keyOK := labelField(val, "key", "Key")
+		labelValue, valueOK := labelField(val, "value", "Value")
+		if keyOK && valueOK {
+			labels[key] = labelValue
+			return
+		}
+
+		for _, field := range val.fields {
+			collectLabels(field, labels)
+		}
+	}
+}
+
+// labelField returns the value of the string field which has one of the names.
+func labelField(val structValue, names ...string) (string, bool) {
+	for _, name := range names {
+		if field, ok := val.fields[name].(stringValue); ok {
+			return field.val, true
+		}
+	}
+	return "", false
 }
 
+// singleStepUnspecifiedThreads steps the threads which hit the breakpoints while the specified thread is handled.
+// The specified thread itself is not stepped here even if it's in the list. The caller decides whether it's stepped.
+// The thread stopped at the address other than the breakpoint is not stepped, because its pc must not be rewinded.
 func (p *Process) singleStepUnspecifiedThreads(threadID int, err debugapi.UnspecifiedThreadError) error {
 	for _, unspecifiedThread := range err.ThreadIDs {
 		if unspecifiedThread == threadID {
@@ -799,13 +2149,27 @@ func (p *Process) singleStepUnspecifiedThreads(threadID int, err debugapi.Unspec
 		if err != nil {
 			return err
 		}
-		if err := p.SingleStep(unspecifiedThread, regs.Rip-1); err != nil {
+		if !p.ExistBreakpoint(p.arch.BreakpointAddr(regs.Rip)) {
+			log.Debugf("thread %d stopped at %#x, not at the breakpoint", unspecifiedThread, regs.Rip)
+			continue
+		}
+		// SingleStep handles the threads stopped while this thread is stepped.
+		if err := p.SingleStep(unspecifiedThread, p.arch.BreakpointAddr(regs.Rip)); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+func containsThread(threadIDs []int, threadID int) bool {
+	for _, id := range threadIDs {
+		if id == threadID {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *Process) findNextDeferFuncAddr(gAddr uint64) (uint64, error) {
 	ptrToDeferType, rawVal, err := p.findFieldInStruct(gAddr, p.Binary.runtimeGType(), "_defer")
 	if err != nil {
@@ -822,15 +2186,19 @@ func (p *Process) findNextDeferFuncAddr(gAddr uint64) (uint64, error) {
 		return 0, err
 	}
 	ptrToFuncAddr := binary.LittleEndian.Uint64(rawVal)
+	if ptrToFuncAddr == 0x0 {
+		return 0x0, nil // the open-coded defer has no function since go 1.22.
+	}
 
 	buff := make([]byte, 8)
 	if err := p.debugapiClient.ReadMemory(ptrToFuncAddr, buff); err != nil {
-		return 0, fmt.Errorf("failed to read memory at %#x: %v", ptrToFuncAddr, err)
+		return 0, fmt.Errorf("failed to read the deferred function of the _defer at %#x: %w", deferAddr, err)
 	}
 	return binary.LittleEndian.Uint64(buff), nil
 }
 
-func (p *Process) findFieldInStruct(structAddr uint64, structType dwarf.Type, fieldName string) (dwarf.Type, []byte, error) {
+// structFieldType returns the type of the field in the struct.
+func structFieldType(structType dwarf.Type, fieldName string) (dwarf.Type, error) {
 	for {
 		typedefType, ok := structType.(*dwarf.TypedefType)
 		if !ok {
@@ -839,19 +2207,61 @@ func (p *Process) findFieldInStruct(structAddr uint64, structType dwarf.Type, fi
 		structType = typedefType.Type
 	}
 
-	for _, field := range structType.(*dwarf.StructType).Field {
-		if field.Name != fieldName {
-			continue
+	typ, ok := structType.(*dwarf.StructType)
+	if !ok {
+		return nil, fmt.Errorf("unexpected struct type: %T", structType)
+	}
+	for _, field := range typ.Field {
+		if field.Name == fieldName {
+			return field.Type, nil
+		}
+	}
+	return nil, fmt.Errorf("field %s not found", fieldName)
+}
+
+func (p *Process) findFieldInStruct(structAddr uint64, structType dwarf.Type, fieldName string) (dwarf.Type, []byte, error) {
+	types, rawVals, err := p.findFieldsInStruct(structAddr, structType, fieldName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return types[0], rawVals[0], nil
+}
+
+// findFieldsInStruct is same as findFieldInStruct, except it reads the multiple fields in one batch.
+// The types and values are returned in the order of `fieldNames`.
+func (p *Process) findFieldsInStruct(structAddr uint64, structType dwarf.Type, fieldNames ...string) ([]dwarf.Type, [][]byte, error) {
+	for {
+		typedefType, ok := structType.(*dwarf.TypedefType)
+		if !ok {
+			break
 		}
+		structType = typedefType.Type
+	}
 
-		buff := make([]byte, field.Type.Size())
-		addr := structAddr + uint64(field.ByteOffset)
-		if err := p.debugapiClient.ReadMemory(addr, buff); err != nil {
-			return nil, nil, fmt.Errorf("failed to read memory at %#x: %v", addr, err)
+	types := make([]dwarf.Type, len(fieldNames))
+	reads := make([]debugapi.MemoryRead, len(fieldNames))
+	for i, fieldName := range fieldNames {
+		for _, field := range structType.(*dwarf.StructType).Field {
+			if field.Name == fieldName {
+				types[i] = field.Type
+				reads[i] = debugapi.MemoryRead{Addr: structAddr + uint64(field.ByteOffset), Buf: make([]byte, field.Type.Size())}
+				break
+			}
 		}
-		return field.Type, buff, nil
+		if types[i] == nil {
+			return nil, nil, fmt.Errorf("field %q not found in %s", fieldName, structType)
+		}
+	}
+
+	if err := p.ReadMemoryBatch(reads); err != nil {
+		return nil, nil, fmt.Errorf("failed to read the fields %q of %s at %#x: %w", fieldNames, structType, structAddr, err)
+	}
+
+	rawVals := make([][]byte, len(reads))
+	for i, read := range reads {
+		rawVals[i] = read.Buf
 	}
-	return nil, nil, fmt.Errorf("field %s not found", fieldName)
+	return types, rawVals, nil
 }
 
 func (p *Process) findPanicHandler(gAddr, panicAddr, stackHi uint64) (*PanicHandler, error) {
@@ -860,43 +2270,71 @@ func (p *Process) findPanicHandler(gAddr, panicAddr, stackHi uint64) (*PanicHand
 		return nil, err
 	}
 	deferAddr := binary.LittleEndian.Uint64(rawVal)
-	deferType := ptrToDeferType.(*dwarf.PtrType).Type
+	ptrType, ok := ptrToDeferType.(*dwarf.PtrType)
+	if !ok {
+		return nil, fmt.Errorf("unexpected _defer type: %T", ptrToDeferType)
+	}
+	deferType := ptrType.Type
 
-	for deferAddr != 0 {
-		_, rawVal, err := p.findFieldInStruct(deferAddr, deferType, "_panic")
-		if err != nil {
-			return nil, err
-		}
-		panicInDefer := binary.LittleEndian.Uint64(rawVal)
-		if panicInDefer == panicAddr {
-			break
+	if _, err := structFieldType(deferType, "_panic"); err != nil {
+		// since go 1.22, the _defer doesn't know the panic. Instead, the panic knows the frame whose deferred calls are running.
+		if panicAddr != 0 {
+			return p.findPanicHandlerByPanic(panicAddr, stackHi)
 		}
+	} else {
+		for deferAddr != 0 {
+			_, rawVal, err := p.findFieldInStruct(deferAddr, deferType, "_panic")
+			if err != nil {
+				return nil, err
+			}
+			panicInDefer := binary.LittleEndian.Uint64(rawVal)
+			if panicInDefer == panicAddr {
+				break
+			}
 
-		_, rawVal, err = p.findFieldInStruct(deferAddr, deferType, "link")
-		if err != nil {
-			return nil, err
+			_, rawVal, err = p.findFieldInStruct(deferAddr, deferType, "link")
+			if err != nil {
+				return nil, err
+			}
+			deferAddr = binary.LittleEndian.Uint64(rawVal)
 		}
-		deferAddr = binary.LittleEndian.Uint64(rawVal)
 	}
 
 	if deferAddr == 0 {
 		return nil, nil
 	}
 
-	_, rawVal, err = p.findFieldInStruct(deferAddr, deferType, "sp")
+	_, rawVals, err := p.findFieldsInStruct(deferAddr, deferType, "sp", "pc")
 	if err != nil {
 		return nil, err
 	}
-	stackAddress := binary.LittleEndian.Uint64(rawVal)
-	usedStackSizeAtDefer := stackHi - stackAddress
+	stackAddress := binary.LittleEndian.Uint64(rawVals[0])
+	pc := binary.LittleEndian.Uint64(rawVals[1])
+	return &PanicHandler{UsedStackSizeAtDefer: stackHi - stackAddress, PCAtDefer: pc}, nil
+}
 
-	_, rawVal, err = p.findFieldInStruct(deferAddr, deferType, "pc")
+// findPanicHandlerByPanic finds the panic handler using the sp and pc fields of the panic, which are the frame whose
+// deferred calls are running.
+func (p *Process) findPanicHandlerByPanic(panicAddr, stackHi uint64) (*PanicHandler, error) {
+	ptrToPanicType, err := structFieldType(p.Binary.runtimeGType(), "_panic")
 	if err != nil {
 		return nil, err
 	}
-	pc := binary.LittleEndian.Uint64(rawVal)
+	ptrType, ok := ptrToPanicType.(*dwarf.PtrType)
+	if !ok {
+		return nil, fmt.Errorf("unexpected _panic type: %T", ptrToPanicType)
+	}
 
-	return &PanicHandler{UsedStackSizeAtDefer: usedStackSizeAtDefer, PCAtDefer: pc}, nil
+	_, rawVals, err := p.findFieldsInStruct(panicAddr, ptrType.Type, "sp", "pc")
+	if err != nil {
+		return nil, err
+	}
+	stackAddress := binary.LittleEndian.Uint64(rawVals[0])
+	if stackAddress == 0 {
+		return nil, nil // no frame is found yet.
+	}
+	pc := binary.LittleEndian.Uint64(rawVals[1])
+	return &PanicHandler{UsedStackSizeAtDefer: stackHi - stackAddress, PCAtDefer: pc}, nil
 }
 
 // ThreadInfo describes the various info of thread.
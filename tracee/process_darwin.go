@@ -1,8 +1,39 @@
 package tracee
 
+import (
+	"bytes"
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
 func (p *Process) offsetToG() int32 {
-	if p.GoVersion.LaterThan(GoVersion{MajorVersion: 1, MinorVersion: 11}) {
+	return p.tlsOffsetToG
+}
+
+// findOffsetToG finds the offset of the g from the gs register.
+func findOffsetToG(pathToProgram string, goVersion GoVersion) int32 {
+	if goVersion.AtLeast(GoVersion{MajorVersion: 1, MinorVersion: 11}) {
 		return 0x30
 	}
 	return 0x8a0
 }
+
+// programPathOf returns the path to the program the process is running.
+func programPathOf(pid int) (string, error) {
+	// The result of kern.procargs2 starts with argc, followed by the null-terminated path to the program.
+	buff, err := unix.SysctlRaw("kern.procargs2", pid)
+	if err != nil {
+		return "", err
+	}
+	const argcLen = 4
+	if len(buff) <= argcLen {
+		return "", errors.New("too short procargs")
+	}
+
+	path := buff[argcLen:]
+	if end := bytes.IndexByte(path, 0); end != -1 {
+		path = path[:end]
+	}
+	return string(path), nil
+}
@@ -1,5 +1,62 @@
 package tracee
 
+import (
+	"debug/elf"
+	"fmt"
+	"os"
+
+	"github.com/ks888/tgo/log"
+)
+
+// defaultOffsetToG is the offset of the g from the fs register when the binary is linked internally.
+const defaultOffsetToG = -8
+
 func (p *Process) offsetToG() int32 {
-	return -8
+	return p.tlsOffsetToG
+}
+
+// findOffsetToG finds the offset of the g from the fs register. The offset depends on the TLS segment
+// if the binary is linked externally (e.g. cgo is used), so it's calculated using the runtime.tlsg symbol.
+func findOffsetToG(pathToProgram string, goVersion GoVersion) int32 {
+	elfFile, err := elf.Open(pathToProgram)
+	if err != nil {
+		log.Debugf("failed to open the binary: %v", err)
+		return defaultOffsetToG
+	}
+	defer elfFile.Close()
+
+	var tlsProg *elf.Prog
+	for _, prog := range elfFile.Progs {
+		if prog.Type == elf.PT_TLS {
+			tlsProg = prog
+			break
+		}
+	}
+	if tlsProg == nil {
+		return defaultOffsetToG
+	}
+
+	symbols, err := elfFile.Symbols()
+	if err != nil {
+		return defaultOffsetToG
+	}
+	for _, sym := range symbols {
+		if sym.Name != "runtime.tlsg" {
+			continue
+		}
+
+		// the TLS block ends at the fs register. Its size is aligned.
+		align := tlsProg.Align
+		if align == 0 {
+			align = 1
+		}
+		memsz := tlsProg.Memsz + (-tlsProg.Vaddr-tlsProg.Memsz)&(align-1)
+		return int32(sym.Value - memsz)
+	}
+	return defaultOffsetToG
+}
+
+// programPathOf returns the path to the program the process is running.
+func programPathOf(pid int) (string, error) {
+	return os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
 }
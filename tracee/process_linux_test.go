@@ -0,0 +1,32 @@
+package tracee
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ks888/tgo/testutils"
+)
+
+func TestFindOffsetToG(t *testing.T) {
+	for i, testProgram := range []string{testutils.ProgramHelloworld, testutils.ProgramHelloworldNoDwarf} {
+		if offset := findOffsetToG(testProgram, GoVersion{}); offset != defaultOffsetToG {
+			t.Errorf("[%d] wrong offset: %d", i, offset)
+		}
+	}
+
+	if offset := findOffsetToG("not-exist", GoVersion{}); offset != defaultOffsetToG {
+		t.Errorf("wrong offset: %d", offset)
+	}
+}
+
+func TestProgramPathOf(t *testing.T) {
+	path, err := programPathOf(os.Getpid())
+	if err != nil {
+		t.Fatalf("failed to find the path: %v", err)
+	}
+	executable, _ := os.Executable()
+	if filepath.Base(path) != filepath.Base(executable) {
+		t.Errorf("wrong path: %s", path)
+	}
+}
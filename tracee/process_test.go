@@ -2,10 +2,15 @@ package tracee
 
 import (
 	"debug/dwarf"
+	"fmt"
 	"os/exec"
+	"reflect"
 	"runtime"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/ks888/tgo/debugapi"
 	"github.com/ks888/tgo/testutils"
 	"golang.org/x/arch/x86/x86asm"
 )
@@ -50,6 +55,25 @@ func TestAttachProcess(t *testing.T) {
 	}()
 }
 
+func TestAttachProcess_NoAttributes(t *testing.T) {
+	cmd := exec.Command(testutils.ProgramInfloop)
+	_ = cmd.Start()
+
+	proc, err := AttachProcess(cmd.Process.Pid, Attributes{})
+	if err != nil {
+		t.Fatalf("failed to attach process: %v", err)
+	}
+	defer func() {
+		proc.Detach()
+		cmd.Process.Kill()
+		cmd.Process.Wait()
+	}()
+
+	if proc.GoVersion.Raw != runtime.Version() {
+		t.Errorf("wrong go version: %s", proc.GoVersion.Raw)
+	}
+}
+
 func TestDetach(t *testing.T) {
 	proc, err := LaunchProcess(testutils.ProgramHelloworld, nil, helloworldAttr)
 	if err != nil {
@@ -108,6 +132,190 @@ func TestContinueAndWait(t *testing.T) {
 	}
 }
 
+func TestSetBreakpointByFileLine(t *testing.T) {
+	proc, err := LaunchProcess(testutils.ProgramHelloworld, nil, helloworldAttr)
+	if err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	defer proc.Detach()
+
+	if err := proc.SetBreakpointByFileLine("helloworld.go", 20); err != nil {
+		t.Fatalf("failed to set breakpoint: %v", err)
+	}
+	if !proc.ExistBreakpoint(testutils.HelloworldAddrOneParameterAndVariable) {
+		t.Errorf("breakpoint is not set at the expected address")
+	}
+
+	if err := proc.SetBreakpointByFileLine("helloworld.go", 1); err == nil {
+		t.Errorf("error not returned when no code is associated with the line")
+	}
+}
+
+func TestReadMemory(t *testing.T) {
+	proc, err := LaunchProcess(testutils.ProgramHelloworld, nil, helloworldAttr)
+	if err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	defer proc.Detach()
+
+	if err := proc.SetBreakpoint(testutils.HelloworldAddrMain); err != nil {
+		t.Fatalf("failed to set breakpoint: %v", err)
+	}
+
+	buff := make([]byte, 1)
+	if err := proc.ReadMemory(testutils.HelloworldAddrMain, buff); err != nil {
+		t.Fatalf("failed to read memory: %v", err)
+	}
+	if buff[0] != 0xcc {
+		t.Errorf("unexpected memory: %v", buff)
+	}
+}
+
+func TestSetHardwareBreakpoint(t *testing.T) {
+	proc, err := LaunchProcess(testutils.ProgramHelloworld, nil, helloworldAttr)
+	if err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	defer proc.Detach()
+
+	orgInsts := make([]byte, 1)
+	_ = proc.ReadMemory(testutils.HelloworldAddrMain, orgInsts)
+	if err := proc.SetHardwareBreakpoint(testutils.HelloworldAddrMain); err == debugapi.ErrNotSupported {
+		t.Skip("the hardware breakpoint is not supported")
+	} else if err != nil {
+		t.Fatalf("failed to set breakpoint: %v", err)
+	}
+	insts := make([]byte, 1)
+	_ = proc.ReadMemory(testutils.HelloworldAddrMain, insts)
+	if !reflect.DeepEqual(orgInsts, insts) {
+		t.Errorf("text is modified: %v", insts)
+	}
+
+	event, err := proc.ContinueAndWait()
+	if err != nil {
+		t.Fatalf("failed to continue and wait: %v", err)
+	}
+	addr, hit := proc.HardwareBreakpointHit(event.Data.([]int)[0])
+	if !hit || addr != testutils.HelloworldAddrMain {
+		t.Errorf("unexpected hit: %v, %#x", hit, addr)
+	}
+}
+
+func TestSetHardwareBreakpoint_TooMany(t *testing.T) {
+	proc, err := LaunchProcess(testutils.ProgramHelloworld, nil, helloworldAttr)
+	if err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	defer proc.Detach()
+
+	for i := 0; i < 4; i++ {
+		if err := proc.SetHardwareBreakpoint(testutils.HelloworldAddrMain + uint64(i)); err == debugapi.ErrNotSupported {
+			t.Skip("the hardware breakpoint is not supported")
+		} else if err != nil {
+			t.Fatalf("failed to set breakpoint: %v", err)
+		}
+	}
+	if err := proc.SetHardwareBreakpoint(testutils.HelloworldAddrMain + 4); err == nil {
+		t.Errorf("error not returned")
+	}
+
+	if err := proc.ClearHardwareBreakpoint(testutils.HelloworldAddrMain); err != nil {
+		t.Fatalf("failed to clear breakpoint: %v", err)
+	}
+	if err := proc.SetHardwareBreakpoint(testutils.HelloworldAddrMain + 4); err != nil {
+		t.Errorf("failed to set breakpoint: %v", err)
+	}
+}
+
+func TestSetWatchpoint(t *testing.T) {
+	proc, err := LaunchProcess(testutils.ProgramHelloworld, nil, helloworldAttr)
+	if err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	defer proc.Detach()
+
+	if err := proc.SetWatchpoint(testutils.HelloworldAddrMainStarted, 1, debugapi.WatchWrite); err != nil {
+		t.Fatalf("failed to set watchpoint: %v", err)
+	}
+
+	event, err := proc.ContinueAndWait()
+	if err != nil {
+		t.Fatalf("failed to continue and wait: %v", err)
+	}
+	if event.Type != debugapi.EventTypeWatched {
+		t.Fatalf("wrong event type: %v", event.Type)
+	}
+	data := event.Data.(debugapi.WatchedData)
+	if data.Addr != testutils.HelloworldAddrMainStarted {
+		t.Errorf("wrong address: %#x", data.Addr)
+	}
+
+	info, err := proc.CurrentGoRoutineInfo(data.ThreadIDs[0])
+	if err != nil {
+		t.Fatalf("failed to get go routine info: %v", err)
+	}
+	if info.WatchpointAddr != testutils.HelloworldAddrMainStarted {
+		t.Errorf("wrong watchpoint address: %#x", info.WatchpointAddr)
+	}
+
+	if err := proc.ClearWatchpoint(testutils.HelloworldAddrMainStarted); err != nil {
+		t.Fatalf("failed to clear watchpoint: %v", err)
+	}
+	if proc.hwBreakpoints != ([debugapi.MaxHardwareBreakpoints]hardwareBreakpoint{}) {
+		t.Errorf("watchpoint remains: %v", proc.hwBreakpoints)
+	}
+}
+
+func TestAllThreadRegisters(t *testing.T) {
+	proc, err := LaunchProcess(testutils.ProgramHelloworld, nil, helloworldAttr)
+	if err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	defer proc.Detach()
+
+	if err := proc.SetBreakpoint(testutils.HelloworldAddrMain); err != nil {
+		t.Fatalf("failed to set breakpoint: %v", err)
+	}
+	event, err := proc.ContinueAndWait()
+	if err != nil {
+		t.Fatalf("failed to continue and wait: %v", err)
+	}
+	tid := event.Data.([]int)[0]
+
+	allRegs, err := proc.AllThreadRegisters()
+	if err != nil {
+		t.Fatalf("failed to get registers: %v", err)
+	}
+	regs, ok := allRegs[tid]
+	if !ok {
+		t.Fatalf("no registers of the trapped thread: %v", allRegs)
+	}
+	if regs.Rip != testutils.HelloworldAddrMain+1 {
+		t.Errorf("wrong rip: %#x", regs.Rip)
+	}
+}
+
+func TestWriteMemory(t *testing.T) {
+	proc, err := LaunchProcess(testutils.ProgramHelloworld, nil, helloworldAttr)
+	if err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	defer proc.Detach()
+
+	data := []byte{0x90, 0x90}
+	if err := proc.WriteMemory(testutils.HelloworldAddrMain, data); err != nil {
+		t.Fatalf("failed to write memory: %v", err)
+	}
+
+	buff := make([]byte, len(data))
+	if err := proc.ReadMemory(testutils.HelloworldAddrMain, buff); err != nil {
+		t.Fatalf("failed to read memory: %v", err)
+	}
+	if !reflect.DeepEqual(buff, data) {
+		t.Errorf("unexpected memory: %v", buff)
+	}
+}
+
 func TestSingleStep(t *testing.T) {
 	proc, err := LaunchProcess(testutils.ProgramHelloworld, nil, helloworldAttr)
 	if err != nil {
@@ -181,7 +389,7 @@ func TestStackFrameAt(t *testing.T) {
 		t.Fatalf("failed to read registers: %v", err)
 	}
 
-	stackFrame, err := proc.StackFrameAt(regs.Rsp, regs.Rip)
+	stackFrame, err := proc.StackFrameWithRegisters(tids[0], regs)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
@@ -206,6 +414,41 @@ func TestStackFrameAt(t *testing.T) {
 	if len(stackFrame.OutputArguments) != 0 {
 		t.Errorf("wrong output args length: %d", len(stackFrame.OutputArguments))
 	}
+	if !strings.HasSuffix(stackFrame.File, "helloworld.go") || stackFrame.Line == 0 {
+		t.Errorf("wrong source location: %s:%d", stackFrame.File, stackFrame.Line)
+	}
+}
+
+func TestStackFrameWithRegisters_FloatArg(t *testing.T) {
+	proc, err := LaunchProcess(testutils.ProgramTypePrint, nil, typePrintAttr)
+	if err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	defer proc.Detach()
+
+	if err := proc.SetBreakpoint(testutils.TypePrintAddrPrintFloat64); err != nil {
+		t.Fatalf("failed to set breakpoint: %v", err)
+	}
+	event, err := proc.ContinueAndWait()
+	if err != nil {
+		t.Fatalf("failed to continue and wait: %v", err)
+	}
+
+	tids := event.Data.([]int)
+	regs, err := proc.debugapiClient.ReadRegisters(tids[0])
+	if err != nil {
+		t.Fatalf("failed to read registers: %v", err)
+	}
+	regs.Rip-- // the breakpoint address
+
+	// the xmm registers are read only when the arg may be in them.
+	stackFrame, err := proc.StackFrameWithRegisters(tids[0], regs)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(stackFrame.InputArguments) != 1 || stackFrame.InputArguments[0].ParseValue(1) != "v = 0.12345678901234568" {
+		t.Errorf("wrong input args: %v", stackFrame.InputArguments)
+	}
 }
 
 func TestStackFrameAt_NoDwarfCase(t *testing.T) {
@@ -249,8 +492,178 @@ func TestStackFrameAt_NoDwarfCase(t *testing.T) {
 	if stackFrame.Function.Parameters[0].IsOutput {
 		t.Errorf("should be input parameter")
 	}
-	if !stackFrame.Function.Parameters[1].IsOutput {
-		t.Errorf("should be output parameter")
+	if !stackFrame.Function.Parameters[1].IsOutput {
+		t.Errorf("should be output parameter")
+	}
+}
+
+func TestStackTrace(t *testing.T) {
+	for i, testProgram := range []string{testutils.ProgramHelloworld, testutils.ProgramHelloworldNoDwarf} {
+		proc, err := LaunchProcess(testProgram, nil, helloworldAttr)
+		if err != nil {
+			t.Fatalf("[%d] failed to launch process: %v", i, err)
+		}
+		defer proc.Detach()
+
+		if err := proc.SetBreakpoint(testutils.HelloworldAddrNoParameter); err != nil {
+			t.Fatalf("[%d] failed to set breakpoint: %v", i, err)
+		}
+		event, err := proc.ContinueAndWait()
+		if err != nil {
+			t.Fatalf("[%d] failed to continue and wait: %v", i, err)
+		}
+
+		tids := event.Data.([]int)
+		goRoutineInfo, err := proc.CurrentGoRoutineInfo(tids[0])
+		if err != nil {
+			t.Fatalf("[%d] failed to get CurrentGoRoutineInfo: %v", i, err)
+		}
+		goRoutineInfo.CurrentPC-- // the breakpoint address
+
+		stackFrames, err := proc.StackTrace(goRoutineInfo)
+		if err != nil {
+			t.Fatalf("[%d] failed to get stack trace: %v", i, err)
+		}
+		if len(stackFrames) < 3 {
+			t.Fatalf("[%d] too few stack frames: %d", i, len(stackFrames))
+		}
+		if stackFrames[0].Function.Name != "main.noParameter" {
+			t.Errorf("[%d] wrong function name: %s", i, stackFrames[0].Function.Name)
+		}
+		if stackFrames[1].Function.Name != "main.main" {
+			t.Errorf("[%d] wrong function name: %s", i, stackFrames[1].Function.Name)
+		}
+		if stackFrames[len(stackFrames)-1].Function.Name != "runtime.goexit" {
+			t.Errorf("[%d] wrong function name: %s", i, stackFrames[len(stackFrames)-1].Function.Name)
+		}
+		if testProgram == testutils.ProgramHelloworld {
+			if !strings.HasSuffix(stackFrames[1].File, "helloworld.go") || stackFrames[1].Line == 0 {
+				t.Errorf("[%d] wrong source location: %s:%d", i, stackFrames[1].File, stackFrames[1].Line)
+			}
+		} else if stackFrames[1].File != "" {
+			t.Errorf("[%d] source location is found without DWARF: %s", i, stackFrames[1].File)
+		}
+	}
+}
+
+// launchCgoProgram builds the cgo program if not built yet and launches it. The test is skipped if cgo is not available.
+func launchCgoProgram(t *testing.T) *Process {
+	if err := testutils.BuildProgramCgo(); err != nil {
+		t.Skipf("failed to build the cgo program: %v", err)
+	}
+
+	cgoAttr := Attributes{FirstModuleDataAddr: testutils.CgoAddrFirstModuleData, CompiledGoVersion: runtime.Version()}
+	proc, err := LaunchProcess(testutils.ProgramCgo, nil, cgoAttr)
+	if err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	return proc
+}
+
+func TestStackTrace_CFunction(t *testing.T) {
+	proc := launchCgoProgram(t)
+	defer proc.Detach()
+
+	if err := proc.SetBreakpoint(testutils.CgoAddrCFunction); err != nil {
+		t.Fatalf("failed to set breakpoint: %v", err)
+	}
+	event, err := proc.ContinueAndWait()
+	if err != nil {
+		t.Fatalf("failed to continue and wait: %v", err)
+	}
+
+	tids := event.Data.([]int)
+	goRoutineInfo, err := proc.CurrentGoRoutineInfo(tids[0])
+	if err != nil {
+		t.Fatalf("failed to get CurrentGoRoutineInfo: %v", err)
+	}
+	goRoutineInfo.CurrentPC-- // the breakpoint address
+
+	stackFrames, err := proc.StackTrace(goRoutineInfo)
+	if err != nil {
+		t.Fatalf("failed to get stack trace: %v", err)
+	}
+	if stackFrames[0].Function.Name != cgoFunctionName {
+		t.Errorf("wrong function name: %s", stackFrames[0].Function.Name)
+	}
+	var names []string
+	for _, stackFrame := range stackFrames {
+		names = append(names, stackFrame.Function.Name)
+	}
+	if !strings.Contains(strings.Join(names, " "), "runtime.cgocall main._Cfunc_callC main.main") {
+		t.Errorf("wrong stack frames: %v", names)
+	}
+	if names[len(names)-1] != "runtime.goexit" {
+		t.Errorf("wrong function name: %s", names[len(names)-1])
+	}
+}
+
+func TestStackTrace_GoCallbackFromC(t *testing.T) {
+	proc := launchCgoProgram(t)
+	defer proc.Detach()
+
+	if err := proc.SetBreakpoint(testutils.CgoAddrCallback); err != nil {
+		t.Fatalf("failed to set breakpoint: %v", err)
+	}
+	event, err := proc.ContinueAndWait()
+	if err != nil {
+		t.Fatalf("failed to continue and wait: %v", err)
+	}
+
+	tids := event.Data.([]int)
+	goRoutineInfo, err := proc.CurrentGoRoutineInfo(tids[0])
+	if err != nil {
+		t.Fatalf("failed to get CurrentGoRoutineInfo: %v", err)
+	}
+	goRoutineInfo.CurrentPC--
+
+	// the runtime links the frames of the callback to the frames which called the C function.
+	stackFrames, err := proc.StackTrace(goRoutineInfo)
+	if err != nil {
+		t.Fatalf("failed to get stack trace: %v", err)
+	}
+	if stackFrames[0].Function.Name != "main.callback" {
+		t.Errorf("wrong function name: %s", stackFrames[0].Function.Name)
+	}
+	if stackFrames[len(stackFrames)-1].Function.Name != "runtime.goexit" {
+		t.Errorf("wrong function name: %s", stackFrames[len(stackFrames)-1].Function.Name)
+	}
+}
+
+func TestCallStack(t *testing.T) {
+	proc, err := LaunchProcess(testutils.ProgramHelloworld, nil, helloworldAttr)
+	if err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	defer proc.Detach()
+
+	if err := proc.SetBreakpoint(testutils.HelloworldAddrNoParameter); err != nil {
+		t.Fatalf("failed to set breakpoint: %v", err)
+	}
+	event, err := proc.ContinueAndWait()
+	if err != nil {
+		t.Fatalf("failed to continue and wait: %v", err)
+	}
+	tids := event.Data.([]int)
+	if err := proc.SingleStep(tids[0], testutils.HelloworldAddrNoParameter); err != nil {
+		t.Fatalf("failed to single step: %v", err)
+	}
+
+	stackFrames, err := proc.CallStack(tids[0])
+	if err != nil {
+		t.Fatalf("failed to get call stack: %v", err)
+	}
+	if len(stackFrames) < 3 {
+		t.Fatalf("too few stack frames: %d", len(stackFrames))
+	}
+	if stackFrames[0].Function.Name != "main.noParameter" {
+		t.Errorf("wrong function name: %s", stackFrames[0].Function.Name)
+	}
+	if stackFrames[1].Function.Name != "main.main" {
+		t.Errorf("wrong function name: %s", stackFrames[1].Function.Name)
+	}
+	if stackFrames[len(stackFrames)-1].Function.Name != "runtime.goexit" {
+		t.Errorf("wrong function name: %s", stackFrames[len(stackFrames)-1].Function.Name)
 	}
 }
 
@@ -325,9 +738,59 @@ func TestFindFunction_FillInOutputParametersOffset(t *testing.T) {
 	}
 }
 
+// fakeBinaryFile is the BinaryFile which knows only the specified functions. The other methods panic.
+type fakeBinaryFile struct {
+	BinaryFile
+	functions []*Function
+	stripped  bool
+}
+
+func (b fakeBinaryFile) FindFunction(pc uint64) (*Function, error) {
+	for _, f := range b.functions {
+		if f.StartAddr <= pc && pc < f.EndAddr {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("no function found at %#x", pc)
+}
+
+func (b fakeBinaryFile) IsStripped() bool {
+	return b.stripped
+}
+
+func TestFindFunction_FakeBinary(t *testing.T) {
+	function := &Function{Name: "main.f", StartAddr: 0x100, EndAddr: 0x200}
+	for i, testdata := range []struct {
+		stripped  bool
+		pc        uint64
+		expectErr bool
+	}{
+		{stripped: false, pc: 0x100},
+		{stripped: false, pc: 0x1ff},
+		{stripped: true, pc: 0x100},
+		{stripped: false, pc: 0x200, expectErr: true},
+	} {
+		proc := &Process{Binary: fakeBinaryFile{functions: []*Function{function}, stripped: testdata.stripped}}
+
+		f, err := proc.FindFunction(testdata.pc)
+		if testdata.expectErr {
+			if err == nil {
+				t.Errorf("[%d] no error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("[%d] failed to find function: %v", i, err)
+		}
+		if f.Name != function.Name {
+			t.Errorf("[%d] wrong function: %s", i, f.Name)
+		}
+	}
+}
+
 func TestFuncTypeOffsets(t *testing.T) {
 	binary, _ := OpenBinaryFile(testutils.ProgramHelloworld, GoVersion{})
-	debuggableBinary, _ := binary.(debuggableBinaryFile)
+	debuggableBinary, _ := binary.(*cachedBinaryFile).BinaryFile.(debuggableBinaryFile)
 
 	entry, err := debuggableBinary.findDWARFEntryByName(func(entry *dwarf.Entry) bool {
 		if entry.Tag != dwarf.TagStructType {
@@ -362,12 +825,12 @@ func TestFuncTypeOffsets(t *testing.T) {
 }
 
 func TestFindfuncbucketTypeOffsets(t *testing.T) {
-	if !ParseGoVersion(runtime.Version()).LaterThan(GoVersion{MajorVersion: 1, MinorVersion: 11}) {
+	if ParseGoVersion(runtime.Version()).OlderThan(GoVersion{MajorVersion: 1, MinorVersion: 11}) {
 		t.Skip("go1.10 or earlier doesn't have findfuncbucket type in DWARF")
 	}
 
 	binary, _ := OpenBinaryFile(testutils.ProgramHelloworld, GoVersion{})
-	debuggableBinary, _ := binary.(debuggableBinaryFile)
+	debuggableBinary, _ := binary.(*cachedBinaryFile).BinaryFile.(debuggableBinaryFile)
 
 	entry, err := debuggableBinary.findDWARFEntryByName(func(entry *dwarf.Entry) bool {
 		if entry.Tag != dwarf.TagStructType {
@@ -458,11 +921,174 @@ func TestReadInstructions_SetBreakpointBefore(t *testing.T) {
 	if len(insts) == 0 {
 		t.Errorf("empty insts")
 	}
-	if insts[0].Op == x86asm.INT {
+	if inst, ok := insts[0].Raw.(x86asm.Inst); ok && inst.Op == x86asm.INT {
 		t.Errorf("breakpoint is not reset")
 	}
 }
 
+func TestFunctionBodyStart(t *testing.T) {
+	proc, err := LaunchProcess(testutils.ProgramHelloworld, nil, helloworldAttr)
+	if err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	defer proc.Detach()
+
+	f, err := proc.FindFunction(testutils.HelloworldAddrMain)
+	if err != nil {
+		t.Fatalf("failed to find function: %v", err)
+	}
+
+	addr, err := proc.FunctionBodyStart(f)
+	if err != nil {
+		t.Fatalf("failed to find the body: %v", err)
+	}
+	// main.main checks the stack growth.
+	if addr <= f.StartAddr || addr >= f.EndAddr {
+		t.Errorf("wrong address: %#x", addr)
+	}
+	if size, _ := f.Annotation(AnnotationPrologueSize); size != strconv.FormatUint(addr-f.StartAddr, 10) {
+		t.Errorf("wrong prologue size: %s", size)
+	}
+}
+
+func TestSetBreakpoints(t *testing.T) {
+	proc, err := LaunchProcess(testutils.ProgramHelloworld, nil, helloworldAttr)
+	if err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	defer proc.Detach()
+
+	addrs := []uint64{testutils.HelloworldAddrOneParameter, testutils.HelloworldAddrMain, testutils.HelloworldAddrMain}
+	if err := proc.SetBreakpoints(addrs); err != nil {
+		t.Fatalf("failed to set breakpoints: %v", err)
+	}
+	for _, addr := range addrs {
+		if !proc.ExistBreakpoint(addr) {
+			t.Errorf("breakpoint not set at %#x", addr)
+		}
+	}
+
+	event, err := proc.ContinueAndWait()
+	if err != nil {
+		t.Fatalf("failed to continue and wait: %v", err)
+	}
+	threadIDs := event.Data.([]int)
+	regs, err := proc.debugapiClient.ReadRegisters(threadIDs[0])
+	if err != nil {
+		t.Fatalf("failed to read registers: %v", err)
+	}
+	if regs.Rip-1 != testutils.HelloworldAddrMain {
+		t.Errorf("wrong pc: %#x", regs.Rip)
+	}
+}
+
+func BenchmarkSetBreakpoints(b *testing.B) {
+	proc, err := LaunchProcess(testutils.ProgramHelloworld, nil, helloworldAttr)
+	if err != nil {
+		b.Fatalf("failed to launch process: %v", err)
+	}
+	defer proc.Detach()
+
+	functions, err := proc.Binary.ListFunctions()
+	if err != nil {
+		b.Fatalf("failed to list functions: %v", err)
+	}
+	var addrs []uint64
+	for _, f := range functions {
+		addrs = append(addrs, f.StartAddr)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := proc.SetBreakpoints(addrs); err != nil {
+			b.Fatalf("failed to set breakpoints: %v", err)
+		}
+		b.StopTimer()
+		for _, addr := range addrs {
+			if err := proc.ClearBreakpoint(addr); err != nil {
+				b.Fatalf("failed to clear breakpoint: %v", err)
+			}
+		}
+		b.StartTimer()
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	proc, err := LaunchProcess(testutils.ProgramHelloworld, nil, helloworldAttr)
+	if err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	defer proc.Detach()
+
+	f, err := proc.FindFunction(testutils.HelloworldAddrMain)
+	if err != nil {
+		t.Fatalf("failed to find function: %v", err)
+	}
+	if err := proc.SetBreakpoint(f.StartAddr); err != nil {
+		t.Fatalf("failed to set breakpoint: %v", err)
+	}
+
+	insts, err := proc.Disassemble(f.StartAddr, f.EndAddr)
+	if err != nil {
+		t.Fatalf("failed to disassemble: %v", err)
+	}
+	if len(insts) == 0 {
+		t.Fatalf("empty insts")
+	}
+	if insts[0].Addr != f.StartAddr || !insts[0].HasBreakpoint {
+		t.Errorf("unexpected first inst: %#v", insts[0])
+	}
+	if insts[0].SourceLine == "" {
+		t.Errorf("empty source line")
+	}
+	if insts[1].HasBreakpoint {
+		t.Errorf("unexpected breakpoint: %#v", insts[1])
+	}
+	if insts[0].Text(AsmSyntaxIntel) == "" || insts[0].Text(AsmSyntaxATT) == "" {
+		t.Errorf("empty text")
+	}
+}
+
+func TestDisassembleBinary(t *testing.T) {
+	binary, err := OpenBinaryFile(testutils.ProgramHelloworld, GoVersion{})
+	if err != nil {
+		t.Fatalf("failed to open: %v", err)
+	}
+	defer binary.Close()
+
+	f, err := binary.FindFunction(testutils.HelloworldAddrMain)
+	if err != nil {
+		t.Fatalf("failed to find function: %v", err)
+	}
+
+	insts, err := DisassembleBinary(binary, f.StartAddr, f.EndAddr)
+	if err != nil {
+		t.Fatalf("failed to disassemble: %v", err)
+	}
+	if len(insts) == 0 {
+		t.Fatalf("empty insts")
+	}
+	if insts[0].Addr != f.StartAddr || insts[0].HasBreakpoint {
+		t.Errorf("unexpected first inst: %#v", insts[0])
+	}
+	if insts[0].SourceLine == "" {
+		t.Errorf("empty source line")
+	}
+
+	proc, err := LaunchProcess(testutils.ProgramHelloworld, nil, helloworldAttr)
+	if err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	defer proc.Detach()
+	procInsts, err := proc.Disassemble(f.StartAddr, f.EndAddr)
+	if err != nil {
+		t.Fatalf("failed to disassemble: %v", err)
+	}
+	if len(procInsts) != len(insts) || procInsts[len(insts)-1].Text(AsmSyntaxIntel) != insts[len(insts)-1].Text(AsmSyntaxIntel) {
+		t.Errorf("the insts differ from the process's ones: %d, %d", len(procInsts), len(insts))
+	}
+}
+
 func TestCurrentGoRoutineInfo(t *testing.T) {
 	for i, testProgram := range []string{testutils.ProgramHelloworld, testutils.ProgramHelloworldNoDwarf} {
 		proc, err := LaunchProcess(testProgram, nil, helloworldAttr)
@@ -497,6 +1123,24 @@ func TestCurrentGoRoutineInfo(t *testing.T) {
 		if goRoutineInfo.CurrentStackAddr == 0 {
 			t.Errorf("[%d] current stack address is 0", i)
 		}
+		threadID := proc.GoRoutineThreadID(goRoutineInfo)
+		if testProgram == testutils.ProgramHelloworldNoDwarf {
+			// the m struct is unknown without DWARF info.
+			if threadID != 0 {
+				t.Errorf("[%d] wrong thread id: %d", i, threadID)
+			}
+		} else if threadID == 0 || (runtime.GOOS == "linux" && threadID != threadIDs[0]) {
+			t.Errorf("[%d] wrong thread id: %d", i, threadID)
+		}
+		if goRoutineInfo.ThreadID != 0 {
+			t.Errorf("[%d] thread id is filled by default: %d", i, goRoutineInfo.ThreadID)
+		}
+		proc.SetGoRoutineDetails(true)
+		if goRoutineInfo, err := proc.CurrentGoRoutineInfo(threadIDs[0]); err != nil {
+			t.Errorf("[%d] error: %v", i, err)
+		} else if goRoutineInfo.ThreadID != threadID {
+			t.Errorf("[%d] wrong thread id: %d", i, goRoutineInfo.ThreadID)
+		}
 		if goRoutineInfo.NextDeferFuncAddr == 0 {
 			t.Errorf("[%d] NextDeferFuncAddr is 0", i)
 		}
@@ -510,6 +1154,69 @@ func TestCurrentGoRoutineInfo(t *testing.T) {
 	}
 }
 
+func BenchmarkCurrentGoRoutineInfo(b *testing.B) {
+	proc, err := LaunchProcess(testutils.ProgramHelloworld, nil, helloworldAttr)
+	if err != nil {
+		b.Fatalf("failed to launch process: %v", err)
+	}
+	defer proc.Detach()
+
+	if err := proc.SetBreakpoint(testutils.HelloworldAddrMain); err != nil {
+		b.Fatalf("failed to set breakpoint: %v", err)
+	}
+
+	event, err := proc.ContinueAndWait()
+	if err != nil {
+		b.Fatalf("failed to continue and wait: %v", err)
+	}
+	threadIDs := event.Data.([]int)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := proc.CurrentGoRoutineInfo(threadIDs[0]); err != nil {
+			b.Fatalf("error: %v", err)
+		}
+	}
+}
+
+func TestCurrentGoRoutineInfo_Labels(t *testing.T) {
+	proc, err := LaunchProcess(testutils.ProgramLabels, nil, Attributes{FirstModuleDataAddr: testutils.LabelsAddrFirstModuleData, CompiledGoVersion: runtime.Version()})
+	if err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	defer proc.Detach()
+
+	if err := proc.SetBreakpoint(testutils.LabelsAddrWork); err != nil {
+		t.Fatalf("failed to set breakpoint: %v", err)
+	}
+
+	event, err := proc.ContinueAndWait()
+	if err != nil {
+		t.Fatalf("failed to continue and wait: %v", err)
+	}
+
+	threadIDs := event.Data.([]int)
+	goRoutineInfo, err := proc.CurrentGoRoutineInfo(threadIDs[0])
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if labels := proc.GoRoutineLabels(goRoutineInfo); !reflect.DeepEqual(labels, map[string]string{"role": "worker"}) {
+		t.Errorf("wrong labels: %v", labels)
+	}
+	if goRoutineInfo.Labels != nil {
+		t.Errorf("labels are filled by default: %v", goRoutineInfo.Labels)
+	}
+
+	proc.SetGoRoutineDetails(true)
+	goRoutineInfo, err = proc.CurrentGoRoutineInfo(threadIDs[0])
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if !reflect.DeepEqual(goRoutineInfo.Labels, map[string]string{"role": "worker"}) {
+		t.Errorf("wrong labels: %v", goRoutineInfo.Labels)
+	}
+}
+
 func TestCurrentGoRoutineInfo_Panicking(t *testing.T) {
 	for _, testProgram := range []string{testutils.ProgramPanic, testutils.ProgramPanicNoDwarf} {
 		proc, err := LaunchProcess(testProgram, nil, helloworldAttr)
@@ -542,6 +1249,156 @@ func TestCurrentGoRoutineInfo_Panicking(t *testing.T) {
 	}
 }
 
+func TestFindFunction_Plugin(t *testing.T) {
+	if err := testutils.BuildProgramPlugin(); err != nil {
+		t.Skipf("failed to build the plugin program: %v", err)
+	}
+
+	pluginAttr := Attributes{FirstModuleDataAddr: testutils.PluginAddrFirstModuleData, CompiledGoVersion: runtime.Version()}
+	proc, err := LaunchProcess(testutils.ProgramPlugin, []string{testutils.ProgramPluginLib}, pluginAttr)
+	if err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	defer proc.Detach()
+
+	// the plugin is loaded when main.callPlugin is called.
+	if err := proc.SetBreakpoint(testutils.PluginAddrCallPlugin); err != nil {
+		t.Fatalf("failed to set breakpoint: %v", err)
+	}
+	if _, err := proc.ContinueAndWait(); err != nil {
+		t.Fatalf("failed to continue and wait: %v", err)
+	}
+
+	numModuleData := len(proc.moduleDataList)
+	allModuleData := parseModuleDataList(testutils.PluginAddrFirstModuleData, proc.Binary, proc.debugapiClient)
+	if len(allModuleData) != numModuleData+1 {
+		t.Fatalf("wrong number of moduledata: %d, %d", numModuleData, len(allModuleData))
+	}
+
+	pluginFuncAddr, _ := allModuleData[numModuleData].pcRange(proc.debugapiClient)
+	function, err := proc.FindFunction(pluginFuncAddr)
+	if err != nil {
+		t.Fatalf("failed to find the function in the plugin: %v", err)
+	}
+	if function.StartAddr != pluginFuncAddr {
+		t.Errorf("wrong function: %#v", function)
+	}
+	if len(proc.moduleDataList) != numModuleData+1 {
+		t.Errorf("moduledata list is not refreshed: %d", len(proc.moduleDataList))
+	}
+
+	// the DWARF of the plugin is not loaded.
+	if _, err := proc.mapRuntimeType(allModuleData[numModuleData].types(proc.debugapiClient)); err == nil {
+		t.Errorf("the type in the plugin is mapped")
+	}
+}
+
+var defersAttr = Attributes{
+	FirstModuleDataAddr: testutils.DefersAddrFirstModuleData,
+	CompiledGoVersion:   runtime.Version(),
+}
+
+func TestDeferredCallAt(t *testing.T) {
+	proc, err := LaunchProcess(testutils.ProgramDefers, nil, defersAttr)
+	if err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	defer proc.Detach()
+
+	if err := proc.SetBreakpoint(testutils.DefersAddrDeferProc); err != nil {
+		t.Fatalf("failed to set breakpoint: %v", err)
+	}
+
+	event, err := proc.ContinueAndWait()
+	if err != nil {
+		t.Fatalf("failed to continue and wait: %v", err)
+	}
+
+	threadIDs := event.Data.([]int)
+	deferredCall, err := proc.DeferredCallAt(threadIDs[0])
+	if err != nil {
+		t.Fatalf("failed to find the deferred call: %v", err)
+	}
+	if !strings.HasPrefix(deferredCall.Function.Name, "main.deferInLoop") && deferredCall.Function.Name != "main.cleanup" {
+		t.Errorf("wrong function: %s", deferredCall.Function.Name)
+	}
+}
+
+var goRoutinesAttr = Attributes{
+	FirstModuleDataAddr: testutils.GoRoutinesAddrFirstModuleData,
+	CompiledGoVersion:   runtime.Version(),
+}
+
+func TestListGoRoutines(t *testing.T) {
+	proc, err := LaunchProcess(testutils.ProgramGoRoutines, nil, goRoutinesAttr)
+	if err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	defer proc.Detach()
+
+	if err := proc.SetBreakpoint(testutils.GoRoutinesAddrInc); err != nil {
+		t.Fatalf("failed to set breakpoint: %v", err)
+	}
+	if _, err := proc.ContinueAndWait(); err != nil {
+		t.Fatalf("failed to continue and wait: %v", err)
+	}
+
+	goRoutines, err := proc.ListGoRoutines()
+	if err != nil {
+		t.Fatalf("failed to list go routines: %v", err)
+	}
+
+	mainGoRoutineFound := false
+	for _, goRoutine := range goRoutines {
+		if goRoutine.ID == 1 {
+			mainGoRoutineFound = true
+		}
+		if goRoutine.Status&^goRoutineStatusScan == goRoutineStatusDead {
+			t.Errorf("dead go routine is listed: %d", goRoutine.ID)
+		}
+		if goRoutine.CurrentStackAddr == 0 {
+			t.Errorf("current stack address is 0: %d", goRoutine.ID)
+		}
+		if goRoutine.Status&^goRoutineStatusScan == goRoutineStatusWaiting {
+			if waitReason := proc.GoRoutineWaitReason(goRoutine); waitReason == "" || strings.HasPrefix(waitReason, "waitreason(") {
+				t.Errorf("wrong wait reason: %d %q", goRoutine.ID, waitReason)
+			}
+		}
+	}
+	if !mainGoRoutineFound {
+		t.Errorf("main go routine not found: %v", goRoutines)
+	}
+}
+
+func TestGoRoutineCreator(t *testing.T) {
+	proc, err := LaunchProcess(testutils.ProgramGoRoutines, nil, goRoutinesAttr)
+	if err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	defer proc.Detach()
+
+	if err := proc.SetBreakpoint(testutils.GoRoutinesAddrInc); err != nil {
+		t.Fatalf("failed to set breakpoint: %v", err)
+	}
+	event, err := proc.ContinueAndWait()
+	if err != nil {
+		t.Fatalf("failed to continue and wait: %v", err)
+	}
+
+	threadIDs := event.Data.([]int)
+	goRoutineInfo, err := proc.CurrentGoRoutineInfo(threadIDs[0])
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	createdBy, goStatementPC := proc.GoRoutineCreator(goRoutineInfo)
+	if createdBy != "main.main" {
+		t.Errorf("wrong creator: %s", createdBy)
+	}
+	if goStatementPC == 0 {
+		t.Errorf("go statement pc is 0")
+	}
+}
+
 func TestArgument_ParseValue(t *testing.T) {
 	for i, testdata := range []struct {
 		arg      Argument
@@ -0,0 +1,45 @@
+package tracee
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"fmt"
+	"io"
+)
+
+// readProgramData reads the data loaded at the address from the program file. Both the ELF and Mach-O formats are
+// supported regardless of the host OS, because the binary cross-compiled for linux may be inspected on the other OSes.
+func readProgramData(pathToProgram string, addr uint64, buff []byte) error {
+	end := addr + uint64(len(buff))
+
+	if elfFile, err := elf.Open(pathToProgram); err == nil {
+		defer elfFile.Close()
+		for _, prog := range elfFile.Progs {
+			if prog.Type == elf.PT_LOAD && prog.Vaddr <= addr && end <= prog.Vaddr+prog.Filesz {
+				return readFull(prog, int64(addr-prog.Vaddr), buff)
+			}
+		}
+		return fmt.Errorf("no loadable segment contains %#x-%#x", addr, end)
+	}
+
+	machoFile, err := macho.Open(pathToProgram)
+	if err != nil {
+		return err
+	}
+	defer machoFile.Close()
+	for _, load := range machoFile.Loads {
+		segment, ok := load.(*macho.Segment)
+		if ok && segment.Addr <= addr && end <= segment.Addr+segment.Filesz {
+			return readFull(segment, int64(addr-segment.Addr), buff)
+		}
+	}
+	return fmt.Errorf("no segment contains %#x-%#x", addr, end)
+}
+
+func readFull(r io.ReaderAt, offset int64, buff []byte) error {
+	n, err := r.ReadAt(buff, offset)
+	if n == len(buff) {
+		return nil
+	}
+	return err
+}
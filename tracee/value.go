@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"math"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -142,6 +143,9 @@ type ptrValue struct {
 }
 
 func (v ptrValue) String() string {
+	if v.addr == 0 {
+		return "<nil>"
+	}
 	if v.pointedVal != nil {
 		return fmt.Sprintf("&%s", v.pointedVal)
 	}
@@ -151,9 +155,14 @@ func (v ptrValue) String() string {
 type funcValue struct {
 	*dwarf.FuncType
 	addr uint64
+	// name is the name of the function the value points to. Empty if unknown.
+	name string
 }
 
 func (v funcValue) String() string {
+	if v.name != "" {
+		return fmt.Sprintf("func(%s)", v.name)
+	}
 	return fmt.Sprintf("%#x", v.addr)
 }
 
@@ -168,7 +177,8 @@ func (v stringValue) String() string {
 
 type sliceValue struct {
 	*dwarf.StructType
-	val []value
+	val      []value
+	len, cap int
 }
 
 func (v sliceValue) String() string {
@@ -176,6 +186,11 @@ func (v sliceValue) String() string {
 		return "nil"
 	}
 
+	size := strconv.Itoa(v.len)
+	if v.cap != v.len {
+		size = fmt.Sprintf("%d/%d", v.len, v.cap)
+	}
+
 	var vals []string
 	abbrev := false
 	for i, v := range v.val {
@@ -187,9 +202,9 @@ func (v sliceValue) String() string {
 	}
 
 	if abbrev {
-		return fmt.Sprintf("[]{%s, ...}", strings.Join(vals, ", "))
+		return fmt.Sprintf("[%s]{%s, ...}", size, strings.Join(vals, ", "))
 	}
-	return fmt.Sprintf("[]{%s}", strings.Join(vals, ", "))
+	return fmt.Sprintf("[%s]{%s}", size, strings.Join(vals, ", "))
 }
 
 type structValue struct {
@@ -224,13 +239,26 @@ func (v interfaceValue) String() string {
 		return "nil"
 	}
 
-	typeName := v.implType.String()
-	const structPrefix = "struct "
-	if strings.HasPrefix(typeName, structPrefix) {
-		// just to make the logs cleaner
-		typeName = strings.TrimPrefix(typeName, structPrefix)
+	typeName := v.typeName()
+	switch implVal := v.implVal.(type) {
+	case structValue:
+		return fmt.Sprintf("(%s)%s", typeName, implVal)
+	case ptrValue:
+		if _, ok := implVal.pointedVal.(structValue); ok {
+			// (*main.S){...} rather than (*main.S)(&{...}), like the composite literal.
+			return fmt.Sprintf("(%s)%s", typeName, strings.TrimPrefix(implVal.String(), "&"))
+		}
 	}
-	return fmt.Sprintf("%s(%s)", typeName, v.implVal)
+	return fmt.Sprintf("(%s)(%s)", typeName, v.implVal)
+}
+
+// typeName returns the go type name of the concrete type, like *os.PathError.
+func (v interfaceValue) typeName() string {
+	if name := v.implType.Common().Name; name != "" {
+		return name
+	}
+	// the dwarf type's string has the 'struct ' prefix, like '*struct main.S'.
+	return strings.Replace(v.implType.String(), "struct ", "", 1)
 }
 
 type arrayValue struct {
@@ -261,9 +289,27 @@ type mapValue struct {
 }
 
 func (v mapValue) String() string {
-	var vals []string
+	return v.SortedString()
+}
+
+// SortedString returns the string representation of the map with the entries sorted by the key's string.
+// The map's iteration order is random and so the output would differ between runs otherwise.
+func (v mapValue) SortedString() string {
+	type entry struct{ key, val string }
+	var entries []entry
 	for k, v := range v.val {
-		vals = append(vals, fmt.Sprintf("%s: %s", k, v))
+		entries = append(entries, entry{key: fmt.Sprintf("%v", k), val: fmt.Sprintf("%v", v)})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].key != entries[j].key {
+			return entries[i].key < entries[j].key
+		}
+		return entries[i].val < entries[j].val
+	})
+
+	var vals []string
+	for _, e := range entries {
+		vals = append(vals, fmt.Sprintf("%s: %s", e.key, e.val))
 	}
 	return fmt.Sprintf("{%s}", strings.Join(vals, ", "))
 }
@@ -280,8 +326,16 @@ func (v voidValue) String() string {
 type valueParser struct {
 	reader         memoryReader
 	mapRuntimeType func(addr uint64) (dwarf.Type, error)
+	// findFunction is used to show the name of the function value. The address is shown instead if nil.
+	findFunction func(pc uint64) (*Function, error)
+	// derefs is the number of the pointers dereferenced to reach the value being parsed.
+	derefs int
 }
 
+// maxDerefs is the maximum number of the pointers dereferenced to parse one value. The remaining depth is not
+// enough to stop parsing the cyclic value which doesn't include the struct, like the interface holding the pointer to itself.
+const maxDerefs = 64
+
 type memoryReader interface {
 	ReadMemory(addr uint64, out []byte) error
 }
@@ -349,6 +403,10 @@ func (b valueParser) parseValue(rawTyp dwarf.Type, val []byte, remainingDepth in
 			// unsafe.Pointer
 			return ptrValue{PtrType: typ, addr: addr}
 		}
+		if b.derefs >= maxDerefs {
+			return ptrValue{PtrType: typ, addr: addr}
+		}
+		b.derefs++
 
 		buff := make([]byte, typ.Type.Size())
 		if err := b.reader.ReadMemory(addr, buff); err != nil {
@@ -360,9 +418,9 @@ func (b valueParser) parseValue(rawTyp dwarf.Type, val []byte, remainingDepth in
 		return ptrValue{PtrType: typ, addr: addr, pointedVal: pointedVal}
 
 	case *dwarf.FuncType:
-		// TODO: print the pointer to the actual function (and the variables in closure if possible).
+		// TODO: print the variables in closure if possible.
 		addr := binary.LittleEndian.Uint64(val)
-		return funcValue{FuncType: typ, addr: addr}
+		return funcValue{FuncType: typ, addr: addr, name: b.funcName(addr)}
 
 	case *dwarf.StructType:
 		switch {
@@ -410,16 +468,40 @@ func (b valueParser) parseStringValue(typ *dwarf.StructType, val []byte) stringV
 	return stringValue{StructType: typ, val: string(buff)}
 }
 
+// funcName returns the name of the function the func value points to. The func value is the pointer to
+// the funcval struct, whose first field is the entry address of the function.
+func (b valueParser) funcName(addr uint64) string {
+	if addr == 0 || b.findFunction == nil {
+		return ""
+	}
+
+	buff := make([]byte, 8)
+	if err := b.reader.ReadMemory(addr, buff); err != nil {
+		log.Debugf("failed to read memory (addr: %x): %v", addr, err)
+		return ""
+	}
+	f, err := b.findFunction(binary.LittleEndian.Uint64(buff))
+	if err != nil {
+		log.Debugf("failed to find the function: %v", err)
+		return ""
+	}
+	return f.Name
+}
+
 func (b valueParser) parseSliceValue(typ *dwarf.StructType, val []byte, remainingDepth int) sliceValue {
 	// Values are wrapped by slice struct. So +1 here.
 	structVal := b.parseStructValue(typ, val, remainingDepth+1)
 	length := int(structVal.fields["len"].(int64Value).val)
+	capacity := int(structVal.fields["cap"].(int64Value).val)
 	if length == 0 {
-		return sliceValue{StructType: typ}
+		return sliceValue{StructType: typ, cap: capacity}
 	}
 
 	firstElem := structVal.fields["array"].(ptrValue)
-	sliceVal := sliceValue{StructType: typ, val: []value{firstElem.pointedVal}}
+	if firstElem.pointedVal == nil {
+		return sliceValue{StructType: typ, len: length, cap: capacity}
+	}
+	sliceVal := sliceValue{StructType: typ, val: []value{firstElem.pointedVal}, len: length, cap: capacity}
 
 	for i := 1; i < length; i++ {
 		addr := firstElem.addr + uint64(firstElem.pointedVal.Size())*uint64(i)
@@ -444,28 +526,20 @@ func (b valueParser) parseInterfaceValue(typ *dwarf.StructType, val []byte, rema
 		return interfaceValue{StructType: typ, abbreviated: true}
 	}
 
-	tab := ptrToTab.pointedVal.(structValue)
-	runtimeTypeAddr := tab.fields["_type"].(ptrValue).addr
-	implType, err := b.mapRuntimeType(runtimeTypeAddr)
-	if err != nil {
-		log.Debugf("failed to find the impl type (runtime type addr: %x): %v", runtimeTypeAddr, err)
+	tab, ok := ptrToTab.pointedVal.(structValue)
+	if !ok {
 		return interfaceValue{StructType: typ}
 	}
-
-	data := structVal.fields["data"].(ptrValue)
-	if _, ok := implType.(*dwarf.PtrType); ok {
-		buff := make([]byte, 8)
-		binary.LittleEndian.PutUint64(buff, data.addr)
-		return interfaceValue{StructType: typ, implType: implType, implVal: b.parseValue(implType, buff, remainingDepth)}
-	}
-
-	// When the actual type is not pointer, we need the explicit dereference because data.addr is the pointer to the data.
-	dataBuff := make([]byte, implType.Size())
-	if err := b.reader.ReadMemory(data.addr, dataBuff); err != nil {
-		log.Debugf("failed to read memory (addr: %x): %v", data.addr, err)
-		return interfaceValue{StructType: typ}
+	// the field is renamed to 'Type' since go 1.22, where the itab struct is moved to the internal/abi package.
+	runtimeType, ok := tab.fields["_type"].(ptrValue)
+	if !ok {
+		if runtimeType, ok = tab.fields["Type"].(ptrValue); !ok {
+			return interfaceValue{StructType: typ}
+		}
 	}
-	return interfaceValue{StructType: typ, implType: implType, implVal: b.parseValue(implType, dataBuff, remainingDepth)}
+	runtimeTypeAddr := runtimeType.addr
+	data := structVal.fields["data"].(ptrValue)
+	return b.parseImplValue(typ, runtimeTypeAddr, data.addr, remainingDepth)
 }
 
 func (b valueParser) parseEmptyInterfaceValue(typ *dwarf.StructType, val []byte, remainingDepth int) interfaceValue {
@@ -481,6 +555,12 @@ func (b valueParser) parseEmptyInterfaceValue(typ *dwarf.StructType, val []byte,
 	}
 
 	runtimeTypeAddr := structVal.fields["_type"].(ptrValue).addr
+	return b.parseImplValue(typ, runtimeTypeAddr, data.addr, remainingDepth)
+}
+
+// parseImplValue parses the actual value the interface holds. Both iface and eface use this
+// once the runtime type is known.
+func (b valueParser) parseImplValue(typ *dwarf.StructType, runtimeTypeAddr, dataAddr uint64, remainingDepth int) interfaceValue {
 	implType, err := b.mapRuntimeType(runtimeTypeAddr)
 	if err != nil {
 		log.Debugf("failed to find the impl type (runtime type addr: %x): %v", runtimeTypeAddr, err)
@@ -489,17 +569,16 @@ func (b valueParser) parseEmptyInterfaceValue(typ *dwarf.StructType, val []byte,
 
 	if _, ok := implType.(*dwarf.PtrType); ok {
 		buff := make([]byte, 8)
-		binary.LittleEndian.PutUint64(buff, data.addr)
+		binary.LittleEndian.PutUint64(buff, dataAddr)
 		return interfaceValue{StructType: typ, implType: implType, implVal: b.parseValue(implType, buff, remainingDepth)}
 	}
 
-	// When the actual type is not pointer, we need the explicit dereference because data.addr is the pointer to the data.
+	// When the actual type is not pointer, we need the explicit dereference because dataAddr is the pointer to the data.
 	dataBuff := make([]byte, implType.Size())
-	if err := b.reader.ReadMemory(data.addr, dataBuff); err != nil {
-		log.Debugf("failed to read memory (addr: %x): %v", data.addr, err)
+	if err := b.reader.ReadMemory(dataAddr, dataBuff); err != nil {
+		log.Debugf("failed to read memory (addr: %x): %v", dataAddr, err)
 		return interfaceValue{StructType: typ}
 	}
-
 	return interfaceValue{StructType: typ, implType: implType, implVal: b.parseValue(implType, dataBuff, remainingDepth)}
 }
 
@@ -518,46 +597,107 @@ func (b valueParser) parseStructValue(typ *dwarf.StructType, val []byte, remaini
 func (b valueParser) parseMapValue(typ *dwarf.TypedefType, val []byte, remainingDepth int) mapValue {
 	// Actual keys and values are wrapped by hmap struct and buckets struct. So +2 here.
 	ptrVal := b.parseValue(typ.Type, val, remainingDepth+2)
-	if ptrVal.(ptrValue).pointedVal == nil {
+	hmapVal, ok := ptrVal.(ptrValue).pointedVal.(structValue)
+	if !ok {
 		return mapValue{TypedefType: typ, val: nil}
 	}
 
-	hmapVal := ptrVal.(ptrValue).pointedVal.(structValue)
-	numBuckets := 1 << hmapVal.fields["B"].(uint8Value).val
+	// the swiss table map since go 1.24 has no buckets.
+	logNumBuckets, ok := hmapVal.fields["B"].(uint8Value)
+	if !ok {
+		return mapValue{TypedefType: typ, val: nil}
+	}
+	numBuckets := 1 << logNumBuckets.val
 	ptrToBuckets := hmapVal.fields["buckets"].(ptrValue)
+	mapValues := b.parseBuckets(ptrToBuckets, numBuckets, false, remainingDepth)
+
+	// the map is growing. The entries in the old buckets which are not evacuated yet are not in the new buckets.
 	ptrToOldBuckets := hmapVal.fields["oldbuckets"].(ptrValue)
 	if ptrToOldBuckets.addr != 0 {
-		log.Debugf("Map values may be defective")
+		numOldBuckets := numBuckets
+		if hmapVal.fields["flags"].(uint8Value).val&sameSizeGrow == 0 {
+			numOldBuckets >>= 1
+		}
+		// Actual keys and values are wrapped by struct buckets. So +1 here.
+		ptrToOldBuckets = b.reparsePtr(ptrToBuckets.PtrType, ptrToOldBuckets.addr, remainingDepth+1)
+		for k, v := range b.parseBuckets(ptrToOldBuckets, numOldBuckets, true, remainingDepth) {
+			mapValues[k] = v
+		}
 	}
 
+	return mapValue{TypedefType: typ, val: mapValues}
+}
+
+// parseBuckets parses the `numBuckets` buckets starting from the `ptrToBuckets`.
+// If skipEvacuated is true, the buckets already evacuated to the new buckets are skipped.
+func (b valueParser) parseBuckets(ptrToBuckets ptrValue, numBuckets int, skipEvacuated bool, remainingDepth int) map[value]value {
 	mapValues := make(map[value]value)
 	for i := 0; ; i++ {
-		mapValuesInBucket := b.parseBucket(ptrToBuckets, remainingDepth)
-		for k, v := range mapValuesInBucket {
-			mapValues[k] = v
+		if !skipEvacuated || !isEvacuatedBucket(ptrToBuckets) {
+			mapValuesInBucket := b.parseBucket(ptrToBuckets, remainingDepth)
+			for k, v := range mapValuesInBucket {
+				mapValues[k] = v
+			}
 		}
-		if i+1 == numBuckets {
+		if i+1 == numBuckets || ptrToBuckets.addr == 0 {
 			break
 		}
 
-		buckets := ptrToBuckets.pointedVal.(structValue)
+		buckets, ok := ptrToBuckets.pointedVal.(structValue)
+		if !ok {
+			break
+		}
 		nextBucketAddr := ptrToBuckets.addr + uint64(buckets.Size())
-		buff := make([]byte, 8)
-		binary.LittleEndian.PutUint64(buff, nextBucketAddr)
 		// Actual keys and values are wrapped by struct buckets. So +1 here.
-		ptrToBuckets = b.parseValue(ptrToBuckets.PtrType, buff, remainingDepth+1).(ptrValue)
+		ptrToBuckets = b.reparsePtr(ptrToBuckets.PtrType, nextBucketAddr, remainingDepth+1)
 	}
+	return mapValues
+}
 
-	return mapValue{TypedefType: typ, val: mapValues}
+func (b valueParser) reparsePtr(typ *dwarf.PtrType, addr uint64, remainingDepth int) ptrValue {
+	buff := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buff, addr)
+	return b.parseValue(typ, buff, remainingDepth).(ptrValue)
 }
 
+// The values of tophash and hmap.flags defined in runtime/map.go (go 1.14 or later).
+const (
+	evacuatedX     = 2
+	evacuatedY     = 3
+	evacuatedEmpty = 4
+	sameSizeGrow   = 8
+)
+
+func isEvacuatedBucket(ptrToBucket ptrValue) bool {
+	if ptrToBucket.addr == 0 {
+		return false
+	}
+	bucket, ok := ptrToBucket.pointedVal.(structValue)
+	if !ok {
+		return false
+	}
+	tophash := bucket.fields["tophash"].(arrayValue)
+	if len(tophash.val) == 0 {
+		return false
+	}
+	return isEvacuated(tophash.val[0].(uint8Value).val)
+}
+
+func isEvacuated(tophash uint8) bool {
+	return tophash >= evacuatedX && tophash <= evacuatedEmpty
+}
+
+// parseBucket parses the bucket and its chain of overflow buckets.
 func (b valueParser) parseBucket(ptrToBucket ptrValue, remainingDepth int) map[value]value {
 	if ptrToBucket.addr == 0 {
 		return nil // initialized map may not have bucket
 	}
 
 	mapValues := make(map[value]value)
-	buckets := ptrToBucket.pointedVal.(structValue)
+	buckets, ok := ptrToBucket.pointedVal.(structValue)
+	if !ok {
+		return nil
+	}
 	tophash := buckets.fields["tophash"].(arrayValue)
 	keys := buckets.fields["keys"].(arrayValue)
 	values := buckets.fields["values"].(arrayValue)
@@ -574,10 +714,8 @@ func (b valueParser) parseBucket(ptrToBucket ptrValue, remainingDepth int) map[v
 		return mapValues
 	}
 
-	buff := make([]byte, 8)
-	binary.LittleEndian.PutUint64(buff, overflow.addr)
 	// Actual keys and values are wrapped by struct buckets. So +1 here.
-	ptrToOverflowBucket := b.parseValue(ptrToBucket.PtrType, buff, remainingDepth+1).(ptrValue)
+	ptrToOverflowBucket := b.reparsePtr(ptrToBucket.PtrType, overflow.addr, remainingDepth+1)
 	overflowedValues := b.parseBucket(ptrToOverflowBucket, remainingDepth)
 	for k, v := range overflowedValues {
 		mapValues[k] = v
@@ -1,6 +1,8 @@
 package tracee
 
 import (
+	"debug/dwarf"
+	"encoding/binary"
 	"fmt"
 	"runtime"
 	"strings"
@@ -41,7 +43,7 @@ func TestParseValue(t *testing.T) {
 		{funcAddr: testutils.TypePrintAddrPrintComplex128, expected: "(3+4i)"},
 		{funcAddr: testutils.TypePrintAddrPrintString, expected: "\"hello\\n\""},
 		{funcAddr: testutils.TypePrintAddrPrintArray, expected: "[2]{1, 2}"},
-		{funcAddr: testutils.TypePrintAddrPrintSlice, expected: "[]{3, 4}"},
+		{funcAddr: testutils.TypePrintAddrPrintSlice, expected: "[2]{3, 4}"},
 		{funcAddr: testutils.TypePrintAddrPrintNilSlice, expected: "nil"},
 		{funcAddr: testutils.TypePrintAddrPrintPtr, expected: "&1"},
 	} {
@@ -87,9 +89,9 @@ func TestParseValue_NotFixedStringCase(t *testing.T) {
 	go1_11 := GoVersion{MajorVersion: 1, MinorVersion: 11, PatchVersion: 0}
 
 	for _, testdata := range []struct {
-		funcAddr        uint64
-		testFunc        func(t *testing.T, val value)
-		testIfLaterThan GoVersion
+		funcAddr      uint64
+		testFunc      func(t *testing.T, val value)
+		testIfAtLeast GoVersion
 	}{
 		// Note: the test order must be same as the order of functions called in typeprint.
 		{funcAddr: testutils.TypePrintAddrPrintStruct, testFunc: func(t *testing.T, val value) {
@@ -103,7 +105,7 @@ func TestParseValue_NotFixedStringCase(t *testing.T) {
 			}
 		}},
 		{funcAddr: testutils.TypePrintAddrPrintFunc, testFunc: func(t *testing.T, val value) {
-			if !strings.HasPrefix(val.String(), "0x") {
+			if !strings.HasPrefix(val.String(), "func(main.main.func") {
 				t.Errorf("wrong prefix: %s", val)
 			}
 		}},
@@ -115,7 +117,7 @@ func TestParseValue_NotFixedStringCase(t *testing.T) {
 			if implVal.fields["a"].(int64Value).val != 5 {
 				t.Errorf("wrong value: %s", implVal.fields)
 			}
-		}, testIfLaterThan: go1_11},
+		}, testIfAtLeast: go1_11},
 		{funcAddr: testutils.TypePrintAddrPrintPtrInterface, testFunc: func(t *testing.T, val value) {
 			implVal, ok := val.(interfaceValue).implVal.(ptrValue).pointedVal.(structValue)
 			if !ok {
@@ -124,7 +126,7 @@ func TestParseValue_NotFixedStringCase(t *testing.T) {
 			if implVal.fields["a"].(int64Value).val != 9 {
 				t.Errorf("wrong value: %s", implVal.fields)
 			}
-		}, testIfLaterThan: go1_11},
+		}, testIfAtLeast: go1_11},
 		{funcAddr: testutils.TypePrintAddrPrintNilInterface, testFunc: func(t *testing.T, val value) {
 			if val.String() != "nil" {
 				t.Errorf("wrong val: %s", val)
@@ -138,7 +140,7 @@ func TestParseValue_NotFixedStringCase(t *testing.T) {
 			if implVal.fields["a"].(int64Value).val != 9 {
 				t.Errorf("wrong value: %s", implVal.fields)
 			}
-		}, testIfLaterThan: go1_11},
+		}, testIfAtLeast: go1_11},
 		{funcAddr: testutils.TypePrintAddrPrintNilEmptyInterface, testFunc: func(t *testing.T, val value) {
 			if val.String() != "nil" {
 				t.Errorf("wrong val: %s", val)
@@ -162,7 +164,7 @@ func TestParseValue_NotFixedStringCase(t *testing.T) {
 			}
 		}},
 	} {
-		if !proc.GoVersion.LaterThan(testdata.testIfLaterThan) {
+		if proc.GoVersion.OlderThan(testdata.testIfAtLeast) {
 			continue
 		}
 
@@ -195,3 +197,222 @@ func TestParseValue_NotFixedStringCase(t *testing.T) {
 		proc.SingleStep(tids[0], testdata.funcAddr)
 	}
 }
+
+func TestParseValue_ParseLevel(t *testing.T) {
+	int64Type := &dwarf.IntType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 8}}}
+	innerType := &dwarf.StructType{StructName: "main.inner", CommonType: dwarf.CommonType{ByteSize: 8}, Field: []*dwarf.StructField{
+		&dwarf.StructField{Name: "b", Type: int64Type, ByteOffset: 0},
+	}}
+	outerType := &dwarf.StructType{StructName: "main.outer", CommonType: dwarf.CommonType{ByteSize: 8}, Field: []*dwarf.StructField{
+		&dwarf.StructField{Name: "in", Type: innerType, ByteOffset: 0},
+	}}
+	val := []byte{1, 0, 0, 0, 0, 0, 0, 0}
+
+	for _, testdata := range []struct {
+		parseLevel int
+		expected   string
+	}{
+		{parseLevel: 0, expected: "{...}"},
+		{parseLevel: 1, expected: "{in: {...}}"},
+		{parseLevel: 2, expected: "{in: {b: 1}}"},
+	} {
+		actual := (valueParser{}).parseValue(outerType, val, testdata.parseLevel)
+		if actual.String() != testdata.expected {
+			t.Errorf("[%d] wrong value: %s", testdata.parseLevel, actual)
+		}
+	}
+}
+
+// fakeMemoryReader reads the memory from the map of the address to the bytes.
+type fakeMemoryReader map[uint64][]byte
+
+func (r fakeMemoryReader) ReadMemory(addr uint64, out []byte) error {
+	data, ok := r[addr]
+	if !ok || len(data) < len(out) {
+		return fmt.Errorf("no memory at %#x", addr)
+	}
+	copy(out, data)
+	return nil
+}
+
+func TestParseValue_DeepLinkedList(t *testing.T) {
+	intType := &dwarf.IntType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 8, Name: "int"}}}
+	nodeType := &dwarf.StructType{CommonType: dwarf.CommonType{ByteSize: 16}, StructName: "main.node", Kind: "struct"}
+	ptrType := &dwarf.PtrType{CommonType: dwarf.CommonType{ByteSize: 8}, Type: nodeType}
+	nodeType.Field = []*dwarf.StructField{{Name: "next", Type: ptrType, ByteOffset: 0}, {Name: "val", Type: intType, ByteOffset: 8}}
+
+	// node i is at 0x1000*(i+1) and points to the node i+1.
+	const numNodes = 100
+	reader := make(fakeMemoryReader)
+	for i := 0; i < numNodes; i++ {
+		node := make([]byte, 16)
+		if i+1 < numNodes {
+			binary.LittleEndian.PutUint64(node, uint64(0x1000*(i+2)))
+		}
+		binary.LittleEndian.PutUint64(node[8:], uint64(i))
+		reader[uint64(0x1000*(i+1))] = node
+	}
+
+	val := (valueParser{reader: reader}).parseValue(nodeType, reader[0x1000], 3)
+	if actual := strings.Count(val.String(), "val: "); actual != 3 {
+		t.Errorf("wrong number of the parsed nodes: %d, %s", actual, val)
+	}
+	if !strings.Contains(val.String(), "{...}") {
+		t.Errorf("not truncated: %s", val)
+	}
+}
+
+func TestParseValue_CyclicPointer(t *testing.T) {
+	// type P *P
+	ptrType := &dwarf.PtrType{CommonType: dwarf.CommonType{ByteSize: 8}}
+	ptrType.Type = ptrType
+
+	buff := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buff, 0x1000)
+	reader := fakeMemoryReader{0x1000: buff}
+
+	val := (valueParser{reader: reader}).parseValue(ptrType, buff, 1)
+	if actual := strings.Count(val.String(), "&"); actual != maxDerefs {
+		t.Errorf("wrong number of the dereferences: %d, %s", actual, val)
+	}
+}
+
+func TestIsEvacuated(t *testing.T) {
+	for _, testdata := range []struct {
+		tophash  uint8
+		expected bool
+	}{
+		{tophash: 0, expected: false},
+		{tophash: 1, expected: false},
+		{tophash: evacuatedX, expected: true},
+		{tophash: evacuatedY, expected: true},
+		{tophash: evacuatedEmpty, expected: true},
+		{tophash: 5, expected: false},
+		{tophash: 0xff, expected: false},
+	} {
+		if actual := isEvacuated(testdata.tophash); actual != testdata.expected {
+			t.Errorf("[%d] wrong result: %v", testdata.tophash, actual)
+		}
+	}
+}
+
+func TestMapValue_SortedString(t *testing.T) {
+	mapVal := mapValue{val: make(map[value]value)}
+	for i := int64(0); i < 20; i++ {
+		mapVal.val[int64Value{val: i}] = int64Value{val: i * 2}
+	}
+
+	expected := mapVal.String()
+	if !strings.HasPrefix(expected, "{0: 0, 1: 2, 10: 20, 11: 22,") {
+		t.Errorf("not sorted: %s", expected)
+	}
+	for i := 0; i < 10; i++ {
+		if actual := mapVal.String(); actual != expected {
+			t.Fatalf("output changed: %s, %s", expected, actual)
+		}
+	}
+}
+
+func TestSliceValue_String(t *testing.T) {
+	for i, testdata := range []struct {
+		val      sliceValue
+		expected string
+	}{
+		{val: sliceValue{val: []value{int64Value{val: 1}, int64Value{val: 2}}, len: 2, cap: 2}, expected: "[2]{1, 2}"},
+		{val: sliceValue{val: []value{int64Value{val: 1}, int64Value{val: 2}}, len: 2, cap: 10}, expected: "[2/10]{1, 2}"},
+		{val: sliceValue{cap: 10}, expected: "nil"},
+	} {
+		if actual := testdata.val.String(); actual != testdata.expected {
+			t.Errorf("[%d] wrong string: %s", i, actual)
+		}
+	}
+}
+
+func TestPtrValue_String(t *testing.T) {
+	for i, testdata := range []struct {
+		val      ptrValue
+		expected string
+	}{
+		{val: ptrValue{}, expected: "<nil>"},
+		{val: ptrValue{addr: 0x1000}, expected: "0x1000"},
+		{val: ptrValue{addr: 0x1000, pointedVal: int64Value{val: 1}}, expected: "&1"},
+	} {
+		if actual := testdata.val.String(); actual != testdata.expected {
+			t.Errorf("[%d] wrong string: %s", i, actual)
+		}
+	}
+}
+
+func TestFuncValue_String(t *testing.T) {
+	for i, testdata := range []struct {
+		val      funcValue
+		expected string
+	}{
+		{val: funcValue{addr: 0x1000, name: "main.f"}, expected: "func(main.f)"},
+		{val: funcValue{addr: 0x1000}, expected: "0x1000"},
+	} {
+		if actual := testdata.val.String(); actual != testdata.expected {
+			t.Errorf("[%d] wrong string: %s", i, actual)
+		}
+	}
+}
+
+func TestInterfaceValue_String(t *testing.T) {
+	structType := &dwarf.StructType{StructName: "main.S"}
+	structType.Name = "main.S"
+	ptrType := &dwarf.PtrType{Type: structType}
+	ptrType.Name = "*main.S"
+	intType := &dwarf.IntType{}
+	intType.Name = "int"
+	structVal := structValue{StructType: structType, fields: map[string]value{"a": int64Value{val: 1}}}
+
+	for i, testdata := range []struct {
+		val      interfaceValue
+		expected string
+	}{
+		{val: interfaceValue{implType: structType, implVal: structVal}, expected: "(main.S){a: 1}"},
+		{val: interfaceValue{implType: ptrType, implVal: ptrValue{PtrType: ptrType, addr: 0x1000, pointedVal: structVal}}, expected: "(*main.S){a: 1}"},
+		{val: interfaceValue{implType: intType, implVal: int64Value{IntType: intType, val: 42}}, expected: "(int)(42)"},
+		{val: interfaceValue{}, expected: "nil"},
+	} {
+		if actual := testdata.val.String(); actual != testdata.expected {
+			t.Errorf("[%d] wrong string: %s", i, actual)
+		}
+	}
+}
+
+func BenchmarkParseValue(b *testing.B) {
+	proc, err := LaunchProcess(testutils.ProgramTypePrint, nil, typePrintAttr)
+	if err != nil {
+		b.Fatalf("failed to launch process: %v", err)
+	}
+	defer proc.Detach()
+
+	if err := proc.SetBreakpoint(testutils.TypePrintAddrPrintStruct); err != nil {
+		b.Fatalf("failed to set breakpoint: %v", err)
+	}
+	event, err := proc.ContinueAndWait()
+	if err != nil {
+		b.Fatalf("failed to continue and wait: %v", err)
+	}
+
+	tids := event.Data.([]int)
+	threadInfo, err := proc.CurrentThreadInfo(tids[0])
+	if err != nil {
+		b.Fatalf("failed to get CurrentThreadInfo: %v", err)
+	}
+	stackFrame, err := proc.StackFrameAt(threadInfo.CurrentStackAddr, testutils.TypePrintAddrPrintStruct)
+	if err != nil {
+		b.Fatalf("failed to get stack frame: %v", err)
+	}
+	if len(stackFrame.InputArguments) == 0 {
+		b.Fatalf("no args: %s", stackFrame.Function.Name)
+	}
+	arg := stackFrame.InputArguments[0]
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		arg.ParseValue(3)
+	}
+}
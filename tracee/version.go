@@ -1,6 +1,8 @@
 package tracee
 
 import (
+	"debug/buildinfo"
+	"fmt"
 	"strconv"
 	"strings"
 )
@@ -17,51 +19,114 @@ type GoVersion struct {
 	MajorVersion, MinorVersion, PatchVersion int
 }
 
-// ParseGoVersion parses the go version string such as 'go1.11.1'
+// ParseGoVersion parses the go version string such as 'go1.11.1'. Only the Raw field is set if the string is not in
+// this form. Use ParseGoVersionE to know the error.
 func ParseGoVersion(raw string) GoVersion {
+	goVersion, _ := ParseGoVersionE(raw)
+	return goVersion
+}
+
+// ParseGoVersionE is same as ParseGoVersion, but returns the error if the string is not in the form such as 'go1.11.1'.
+// The pre-release suffix such as 'rc1' is ignored.
+func ParseGoVersionE(raw string) (GoVersion, error) {
 	goVersion := GoVersion{Raw: raw}
 
 	if strings.HasPrefix(raw, develVersion) {
 		goVersion.Devel = true
-		return goVersion
+		return goVersion, nil
 	}
 
 	if !strings.HasPrefix(raw, versionPrefix) {
-		return goVersion
+		return goVersion, fmt.Errorf("malformed go version %q: no %q prefix", raw, versionPrefix)
 	}
 
-	version := strings.Split(strings.TrimPrefix(raw, versionPrefix), ".")
-	if len(version) > 0 {
-		goVersion.MajorVersion, _ = strconv.Atoi(version[0])
+	// the version may be followed by the experiments, like 'go1.21.0 X:loopvar' or 'go1.21.0-X:loopvar' in the build info.
+	trimmed := strings.TrimPrefix(strings.Fields(raw)[0], versionPrefix)
+	if i := strings.Index(trimmed, "-X:"); i >= 0 {
+		trimmed = trimmed[:i]
 	}
+	version := strings.Split(trimmed, ".")
+	if len(version) < 2 || len(version) > 3 {
+		return GoVersion{Raw: raw}, fmt.Errorf("malformed go version %q: wrong number of components", raw)
+	}
+	version[len(version)-1] = trimPreRelease(version[len(version)-1])
 
-	if len(version) > 1 {
-		goVersion.MinorVersion, _ = strconv.Atoi(version[1])
+	numbers := []*int{&goVersion.MajorVersion, &goVersion.MinorVersion, &goVersion.PatchVersion}
+	for i, component := range version {
+		number, err := strconv.Atoi(component)
+		if err != nil || number < 0 {
+			return GoVersion{Raw: raw}, fmt.Errorf("malformed go version %q: invalid component %q", raw, component)
+		}
+		*numbers[i] = number
 	}
+	return goVersion, nil
+}
 
-	if len(version) > 2 {
-		goVersion.PatchVersion, _ = strconv.Atoi(version[2])
+// trimPreRelease removes the pre-release suffix such as 'rc1' and 'beta1' from the version component.
+func trimPreRelease(component string) string {
+	for _, suffix := range []string{"rc", "beta"} {
+		if i := strings.Index(component, suffix); i > 0 {
+			return component[:i]
+		}
 	}
-	return goVersion
+	return component
+}
+
+// readGoVersion reads the version of the go which compiled the program, such as 'go1.11.1'.
+func readGoVersion(programPath string) (string, error) {
+	info, err := buildinfo.ReadFile(programPath)
+	if err != nil {
+		return "", err
+	}
+	return info.GoVersion, nil
 }
 
 // LaterThan returns true if the version is equal to or later than the given version.
+//
+// Deprecated: the name is misleading because the versions can be equal. Use AtLeast instead.
 func (v GoVersion) LaterThan(target GoVersion) bool {
-	if v.Devel {
-		return true
-	}
+	return v.AtLeast(target)
+}
 
-	if v.MajorVersion > target.MajorVersion {
-		return true
-	} else if v.MajorVersion < target.MajorVersion {
-		return false
-	}
+// AtLeast returns true if the version is equal to or later than the given version.
+func (v GoVersion) AtLeast(target GoVersion) bool {
+	return v.compare(target) >= 0
+}
 
-	if v.MinorVersion > target.MinorVersion {
-		return true
-	} else if v.MinorVersion < target.MinorVersion {
-		return false
+// AtMost returns true if the version is equal to or older than the given version.
+func (v GoVersion) AtMost(target GoVersion) bool {
+	return v.compare(target) <= 0
+}
+
+// OlderThan returns true if the version is strictly older than the given version.
+func (v GoVersion) OlderThan(target GoVersion) bool {
+	return v.compare(target) < 0
+}
+
+// compare returns -1, 0 or 1 if the version is older than, same as or later than the given version respectively.
+// The devel version is considered to be later than any released version.
+func (v GoVersion) compare(target GoVersion) int {
+	if v.Devel || target.Devel {
+		switch {
+		case v.Devel && target.Devel:
+			return 0
+		case v.Devel:
+			return 1
+		default:
+			return -1
+		}
 	}
 
-	return v.PatchVersion >= target.PatchVersion
+	for _, pair := range [][2]int{
+		{v.MajorVersion, target.MajorVersion},
+		{v.MinorVersion, target.MinorVersion},
+		{v.PatchVersion, target.PatchVersion},
+	} {
+		if pair[0] > pair[1] {
+			return 1
+		} else if pair[0] < pair[1] {
+			return -1
+		}
+	}
+	return 0
 }
@@ -1,23 +1,49 @@
 package tracee
 
-import "testing"
+import (
+	"runtime"
+	"testing"
 
-func TestParseGoVersion(t *testing.T) {
+	"github.com/ks888/tgo/testutils"
+)
+
+func TestParseGoVersionE(t *testing.T) {
 	for i, testdata := range []struct {
-		input  string
-		expect GoVersion
+		input     string
+		expect    GoVersion
+		expectErr bool
 	}{
 		{input: "go1.11.1", expect: GoVersion{Raw: "go1.11.1", MajorVersion: 1, MinorVersion: 11, PatchVersion: 1}},
 		{input: "go1.11", expect: GoVersion{Raw: "go1.11", MajorVersion: 1, MinorVersion: 11}},
 		{input: "devel", expect: GoVersion{Raw: "devel", Devel: true}},
+		{input: "go1.21rc2", expect: GoVersion{Raw: "go1.21rc2", MajorVersion: 1, MinorVersion: 21}},
+		{input: "go1.21.0 X:loopvar", expect: GoVersion{Raw: "go1.21.0 X:loopvar", MajorVersion: 1, MinorVersion: 21}},
+		{input: "go1.21.0-X:loopvar", expect: GoVersion{Raw: "go1.21.0-X:loopvar", MajorVersion: 1, MinorVersion: 21}},
+		{input: "", expect: GoVersion{}, expectErr: true},
+		{input: "1.11", expect: GoVersion{Raw: "1.11"}, expectErr: true},
+		{input: "go1", expect: GoVersion{Raw: "go1"}, expectErr: true},
+		{input: "go1.x", expect: GoVersion{Raw: "go1.x"}, expectErr: true},
+		{input: "go1.11.1.1", expect: GoVersion{Raw: "go1.11.1.1"}, expectErr: true},
 	} {
-		actual := ParseGoVersion(testdata.input)
+		actual, err := ParseGoVersionE(testdata.input)
+		if (err != nil) != testdata.expectErr {
+			t.Errorf("[%d] unexpected error: %v", i, err)
+		}
 		if actual != testdata.expect {
 			t.Errorf("[%d] wrong result: %v", i, actual)
 		}
 	}
 }
 
+func TestParseGoVersion(t *testing.T) {
+	if actual := ParseGoVersion("go1.11.1"); actual != (GoVersion{Raw: "go1.11.1", MajorVersion: 1, MinorVersion: 11, PatchVersion: 1}) {
+		t.Errorf("wrong result: %v", actual)
+	}
+	if actual := ParseGoVersion("go1.x"); actual != (GoVersion{Raw: "go1.x"}) {
+		t.Errorf("wrong result: %v", actual)
+	}
+}
+
 func TestGoVersion_LaterThan(t *testing.T) {
 	for i, testdata := range []struct {
 		a, b   GoVersion
@@ -70,3 +96,42 @@ func TestGoVersion_LaterThan(t *testing.T) {
 		}
 	}
 }
+
+func TestGoVersion_Compare(t *testing.T) {
+	go1_11 := GoVersion{MajorVersion: 1, MinorVersion: 11}
+	for i, testdata := range []struct {
+		a, b                       GoVersion
+		olderThan, atLeast, atMost bool
+	}{
+		{a: GoVersion{MajorVersion: 1, MinorVersion: 10}, b: go1_11, olderThan: true, atMost: true},
+		{a: go1_11, b: go1_11, atLeast: true, atMost: true},
+		{a: GoVersion{MajorVersion: 1, MinorVersion: 11, PatchVersion: 1}, b: go1_11, atLeast: true},
+		{a: GoVersion{Devel: true}, b: go1_11, atLeast: true},
+		{a: go1_11, b: GoVersion{Devel: true}, olderThan: true, atMost: true},
+		{a: GoVersion{Devel: true}, b: GoVersion{Devel: true}, atLeast: true, atMost: true},
+	} {
+		if actual := testdata.a.OlderThan(testdata.b); actual != testdata.olderThan {
+			t.Errorf("[%d] wrong OlderThan result: %v", i, actual)
+		}
+		if actual := testdata.a.AtLeast(testdata.b); actual != testdata.atLeast {
+			t.Errorf("[%d] wrong AtLeast result: %v", i, actual)
+		}
+		if actual := testdata.a.AtMost(testdata.b); actual != testdata.atMost {
+			t.Errorf("[%d] wrong AtMost result: %v", i, actual)
+		}
+	}
+}
+
+func TestReadGoVersion(t *testing.T) {
+	goVersion, err := readGoVersion(testutils.ProgramHelloworld)
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	if goVersion != runtime.Version() {
+		t.Errorf("wrong version: %s", goVersion)
+	}
+
+	if _, err := readGoVersion("not-exist"); err == nil {
+		t.Errorf("error not returned")
+	}
+}
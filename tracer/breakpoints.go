@@ -58,12 +58,15 @@ func (b Breakpoints) ClearConditional(addr uint64, goRoutineID int64) error {
 }
 
 // ClearAllByGoRoutineID clears all the breakpoints associated with the specified go routine.
+// The breakpoints which are not conditional (e.g. the tracing points) are kept.
 func (b Breakpoints) ClearAllByGoRoutineID(goRoutineID int64) error {
 	for addr, bp := range b.setBreakpoints {
+		associated := false
 		for bp.Disassociate(goRoutineID) {
+			associated = true
 		}
 
-		if !bp.NoAssociation() {
+		if !associated || !bp.NoAssociation() {
 			continue
 		}
 		if err := b.Clear(addr); err != nil {
@@ -74,6 +77,16 @@ func (b Breakpoints) ClearAllByGoRoutineID(goRoutineID int64) error {
 	return nil
 }
 
+// ClearAll clears all the breakpoints including the conditional ones.
+func (b Breakpoints) ClearAll() error {
+	for addr := range b.setBreakpoints {
+		if err := b.Clear(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Set sets the breakpoint at the specified address.
 // If `SetConditional` is called before for the same address, the conditions are removed.
 func (b Breakpoints) Set(addr uint64) error {
@@ -109,6 +122,43 @@ func (b Breakpoints) SetConditional(addr uint64, goRoutineID int64) error {
 	return nil
 }
 
+// BreakpointSnapshot is the state of the breakpoints at some point. Pass it to `Restore` to go back to that state.
+type BreakpointSnapshot struct {
+	associations map[uint64][]int64
+}
+
+// Snapshot returns the current state of the breakpoints.
+func (b Breakpoints) Snapshot() BreakpointSnapshot {
+	snap := BreakpointSnapshot{associations: make(map[uint64][]int64, len(b.setBreakpoints))}
+	for addr, bp := range b.setBreakpoints {
+		snap.associations[addr] = append([]int64(nil), bp.associations...)
+	}
+	return snap
+}
+
+// Restore restores the state of the breakpoints to the snapshot. The breakpoints set after the snapshot are cleared
+// and the breakpoints cleared after the snapshot are set again.
+func (b Breakpoints) Restore(snap BreakpointSnapshot) error {
+	for addr := range b.setBreakpoints {
+		if _, ok := snap.associations[addr]; ok {
+			continue
+		}
+		if err := b.Clear(addr); err != nil {
+			return err
+		}
+	}
+
+	for addr, associations := range snap.associations {
+		if _, ok := b.setBreakpoints[addr]; !ok {
+			if err := b.doSet(addr); err != nil {
+				return err
+			}
+		}
+		b.setBreakpoints[addr] = &conditionalBreakpoint{addr: addr, associations: append([]int64(nil), associations...)}
+	}
+	return nil
+}
+
 type association struct {
 	goRoutineID int64
 }
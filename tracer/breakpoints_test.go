@@ -200,3 +200,127 @@ func TestBreakpoints_ClearAllByGoRoutineID_DuplicateBreakpoints(t *testing.T) {
 		t.Errorf("wrong number of clear ops: %d", numCleared)
 	}
 }
+
+func TestBreakpoints_ClearAllByGoRoutineID_NotConditional(t *testing.T) {
+	numCleared := 0
+	setBreakpoint := func(uint64) error { return nil }
+	clearBreakpoint := func(uint64) error { numCleared++; return nil }
+	bps := NewBreakpoints(setBreakpoint, clearBreakpoint)
+
+	if err := bps.Set(0x100); err != nil {
+		t.Fatalf("failed to set breakpoint: %v", err)
+	}
+
+	if err := bps.ClearAllByGoRoutineID(1); err != nil {
+		t.Fatalf("failed to clear breakpoint: %v", err)
+	}
+
+	if numCleared != 0 {
+		t.Errorf("wrong number of clear ops: %d", numCleared)
+	}
+	if !bps.Hit(0x100, 1) {
+		t.Errorf("breakpoint is cleared")
+	}
+}
+
+func TestBreakpoints_ClearAll(t *testing.T) {
+	numCleared := 0
+	setBreakpoint := func(uint64) error { return nil }
+	clearBreakpoint := func(uint64) error { numCleared++; return nil }
+	bps := NewBreakpoints(setBreakpoint, clearBreakpoint)
+
+	if err := bps.Set(0x100); err != nil {
+		t.Fatalf("failed to set breakpoint: %v", err)
+	}
+
+	if err := bps.SetConditional(0x200, 1); err != nil {
+		t.Fatalf("failed to set breakpoint: %v", err)
+	}
+
+	if err := bps.ClearAll(); err != nil {
+		t.Fatalf("failed to clear breakpoint: %v", err)
+	}
+
+	if numCleared != 2 {
+		t.Errorf("wrong number of clear ops: %d", numCleared)
+	}
+	if bps.Exist(0x100) || bps.Exist(0x200) {
+		t.Errorf("breakpoint remains")
+	}
+}
+
+func TestBreakpoints_SnapshotAndRestore(t *testing.T) {
+	numSet, numCleared := 0, 0
+	setBreakpoint := func(uint64) error { numSet++; return nil }
+	clearBreakpoint := func(uint64) error { numCleared++; return nil }
+	bps := NewBreakpoints(setBreakpoint, clearBreakpoint)
+
+	if err := bps.Set(0x100); err != nil {
+		t.Fatalf("failed to set breakpoint: %v", err)
+	}
+	if err := bps.SetConditional(0x200, 1); err != nil {
+		t.Fatalf("failed to set breakpoint: %v", err)
+	}
+	snap := bps.Snapshot()
+
+	if err := bps.Clear(0x100); err != nil {
+		t.Fatalf("failed to clear breakpoint: %v", err)
+	}
+	if err := bps.SetConditional(0x200, 2); err != nil {
+		t.Fatalf("failed to set breakpoint: %v", err)
+	}
+	if err := bps.Set(0x300); err != nil {
+		t.Fatalf("failed to set breakpoint: %v", err)
+	}
+
+	if err := bps.Restore(snap); err != nil {
+		t.Fatalf("failed to restore breakpoints: %v", err)
+	}
+
+	if !bps.Hit(0x100, 1) {
+		t.Errorf("cleared breakpoint is not restored")
+	}
+	if !bps.Hit(0x200, 1) || bps.Hit(0x200, 2) {
+		t.Errorf("associations are not restored")
+	}
+	if bps.Exist(0x300) {
+		t.Errorf("new breakpoint is not cleared")
+	}
+	if numSet != 4 {
+		t.Errorf("wrong number of set ops: %d", numSet)
+	}
+	if numCleared != 2 {
+		t.Errorf("wrong number of clear ops: %d", numCleared)
+	}
+}
+
+func BenchmarkBreakpoints_Set(b *testing.B) {
+	nop := func(uint64) error { return nil }
+	bps := NewBreakpoints(nop, nop)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := bps.Set(uint64(i % 1024)); err != nil {
+			b.Fatalf("failed to set breakpoint: %v", err)
+		}
+	}
+}
+
+func BenchmarkBreakpoints_Hit(b *testing.B) {
+	nop := func(uint64) error { return nil }
+	bps := NewBreakpoints(nop, nop)
+	for addr := uint64(0); addr < 1024; addr++ {
+		// the return breakpoints are conditional, so associate multiple go routines like the recursive calls.
+		for goRoutineID := int64(1); goRoutineID <= 8; goRoutineID++ {
+			if err := bps.SetConditional(addr, goRoutineID); err != nil {
+				b.Fatalf("failed to set breakpoint: %v", err)
+			}
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bps.Hit(uint64(i%1024), 8)
+	}
+}
@@ -0,0 +1,84 @@
+package tracer
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ks888/tgo/tracee"
+)
+
+// callGraph holds the call chains traced during the session. It's safe for concurrent use because the profile
+// may be written while the controller is tracing.
+type callGraph struct {
+	mu      sync.Mutex
+	samples map[string]*callChainSample
+	// keys keeps the order the call chains are first seen.
+	keys []string
+}
+
+// callChainSample is the statistics of the call chain.
+type callChainSample struct {
+	// functions is the call chain. The first function is the outermost one.
+	functions []*tracee.Function
+	numCalls  int64
+	duration  time.Duration
+}
+
+func newCallGraph() *callGraph {
+	return &callGraph{samples: make(map[string]*callChainSample)}
+}
+
+// AddCall counts up the call of the last function in the chain.
+func (g *callGraph) AddCall(functions []*tracee.Function) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.sample(functions).numCalls++
+}
+
+// AddDuration adds the time the last function in the chain took.
+func (g *callGraph) AddDuration(functions []*tracee.Function, duration time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.sample(functions).duration += duration
+}
+
+// Samples returns the copies of the samples in the order the call chains are first seen.
+func (g *callGraph) Samples() []*callChainSample {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var samples []*callChainSample
+	for _, key := range g.keys {
+		sample := *g.samples[key]
+		samples = append(samples, &sample)
+	}
+	return samples
+}
+
+// Reset removes all the samples.
+func (g *callGraph) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.samples = make(map[string]*callChainSample)
+	g.keys = nil
+}
+
+func (g *callGraph) sample(functions []*tracee.Function) *callChainSample {
+	var names []string
+	for _, function := range functions {
+		names = append(names, function.Name)
+	}
+	key := strings.Join(names, "\n")
+
+	sample, ok := g.samples[key]
+	if !ok {
+		sample = &callChainSample{functions: append([]*tracee.Function(nil), functions...)}
+		g.samples[key] = sample
+		g.keys = append(g.keys, key)
+	}
+	return sample
+}
@@ -0,0 +1,59 @@
+package tracer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ks888/tgo/tracee"
+)
+
+func TestCallGraph(t *testing.T) {
+	mainFunc := &tracee.Function{Name: "main.main"}
+	fooFunc := &tracee.Function{Name: "main.foo"}
+	graph := newCallGraph()
+
+	graph.AddCall([]*tracee.Function{mainFunc, fooFunc})
+	graph.AddDuration([]*tracee.Function{mainFunc, fooFunc}, time.Second)
+	graph.AddCall([]*tracee.Function{mainFunc, fooFunc})
+	graph.AddCall([]*tracee.Function{mainFunc})
+
+	samples := graph.Samples()
+	if len(samples) != 2 {
+		t.Fatalf("wrong number of samples: %d", len(samples))
+	}
+	if len(samples[0].functions) != 2 || samples[0].numCalls != 2 || samples[0].duration != time.Second {
+		t.Errorf("wrong sample: %#v", samples[0])
+	}
+	if len(samples[1].functions) != 1 || samples[1].numCalls != 1 {
+		t.Errorf("wrong sample: %#v", samples[1])
+	}
+}
+
+func TestCallGraph_Concurrent(t *testing.T) {
+	mainFunc := &tracee.Function{Name: "main.main"}
+	graph := newCallGraph()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			graph.AddCall([]*tracee.Function{mainFunc})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		for _, sample := range graph.Samples() {
+			_ = sample.numCalls
+		}
+	}
+	<-done
+
+	samples := graph.Samples()
+	if len(samples) != 1 || samples[0].numCalls != 100 {
+		t.Fatalf("wrong samples: %#v", samples)
+	}
+
+	graph.Reset()
+	if samples := graph.Samples(); len(samples) != 0 {
+		t.Errorf("not reset: %#v", samples)
+	}
+}
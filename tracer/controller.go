@@ -1,19 +1,50 @@
 package tracer
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/ks888/tgo/debugapi"
+	"github.com/ks888/tgo/log"
 	"github.com/ks888/tgo/tracee"
-	"golang.org/x/arch/x86/x86asm"
 )
 
 const chanBufferSize = 64
 
+// The output formats of the traced data.
+const (
+	// OutputFormatText prints each traced call in the human-readable text.
+	OutputFormatText = "text"
+	// OutputFormatJSON prints each traced call as one JSON object per line.
+	OutputFormatJSON = "json"
+	// OutputFormatChrome prints the traced calls as the JSON array of the Chrome Trace Event format,
+	// which chrome://tracing, Perfetto and Speedscope can load.
+	OutputFormatChrome = "chrome"
+)
+
+// TraceMode decides which of the function call and return is traced.
+type TraceMode int
+
+const (
+	// TraceModeBoth traces both the function call and return.
+	TraceModeBoth TraceMode = iota
+	// TraceModeInputOnly traces only the function call. The breakpoint at the return address is not set,
+	// which saves one trap per call.
+	TraceModeInputOnly
+	// TraceModeOutputOnly traces only the function return.
+	TraceModeOutputOnly
+)
+
 // ErrInterrupted indicates the tracer is interrupted due to the Interrupt() call.
 var ErrInterrupted = errors.New("interrupted")
 
@@ -30,17 +61,47 @@ const (
 
 // Controller controls the associated tracee process.
 type Controller struct {
-	process             *tracee.Process
+	process *tracee.Process
+	// debugapiClient is used to launch or attach the tracee. The new debugapi.Client is used if nil.
+	debugapiClient      debugapi.Debugger
 	firstModuleDataAddr uint64
 	statusStore         map[int64]goRoutineStatus
 	callInstAddrCache   map[uint64][]uint64
 
 	breakpointTypes map[uint64]breakpointType
 	breakpoints     Breakpoints
+	// spawnDepths holds the stack depths of the go statements executed inside the tracing scope, keyed by the pc of
+	// the go statement. The go routine created there is traced as if its function is called at that depth.
+	spawnDepths map[uint64][]int
+	// spawnedFuncs holds the number of the go routines which will start with the function and are not traced yet,
+	// keyed by the function's start address. The breakpoint is set at the address to detect the go routine's start.
+	spawnedFuncs map[uint64]int
 
 	tracingPoints tracingPoints
-	traceLevel    int
-	parseLevel    int
+	// traceAll is true if every traceable function is the start trace point.
+	traceAll            bool
+	traceLevel          int
+	traceMode           TraceMode
+	parseLevel          int
+	printSourceLocation bool
+	showLocals          bool
+	traceDefers         bool
+	firstCallOnly       bool
+	// testBinary is true if the tracee is the test binary built by `go test -c`.
+	testBinary bool
+	// seenFuncs holds the go routines which printed the function's return, keyed by the function name.
+	// Used in the first call only mode.
+	seenFuncs map[string]map[int64]bool
+	// dedupCalls is the number of the same consecutive calls printed before the rest are suppressed. 0 if not deduplicated.
+	dedupCalls   int
+	outputFormat string
+	// tracingFilter decides whether to print the traced call or return. Nil if not set.
+	tracingFilter func(funcName string, goRoutineID int64, depth int, args []tracee.Argument) bool
+	// sampleRate is the fraction of the function calls to be traced.
+	sampleRate float64
+	sampler    *rand.Rand
+	// signalForwarding holds whether the signal is delivered to the tracee. The signal not in this map is delivered.
+	signalForwarding map[syscall.Signal]bool
 
 	// Use the buffered channels to handle the requests to the controller asyncronously.
 	// It's because the tracee process must be trapped to handle these requests, but the process may not
@@ -48,13 +109,48 @@ type Controller struct {
 	interruptCh            chan bool
 	pendingStartTracePoint chan uint64
 	pendingEndTracePoint   chan uint64
+	pendingPause           chan bool
+	// keepAttached is true if the tracee is not detached when the main loop is interrupted.
+	keepAttached bool
+	// paused is true if the printing of the traced data is paused. The breakpoints are still handled.
+	paused bool
 	// The traced data is written to this writer.
 	outputWriter io.Writer
+	// eventHandler is called with each traced event. Nil if not set.
+	eventHandler func(Event)
+	// goRoutineOutputs holds the text output of each go routine until it's flushed. Used only when separateGoRoutineOutput is true.
+	goRoutineOutputs        map[int64]*goRoutineOutput
+	separateGoRoutineOutput bool
+	goRoutineOutputLimit    int
+	// chromeEventWritten is true if any event is written in the chrome format. Used to separate the events in the array.
+	chromeEventWritten bool
+	// callGraph holds the traced call chains. See WritePprofProfile.
+	callGraph *callGraph
+	// otel exports the traced function calls as the OpenTelemetry spans. See SetOTelExporter.
+	otel otelTracer
 }
 
 type goRoutineStatus struct {
 	// This list include only the functions which hit the breakpoint before and so is not complete.
 	callingFunctions []callingFunction
+	// baseDepth is the stack depth at which the go routine starts to be traced. Non-zero if the go routine
+	// is spawned inside the tracing scope.
+	baseDepth int
+	// lastCall is the last printed call. Used to deduplicate the consecutive calls.
+	lastCall repeatedCall
+	// unsampledStackSize is the used stack size at the call which is not sampled. Non-zero while the call is running,
+	// and the calls it makes (i.e. the calls whose used stack size is larger) are not traced either.
+	unsampledStackSize uint64
+}
+
+// repeatedCall is the call which may be repeated with the same args.
+type repeatedCall struct {
+	// key is the function name and its args.
+	key      string
+	funcName string
+	depth    int
+	// count is the number of the consecutive calls, including the printed ones.
+	count int
 }
 
 func (status goRoutineStatus) usedStackSize() uint64 {
@@ -77,38 +173,112 @@ type callingFunction struct {
 	returnAddress          uint64
 	usedStackSize          uint64
 	setCallInstBreakpoints bool
+	spanContext            spanContext
+	// calledAt is the time the call is traced. Zero if not traced or the pprof profile is disabled.
+	calledAt time.Time
+	// suppressed is true if the call is not printed because of the deduplication. The return is not printed either.
+	suppressed bool
+}
+
+// callChain returns the functions in the list, the outermost first.
+func callChain(callingFuncs []callingFunction) []*tracee.Function {
+	var functions []*tracee.Function
+	for _, callingFunc := range callingFuncs {
+		functions = append(functions, callingFunc.Function)
+	}
+	return functions
 }
 
 // NewController returns the new controller.
 func NewController() *Controller {
 	return &Controller{
 		outputWriter:           os.Stdout,
+		outputFormat:           OutputFormatText,
+		sampleRate:             1,
+		signalForwarding:       make(map[syscall.Signal]bool),
 		statusStore:            make(map[int64]goRoutineStatus),
 		breakpointTypes:        make(map[uint64]breakpointType),
+		spawnDepths:            make(map[uint64][]int),
+		spawnedFuncs:           make(map[uint64]int),
+		seenFuncs:              make(map[string]map[int64]bool),
+		goRoutineOutputs:       make(map[int64]*goRoutineOutput),
+		goRoutineOutputLimit:   defaultGoRoutineOutputLimit,
 		callInstAddrCache:      make(map[uint64][]uint64),
+		callGraph:              newCallGraph(),
 		interruptCh:            make(chan bool, chanBufferSize),
 		pendingStartTracePoint: make(chan uint64, chanBufferSize),
 		pendingEndTracePoint:   make(chan uint64, chanBufferSize),
+		pendingPause:           make(chan bool, chanBufferSize),
 	}
 }
 
+// NewControllerWithClient returns the new controller which controls the tracee using the specified debug api client.
+// It allows the controller to use the client other than debugapi.Client, such as the mock client.
+func NewControllerWithClient(client debugapi.Debugger) *Controller {
+	c := NewController()
+	c.debugapiClient = client
+	return c
+}
+
 // Attributes represents the tracee's attributes.
 type Attributes tracee.Attributes
 
 // LaunchTracee launches the new tracee process to be controlled.
 func (c *Controller) LaunchTracee(name string, arg []string, attrs Attributes) error {
 	var err error
-	c.process, err = tracee.LaunchProcess(name, arg, tracee.Attributes(attrs))
+	c.process, err = tracee.LaunchProcessWithClient(c.newDebugapiClient(), name, arg, tracee.Attributes(attrs))
+	if err != nil {
+		return err
+	}
 	c.breakpoints = NewBreakpoints(c.process.SetBreakpoint, c.process.ClearBreakpoint)
-	return err
+	c.applySignalForwarding()
+	return nil
 }
 
 // AttachTracee attaches to the existing process.
 func (c *Controller) AttachTracee(pid int, attrs Attributes) error {
 	var err error
-	c.process, err = tracee.AttachProcess(pid, tracee.Attributes(attrs))
+	c.process, err = tracee.AttachProcessWithClient(c.newDebugapiClient(), pid, tracee.Attributes(attrs))
+	if err != nil {
+		return err
+	}
 	c.breakpoints = NewBreakpoints(c.process.SetBreakpoint, c.process.ClearBreakpoint)
-	return err
+	c.applySignalForwarding()
+	return nil
+}
+
+func (c *Controller) newDebugapiClient() debugapi.Debugger {
+	if c.debugapiClient != nil {
+		return c.debugapiClient
+	}
+	return debugapi.NewClient()
+}
+
+// ForwardSignal lets the signal the tracee received be delivered to the tracee. All the signals are forwarded by default.
+// It must be called before the tracee is launched or attached.
+func (c *Controller) ForwardSignal(sig os.Signal) {
+	c.setSignalForwarding(sig, true)
+}
+
+// SuppressSignal prevents the signal the tracee received from being delivered to the tracee.
+// It must be called before the tracee is launched or attached.
+func (c *Controller) SuppressSignal(sig os.Signal) {
+	c.setSignalForwarding(sig, false)
+}
+
+func (c *Controller) setSignalForwarding(sig os.Signal, forward bool) {
+	syscallSig, ok := sig.(syscall.Signal)
+	if !ok {
+		log.Printf("unsupported signal: %v", sig)
+		return
+	}
+	c.signalForwarding[syscallSig] = forward
+}
+
+func (c *Controller) applySignalForwarding() {
+	for sig, forward := range c.signalForwarding {
+		c.process.SetSignalForwarding(sig, forward)
+	}
 }
 
 // AddStartTracePoint adds the starting point of the tracing. The go routines which executed one of these addresses start to be traced.
@@ -122,6 +292,52 @@ func (c *Controller) AddStartTracePoint(startAddr uint64) error {
 	return nil
 }
 
+// AddStartTracePointByName adds the starting point of the tracing using the function name.
+// In the test binary mode, the name without the package path (e.g. TestMyFunction) is accepted as well.
+func (c *Controller) AddStartTracePointByName(funcName string) error {
+	f, err := c.process.Binary.FindFunctionByName(funcName)
+	if err != nil && c.testBinary {
+		f, err = c.findTestFunction(funcName)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find the function %s: %w", funcName, err)
+	}
+	return c.AddStartTracePoint(f.StartAddr)
+}
+
+// findTestFunction finds the function whose name is the `funcName` prefixed by the package path.
+func (c *Controller) findTestFunction(funcName string) (*tracee.Function, error) {
+	var candidates []*tracee.Function
+	err := c.process.Binary.IterateFunctions(func(f *tracee.Function) bool {
+		if matchTestFunction(f.Name, funcName) {
+			candidates = append(candidates, f)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(candidates) {
+	case 0:
+		return nil, errors.New("function not found")
+	case 1:
+		return candidates[0], nil
+	default:
+		return nil, fmt.Errorf("ambiguous function name: found in %s and %s", candidates[0].Name, candidates[1].Name)
+	}
+}
+
+// matchTestFunction returns true if the `fullName` is the `funcName` prefixed by the package path,
+// like github.com/org/pkg.TestMyFunction. The methods are not matched.
+func matchTestFunction(fullName, funcName string) bool {
+	if !strings.HasSuffix(fullName, "."+funcName) {
+		return false
+	}
+	pkgPath := strings.TrimSuffix(fullName, "."+funcName)
+	return pkgPath != "" && !strings.ContainsAny(pkgPath, "()*")
+}
+
 // AddEndTracePoint adds the ending point of the tracing. The tracing is disabled when any go routine executes any of these addresses.
 func (c *Controller) AddEndTracePoint(endAddr uint64) error {
 	select {
@@ -136,26 +352,155 @@ func (c *Controller) AddEndTracePoint(endAddr uint64) error {
 // SetTraceLevel set the tracing level, which determines whether to print the traced info of the functions.
 // The traced info is printed if the function is (directly or indirectly) called by the trace point function AND
 // the stack depth is within the `level`.
-// The depth here is the relative value from the point the tracing starts.
+// The depth here is the relative value from the point the tracing starts. If the tracing point function is called
+// recursively, the depth is relative to the innermost call.
+// The breakpoints are set lazily: when the go routine enters the function within the level, the breakpoints are set
+// at its call instructions only, and cleared when it returns.
 func (c *Controller) SetTraceLevel(level int) {
 	c.traceLevel = level
 }
 
+// SetTracingFilter sets the predicate to decide whether to print the traced call or return.
+// The args are the input args for the call and the output args for the return. If the predicate returns false,
+// the event is not printed, though the function is traced as usual (e.g. its callees may be printed).
+// Note the predicate is called while the tracee is stopped, so it must return quickly.
+func (c *Controller) SetTracingFilter(fn func(funcName string, goRoutineID int64, depth int, args []tracee.Argument) bool) {
+	c.tracingFilter = fn
+}
+
+// SetTraceAll sets whether to trace every go routine from its entry. If true, every traceable function (i.e. not in
+// the runtime and internal packages) becomes the start trace point when the main loop starts, so the tracing starts
+// without waiting for the specific function to be called. It's useful for the short-lived programs.
+// The first traceable function the go routine calls (e.g. the init function, main.main or the function the go statement
+// spawns) is the entry and the trace level is applied to the depth relative to it. The go routine leaves the tracing
+// point when the entry function returns.
+func (c *Controller) SetTraceAll(b bool) {
+	c.traceAll = b
+}
+
+// SetTraceMode sets which of the function call and return is traced. The default is TraceModeBoth.
+func (c *Controller) SetTraceMode(mode TraceMode) {
+	c.traceMode = mode
+}
+
+// SetFirstCallOnly sets the flag to trace only the first call of each function per go routine.
+// Once the go routine printed the function's return, the later calls of the function by the go routine are not printed.
+// The breakpoints are kept as they are, because the calls made by these calls may be the first calls.
+func (c *Controller) SetFirstCallOnly(b bool) {
+	c.firstCallOnly = b
+}
+
+// SetDeduplicateCalls suppresses the same consecutive calls after `n` calls are printed. The call is regarded as the same
+// if the function and the args are same. The number of the suppressed calls is printed when another call happens.
+// 0 disables the deduplication, which is the default.
+func (c *Controller) SetDeduplicateCalls(n int) {
+	c.dedupCalls = n
+}
+
+// SetTraceDefers sets the flag to print the function and its args registered by the defer statement, like 'defer (#01) main.cleanup(fd=3)'.
+// Note that the defer statements which the compiler open-codes don't call runtime.deferproc and so are not printed.
+func (c *Controller) SetTraceDefers(b bool) {
+	c.traceDefers = b
+}
+
 // SetParseLevel sets the parsing level, which determines how deeply the parser parses the value of args.
 func (c *Controller) SetParseLevel(level int) {
 	c.parseLevel = level
 }
 
+// SetEventHandler sets the handler called with each traced event, regardless of the output format.
+// The handler is called in the main loop, so the tracee is stopped until it returns.
+func (c *Controller) SetEventHandler(handler func(Event)) {
+	c.eventHandler = handler
+}
+
+// SetOutputWriter sets the writer for the traced data. The default is os.Stdout.
+func (c *Controller) SetOutputWriter(w io.Writer) {
+	c.outputWriter = w
+}
+
+// SetSeparateGoroutineOutput sets whether to print the traced data of each go routine in one contiguous block.
+// The text output is buffered per go routine and written when the go routine exits the tracing scope, the buffer
+// exceeds the limit (see SetGoroutineOutputLimit) or the tracing ends. Other output formats are not affected.
+func (c *Controller) SetSeparateGoroutineOutput(b bool) {
+	c.separateGoRoutineOutput = b
+}
+
+// SetGoroutineOutputLimit sets the size of the per go routine buffer in bytes. The default is 64KiB.
+func (c *Controller) SetGoroutineOutputLimit(size int) {
+	c.goRoutineOutputLimit = size
+}
+
+// SetOutputFormat sets the format of the traced data. The format is OutputFormatText (default), OutputFormatJSON or OutputFormatChrome.
+func (c *Controller) SetOutputFormat(format string) error {
+	switch format {
+	case OutputFormatText, OutputFormatJSON, OutputFormatChrome:
+		c.outputFormat = format
+		return nil
+	default:
+		return fmt.Errorf("unknown output format: %s", format)
+	}
+}
+
+// SetSampleRate sets the fraction of the function calls to be traced, from 0.0 to 1.0. The default is 1.0.
+// The sampling decision is made per call. The return of the call is not traced if the call is not sampled,
+// and neither are the functions it calls.
+func (c *Controller) SetSampleRate(rate float64) {
+	c.sampleRate = rate
+	c.sampler = rand.New(rand.NewSource(time.Now().UnixNano()))
+}
+
+func (c *Controller) sampled() bool {
+	if c.sampleRate >= 1 || c.sampler == nil {
+		return true
+	}
+	return c.sampler.Float64() < c.sampleRate
+}
+
+// SetPrintSourceLocation sets whether to print the source location (e.g. [main.go:10]) of the traced functions.
+func (c *Controller) SetPrintSourceLocation(b bool) {
+	c.printSourceLocation = b
+}
+
+// SetTestBinary sets whether the tracee is the test binary built by `go test -c`. If true, the function name
+// without the package path, such as TestMyFunction, can be used to add the trace point.
+// Use the args of the tracee (e.g. -test.run) to choose the tests to run.
+func (c *Controller) SetTestBinary(b bool) {
+	c.testBinary = b
+}
+
+// SetShowLocals sets whether to print the local variables of the caller in scope at the call of the traced functions.
+// The locals of the traced function itself are not printed because they are not initialized yet at its entry and
+// its stack frame is gone at its exit.
+func (c *Controller) SetShowLocals(b bool) {
+	c.showLocals = b
+}
+
 // MainLoop repeatedly lets the tracee continue and then wait an event. It returns ErrInterrupted error if
 // the trace ends due to the interrupt.
-func (c *Controller) MainLoop() error {
-	defer c.process.Detach() // the connection status is unknown at this point
+func (c *Controller) MainLoop() (err error) {
+	defer func() {
+		if c.keepAttached && err == ErrInterrupted {
+			return
+		}
+		c.process.Detach() // the connection status is unknown at this point
+	}()
+	defer c.flushSpans()
+	defer c.closeChromeEvents()
+	defer c.flushAllGoRoutineOutputs()
+	defer c.flushAllRepeatedCalls()
+
+	if c.traceAll {
+		if err := c.setAllStartTracePoints(); err != nil {
+			return fmt.Errorf("failed to set trace points: %w", err)
+		}
+	}
 
 	event, err := c.continueAndWait()
 	if err == ErrInterrupted {
 		return err
 	} else if err != nil {
-		return fmt.Errorf("failed to trace: %v", err)
+		return traceError(err)
 	}
 
 	for {
@@ -172,7 +517,7 @@ func (c *Controller) MainLoop() error {
 			if err == ErrInterrupted {
 				return err
 			} else if err != nil {
-				return fmt.Errorf("failed to trace: %v", err)
+				return traceError(err)
 			}
 		default:
 			return fmt.Errorf("unknown event: %v", event.Type)
@@ -180,6 +525,120 @@ func (c *Controller) MainLoop() error {
 	}
 }
 
+// traceError wraps the error MainLoop returns. The hint is added if the process memory can't be read,
+// because it typically happens when the process exits while it's traced.
+func traceError(err error) error {
+	var memoryReadErr debugapi.MemoryReadError
+	if errors.As(err, &memoryReadErr) {
+		return fmt.Errorf("failed to trace: %w: the process may have exited", err)
+	}
+	return fmt.Errorf("failed to trace: %w", err)
+}
+
+// setAllStartTracePoints sets the start trace points at all the traceable functions.
+func (c *Controller) setAllStartTracePoints() error {
+	var traceableFuncs []*tracee.Function
+	err := c.process.Binary.IterateFunctions(func(f *tracee.Function) bool {
+		if traceableFunc(f) && !c.trivialFunc(f) {
+			traceableFuncs = append(traceableFuncs, f)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	// the start of the function is executed again after the stack growth. Skip the stack growth check.
+	startAddrs, err := c.process.FunctionBodyStarts(traceableFuncs)
+	if err != nil {
+		return err
+	}
+
+	// set the breakpoints at once first, because there can be many functions. Breakpoints.Set is cheap after that.
+	if err := c.process.SetBreakpoints(startAddrs); err != nil {
+		return err
+	}
+
+	for _, startAddr := range startAddrs {
+		if c.tracingPoints.IsStartAddress(startAddr) {
+			continue
+		}
+
+		if err := c.breakpoints.Set(startAddr); err != nil {
+			return err
+		}
+		c.tracingPoints.startAddressList = append(c.tracingPoints.startAddressList, startAddr)
+	}
+	return nil
+}
+
+// untraceableFuncs is the list of the functions the breakpoint should not be set at, in addition to the runtime functions.
+// They are the low-level primitives called very often, including while the go routine holds the lock.
+var untraceableFuncs = []string{
+	"sync/atomic.",
+	"sync.(*Mutex).",
+	"sync.(*RWMutex).",
+	"sync.(*Once).",
+	"sync.runtime_",
+	"sync.throw",
+	"sync.fatal",
+	"reflect.memmove",
+	"reflect.typedmemmove",
+	"syscall.Syscall",
+	"syscall.RawSyscall",
+	"syscall.rawVforkSyscall",
+}
+
+// IsTraceable returns true if the function can be the trace point. The functions the tracer skips even in
+// the trace all mode, such as the runtime functions, are not traceable.
+func IsTraceable(f *tracee.Function) bool {
+	return traceableFunc(f)
+}
+
+// traceableFunc returns true if the breakpoint can be set at the beginning of the function safely.
+// The runtime and internal functions may be called while the go routine is not ready.
+// The functions generated by the compiler (e.g. type..eq.main.T) are not interesting.
+// The function without the package name (e.g. gosave_systemstack_switch) is the assembly function of the runtime.
+func traceableFunc(f *tracee.Function) bool {
+	if !strings.Contains(f.Name, ".") {
+		return false
+	}
+	for _, prefix := range []string{"runtime.", "runtime/", "internal/", "type.", "type:", "go.", "go:"} {
+		if strings.HasPrefix(f.Name, prefix) {
+			return false
+		}
+	}
+	for _, prefix := range untraceableFuncs {
+		if strings.HasPrefix(f.Name, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+const (
+	// minTraceableInsts is the minimum number of the instructions the traceable function has.
+	minTraceableInsts = 3
+	// maxInstLen is the maximum length of one instruction among the supported architectures.
+	maxInstLen = 15
+)
+
+// trivialFunc returns true if the function is too small to set the breakpoint safely (e.g. the trivial getter).
+// The instructions are read only when the function is small enough, because reading all the functions is slow.
+func (c *Controller) trivialFunc(f *tracee.Function) bool {
+	size := f.Size()
+	if size == 0 || size >= minTraceableInsts*maxInstLen {
+		return false
+	}
+
+	insts, err := c.process.ReadInstructions(f)
+	if err != nil {
+		log.Debugf("failed to read instructions of %s: %v", f.Name, err)
+		return false
+	}
+	return len(insts) < minTraceableInsts
+}
+
 // continueAndWait resumes the traced process and waits the process trapped again.
 // It handles requests via channels before resuming.
 func (c *Controller) continueAndWait() (debugapi.Event, error) {
@@ -190,6 +649,7 @@ func (c *Controller) continueAndWait() (debugapi.Event, error) {
 		if err := c.setPendingTracePoints(); err != nil {
 			return debugapi.Event{}, err
 		}
+		c.applyPendingPause()
 
 		return c.process.ContinueAndWait()
 	}
@@ -228,7 +688,7 @@ func (c *Controller) handleTrapEvent(trappedThreadIDs []int) (debugapi.Event, er
 	for i := 0; i < len(trappedThreadIDs); i++ {
 		threadID := trappedThreadIDs[i]
 		if err := c.handleTrapEventOfThread(threadID); err != nil {
-			return debugapi.Event{}, fmt.Errorf("failed to handle trap event (thread id: %d): %v", threadID, err)
+			return debugapi.Event{}, fmt.Errorf("failed to handle trap event (thread id: %d): %w", threadID, err)
 		}
 	}
 
@@ -241,24 +701,30 @@ func (c *Controller) handleTrapEventOfThread(threadID int) error {
 		return c.handleTrappedSystemRoutine(threadID)
 	}
 
-	breakpointAddr := goRoutineInfo.CurrentPC - 1
+	breakpointAddr := c.breakpointAddr(goRoutineInfo.CurrentPC)
 	if !c.breakpoints.Hit(breakpointAddr, goRoutineInfo.ID) {
 		return c.handleTrapAtUnrelatedBreakpoint(threadID, breakpointAddr)
 	}
 
 	if !c.tracingPoints.Inside(goRoutineInfo.ID) {
-		if !c.tracingPoints.IsStartAddress(breakpointAddr) {
+		if depth, ok := c.popSpawnDepth(breakpointAddr, goRoutineInfo); ok {
+			if err := c.enterSpawnedGoRoutine(threadID, breakpointAddr, goRoutineInfo, depth); err != nil {
+				return err
+			}
+			if _, ok := c.breakpointTypes[breakpointAddr]; !ok && !c.tracingPoints.IsEndAddress(breakpointAddr) {
+				return c.handleTrapAtUnrelatedBreakpoint(threadID, breakpointAddr)
+			}
+		} else if !c.tracingPoints.IsStartAddress(breakpointAddr) {
 			return c.handleTrapAtUnrelatedBreakpoint(threadID, breakpointAddr)
-		}
-		if err := c.enterTracepoint(threadID, goRoutineInfo); err != nil {
+		} else if err := c.enterTracepoint(threadID, goRoutineInfo); err != nil {
 			return err
 		}
 	}
 
 	if c.tracingPoints.IsEndAddress(breakpointAddr) {
-		return c.exitTracepoint(threadID, goRoutineInfo.ID, goRoutineInfo.CurrentPC-1)
-	} else if c.tracingPoints.IsStartAddress(breakpointAddr) {
-		// the tracing point may be used as the break point as well. If not, return here.
+		return c.exitTracepoint(threadID, goRoutineInfo.ID, c.breakpointAddr(goRoutineInfo.CurrentPC))
+	} else if c.tracingPoints.IsStartAddress(breakpointAddr) || c.spawnedFuncs[breakpointAddr] > 0 {
+		// the tracing point (or the start of the spawned go routine) may be used as the break point as well. If not, return here.
 		if _, ok := c.breakpointTypes[breakpointAddr]; !ok {
 			return c.handleTrapAtUnrelatedBreakpoint(threadID, breakpointAddr)
 		}
@@ -288,25 +754,123 @@ func (c *Controller) enterTracepoint(threadID int, goRoutineInfo tracee.GoRoutin
 			return err
 		}
 
-		c.tracingPoints.Enter(goRoutineID)
+		if c.traceAll {
+			if err := c.setEntryEndTracePoint(goRoutineInfo); err != nil {
+				return err
+			}
+		}
+
+		c.tracingPoints.Enter(goRoutineID, 0)
+		c.printGoRoutineCreator(goRoutineInfo)
 	}
 
 	// not single step here, because tracing point may be used as breakpoint as well.
 	return nil
 }
 
+// traceSpawnedGoRoutine prepares to trace the go routine the go statement is spawning. The function the go routine
+// starts with is regarded as called at the depth of runtime.newproc.
+func (c *Controller) traceSpawnedGoRoutine(threadID int, goStatementPC uint64, depth int) error {
+	f, err := c.process.SpawnedFunctionAt(threadID)
+	if err != nil {
+		log.Debugf("failed to find the spawned function: %v", err)
+		return nil
+	}
+
+	if !c.breakpoints.Exist(f.StartAddr) {
+		if err := c.breakpoints.Set(f.StartAddr); err != nil {
+			return err
+		}
+	}
+	c.spawnedFuncs[f.StartAddr]++
+	// the depth is the base depth of the go routine, so that the function the go routine starts with is at `depth`.
+	c.spawnDepths[goStatementPC] = append(c.spawnDepths[goStatementPC], depth-1)
+	return nil
+}
+
+// popSpawnDepth returns the depth at which the go routine is spawned, if the go routine is spawned inside the tracing scope
+// and is starting now.
+func (c *Controller) popSpawnDepth(breakpointAddr uint64, goRoutineInfo tracee.GoRoutineInfo) (int, bool) {
+	if c.spawnedFuncs[breakpointAddr] == 0 {
+		return 0, false
+	}
+
+	goStatementPC := c.process.GoStatementPC(goRoutineInfo)
+	depths := c.spawnDepths[goStatementPC]
+	if len(depths) == 0 {
+		return 0, false
+	}
+
+	if len(depths) == 1 {
+		delete(c.spawnDepths, goStatementPC)
+	} else {
+		c.spawnDepths[goStatementPC] = depths[1:]
+	}
+	return depths[0], true
+}
+
+// enterSpawnedGoRoutine starts tracing the go routine spawned inside the tracing scope.
+func (c *Controller) enterSpawnedGoRoutine(threadID int, breakpointAddr uint64, goRoutineInfo tracee.GoRoutineInfo, depth int) error {
+	c.spawnedFuncs[breakpointAddr]--
+	if c.spawnedFuncs[breakpointAddr] == 0 {
+		delete(c.spawnedFuncs, breakpointAddr)
+		if _, ok := c.breakpointTypes[breakpointAddr]; !ok && !c.tracingPoints.IsStartAddress(breakpointAddr) && !c.tracingPoints.IsEndAddress(breakpointAddr) {
+			if err := c.breakpoints.Clear(breakpointAddr); err != nil {
+				return err
+			}
+		}
+	}
+
+	c.statusStore[goRoutineInfo.ID] = goRoutineStatus{baseDepth: depth}
+	return c.enterTracepoint(threadID, goRoutineInfo)
+}
+
+// setEntryEndTracePoint sets the end trace point at the return address of the function the go routine entered the tracing
+// point with. Used in the trace all mode, so that the go routine leaves the tracing point when the function returns and the
+// next function the runtime calls (e.g. the next init function or main.main) becomes the new entry.
+func (c *Controller) setEntryEndTracePoint(goRoutineInfo tracee.GoRoutineInfo) error {
+	returnAddr, err := c.process.ReturnAddress(goRoutineInfo.CurrentStackAddr, c.breakpointAddr(goRoutineInfo.CurrentPC))
+	if err != nil {
+		return err
+	}
+	if c.tracingPoints.IsEndAddress(returnAddr) {
+		return nil
+	}
+
+	if err := c.breakpoints.Set(returnAddr); err != nil {
+		return err
+	}
+	c.tracingPoints.endAddressList = append(c.tracingPoints.endAddressList, returnAddr)
+	return nil
+}
+
 func (c *Controller) exitTracepoint(threadID int, goRoutineID int64, breakpointAddr uint64) error {
 	if c.tracingPoints.Inside(goRoutineID) {
 		if err := c.breakpoints.ClearAllByGoRoutineID(goRoutineID); err != nil {
 			return err
 		}
 
-		c.tracingPoints.Exit(goRoutineID)
+		c.tracingPoints.Exit(goRoutineID, 0)
+		c.flushGoRoutineOutput(goRoutineID)
+		c.endOpenSpans(goRoutineID)
 	}
 
 	return c.handleTrapAtUnrelatedBreakpoint(threadID, breakpointAddr)
 }
 
+// endOpenSpans ends the spans of the functions the go routine is still calling, because their returns are not traced anymore.
+func (c *Controller) endOpenSpans(goRoutineID int64) {
+	status, ok := c.statusStore[goRoutineID]
+	if !ok {
+		return
+	}
+
+	for i := len(status.callingFunctions) - 1; i >= 0; i-- {
+		c.endSpan(status.callingFunctions[i].spanContext)
+		status.callingFunctions[i].spanContext = spanContext{}
+	}
+}
+
 func (c *Controller) setCallInstBreakpoints(goRoutineID int64, pc uint64) error {
 	return c.alterCallInstBreakpoints(true, goRoutineID, pc)
 }
@@ -360,16 +924,21 @@ func (c *Controller) handleTrappedSystemRoutine(threadID int) error {
 		return err
 	}
 
-	breakpointAddr := threadInfo.CurrentPC - 1
+	breakpointAddr := c.breakpointAddr(threadInfo.CurrentPC)
 	return c.process.SingleStep(threadID, breakpointAddr)
 }
 
+// breakpointAddr returns the address of the breakpoint the thread trapped at. The `pc` is the one when trapped.
+func (c *Controller) breakpointAddr(pc uint64) uint64 {
+	return c.process.Arch().BreakpointAddr(pc)
+}
+
 func (c *Controller) handleTrapAtUnrelatedBreakpoint(threadID int, breakpointAddr uint64) error {
 	return c.process.SingleStep(threadID, breakpointAddr)
 }
 
 func (c *Controller) handleTrapBeforeFunctionCall(threadID int, goRoutineInfo tracee.GoRoutineInfo) error {
-	breakpointAddr := goRoutineInfo.CurrentPC - 1
+	breakpointAddr := c.breakpointAddr(goRoutineInfo.CurrentPC)
 
 	var err error
 	if c.breakpointTypes[breakpointAddr] == breakpointTypeReturnAndCall {
@@ -400,7 +969,16 @@ func (c *Controller) handleTrapBeforeFunctionCall(threadID int, goRoutineInfo tr
 // the breakpoint address is not explicit in that case.
 func (c *Controller) handleTrapAtFunctionCall(threadID int, breakpointAddr uint64, goRoutineInfo tracee.GoRoutineInfo) error {
 	status, _ := c.statusStore[goRoutineInfo.ID]
-	stackFrame, err := c.currentStackFrame(goRoutineInfo)
+	if status.unsampledStackSize != 0 {
+		if goRoutineInfo.UsedStackSize > status.unsampledStackSize {
+			// called by the call which is not sampled. The breakpoint is hit because the caller is called recursively
+			// or the callee is the tracing point.
+			return c.process.SingleStep(threadID, breakpointAddr)
+		}
+		status.unsampledStackSize = 0
+	}
+
+	stackFrame, err := c.currentStackFrame(threadID, goRoutineInfo)
 	if err != nil {
 		return err
 	}
@@ -408,21 +986,42 @@ func (c *Controller) handleTrapAtFunctionCall(threadID int, breakpointAddr uint6
 	// unwinded here in some cases:
 	// * just recovered from panic.
 	// * the last function used 'JMP' to call the next function and didn't change the SP. e.g. runtime.deferreturn
+	//   and the wrapper functions (see StackFrame.IsTailCall). So the depth is not incremented for the tail call.
 	remainingFuncs, _, err := c.unwindFunctions(status.callingFunctions, goRoutineInfo)
 	if err != nil {
 		return err
 	}
 
-	currStackDepth := len(remainingFuncs) + 1 // add the currently calling function
+	currStackDepth := status.baseDepth + len(remainingFuncs) + 1 // add the currently calling function
 	if goRoutineInfo.Panicking && goRoutineInfo.PanicHandler != nil {
 		currStackDepth -= c.countSkippedFuncs(status.callingFunctions, goRoutineInfo.PanicHandler.UsedStackSizeAtDefer)
 	}
 
+	// the trace level is applied to the depth relative to the innermost call of the tracing point function.
+	// The recursive call of the tracing point function is the new entry, except in the trace all mode, where every function
+	// is the tracing point and only the go routine's entry counts.
+	c.tracingPoints.Exit(goRoutineInfo.ID, currStackDepth) // the calls at this depth or deeper returned already.
+	levelDepth := c.tracingPoints.Depth(goRoutineInfo.ID, currStackDepth)
+	if !c.traceAll && c.tracingPoints.Inside(goRoutineInfo.ID) && c.tracingPoints.IsStartAddress(stackFrame.Function.StartAddr) {
+		c.tracingPoints.Enter(goRoutineInfo.ID, currStackDepth)
+	}
+
+	if !c.sampled() {
+		// The return breakpoint is not set and so this call is not traced at all.
+		if err := c.process.SingleStep(threadID, breakpointAddr); err != nil {
+			return err
+		}
+		status.callingFunctions = remainingFuncs
+		status.unsampledStackSize = goRoutineInfo.UsedStackSize
+		c.statusStore[goRoutineInfo.ID] = status
+		return nil
+	}
+
 	callingFunc := callingFunction{
 		Function:               stackFrame.Function,
 		returnAddress:          stackFrame.ReturnAddress,
 		usedStackSize:          goRoutineInfo.UsedStackSize,
-		setCallInstBreakpoints: currStackDepth < c.traceLevel,
+		setCallInstBreakpoints: c.tracingPoints.Depth(goRoutineInfo.ID, currStackDepth) < c.traceLevel,
 	}
 	remainingFuncs, err = c.appendFunction(remainingFuncs, callingFunc, goRoutineInfo.ID)
 	if err != nil {
@@ -433,17 +1032,44 @@ func (c *Controller) handleTrapAtFunctionCall(threadID int, breakpointAddr uint6
 		return err
 	}
 
-	if currStackDepth <= c.traceLevel && c.printableFunc(stackFrame.Function) {
-		if err := c.printFunctionInput(goRoutineInfo.ID, stackFrame, currStackDepth); err != nil {
+	if stackFrame.Function.Name == "runtime.newproc" && levelDepth < c.traceLevel {
+		if err := c.traceSpawnedGoRoutine(threadID, stackFrame.ReturnAddress, levelDepth); err != nil {
 			return err
 		}
 	}
 
+	if c.traceDefers && isDeferProc(stackFrame.Function) {
+		c.printDeferredCall(threadID, goRoutineInfo.ID, currStackDepth-1)
+	}
+
+	if c.canPrint(stackFrame.Function, levelDepth) && c.filter(goRoutineInfo.ID, stackFrame.Function, currStackDepth, stackFrame.InputArguments) {
+		remainingFuncs[len(remainingFuncs)-1].spanContext = c.startSpan(remainingFuncs[:len(remainingFuncs)-1], goRoutineInfo.ID, stackFrame)
+		if pprofEnabled {
+			remainingFuncs[len(remainingFuncs)-1].calledAt = time.Now()
+			c.callGraph.AddCall(callChain(remainingFuncs))
+		}
+		if c.firstCallOnly && c.seenFuncs[stackFrame.Function.Name][goRoutineInfo.ID] {
+			// the first call is printed already. The call is still traced to keep the depth of its callees right.
+			remainingFuncs[len(remainingFuncs)-1].suppressed = true
+		} else if c.traceMode != TraceModeOutputOnly {
+			if c.dedupCall(&status, goRoutineInfo.ID, stackFrame, currStackDepth) {
+				remainingFuncs[len(remainingFuncs)-1].suppressed = true
+			} else if err := c.printFunctionInput(goRoutineInfo, stackFrame, currStackDepth); err != nil {
+				return err
+			}
+			if c.traceMode == TraceModeInputOnly {
+				// the return is never printed.
+				c.markSeen(stackFrame.Function, goRoutineInfo.ID)
+			}
+		}
+	}
+
 	if err := c.process.SingleStep(threadID, breakpointAddr); err != nil {
 		return err
 	}
 
-	c.statusStore[goRoutineInfo.ID] = goRoutineStatus{callingFunctions: remainingFuncs}
+	status.callingFunctions = remainingFuncs
+	c.statusStore[goRoutineInfo.ID] = status
 	return nil
 }
 
@@ -456,6 +1082,10 @@ func (c *Controller) countSkippedFuncs(callingFuncs []callingFunction, usedStack
 	return len(callingFuncs) - 1
 }
 
+// unwindFunctions removes the functions which already returned from the list and returns the remaining and removed ones.
+// The function is regarded as returned if its used stack size is larger than the current one. The return address is
+// not used here, because the recursive calls share the same return address. The used stack size is not changed by
+// the stack growth, which copies the stack.
 func (c *Controller) unwindFunctions(callingFuncs []callingFunction, goRoutineInfo tracee.GoRoutineInfo) ([]callingFunction, []callingFunction, error) {
 	for i := len(callingFuncs) - 1; i >= 0; i-- {
 		if callingFuncs[i].usedStackSize < goRoutineInfo.UsedStackSize {
@@ -473,6 +1103,7 @@ func (c *Controller) unwindFunctions(callingFuncs []callingFunction, goRoutineIn
 		}
 
 		unwindFunc := callingFuncs[i]
+		c.endSpan(unwindFunc.spanContext)
 		if err := c.breakpoints.ClearConditional(unwindFunc.returnAddress, goRoutineInfo.ID); err != nil {
 			return nil, nil, err
 		}
@@ -487,13 +1118,16 @@ func (c *Controller) unwindFunctions(callingFuncs []callingFunction, goRoutineIn
 }
 
 func (c *Controller) appendFunction(callingFuncs []callingFunction, newFunc callingFunction, goRoutineID int64) ([]callingFunction, error) {
-	if err := c.breakpoints.SetConditional(newFunc.returnAddress, goRoutineID); err != nil {
-		return nil, err
-	}
-	if typ, ok := c.breakpointTypes[newFunc.returnAddress]; ok && typ == breakpointTypeCall {
-		c.breakpointTypes[newFunc.returnAddress] = breakpointTypeReturnAndCall
-	} else {
-		c.breakpointTypes[newFunc.returnAddress] = breakpointTypeReturn
+	// In the input only mode, the function is unwinded when the go routine calls the next function.
+	if c.traceMode != TraceModeInputOnly {
+		if err := c.breakpoints.SetConditional(newFunc.returnAddress, goRoutineID); err != nil {
+			return nil, err
+		}
+		if typ, ok := c.breakpointTypes[newFunc.returnAddress]; ok && typ == breakpointTypeCall {
+			c.breakpointTypes[newFunc.returnAddress] = breakpointTypeReturnAndCall
+		} else {
+			c.breakpointTypes[newFunc.returnAddress] = breakpointTypeReturn
+		}
 	}
 
 	if newFunc.setCallInstBreakpoints {
@@ -506,11 +1140,11 @@ func (c *Controller) appendFunction(callingFuncs []callingFunction, newFunc call
 }
 
 func (c *Controller) handleTrapAtDeferredFuncCall(threadID int, goRoutineInfo tracee.GoRoutineInfo) error {
-	if err := c.handleTrapAtFunctionCall(threadID, goRoutineInfo.CurrentPC-1, goRoutineInfo); err != nil {
+	if err := c.handleTrapAtFunctionCall(threadID, c.breakpointAddr(goRoutineInfo.CurrentPC), goRoutineInfo); err != nil {
 		return err
 	}
 
-	return c.breakpoints.ClearConditional(goRoutineInfo.CurrentPC-1, goRoutineInfo.ID)
+	return c.breakpoints.ClearConditional(c.breakpointAddr(goRoutineInfo.CurrentPC), goRoutineInfo.ID)
 }
 
 func (c *Controller) handleTrapAfterFunctionReturn(threadID int, goRoutineInfo tracee.GoRoutineInfo) error {
@@ -520,39 +1154,78 @@ func (c *Controller) handleTrapAfterFunctionReturn(threadID int, goRoutineInfo t
 	if err != nil {
 		return err
 	}
+	if len(unwindedFuncs) == 0 {
+		// The stack is deeper than the last traced function. The untraced function (e.g. the deeper recursive call)
+		// returns to the same address. Note that the stack growth copies the stack, but the return addresses and the
+		// used stack sizes are not changed, so the breakpoints are still valid.
+		return c.handleTrapAtUnrelatedBreakpoint(threadID, c.breakpointAddr(goRoutineInfo.CurrentPC))
+	}
 	returnedFunc := unwindedFuncs[0].Function
 
-	currStackDepth := len(remainingFuncs) + 1 // include returnedFunc for now
+	currStackDepth := status.baseDepth + len(remainingFuncs) + 1 // include returnedFunc for now
 	if goRoutineInfo.Panicking && goRoutineInfo.PanicHandler != nil {
 		currStackDepth -= c.countSkippedFuncs(remainingFuncs, goRoutineInfo.PanicHandler.UsedStackSizeAtDefer)
 	}
 
-	if currStackDepth <= c.traceLevel && c.printableFunc(returnedFunc) {
-		prevStackFrame, err := c.prevStackFrame(goRoutineInfo, returnedFunc.StartAddr)
+	// if the returned function is the recursive call of the tracing point function, its entry is removed here.
+	c.tracingPoints.Exit(goRoutineInfo.ID, currStackDepth)
+	if c.canPrint(returnedFunc, c.tracingPoints.Depth(goRoutineInfo.ID, currStackDepth)) {
+		prevStackFrame, err := c.prevStackFrame(threadID, goRoutineInfo, returnedFunc.StartAddr)
 		if err != nil {
 			return err
 		}
-		if err := c.printFunctionOutput(goRoutineInfo.ID, prevStackFrame, currStackDepth); err != nil {
-			return err
+		if c.filter(goRoutineInfo.ID, returnedFunc, currStackDepth, prevStackFrame.OutputArguments) {
+			if calledAt := unwindedFuncs[0].calledAt; !calledAt.IsZero() {
+				c.callGraph.AddDuration(append(callChain(remainingFuncs), returnedFunc), time.Since(calledAt))
+			}
+			if !unwindedFuncs[0].suppressed {
+				if returnedFunc.Name != status.lastCall.funcName || currStackDepth != status.lastCall.depth {
+					c.flushRepeatedCalls(&status, goRoutineInfo.ID)
+				}
+				if err := c.printFunctionOutput(goRoutineInfo, prevStackFrame, currStackDepth); err != nil {
+					return err
+				}
+				c.markSeen(returnedFunc, goRoutineInfo.ID)
+			}
 		}
 	}
 
-	if err := c.process.SingleStep(threadID, goRoutineInfo.CurrentPC-1); err != nil {
+	if err := c.process.SingleStep(threadID, c.breakpointAddr(goRoutineInfo.CurrentPC)); err != nil {
 		return err
 	}
 
-	c.statusStore[goRoutineInfo.ID] = goRoutineStatus{callingFunctions: remainingFuncs}
+	status.callingFunctions = remainingFuncs
+	c.statusStore[goRoutineInfo.ID] = status
 	return nil
 }
 
 // It must be called at the beginning of the function due to the StackFrameAt's constraint.
-func (c *Controller) currentStackFrame(goRoutineInfo tracee.GoRoutineInfo) (*tracee.StackFrame, error) {
-	return c.process.StackFrameAt(goRoutineInfo.CurrentStackAddr, goRoutineInfo.CurrentPC)
+func (c *Controller) currentStackFrame(threadID int, goRoutineInfo tracee.GoRoutineInfo) (*tracee.StackFrame, error) {
+	if goRoutineInfo.Registers == nil {
+		return c.process.StackFrameAt(goRoutineInfo.CurrentStackAddr, goRoutineInfo.CurrentPC)
+	}
+	return c.process.StackFrameWithRegisters(threadID, *goRoutineInfo.Registers)
 }
 
 // It must be called at return address due to the StackFrameAt's constraint.
-func (c *Controller) prevStackFrame(goRoutineInfo tracee.GoRoutineInfo, rip uint64) (*tracee.StackFrame, error) {
-	return c.process.StackFrameAt(goRoutineInfo.CurrentStackAddr-8, rip)
+// The results passed by the registers are still in the registers at the return address.
+func (c *Controller) prevStackFrame(threadID int, goRoutineInfo tracee.GoRoutineInfo, rip uint64) (*tracee.StackFrame, error) {
+	if goRoutineInfo.Registers == nil {
+		return c.process.StackFrameAt(goRoutineInfo.CurrentStackAddr-8, rip)
+	}
+	regs := *goRoutineInfo.Registers
+	regs.Rsp, regs.Rip = goRoutineInfo.CurrentStackAddr-8, rip
+	return c.process.StackFrameWithRegisters(threadID, regs)
+}
+
+// canPrint returns true if the function is within the trace level and printable.
+func (c *Controller) canPrint(f *tracee.Function, depth int) bool {
+	return !c.paused && depth <= c.traceLevel && c.printableFunc(f)
+}
+
+// filter returns the result of the tracing filter. True if the filter is not set.
+func (c *Controller) filter(goRoutineID int64, f *tracee.Function, depth int, args []tracee.Argument) bool {
+	return c.tracingFilter == nil || c.tracingFilter(f.Name, goRoutineID, depth, args)
 }
 
 func (c *Controller) printableFunc(f *tracee.Function) bool {
@@ -566,27 +1239,369 @@ func (c *Controller) printableFunc(f *tracee.Function) bool {
 	return true
 }
 
-func (c *Controller) printFunctionInput(goRoutineID int64, stackFrame *tracee.StackFrame, depth int) error {
+func (c *Controller) printFunctionInput(goRoutineInfo tracee.GoRoutineInfo, stackFrame *tracee.StackFrame, depth int) error {
+	goRoutineID := goRoutineInfo.ID
 	var args []string
 	for _, arg := range stackFrame.InputArguments {
 		args = append(args, arg.ParseValue(c.parseLevel))
 	}
+	argList := strings.Join(args, ", ")
+	if guessed, _ := stackFrame.Function.Annotation(tracee.AnnotationParametersGuessed); guessed == "true" || c.process.Binary.IsStripped() {
+		// the values are not reliable because the parameters are guessed.
+		argList = "no DWARF info"
+	}
 
-	fmt.Fprintf(c.outputWriter, "%s\\ (#%02d) %s(%s)\n", strings.Repeat("|", depth-1), goRoutineID, stackFrame.Function.Name, strings.Join(args, ", "))
+	event := c.newEvent("call", goRoutineID, stackFrame, depth, args, c.callerLocals(goRoutineInfo.CurrentStackAddr+8, stackFrame.ReturnAddress))
+	if c.eventHandler != nil {
+		c.eventHandler(event)
+	}
+	switch c.outputFormat {
+	case OutputFormatJSON:
+		return json.NewEncoder(c.outputWriter).Encode(event)
+	case OutputFormatChrome:
+		return c.printChromeEvent("B", goRoutineInfo, stackFrame, stackFrame.InputArguments)
+	}
+	fmt.Fprintf(c.textWriter(goRoutineID), "%s\\ (#%02d%s) %s(%s)%s%s\n", strings.Repeat("|", depth-1), goRoutineID, labelList(c.process.GoRoutineLabels(goRoutineInfo)), stackFrame.Function.Name, argList, localList(event.Locals), sourceLocation(event.File, event.Line))
 
 	return nil
 }
 
-func (c *Controller) printFunctionOutput(goRoutineID int64, stackFrame *tracee.StackFrame, depth int) error {
+func (c *Controller) printFunctionOutput(goRoutineInfo tracee.GoRoutineInfo, stackFrame *tracee.StackFrame, depth int) error {
+	goRoutineID := goRoutineInfo.ID
 	var args []string
 	for _, arg := range stackFrame.OutputArguments {
 		args = append(args, arg.ParseValue(c.parseLevel))
 	}
-	fmt.Fprintf(c.outputWriter, "%s/ (#%02d) %s() (%s)\n", strings.Repeat("|", depth-1), goRoutineID, stackFrame.Function.Name, strings.Join(args, ", "))
+	event := c.newEvent("return", goRoutineID, stackFrame, depth, args, c.callerLocals(goRoutineInfo.CurrentStackAddr, c.breakpointAddr(goRoutineInfo.CurrentPC)))
+	if c.eventHandler != nil {
+		c.eventHandler(event)
+	}
+	switch c.outputFormat {
+	case OutputFormatJSON:
+		return json.NewEncoder(c.outputWriter).Encode(event)
+	case OutputFormatChrome:
+		return c.printChromeEvent("E", goRoutineInfo, stackFrame, stackFrame.OutputArguments)
+	}
+	fmt.Fprintf(c.textWriter(goRoutineID), "%s/ (#%02d%s) %s() (%s)%s%s\n", strings.Repeat("|", depth-1), goRoutineID, labelList(c.process.GoRoutineLabels(goRoutineInfo)), stackFrame.Function.Name, strings.Join(args, ", "), localList(event.Locals), sourceLocation(event.File, event.Line))
 
 	return nil
 }
 
+// markSeen records that the go routine has traced the function. Used in the first call only mode.
+func (c *Controller) markSeen(f *tracee.Function, goRoutineID int64) {
+	if !c.firstCallOnly {
+		return
+	}
+
+	if c.seenFuncs[f.Name] == nil {
+		c.seenFuncs[f.Name] = make(map[int64]bool)
+	}
+	c.seenFuncs[f.Name][goRoutineID] = true
+}
+
+// dedupCall returns true if the call should be suppressed because the same call is printed `dedupCalls` times in a row.
+func (c *Controller) dedupCall(status *goRoutineStatus, goRoutineID int64, stackFrame *tracee.StackFrame, depth int) bool {
+	if c.dedupCalls <= 0 {
+		return false
+	}
+
+	var args []string
+	for _, arg := range stackFrame.InputArguments {
+		args = append(args, arg.ParseValue(c.parseLevel))
+	}
+	key := fmt.Sprintf("%s(%s)", stackFrame.Function.Name, strings.Join(args, ", "))
+	if key == status.lastCall.key && depth == status.lastCall.depth {
+		status.lastCall.count++
+		return status.lastCall.count > c.dedupCalls
+	}
+
+	c.flushRepeatedCalls(status, goRoutineID)
+	status.lastCall = repeatedCall{key: key, funcName: stackFrame.Function.Name, depth: depth, count: 1}
+	return false
+}
+
+// flushRepeatedCalls prints the number of the suppressed calls, if any, and then forgets the last call.
+func (c *Controller) flushRepeatedCalls(status *goRoutineStatus, goRoutineID int64) {
+	lastCall := status.lastCall
+	status.lastCall = repeatedCall{}
+	numSuppressed := lastCall.count - c.dedupCalls
+	if c.dedupCalls <= 0 || numSuppressed <= 0 {
+		return
+	}
+
+	event := Event{Type: "repeated", GoRoutineID: goRoutineID, Depth: lastCall.depth, Function: lastCall.funcName, Repeated: numSuppressed}
+	if c.eventHandler != nil {
+		c.eventHandler(event)
+	}
+	switch c.outputFormat {
+	case OutputFormatJSON:
+		if err := json.NewEncoder(c.outputWriter).Encode(event); err != nil {
+			log.Debugf("failed to print the repeated calls: %v", err)
+		}
+	case OutputFormatText:
+		fmt.Fprintf(c.textWriter(goRoutineID), "%s... (repeated %d more times)\n", strings.Repeat("|", lastCall.depth-1), numSuppressed)
+	}
+}
+
+// flushAllRepeatedCalls prints the number of the suppressed calls of all the go routines. Used when the tracing ends.
+func (c *Controller) flushAllRepeatedCalls() {
+	for goRoutineID, status := range c.statusStore {
+		c.flushRepeatedCalls(&status, goRoutineID)
+		c.statusStore[goRoutineID] = status
+	}
+}
+
+// isDeferProc returns true if the function registers the deferred function.
+func isDeferProc(f *tracee.Function) bool {
+	return f.Name == "runtime.deferproc" || f.Name == "runtime.deferprocStack"
+}
+
+// printDeferredCall prints the function call being registered by the defer statement. The depth is the one of
+// the function which has the defer statement. The failure is not fatal because the deferproc's args depend on the go version.
+func (c *Controller) printDeferredCall(threadID int, goRoutineID int64, depth int) {
+	deferredCall, err := c.process.DeferredCallAt(threadID)
+	if err != nil {
+		log.Debugf("failed to find the deferred call: %v", err)
+		return
+	}
+
+	var args []string
+	for _, arg := range deferredCall.Arguments {
+		args = append(args, arg.ParseValue(c.parseLevel))
+	}
+
+	event := Event{Type: "defer", GoRoutineID: goRoutineID, Depth: depth, Function: deferredCall.Function.Name, Args: args}
+	if c.eventHandler != nil {
+		c.eventHandler(event)
+	}
+	switch c.outputFormat {
+	case OutputFormatJSON:
+		if err := json.NewEncoder(c.outputWriter).Encode(event); err != nil {
+			log.Debugf("failed to print the deferred call: %v", err)
+		}
+	case OutputFormatText:
+		fmt.Fprintf(c.textWriter(goRoutineID), "%sdefer (#%02d) %s(%s)\n", strings.Repeat("|", depth), goRoutineID, deferredCall.Function.Name, strings.Join(args, ", "))
+	}
+}
+
+// printGoRoutineCreator prints the function which created the go routine, like 'goroutine #5 [created by main.worker @ worker.go:42]'.
+// Nothing is printed if the go routine is created by the runtime (e.g. the main go routine) or the output format is not text.
+func (c *Controller) printGoRoutineCreator(goRoutineInfo tracee.GoRoutineInfo) {
+	if c.outputFormat != OutputFormatText {
+		return
+	}
+	createdBy, goStatementPC := c.process.GoRoutineCreator(goRoutineInfo)
+	if createdBy == "" || strings.HasPrefix(createdBy, "runtime.") {
+		return
+	}
+
+	location := createdBy
+	if file, line, err := c.process.Binary.PCToFileLine(goStatementPC - 1); err == nil {
+		location = fmt.Sprintf("%s @ %s:%d", location, filepath.Base(file), line)
+	}
+	fmt.Fprintf(c.textWriter(goRoutineInfo.ID), "goroutine #%d [created by %s]\n", goRoutineInfo.ID, location)
+}
+
+const defaultGoRoutineOutputLimit = 64 * 1024
+
+// goRoutineOutput buffers the text output of the go routine. The buffer is flushed when it exceeds the limit.
+type goRoutineOutput struct {
+	buff  bytes.Buffer
+	w     io.Writer
+	limit int
+}
+
+func (o *goRoutineOutput) Write(p []byte) (int, error) {
+	n, err := o.buff.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if o.buff.Len() >= o.limit {
+		return n, o.Flush()
+	}
+	return n, nil
+}
+
+// Flush writes the buffered output to the underlying writer.
+func (o *goRoutineOutput) Flush() error {
+	_, err := o.buff.WriteTo(o.w)
+	return err
+}
+
+// textWriter returns the writer for the text output of the go routine.
+func (c *Controller) textWriter(goRoutineID int64) io.Writer {
+	if !c.separateGoRoutineOutput {
+		return c.outputWriter
+	}
+
+	output, ok := c.goRoutineOutputs[goRoutineID]
+	if !ok {
+		output = &goRoutineOutput{w: c.outputWriter, limit: c.goRoutineOutputLimit}
+		c.goRoutineOutputs[goRoutineID] = output
+	}
+	return output
+}
+
+func (c *Controller) flushGoRoutineOutput(goRoutineID int64) {
+	output, ok := c.goRoutineOutputs[goRoutineID]
+	if !ok {
+		return
+	}
+
+	if err := output.Flush(); err != nil {
+		log.Debugf("failed to flush the output of go routine #%d: %v", goRoutineID, err)
+	}
+	delete(c.goRoutineOutputs, goRoutineID)
+}
+
+// flushAllGoRoutineOutputs flushes the outputs of all the go routines in the order of the go routine id. Used when the tracing ends.
+func (c *Controller) flushAllGoRoutineOutputs() {
+	var goRoutineIDs []int64
+	for goRoutineID := range c.goRoutineOutputs {
+		goRoutineIDs = append(goRoutineIDs, goRoutineID)
+	}
+	sort.Slice(goRoutineIDs, func(i, j int) bool { return goRoutineIDs[i] < goRoutineIDs[j] })
+
+	for _, goRoutineID := range goRoutineIDs {
+		c.flushGoRoutineOutput(goRoutineID)
+	}
+}
+
+// Event is the traced call, return, deferred call, or the repeated calls suppressed. The json output is the sequence of
+// the events, one per line.
+type Event struct {
+	// Type is either "call", "return", "defer" or "repeated".
+	Type        string   `json:"type"`
+	GoRoutineID int64    `json:"goroutine"`
+	Depth       int      `json:"depth"`
+	Function    string   `json:"function"`
+	Args        []string `json:"args"`
+	Locals      []string `json:"locals,omitempty"`
+	// File and Line are set only when the source location is printed.
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+	// Repeated is the number of the suppressed calls. Used only when the type is "repeated".
+	Repeated int `json:"repeated,omitempty"`
+}
+
+func (c *Controller) newEvent(typ string, goRoutineID int64, stackFrame *tracee.StackFrame, depth int, args, locals []string) Event {
+	event := Event{Type: typ, GoRoutineID: goRoutineID, Depth: depth, Function: stackFrame.Function.Name, Args: args, Locals: locals}
+	if typ == "call" && c.process.Binary.IsStripped() {
+		event.Args = nil
+	}
+	if c.printSourceLocation {
+		event.File, event.Line = stackFrame.File, stackFrame.Line
+	}
+	return event
+}
+
+// chromeEvent is the event of the Chrome Trace Event format. The go routine is regarded as the process.
+type chromeEvent struct {
+	Name     string            `json:"name"`
+	Category string            `json:"cat"`
+	Phase    string            `json:"ph"`
+	PID      int64             `json:"pid"`
+	TID      int64             `json:"tid"`
+	TS       int64             `json:"ts"`
+	Args     map[string]string `json:"args,omitempty"`
+}
+
+func (c *Controller) printChromeEvent(phase string, goRoutineInfo tracee.GoRoutineInfo, stackFrame *tracee.StackFrame, arguments []tracee.Argument) error {
+	event := chromeEvent{
+		Name:     stackFrame.Function.Name,
+		Category: "function",
+		Phase:    phase,
+		PID:      goRoutineInfo.ID,
+		TID:      int64(c.process.GoRoutineThreadID(goRoutineInfo)),
+		TS:       time.Now().UnixNano() / int64(time.Microsecond),
+	}
+	if !(phase == "B" && c.process.Binary.IsStripped()) {
+		event.Args = make(map[string]string)
+		for _, arg := range arguments {
+			event.Args[arg.Name] = arg.ParseValue(c.parseLevel)
+		}
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	separator := "[\n"
+	if c.chromeEventWritten {
+		separator = ",\n"
+	}
+	c.chromeEventWritten = true
+	_, err = fmt.Fprintf(c.outputWriter, "%s%s", separator, data)
+	return err
+}
+
+// closeChromeEvents closes the JSON array of the chrome events so that the output is the valid JSON.
+func (c *Controller) closeChromeEvents() {
+	if c.outputFormat != OutputFormatChrome {
+		return
+	}
+
+	if c.chromeEventWritten {
+		fmt.Fprint(c.outputWriter, "\n]\n")
+	} else {
+		fmt.Fprint(c.outputWriter, "[]\n")
+	}
+}
+
+// callerLocals returns the local variables of the caller in scope at the call instruction if the locals are printed.
+// `rsp` is the caller's stack pointer and `retAddr` is the return address of the call.
+func (c *Controller) callerLocals(rsp, retAddr uint64) []string {
+	if !c.showLocals {
+		return nil
+	}
+
+	locals, err := c.process.LocalsAt(rsp, retAddr-1)
+	if err != nil {
+		log.Debugf("failed to find the local variables: %v", err)
+		return nil
+	}
+
+	var values []string
+	for _, local := range locals {
+		values = append(values, local.ParseValue(c.parseLevel))
+	}
+	return values
+}
+
+func localList(locals []string) string {
+	if len(locals) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" locals(%s)", strings.Join(locals, ", "))
+}
+
+// labelList returns the profiler labels of the go routine, sorted by the key.
+func labelList(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var list []string
+	for _, key := range keys {
+		list = append(list, fmt.Sprintf("%s=%s", key, labels[key]))
+	}
+	return " " + strings.Join(list, " ")
+}
+
+func sourceLocation(file string, line int) string {
+	if file == "" {
+		return ""
+	}
+	return fmt.Sprintf(" [%s:%d]", filepath.Base(file), line)
+}
+
 func (c *Controller) findCallInstAddresses(f *tracee.Function) ([]uint64, error) {
 	// this cache is not only efficient, but required because there are no call insts if breakpoints are set.
 	if cache, ok := c.callInstAddrCache[f.StartAddr]; ok {
@@ -601,7 +1616,7 @@ func (c *Controller) findCallInstAddresses(f *tracee.Function) ([]uint64, error)
 	var pos int
 	var addresses []uint64
 	for _, inst := range insts {
-		if inst.Op == x86asm.CALL || inst.Op == x86asm.LCALL {
+		if inst.IsCall {
 			addresses = append(addresses, f.StartAddr+uint64(pos))
 		}
 		pos += inst.Len
@@ -611,6 +1626,68 @@ func (c *Controller) findCallInstAddresses(f *tracee.Function) ([]uint64, error)
 	return addresses, nil
 }
 
+// Pause pauses printing the traced data. The tracee is still traced, so the printing restarts with the correct depth
+// when resumed. It's no-op if paused already.
+func (c *Controller) Pause() {
+	c.pendingPause <- true
+}
+
+// Resume resumes printing the traced data. It's no-op if not paused.
+func (c *Controller) Resume() {
+	c.pendingPause <- false
+}
+
+func (c *Controller) applyPendingPause() {
+	for {
+		select {
+		case paused := <-c.pendingPause:
+			c.paused = paused
+		default:
+			return
+		}
+	}
+}
+
+// SetKeepAttached sets the flag to keep the tracee attached when the main loop is interrupted.
+// Call Reset and then MainLoop to start the next tracing session, or Detach to end the tracing.
+func (c *Controller) SetKeepAttached(b bool) {
+	c.keepAttached = b
+}
+
+// Reset clears all the tracing state, including the breakpoints and the trace points, while the tracee stays attached.
+// Call it after the main loop is interrupted. Add the trace points again before the next main loop.
+func (c *Controller) Reset() error {
+	if err := c.breakpoints.ClearAll(); err != nil {
+		return err
+	}
+
+	c.statusStore = make(map[int64]goRoutineStatus)
+	c.breakpointTypes = make(map[uint64]breakpointType)
+	c.spawnDepths = make(map[uint64][]int)
+	c.spawnedFuncs = make(map[uint64]int)
+	c.seenFuncs = make(map[string]map[int64]bool)
+	c.goRoutineOutputs = make(map[int64]*goRoutineOutput)
+	c.tracingPoints = tracingPoints{}
+	c.callGraph.Reset()
+	c.chromeEventWritten = false
+	c.paused = false
+	for {
+		select {
+		case <-c.interruptCh:
+		case <-c.pendingStartTracePoint:
+		case <-c.pendingEndTracePoint:
+		case <-c.pendingPause:
+		default:
+			return nil
+		}
+	}
+}
+
+// Detach detaches from the tracee. Use it to end the tracing when the tracee is kept attached.
+func (c *Controller) Detach() error {
+	return c.process.Detach()
+}
+
 // Interrupt interrupts the main loop.
 func (c *Controller) Interrupt() {
 	c.interruptCh <- true
@@ -2,14 +2,24 @@ package tracer
 
 import (
 	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"os"
 	"os/exec"
+	"regexp"
 	"runtime"
 	"strings"
+	"syscall"
 	"testing"
 
+	"github.com/ks888/tgo/debugapi"
+	"github.com/ks888/tgo/debugapi/mock"
 	"github.com/ks888/tgo/testutils"
+	"github.com/ks888/tgo/tracee"
 )
 
 var helloworldAttrs = Attributes{
@@ -117,17 +127,46 @@ func TestMainLoop_MainMain(t *testing.T) {
 	if strings.Count(output, "main.main") != 0 {
 		t.Errorf("unexpected output: %s", output)
 	}
+	t.Log(output)
 	if strings.Count(output, "main.noParameter") != 2 {
 		t.Errorf("unexpected output: %s", output)
 	}
 }
 
+func TestMainLoop_OneParameter(t *testing.T) {
+	controller := NewController()
+	buff := &bytes.Buffer{}
+	controller.outputWriter = buff
+	controller.SetTraceLevel(1)
+	if err := controller.LaunchTracee(testutils.ProgramHelloworld, nil, helloworldAttrs); err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	// the functions main.main calls are traced.
+	if err := controller.AddStartTracePoint(testutils.HelloworldAddrMain); err != nil {
+		t.Fatalf("failed to set tracing point: %v", err)
+	}
+
+	if err := controller.MainLoop(); err != nil {
+		t.Errorf("failed to run main loop: %v", err)
+	}
+
+	output := buff.String()
+	if !strings.Contains(output, fmt.Sprintf("\\ (#01) %s(s = [1]{1})", testutils.HelloworldFuncOneParameter)) {
+		t.Errorf("no call with the argument: %s", output)
+	}
+	if !strings.Contains(output, fmt.Sprintf("/ (#01) %s() (", testutils.HelloworldFuncOneParameter)) || !strings.Contains(output, "= [2]{1, 2})") {
+		t.Errorf("no return with the result: %s", output)
+	}
+}
+
 func TestMainLoop_NoDWARFBinary(t *testing.T) {
 	controller := NewController()
 	buff := &bytes.Buffer{}
 	controller.outputWriter = buff
 	controller.SetTraceLevel(1)
-	if err := controller.LaunchTracee(testutils.ProgramHelloworldNoDwarf, nil, helloworldAttrs); err != nil {
+	attrs := helloworldAttrs
+	attrs.ProgramPath = testutils.ProgramHelloworldNoDwarf
+	if err := controller.LaunchTracee(testutils.ProgramHelloworldNoDwarf, nil, attrs); err != nil {
 		t.Fatalf("failed to launch process: %v", err)
 	}
 	if err := controller.AddStartTracePoint(testutils.HelloworldAddrMain); err != nil {
@@ -142,6 +181,9 @@ func TestMainLoop_NoDWARFBinary(t *testing.T) {
 	if strings.Count(output, "main.main") != 0 {
 		t.Errorf("unexpected output: %s", output)
 	}
+	if !strings.Contains(output, "main.noParameter(no DWARF info)") {
+		t.Errorf("unexpected output: %s", output)
+	}
 }
 
 func TestMainLoop_MainNoParameter(t *testing.T) {
@@ -175,6 +217,369 @@ func TestMainLoop_MainNoParameter(t *testing.T) {
 	}
 }
 
+func TestMainLoop_PrintSourceLocation(t *testing.T) {
+	controller := NewController()
+	buff := &bytes.Buffer{}
+	controller.outputWriter = buff
+	controller.SetTraceLevel(1)
+	controller.SetPrintSourceLocation(true)
+	if err := controller.LaunchTracee(testutils.ProgramHelloworld, nil, helloworldAttrs); err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	if err := controller.AddStartTracePoint(testutils.HelloworldAddrMain); err != nil {
+		t.Fatalf("failed to set tracing point: %v", err)
+	}
+
+	if err := controller.MainLoop(); err != nil {
+		t.Errorf("failed to run main loop: %v", err)
+	}
+
+	output := buff.String()
+	if !strings.Contains(output, "[helloworld.go:") {
+		t.Errorf("unexpected output: %s", output)
+	}
+}
+
+func TestMainLoop_ShowLocals(t *testing.T) {
+	controller := NewController()
+	buff := &bytes.Buffer{}
+	controller.outputWriter = buff
+	controller.SetTraceLevel(1)
+	controller.SetShowLocals(true)
+	if err := controller.LaunchTracee(testutils.ProgramHelloworld, nil, helloworldAttrs); err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	if err := controller.AddStartTracePoint(testutils.HelloworldAddrOneParameterAndVariable); err != nil {
+		t.Fatalf("failed to set tracing point: %v", err)
+	}
+
+	if err := controller.MainLoop(); err != nil {
+		t.Errorf("failed to run main loop: %v", err)
+	}
+
+	output := buff.String()
+	t.Log(output)
+	if strings.Count(output, "locals(a = ") != 4 {
+		t.Errorf("unexpected output: %s", output)
+	}
+}
+
+func TestMainLoop_PrintLabels(t *testing.T) {
+	controller := NewController()
+	buff := &bytes.Buffer{}
+	controller.outputWriter = buff
+	controller.SetTraceLevel(1)
+	attrs := Attributes{
+		ProgramPath:         testutils.ProgramLabels,
+		FirstModuleDataAddr: testutils.LabelsAddrFirstModuleData,
+		CompiledGoVersion:   runtime.Version(),
+	}
+	if err := controller.LaunchTracee(testutils.ProgramLabels, nil, attrs); err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	if err := controller.AddStartTracePoint(testutils.LabelsAddrWork); err != nil {
+		t.Fatalf("failed to set tracing point: %v", err)
+	}
+
+	if err := controller.MainLoop(); err != nil {
+		t.Errorf("failed to run main loop: %v", err)
+	}
+
+	output := buff.String()
+	// the tracing point function itself is not printed, but the functions it calls are.
+	if !strings.Contains(output, " role=worker) fmt.") {
+		t.Errorf("unexpected output: %s", output)
+	}
+}
+
+func TestLabelList(t *testing.T) {
+	for i, test := range []struct {
+		labels   map[string]string
+		expected string
+	}{
+		{labels: nil, expected: ""},
+		{labels: map[string]string{"role": "worker"}, expected: " role=worker"},
+		{labels: map[string]string{"z": "1", "a": "2"}, expected: " a=2 z=1"},
+	} {
+		if actual := labelList(test.labels); actual != test.expected {
+			t.Errorf("[%d] wrong list: %q", i, actual)
+		}
+	}
+}
+
+func TestMainLoop_TracingFilter(t *testing.T) {
+	controller := NewController()
+	buff := &bytes.Buffer{}
+	controller.outputWriter = buff
+	controller.SetTraceLevel(1)
+	controller.SetTracingFilter(func(funcName string, goRoutineID int64, depth int, args []tracee.Argument) bool {
+		return funcName != "main.noParameter"
+	})
+	if err := controller.LaunchTracee(testutils.ProgramHelloworld, nil, helloworldAttrs); err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	if err := controller.AddStartTracePoint(testutils.HelloworldAddrMain); err != nil {
+		t.Fatalf("failed to set tracing point: %v", err)
+	}
+
+	if err := controller.MainLoop(); err != nil {
+		t.Errorf("failed to run main loop: %v", err)
+	}
+
+	output := buff.String()
+	if strings.Count(output, "main.noParameter") != 0 {
+		t.Errorf("unexpected output: %s", output)
+	}
+	if strings.Count(output, "main.twoParameters") != 2 {
+		t.Errorf("unexpected output: %s", output)
+	}
+}
+
+func TestMainLoop_Pause(t *testing.T) {
+	for i, testCase := range []struct {
+		ops         []func(c *Controller)
+		expectEmpty bool
+	}{
+		{ops: []func(c *Controller){(*Controller).Pause}, expectEmpty: true},
+		{ops: []func(c *Controller){(*Controller).Pause, (*Controller).Pause, (*Controller).Resume}, expectEmpty: false},
+		{ops: []func(c *Controller){(*Controller).Resume}, expectEmpty: false},
+	} {
+		controller := NewController()
+		buff := &bytes.Buffer{}
+		controller.outputWriter = buff
+		controller.SetTraceLevel(1)
+		if err := controller.LaunchTracee(testutils.ProgramHelloworld, nil, helloworldAttrs); err != nil {
+			t.Fatalf("[%d] failed to launch process: %v", i, err)
+		}
+		if err := controller.AddStartTracePoint(testutils.HelloworldAddrMain); err != nil {
+			t.Fatalf("[%d] failed to set tracing point: %v", i, err)
+		}
+		for _, op := range testCase.ops {
+			op(controller)
+		}
+
+		if err := controller.MainLoop(); err != nil {
+			t.Errorf("[%d] failed to run main loop: %v", i, err)
+		}
+
+		if output := buff.String(); (output == "") != testCase.expectEmpty {
+			t.Errorf("[%d] unexpected output: %s", i, output)
+		}
+	}
+}
+
+func TestMainLoop_TraceMode(t *testing.T) {
+	for i, testCase := range []struct {
+		mode                     TraceMode
+		expectCall, expectReturn bool
+	}{
+		{mode: TraceModeBoth, expectCall: true, expectReturn: true},
+		{mode: TraceModeInputOnly, expectCall: true, expectReturn: false},
+		{mode: TraceModeOutputOnly, expectCall: false, expectReturn: true},
+	} {
+		controller := NewController()
+		buff := &bytes.Buffer{}
+		controller.outputWriter = buff
+		controller.SetTraceLevel(1)
+		controller.SetTraceMode(testCase.mode)
+		if err := controller.LaunchTracee(testutils.ProgramHelloworld, nil, helloworldAttrs); err != nil {
+			t.Fatalf("[%d] failed to launch process: %v", i, err)
+		}
+		if err := controller.AddStartTracePoint(testutils.HelloworldAddrMain); err != nil {
+			t.Fatalf("[%d] failed to set tracing point: %v", i, err)
+		}
+
+		if err := controller.MainLoop(); err != nil {
+			t.Errorf("[%d] failed to run main loop: %v", i, err)
+		}
+
+		output := buff.String()
+		if strings.Contains(output, "\\ (#01) main.noParameter") != testCase.expectCall {
+			t.Errorf("[%d] unexpected output: %s", i, output)
+		}
+		if strings.Contains(output, "/ (#01) main.noParameter") != testCase.expectReturn {
+			t.Errorf("[%d] unexpected output: %s", i, output)
+		}
+	}
+}
+
+func TestMainLoop_FirstCallOnly(t *testing.T) {
+	controller := NewController()
+	buff := &bytes.Buffer{}
+	controller.outputWriter = buff
+	controller.SetTraceLevel(2)
+	controller.SetFirstCallOnly(true)
+	if err := controller.LaunchTracee(testutils.ProgramRecursive, nil, recursiveAttrs); err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	if err := controller.AddStartTracePoint(testutils.RecursiveAddrMain); err != nil {
+		t.Fatalf("failed to set tracing point: %v", err)
+	}
+
+	if err := controller.MainLoop(); err != nil {
+		t.Errorf("failed to run main loop: %v", err)
+	}
+
+	// fmt.Println is called twice, but only the first call is printed.
+	output := buff.String()
+	if strings.Count(output, "fmt.Fprintln(") != 2 {
+		t.Errorf("wrong number of fmt.Fprintln: %d\n%s", strings.Count(output, "fmt.Fprintln("), output)
+	}
+	// the call which is printed after the not printed call has the right depth.
+	if strings.Count(output, "\\ (#01) main.deep()") != 1 || strings.Count(output, "|\\ (#01) main.grow(") != 1 {
+		t.Errorf("main.deep or main.grow is not printed as expected\n%s", output)
+	}
+}
+
+func TestDedupCall(t *testing.T) {
+	controller := NewController()
+	buff := &bytes.Buffer{}
+	controller.outputWriter = buff
+	controller.SetDeduplicateCalls(2)
+
+	status := &goRoutineStatus{}
+	polling := &tracee.StackFrame{Function: &tracee.Function{Name: "main.polling"}}
+	for i, expected := range []bool{false, false, true, true, true} {
+		if actual := controller.dedupCall(status, 1, polling, 1); actual != expected {
+			t.Errorf("[%d] wrong result: %v", i, actual)
+		}
+	}
+
+	done := &tracee.StackFrame{Function: &tracee.Function{Name: "main.done"}}
+	if controller.dedupCall(status, 1, done, 1) {
+		t.Errorf("different call is suppressed")
+	}
+	if output := buff.String(); output != "... (repeated 3 more times)\n" {
+		t.Errorf("unexpected output: %s", output)
+	}
+}
+
+func TestMainLoop_TraceAll(t *testing.T) {
+	controller := NewController()
+	buff := &bytes.Buffer{}
+	controller.outputWriter = buff
+	controller.SetTraceLevel(1)
+	controller.SetTraceAll(true)
+	if err := controller.LaunchTracee(testutils.ProgramHelloworld, nil, helloworldAttrs); err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+
+	if err := controller.MainLoop(); err != nil {
+		t.Errorf("failed to run main loop: %v", err)
+	}
+
+	// the depth is relative to main.main, and the trace level applies to it.
+	output := buff.String()
+	if !strings.Contains(output, "\n\\ (#01) main.noParameter()\n/ (#01) main.noParameter() ()\n") {
+		t.Errorf("main.noParameter is not printed at the depth 1: %s", output)
+	}
+	if strings.Contains(output, "fmt.Fprintln") {
+		t.Errorf("the function deeper than the trace level is printed: %s", output)
+	}
+}
+
+func TestMainLoop_JSONOutputFormat(t *testing.T) {
+	controller := NewController()
+	buff := &bytes.Buffer{}
+	controller.outputWriter = buff
+	controller.SetTraceLevel(1)
+	if err := controller.SetOutputFormat(OutputFormatJSON); err != nil {
+		t.Fatalf("failed to set output format: %v", err)
+	}
+	if err := controller.LaunchTracee(testutils.ProgramHelloworld, nil, helloworldAttrs); err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	if err := controller.AddStartTracePoint(testutils.HelloworldAddrMain); err != nil {
+		t.Fatalf("failed to set tracing point: %v", err)
+	}
+
+	if err := controller.MainLoop(); err != nil {
+		t.Errorf("failed to run main loop: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buff.String()), "\n")
+	for _, line := range lines {
+		var call Event
+		if err := json.Unmarshal([]byte(line), &call); err != nil {
+			t.Fatalf("failed to unmarshal %s: %v", line, err)
+		}
+		if call.Depth != 1 || (call.Type != "call" && call.Type != "return") {
+			t.Errorf("unexpected call: %#v", call)
+		}
+	}
+}
+
+func TestMainLoop_EventHandler(t *testing.T) {
+	controller := NewController()
+	controller.outputWriter = ioutil.Discard
+	controller.SetTraceLevel(1)
+	var events []Event
+	controller.SetEventHandler(func(event Event) { events = append(events, event) })
+	if err := controller.LaunchTracee(testutils.ProgramHelloworld, nil, helloworldAttrs); err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	if err := controller.AddStartTracePoint(testutils.HelloworldAddrMain); err != nil {
+		t.Fatalf("failed to set tracing point: %v", err)
+	}
+
+	if err := controller.MainLoop(); err != nil {
+		t.Errorf("failed to run main loop: %v", err)
+	}
+
+	if len(events) == 0 || events[0].Type != "call" || events[0].Function != "main.noParameter" {
+		t.Fatalf("unexpected events: %#v", events)
+	}
+	if events[1].Type != "return" || events[1].Function != "main.noParameter" {
+		t.Errorf("unexpected event: %#v", events[1])
+	}
+}
+
+func TestMainLoop_ChromeFormat(t *testing.T) {
+	controller := NewController()
+	buff := &bytes.Buffer{}
+	controller.outputWriter = buff
+	controller.SetTraceLevel(1)
+	if err := controller.SetOutputFormat(OutputFormatChrome); err != nil {
+		t.Fatalf("failed to set output format: %v", err)
+	}
+	if err := controller.LaunchTracee(testutils.ProgramHelloworld, nil, helloworldAttrs); err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	if err := controller.AddStartTracePoint(testutils.HelloworldAddrMain); err != nil {
+		t.Fatalf("failed to set tracing point: %v", err)
+	}
+
+	if err := controller.MainLoop(); err != nil {
+		t.Errorf("failed to run main loop: %v", err)
+	}
+
+	var events []chromeEvent
+	if err := json.Unmarshal(buff.Bytes(), &events); err != nil {
+		t.Fatalf("failed to unmarshal %s: %v", buff.String(), err)
+	}
+	numBegin, numEnd := 0, 0
+	for _, event := range events {
+		switch event.Phase {
+		case "B":
+			numBegin++
+		case "E":
+			numEnd++
+		default:
+			t.Errorf("unexpected event: %#v", event)
+		}
+	}
+	if numBegin == 0 || numBegin != numEnd {
+		t.Errorf("unexpected events: %s", buff.String())
+	}
+}
+
+func TestSetOutputFormat_Unknown(t *testing.T) {
+	controller := NewController()
+	if err := controller.SetOutputFormat("xml"); err == nil {
+		t.Errorf("should return error")
+	}
+}
+
 var goRoutinesAttrs = Attributes{
 	ProgramPath:         testutils.ProgramGoRoutines,
 	FirstModuleDataAddr: testutils.GoRoutinesAddrFirstModuleData,
@@ -209,6 +614,160 @@ func TestMainLoop_GoRoutines(t *testing.T) {
 	if strings.Count(output, "main.receive") != 40 {
 		t.Errorf("unexpected output: %d\n%s", strings.Count(output, "main.receive"), output)
 	}
+	if strings.Count(output, "[created by main.main @ goroutines.go:") != 20 {
+		t.Errorf("unexpected output: %d\n%s", strings.Count(output, "[created by main.main"), output)
+	}
+}
+
+var concurrentAttrs = Attributes{
+	ProgramPath:         testutils.ProgramConcurrent,
+	FirstModuleDataAddr: testutils.ConcurrentAddrFirstModuleData,
+	CompiledGoVersion:   runtime.Version(),
+}
+
+// tracedCallRegexp matches the call or return of main.square and main.multiply. The submatches are the depth marks,
+// the call or return mark, the go routine id, the function name, the input args and the output args respectively.
+var tracedCallRegexp = regexp.MustCompile(`^(\|*)([\\/]) \(#(\d+)\) main\.(square|multiply)\((.*?)\)(?: \((.*)\))?$`)
+
+func TestMainLoop_ConcurrentGoRoutines(t *testing.T) {
+	// Unlike other go routine tests, GOMAXPROCS is not limited. The workers call the traced function at the same time
+	// from the different threads, so the threads trapped while another thread is single-stepping are handled.
+	controller := NewController()
+	buff := &bytes.Buffer{}
+	controller.outputWriter = buff
+	controller.SetTraceLevel(2)
+	if err := controller.LaunchTracee(testutils.ProgramConcurrent, nil, concurrentAttrs); err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	if err := controller.AddStartTracePoint(testutils.ConcurrentAddrWork); err != nil {
+		t.Fatalf("failed to set tracing point: %v", err)
+	}
+
+	if err := controller.MainLoop(); err != nil {
+		t.Errorf("failed to run main loop: %v", err)
+	}
+
+	output := buff.String()
+	tracedCalls := make(map[string][]string)
+	for _, line := range strings.Split(output, "\n") {
+		matches := tracedCallRegexp.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		depthMarks, mark, goRoutineID, funcName := matches[1], matches[2], matches[3], matches[4]
+
+		expectedDepthMarks := ""
+		if funcName == "multiply" {
+			expectedDepthMarks = "|"
+		}
+		if depthMarks != expectedDepthMarks {
+			t.Errorf("wrong depth: %s", line)
+		}
+
+		args := matches[5]
+		if mark == "/" {
+			args = matches[6]
+		}
+		tracedCalls[goRoutineID] = append(tracedCalls[goRoutineID], mark+funcName+"("+args+")")
+	}
+
+	const numWorkers = 8
+	if len(tracedCalls) != numWorkers {
+		t.Fatalf("wrong number of go routines: %d\n%s", len(tracedCalls), output)
+	}
+	seenIDs := make(map[int]bool)
+	for goRoutineID, calls := range tracedCalls {
+		// each go routine calls main.square exactly once with its own id.
+		var id int
+		if _, err := fmt.Sscanf(calls[0], "\\square(id = %d)", &id); err != nil || id < 1 || id > numWorkers || seenIDs[id] {
+			t.Errorf("wrong call in #%s: %v\n%s", goRoutineID, calls, output)
+			continue
+		}
+		seenIDs[id] = true
+
+		expected := []string{
+			fmt.Sprintf("\\square(id = %d)", id),
+			fmt.Sprintf("\\multiply(a = %d, b = %d)", id, id),
+			fmt.Sprintf("/multiply(= %d)", id*id),
+			fmt.Sprintf("/square(= %d)", id*id),
+		}
+		if len(calls) != len(expected) {
+			t.Errorf("wrong number of calls in #%s: %v\n%s", goRoutineID, calls, output)
+			continue
+		}
+		for i, call := range calls {
+			// the name of the output parameter depends on the go version (e.g. ~r0 or ~r1). Ignore it.
+			if index := strings.Index(call, "= "); strings.HasPrefix(call, "/") && index >= 0 {
+				call = call[:strings.Index(call, "(")+1] + call[index:]
+			}
+			if call != expected[i] {
+				t.Errorf("wrong call in #%s: %s, expected: %s", goRoutineID, call, expected[i])
+			}
+		}
+	}
+}
+
+func TestMainLoop_SeparateGoroutineOutput(t *testing.T) {
+	os.Setenv("GOMAXPROCS", "1")
+	defer os.Unsetenv("GOMAXPROCS")
+
+	controller := NewController()
+	buff := &bytes.Buffer{}
+	controller.outputWriter = buff
+	controller.SetTraceLevel(1)
+	controller.SetSeparateGoroutineOutput(true)
+	if err := controller.LaunchTracee(testutils.ProgramGoRoutines, nil, goRoutinesAttrs); err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	if err := controller.AddStartTracePoint(testutils.GoRoutinesAddrInc); err != nil {
+		t.Fatalf("failed to set tracing point: %v", err)
+	}
+
+	if err := controller.MainLoop(); err != nil {
+		t.Errorf("failed to run main loop: %v", err)
+	}
+
+	output := buff.String()
+	if strings.Count(output, "main.send") != 40 {
+		t.Errorf("unexpected output: %d\n%s", strings.Count(output, "main.send"), output)
+	}
+	finished := make(map[string]bool)
+	var curr string
+	for _, line := range strings.Split(output, "\n") {
+		start := strings.Index(line, "(#")
+		if start == -1 {
+			continue
+		}
+		id := line[start : start+strings.IndexAny(line[start:], " )")]
+		if id == curr {
+			continue
+		}
+		if finished[id] {
+			t.Fatalf("the output of %s is not contiguous:\n%s", id, output)
+		}
+		finished[curr] = true
+		curr = id
+	}
+}
+
+func TestGoRoutineOutput(t *testing.T) {
+	buff := &bytes.Buffer{}
+	output := &goRoutineOutput{w: buff, limit: 8}
+	fmt.Fprint(output, "abc")
+	if buff.Len() != 0 {
+		t.Errorf("flushed before the limit: %s", buff)
+	}
+	fmt.Fprint(output, "defghi")
+	if buff.String() != "abcdefghi" {
+		t.Errorf("not flushed after the limit: %s", buff)
+	}
+	fmt.Fprint(output, "jk")
+	if err := output.Flush(); err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+	if buff.String() != "abcdefghijk" {
+		t.Errorf("wrong output: %s", buff)
+	}
 }
 
 var recursiveAttrs = Attributes{
@@ -217,6 +776,37 @@ var recursiveAttrs = Attributes{
 	CompiledGoVersion:   runtime.Version(),
 }
 
+func TestMainLoop_SpawnedGoRoutines(t *testing.T) {
+	os.Setenv("GOMAXPROCS", "1")
+	defer os.Unsetenv("GOMAXPROCS")
+
+	controller := NewController()
+	buff := &bytes.Buffer{}
+	controller.outputWriter = buff
+	controller.SetTraceLevel(2)
+	if err := controller.LaunchTracee(testutils.ProgramGoRoutines, nil, goRoutinesAttrs); err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	if err := controller.AddStartTracePoint(testutils.GoRoutinesAddrMain); err != nil {
+		t.Fatalf("failed to set tracing point: %v", err)
+	}
+
+	if err := controller.MainLoop(); err != nil {
+		t.Errorf("failed to run main loop: %v", err)
+	}
+
+	// the spawned go routines start at the depth of the go statement, so their callees are at depth 2.
+	output := buff.String()
+	for _, line := range strings.Split(output, "\n") {
+		if strings.Contains(line, "main.receive") && !strings.HasPrefix(line, "|") {
+			t.Errorf("wrong depth: %s", line)
+		}
+	}
+	if strings.Count(output, "main.receive") != 2*20 {
+		t.Errorf("wrong number of main.receive: %d\n%s", strings.Count(output, "main.receive"), output)
+	}
+}
+
 func TestMainLoop_Recursive(t *testing.T) {
 	controller := NewController()
 	buff := &bytes.Buffer{}
@@ -239,12 +829,153 @@ func TestMainLoop_Recursive(t *testing.T) {
 	}
 }
 
+func TestMainLoop_RecursiveTracingPoint(t *testing.T) {
+	controller := NewController()
+	buff := &bytes.Buffer{}
+	controller.outputWriter = buff
+	if err := controller.LaunchTracee(testutils.ProgramRecursive, nil, recursiveAttrs); err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	if err := controller.AddStartTracePoint(testutils.RecursiveAddrDec); err != nil {
+		t.Fatalf("failed to set tracing point: %v", err)
+	}
+	controller.SetTraceLevel(1)
+
+	if err := controller.MainLoop(); err != nil {
+		t.Errorf("failed to run main loop: %v", err)
+	}
+
+	// each recursive call is the new entry of the tracing point, so all the 100 calls are traced at their own depth.
+	output := buff.String()
+	if strings.Count(output, "main.dec") != 2*100 {
+		t.Errorf("wrong number of main.dec: %d\n%s", strings.Count(output, "main.dec"), output)
+	}
+	if !strings.Contains(output, "\n"+strings.Repeat("|", 99)+"\\ (#01) main.dec(") {
+		t.Errorf("the innermost call is not traced at the right depth\n%s", output)
+	}
+}
+
+func TestMainLoop_RecursiveWithStackGrowth(t *testing.T) {
+	controller := NewController()
+	buff := &bytes.Buffer{}
+	controller.outputWriter = buff
+	if err := controller.LaunchTracee(testutils.ProgramRecursive, nil, recursiveAttrs); err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	if err := controller.AddStartTracePoint(testutils.RecursiveAddrMain); err != nil {
+		t.Fatalf("failed to set tracing point: %v", err)
+	}
+	controller.SetTraceLevel(4)
+
+	if err := controller.MainLoop(); err != nil {
+		t.Errorf("failed to run main loop: %v", err)
+	}
+
+	// main.deep returns after the recursive calls of main.grow, which grow the stack, return.
+	output := buff.String()
+	if strings.Count(output, "main.grow") != 4 {
+		t.Errorf("wrong number of main.grow: %d\n%s", strings.Count(output, "main.grow"), output)
+	}
+	if !strings.Contains(output, "|/ (#01) main.grow()") || !strings.Contains(output, "\n|/ (#01) main.deep()") {
+		t.Errorf("unexpected output:\n%s", output)
+	}
+	if strings.LastIndex(output, "main.grow") > strings.LastIndex(output, "main.deep") {
+		t.Errorf("wrong order:\n%s", output)
+	}
+}
+
 var panicAttrs = Attributes{
 	ProgramPath:         testutils.ProgramPanic,
 	FirstModuleDataAddr: testutils.PanicAddrFirstModuleData,
 	CompiledGoVersion:   runtime.Version(),
 }
 
+func TestMainLoop_SampleRate(t *testing.T) {
+	controller := NewController()
+	buff := &bytes.Buffer{}
+	controller.outputWriter = buff
+	if err := controller.LaunchTracee(testutils.ProgramRecursive, nil, recursiveAttrs); err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	if err := controller.AddStartTracePoint(testutils.RecursiveAddrMain); err != nil {
+		t.Fatalf("failed to set tracing point: %v", err)
+	}
+	controller.SetTraceLevel(3)
+	controller.SetSampleRate(0.000001)
+
+	if err := controller.MainLoop(); err != nil {
+		t.Errorf("failed to run main loop: %v", err)
+	}
+
+	if strings.Count(buff.String(), "main.dec") != 0 {
+		t.Errorf("not sampled function is traced: %s", buff.String())
+	}
+}
+
+func TestMainLoop_SampleRateHalf(t *testing.T) {
+	traceDecCalls := func(sampleRate float64) ([]string, string) {
+		controller := NewController()
+		buff := &bytes.Buffer{}
+		controller.outputWriter = buff
+		if err := controller.LaunchTracee(testutils.ProgramRecursive, nil, recursiveAttrs); err != nil {
+			t.Fatalf("failed to launch process: %v", err)
+		}
+		if err := controller.AddStartTracePoint(testutils.RecursiveAddrMain); err != nil {
+			t.Fatalf("failed to set tracing point: %v", err)
+		}
+		controller.SetTraceLevel(200)
+		controller.SetSampleRate(sampleRate)
+		controller.sampler = rand.New(rand.NewSource(2))
+
+		if err := controller.MainLoop(); err != nil {
+			t.Errorf("failed to run main loop: %v", err)
+		}
+
+		var calls []string
+		output := buff.String()
+		for _, line := range strings.Split(output, "\n") {
+			if strings.Contains(line, "\\ (#01) main.dec(") {
+				calls = append(calls, line)
+			}
+		}
+		return calls, output
+	}
+
+	allCalls, _ := traceDecCalls(1)
+	sampledCalls, output := traceDecCalls(0.5)
+
+	// the calls made by the call which is not sampled are not traced, so the traced calls are the beginning of all the calls.
+	if len(sampledCalls) == 0 || len(sampledCalls) >= len(allCalls) {
+		t.Fatalf("unexpected number of calls: %d, %d\n%s", len(sampledCalls), len(allCalls), output)
+	}
+	for i, call := range sampledCalls {
+		if call != allCalls[i] {
+			t.Fatalf("the call made by the not sampled call is traced: %s, %s\n%s", call, allCalls[i], output)
+		}
+	}
+	if numReturns := strings.Count(output, "/ (#01) main.dec("); numReturns != len(sampledCalls) {
+		t.Errorf("unexpected number of returns: %d, %d\n%s", len(sampledCalls), numReturns, output)
+	}
+}
+
+func TestSetSampleRate(t *testing.T) {
+	controller := NewController()
+	if !controller.sampled() {
+		t.Errorf("should be sampled by default")
+	}
+
+	controller.SetSampleRate(0.5)
+	numSampled := 0
+	for i := 0; i < 1000; i++ {
+		if controller.sampled() {
+			numSampled++
+		}
+	}
+	if numSampled < 300 || numSampled > 700 {
+		t.Errorf("unexpected number of sampled calls: %d", numSampled)
+	}
+}
+
 func TestMainLoop_Panic(t *testing.T) {
 	controller := NewController()
 	buff := &bytes.Buffer{}
@@ -295,6 +1026,260 @@ func TestMainLoop_SpecialFuncs(t *testing.T) {
 	}
 }
 
+var defersAttrs = Attributes{
+	ProgramPath:         testutils.ProgramDefers,
+	FirstModuleDataAddr: testutils.DefersAddrFirstModuleData,
+	CompiledGoVersion:   runtime.Version(),
+}
+
+func TestMainLoop_TraceDefers(t *testing.T) {
+	controller := NewController()
+	buff := &bytes.Buffer{}
+	controller.outputWriter = buff
+	controller.SetTraceLevel(2)
+	controller.SetTraceDefers(true)
+	if err := controller.LaunchTracee(testutils.ProgramDefers, nil, defersAttrs); err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	if err := controller.AddStartTracePoint(testutils.DefersAddrMain); err != nil {
+		t.Fatalf("failed to set tracing point: %v", err)
+	}
+
+	if err := controller.MainLoop(); err != nil {
+		t.Errorf("failed to run main loop: %v", err)
+	}
+
+	output := buff.String()
+	if strings.Count(output, "|defer (#") != 2 {
+		t.Errorf("wrong number of deferred calls: %d\n%s", strings.Count(output, "|defer (#"), output)
+	}
+}
+
+func TestMainLoop_TestBinary(t *testing.T) {
+	controller := NewController()
+	buff := &bytes.Buffer{}
+	controller.outputWriter = buff
+	controller.SetTraceLevel(1)
+	controller.SetTestBinary(true)
+	attrs := Attributes{
+		ProgramPath:         testutils.ProgramTestBinary,
+		FirstModuleDataAddr: testutils.TestBinaryAddrFirstModuleData,
+		CompiledGoVersion:   runtime.Version(),
+	}
+	if err := controller.LaunchTracee(testutils.ProgramTestBinary, []string{"-test.run", "TestAdd"}, attrs); err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	if err := controller.AddStartTracePointByName("TestAdd"); err != nil {
+		t.Fatalf("failed to set tracing point: %v", err)
+	}
+
+	if err := controller.MainLoop(); err != nil {
+		t.Errorf("failed to run main loop: %v", err)
+	}
+
+	output := buff.String()
+	if strings.Count(output, "command-line-arguments.add") != 2 {
+		t.Errorf("unexpected output: %s", output)
+	}
+}
+
+func TestMainLoop_Plugin(t *testing.T) {
+	if err := testutils.BuildProgramPlugin(); err != nil {
+		t.Skipf("failed to build the plugin program: %v", err)
+	}
+
+	controller := NewController()
+	buff := &bytes.Buffer{}
+	controller.outputWriter = buff
+	controller.SetTraceLevel(2)
+	attrs := Attributes{
+		ProgramPath:         testutils.ProgramPlugin,
+		FirstModuleDataAddr: testutils.PluginAddrFirstModuleData,
+		CompiledGoVersion:   runtime.Version(),
+	}
+	if err := controller.LaunchTracee(testutils.ProgramPlugin, []string{testutils.ProgramPluginLib}, attrs); err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	if err := controller.AddStartTracePoint(testutils.PluginAddrCallPlugin); err != nil {
+		t.Fatalf("failed to set tracing point: %v", err)
+	}
+
+	if err := controller.MainLoop(); err != nil {
+		t.Errorf("failed to run main loop: %v", err)
+	}
+
+	// the functions in the plugin are found by the moduledata of the plugin.
+	output := buff.String()
+	if strings.Count(output, ".Inc(") != 2 || strings.Count(output, ".inc(") != 2 {
+		t.Errorf("unexpected output: %s", output)
+	}
+}
+
+func TestMatchTestFunction(t *testing.T) {
+	for _, testdata := range []struct {
+		fullName string
+		expected bool
+	}{
+		{fullName: "github.com/org/pkg.TestMyFunction", expected: true},
+		{fullName: "github.com/org/pkg_test.TestMyFunction", expected: true},
+		{fullName: "gopkg.in/yaml.v2.TestMyFunction", expected: true},
+		{fullName: "github.com/org/pkg.TestMyFunctionX", expected: false},
+		{fullName: "github.com/org/pkg.(*T).TestMyFunction", expected: false},
+		{fullName: "TestMyFunction", expected: false},
+	} {
+		if actual := matchTestFunction(testdata.fullName, "TestMyFunction"); actual != testdata.expected {
+			t.Errorf("[%s] wrong result: %v", testdata.fullName, actual)
+		}
+	}
+}
+
+func TestTraceableFunc(t *testing.T) {
+	for _, testdata := range []struct {
+		name     string
+		expected bool
+	}{
+		{name: "main.main", expected: true},
+		{name: "fmt.Println", expected: true},
+		{name: "runtime.memmove", expected: false},
+		{name: "type:.eq.main.T", expected: false},
+		{name: "sync/atomic.AddInt32", expected: false},
+		{name: "sync.(*Mutex).Lock", expected: false},
+		{name: "sync.(*WaitGroup).Wait", expected: true},
+	} {
+		if actual := traceableFunc(&tracee.Function{Name: testdata.name}); actual != testdata.expected {
+			t.Errorf("[%s] wrong result: %v", testdata.name, actual)
+		}
+	}
+}
+
+func TestTraceError(t *testing.T) {
+	memoryReadErr := debugapi.MemoryReadError{Addr: 0x1000, Size: 8, Err: syscall.ESRCH}
+	err := traceError(fmt.Errorf("failed to read the goroutine: %w", memoryReadErr))
+	if !strings.HasSuffix(err.Error(), "the process may have exited") {
+		t.Errorf("no hint: %v", err)
+	}
+	if !errors.Is(err, syscall.ESRCH) {
+		t.Errorf("the cause is not wrapped: %v", err)
+	}
+
+	err = traceError(errors.New("other error"))
+	if err.Error() != "failed to trace: other error" {
+		t.Errorf("wrong message: %v", err)
+	}
+}
+
+func TestMainLoop_MockClient(t *testing.T) {
+	// the process is not launched, so the moduledata is not available.
+	attrs := Attributes{ProgramPath: testutils.ProgramHelloworld, CompiledGoVersion: runtime.Version()}
+	memoryReadErr := debugapi.MemoryReadError{Addr: 0x1000, Size: 8, Err: syscall.ESRCH}
+
+	for i, testdata := range []struct {
+		event       debugapi.Event
+		err         error
+		expectedErr string
+	}{
+		{event: debugapi.Event{Type: debugapi.EventTypeExited, Data: 0}},
+		{event: debugapi.Event{Type: debugapi.EventTypeTerminated, Data: 9}, expectedErr: "the process exited due to signal 9"},
+		{event: debugapi.Event{Type: debugapi.EventTypeCoreDump}, expectedErr: "the process exited due to core dump"},
+		{err: memoryReadErr, expectedErr: "the process may have exited"},
+	} {
+		client := mock.NewClient()
+		client.Expect(mock.LaunchProcess)
+		client.Expect(mock.ContinueAndWait).Return(testdata.event, testdata.err)
+		client.Expect(mock.DetachProcess)
+
+		controller := NewControllerWithClient(client)
+		controller.outputWriter = ioutil.Discard
+		if err := controller.LaunchTracee(testutils.ProgramHelloworld, nil, attrs); err != nil {
+			t.Fatalf("[%d] failed to launch process: %v", i, err)
+		}
+
+		err := controller.MainLoop()
+		if testdata.expectedErr == "" && err != nil {
+			t.Errorf("[%d] failed to run main loop: %v", i, err)
+		} else if testdata.expectedErr != "" && (err == nil || !strings.Contains(err.Error(), testdata.expectedErr)) {
+			t.Errorf("[%d] wrong error: %v", i, err)
+		}
+		if client.Remaining(mock.ContinueAndWait) != 0 || client.Calls(mock.DetachProcess) != 1 {
+			t.Errorf("[%d] wrong calls: %d, %d", i, client.Remaining(mock.ContinueAndWait), client.Calls(mock.DetachProcess))
+		}
+	}
+}
+
+func TestMainLoop_MockClient_TrapAtUnrelatedBreakpoint(t *testing.T) {
+	// the process is not launched, so the moduledata is not available.
+	attrs := Attributes{ProgramPath: testutils.ProgramHelloworld, CompiledGoVersion: runtime.Version()}
+	const threadID, goRoutineID = 1, 1
+	const gAddr, breakpointAddr, stackAddr = uint64(0xc000000180), uint64(0x401000), uint64(0xc000030000)
+	trappedRegs := debugapi.Registers{Rip: breakpointAddr + 1, Rsp: stackAddr}
+
+	client := mock.NewClient()
+	client.Expect(mock.LaunchProcess)
+	client.Expect(mock.ContinueAndWait).Return(debugapi.Event{Type: debugapi.EventTypeTrapped, Data: []int{threadID}}, nil)
+	// CurrentGoRoutineInfo reads the g struct.
+	client.Expect(mock.ReadTLS).Return(gAddr, nil)
+	client.Expect(mock.ReadRegisters).Return(trappedRegs, nil)
+	client.Expect(mock.ReadMemoryBatch).Run(func(args ...interface{}) {
+		// goid, stack and _panic fields
+		reads := args[0].([]debugapi.MemoryRead)
+		binary.LittleEndian.PutUint64(reads[0].Buf, goRoutineID)
+		binary.LittleEndian.PutUint64(reads[1].Buf[8:], stackAddr+0x100)
+	})
+	client.Expect(mock.ReadMemoryBatch) // the _defer field to find the panic handler
+	client.Expect(mock.ReadMemoryBatch) // the _defer field to find the next deferred function
+	// no breakpoint is set by the controller, so the thread steps over the breakpoint.
+	var writtenRegs debugapi.Registers
+	client.Expect(mock.ReadRegisters).Return(trappedRegs, nil)
+	client.Expect(mock.WriteRegisters).Run(func(args ...interface{}) { writtenRegs = args[1].(debugapi.Registers) })
+	client.Expect(mock.StepAndWait).Return(debugapi.Event{Type: debugapi.EventTypeTrapped, Data: []int{threadID}}, nil)
+	client.Expect(mock.ContinueAndWait).Return(debugapi.Event{Type: debugapi.EventTypeExited, Data: 0}, nil)
+	client.Expect(mock.DetachProcess)
+
+	controller := NewControllerWithClient(client)
+	controller.outputWriter = ioutil.Discard
+	if err := controller.LaunchTracee(testutils.ProgramHelloworld, nil, attrs); err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+
+	if err := controller.MainLoop(); err != nil {
+		t.Errorf("failed to run main loop: %v", err)
+	}
+	for _, method := range []mock.Method{mock.ContinueAndWait, mock.ReadTLS, mock.ReadRegisters, mock.ReadMemoryBatch, mock.WriteRegisters, mock.StepAndWait, mock.DetachProcess} {
+		if client.Remaining(method) != 0 {
+			t.Errorf("%s is not called: %d", method, client.Remaining(method))
+		}
+	}
+	if client.Calls(mock.ReadMemoryBatch) != 3 || client.Calls(mock.StepAndWait) != 1 {
+		t.Errorf("unexpected calls: %d, %d", client.Calls(mock.ReadMemoryBatch), client.Calls(mock.StepAndWait))
+	}
+	if writtenRegs.Rip != breakpointAddr {
+		t.Errorf("wrong pc: %#x", writtenRegs.Rip)
+	}
+}
+
+// BenchmarkMainLoop measures the trap event handling, from the breakpoint hit to the printed trace log.
+// handleTrapEventOfThread consumes the trap event, so the whole run of the program is measured.
+func BenchmarkMainLoop(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		controller := NewController()
+		controller.outputWriter = ioutil.Discard
+		controller.SetTraceLevel(2)
+		if err := controller.LaunchTracee(testutils.ProgramHelloworld, nil, helloworldAttrs); err != nil {
+			b.Fatalf("failed to launch process: %v", err)
+		}
+		if err := controller.AddStartTracePoint(testutils.HelloworldAddrMain); err != nil {
+			b.Fatalf("failed to set tracing point: %v", err)
+		}
+		b.StartTimer()
+
+		if err := controller.MainLoop(); err != nil {
+			b.Fatalf("failed to run main loop: %v", err)
+		}
+	}
+}
+
 func TestInterrupt(t *testing.T) {
 	controller := NewController()
 	controller.outputWriter = ioutil.Discard
@@ -316,3 +1301,40 @@ func TestInterrupt(t *testing.T) {
 		t.Errorf("not interrupted: %v", err)
 	}
 }
+
+func TestReset(t *testing.T) {
+	controller := NewController()
+	controller.outputWriter = ioutil.Discard
+	controller.SetKeepAttached(true)
+	err := controller.LaunchTracee(testutils.ProgramInfloop, nil, infloopAttrs)
+	if err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	defer controller.Detach()
+
+	for i := 0; i < 2; i++ {
+		if err := controller.AddStartTracePoint(testutils.InfloopAddrMain); err != nil {
+			t.Fatalf("failed to set tracing point: %v", err)
+		}
+
+		done := make(chan error)
+		go func(ch chan error) {
+			ch <- controller.MainLoop()
+		}(done)
+
+		controller.Interrupt()
+		if err := <-done; err != ErrInterrupted {
+			t.Fatalf("not interrupted: %v", err)
+		}
+
+		if err := controller.Reset(); err != nil {
+			t.Fatalf("failed to reset: %v", err)
+		}
+		if controller.breakpoints.Exist(testutils.InfloopAddrMain) {
+			t.Errorf("breakpoint remains")
+		}
+		if controller.tracingPoints.IsStartAddress(testutils.InfloopAddrMain) {
+			t.Errorf("trace point remains")
+		}
+	}
+}
@@ -0,0 +1,78 @@
+//go:build otel
+
+package tracer
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ks888/tgo/log"
+	"github.com/ks888/tgo/tracee"
+)
+
+const otelTracerName = "github.com/ks888/tgo"
+
+// otelTracer creates the span for each traced function call.
+type otelTracer struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+}
+
+// spanContext holds the span of the calling function. The span is nil if the function call is not exported.
+type spanContext struct {
+	span trace.Span
+}
+
+// SetOTelExporter sets the exporter to which the traced function calls are exported as the spans.
+// The span of the function call is the child of the calling function's span.
+func (c *Controller) SetOTelExporter(exp sdktrace.SpanExporter) {
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp))
+	c.otel = otelTracer{provider: provider, tracer: provider.Tracer(otelTracerName)}
+}
+
+// startSpan starts the span of the function call. The nearest calling function which has the span is the parent.
+func (c *Controller) startSpan(callingFuncs []callingFunction, goRoutineID int64, stackFrame *tracee.StackFrame) spanContext {
+	if c.otel.tracer == nil {
+		return spanContext{}
+	}
+
+	ctx := context.Background()
+	for i := len(callingFuncs) - 1; i >= 0; i-- {
+		if parent := callingFuncs[i].spanContext.span; parent != nil {
+			ctx = trace.ContextWithSpan(ctx, parent)
+			break
+		}
+	}
+
+	attrs := []attribute.KeyValue{attribute.Int64("tgo.goroutine_id", goRoutineID)}
+	for _, arg := range stackFrame.InputArguments {
+		attrs = append(attrs, attribute.String(arg.Name, arg.ParseValue(c.parseLevel)))
+	}
+	_, span := c.otel.tracer.Start(ctx, stackFrame.Function.Name, trace.WithAttributes(attrs...))
+	return spanContext{span: span}
+}
+
+// endSpan ends the span of the function call, if any.
+func (c *Controller) endSpan(sc spanContext) {
+	if sc.span != nil {
+		sc.span.End()
+	}
+}
+
+// flushSpans ends the spans of the functions which haven't returned yet, such as main.main, and exports the remaining spans.
+func (c *Controller) flushSpans() {
+	if c.otel.provider == nil {
+		return
+	}
+
+	for goRoutineID := range c.statusStore {
+		c.endOpenSpans(goRoutineID)
+	}
+
+	if err := c.otel.provider.Shutdown(context.Background()); err != nil {
+		log.Debugf("failed to shutdown the tracer provider: %v", err)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !otel
+
+package tracer
+
+import "github.com/ks888/tgo/tracee"
+
+// otelTracer is empty because the OpenTelemetry support is enabled only when built with the 'otel' tag.
+type otelTracer struct{}
+
+type spanContext struct{}
+
+func (c *Controller) startSpan(callingFuncs []callingFunction, goRoutineID int64, stackFrame *tracee.StackFrame) spanContext {
+	return spanContext{}
+}
+
+func (c *Controller) endSpan(sc spanContext) {}
+
+func (c *Controller) flushSpans() {}
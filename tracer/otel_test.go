@@ -0,0 +1,94 @@
+//go:build otel
+
+package tracer
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/ks888/tgo/testutils"
+)
+
+// keptSpansExporter keeps the exported spans after the shutdown, while the InMemoryExporter removes them.
+type keptSpansExporter struct {
+	*tracetest.InMemoryExporter
+}
+
+func (e keptSpansExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func TestMainLoop_OTelExporter(t *testing.T) {
+	controller := NewController()
+	controller.outputWriter = ioutil.Discard
+	controller.SetTraceLevel(2)
+	exporter := keptSpansExporter{tracetest.NewInMemoryExporter()}
+	controller.SetOTelExporter(exporter)
+	if err := controller.LaunchTracee(testutils.ProgramHelloworld, nil, helloworldAttrs); err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	if err := controller.AddStartTracePoint(testutils.HelloworldAddrMain); err != nil {
+		t.Fatalf("failed to set tracing point: %v", err)
+	}
+
+	if err := controller.MainLoop(); err != nil {
+		t.Errorf("failed to run main loop: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	var parent, child *tracetest.SpanStub
+	for i, span := range spans {
+		switch span.Name {
+		case "main.noParameter":
+			parent = &spans[i]
+		case "fmt.Println", "fmt.Fprintln": // fmt.Println may be inlined
+			if child == nil {
+				child = &spans[i]
+			}
+		}
+	}
+	if parent == nil || child == nil {
+		t.Fatalf("spans not exported: %v", spans)
+	}
+	if child.Parent.SpanID() != parent.SpanContext.SpanID() {
+		t.Errorf("wrong parent: %v", child.Parent)
+	}
+}
+
+func TestMainLoop_OTelOpenSpans(t *testing.T) {
+	controller := NewController()
+	controller.outputWriter = ioutil.Discard
+	controller.SetTraceLevel(3)
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	controller.otel = otelTracer{provider: provider, tracer: provider.Tracer(otelTracerName)}
+	if err := controller.LaunchTracee(testutils.ProgramHelloworld, nil, helloworldAttrs); err != nil {
+		t.Fatalf("failed to launch process: %v", err)
+	}
+	if err := controller.AddStartTracePoint(testutils.HelloworldAddrMain); err != nil {
+		t.Fatalf("failed to set tracing point: %v", err)
+	}
+	// main.noParameter doesn't return before the tracing ends.
+	function, err := controller.process.Binary.FindFunctionByName("fmt.Fprintln")
+	if err != nil {
+		t.Fatalf("failed to find the function: %v", err)
+	}
+	if err := controller.AddEndTracePoint(function.StartAddr); err != nil {
+		t.Fatalf("failed to set tracing point: %v", err)
+	}
+
+	if err := controller.MainLoop(); err != nil {
+		t.Errorf("failed to run main loop: %v", err)
+	}
+
+	if len(recorder.Started()) == 0 {
+		t.Fatalf("no spans started")
+	}
+	if len(recorder.Ended()) != len(recorder.Started()) {
+		t.Errorf("spans not ended: %d, %d", len(recorder.Started()), len(recorder.Ended()))
+	}
+}
@@ -0,0 +1,52 @@
+//go:build pprof
+
+package tracer
+
+import (
+	"io"
+
+	"github.com/google/pprof/profile"
+)
+
+// pprofEnabled is true because the call chains are recorded only when built with the 'pprof' tag.
+const pprofEnabled = true
+
+// WritePprofProfile writes the call chains traced so far in the pprof format. Each call chain is the sample and
+// its values are the number of calls and the total time (in microseconds) of the last function in the chain.
+// Run `go tool pprof` to visualize the written profile.
+func (c *Controller) WritePprofProfile(w io.Writer) error {
+	prof := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "calls", Unit: "count"},
+			{Type: "time", Unit: "microseconds"},
+		},
+	}
+
+	locations := make(map[string]*profile.Location)
+	for _, sample := range c.callGraph.Samples() {
+		var locs []*profile.Location
+		// the leaf location comes first in pprof.
+		for i := len(sample.functions) - 1; i >= 0; i-- {
+			function := sample.functions[i]
+			loc, ok := locations[function.Name]
+			if !ok {
+				fn := &profile.Function{ID: uint64(len(prof.Function) + 1), Name: function.Name, SystemName: function.Name}
+				prof.Function = append(prof.Function, fn)
+				loc = &profile.Location{ID: uint64(len(prof.Location) + 1), Address: function.StartAddr, Line: []profile.Line{{Function: fn}}}
+				prof.Location = append(prof.Location, loc)
+				locations[function.Name] = loc
+			}
+			locs = append(locs, loc)
+		}
+
+		prof.Sample = append(prof.Sample, &profile.Sample{
+			Location: locs,
+			Value:    []int64{sample.numCalls, sample.duration.Microseconds()},
+		})
+	}
+
+	if err := prof.CheckValid(); err != nil {
+		return err
+	}
+	return prof.Write(w)
+}
@@ -0,0 +1,6 @@
+//go:build !pprof
+
+package tracer
+
+// pprofEnabled is false because the call chains are recorded only when built with the 'pprof' tag.
+const pprofEnabled = false
@@ -0,0 +1,41 @@
+//go:build pprof
+
+package tracer
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/pprof/profile"
+
+	"github.com/ks888/tgo/tracee"
+)
+
+func TestWritePprofProfile(t *testing.T) {
+	controller := NewController()
+	mainFunc := &tracee.Function{Name: "main.main", StartAddr: 0x100}
+	fooFunc := &tracee.Function{Name: "main.foo", StartAddr: 0x200}
+	controller.callGraph.AddCall([]*tracee.Function{mainFunc, fooFunc})
+	controller.callGraph.AddDuration([]*tracee.Function{mainFunc, fooFunc}, time.Millisecond)
+
+	buff := &bytes.Buffer{}
+	if err := controller.WritePprofProfile(buff); err != nil {
+		t.Fatalf("failed to write profile: %v", err)
+	}
+
+	prof, err := profile.Parse(buff)
+	if err != nil {
+		t.Fatalf("failed to parse profile: %v", err)
+	}
+	if len(prof.Sample) != 1 {
+		t.Fatalf("wrong number of samples: %d", len(prof.Sample))
+	}
+	sample := prof.Sample[0]
+	if sample.Value[0] != 1 || sample.Value[1] != 1000 {
+		t.Errorf("wrong values: %v", sample.Value)
+	}
+	if sample.Location[0].Line[0].Function.Name != "main.foo" {
+		t.Errorf("wrong leaf function: %s", sample.Location[0].Line[0].Function.Name)
+	}
+}
@@ -5,7 +5,9 @@ import "github.com/ks888/tgo/log"
 type tracingPoints struct {
 	startAddressList []uint64
 	endAddressList   []uint64
-	goRoutinesInside []int64
+	// goRoutinesInside holds the stack depths at which the go routine entered the tracing point, the innermost last.
+	// The go routine may enter the tracing point recursively. The depth of the first entry is 0.
+	goRoutinesInside map[int64][]int
 }
 
 // IsStartAddress returns true if the addr is same as the start address.
@@ -28,38 +30,53 @@ func (p *tracingPoints) IsEndAddress(addr uint64) bool {
 	return false
 }
 
-// Enter updates the list of the go routines which are inside the tracing point.
-// It does nothing if the go routine has already entered.
-func (p *tracingPoints) Enter(goRoutineID int64) {
-	for _, existingGoRoutine := range p.goRoutinesInside {
-		if existingGoRoutine == goRoutineID {
-			return
-		}
+// Enter records that the go routine enters the tracing point at the stack depth.
+// The go routine which has already entered can enter again, such as the recursive call of the tracing point function.
+func (p *tracingPoints) Enter(goRoutineID int64, depth int) {
+	if p.goRoutinesInside == nil {
+		p.goRoutinesInside = make(map[int64][]int)
 	}
 
-	log.Debugf("Start tracing of go routine #%d", goRoutineID)
-	p.goRoutinesInside = append(p.goRoutinesInside, goRoutineID)
-	return
+	depths := p.goRoutinesInside[goRoutineID]
+	if len(depths) == 0 {
+		log.Debugf("Start tracing of go routine #%d", goRoutineID)
+	} else if depths[len(depths)-1] >= depth {
+		// the go routine exited from the last entry without notice, e.g. the panic unwinded the stack.
+		p.Exit(goRoutineID, depth)
+	}
+	p.goRoutinesInside[goRoutineID] = append(p.goRoutinesInside[goRoutineID], depth)
 }
 
-// Exit clears the inside go routines list.
-func (p *tracingPoints) Exit(goRoutineID int64) {
-	log.Debugf("End tracing of go routine #%d", goRoutineID)
-	for i, existingGoRoutine := range p.goRoutinesInside {
-		if existingGoRoutine == goRoutineID {
-			p.goRoutinesInside = append(p.goRoutinesInside[0:i], p.goRoutinesInside[i+1:]...)
-			return
-		}
+// Exit removes the entries at the stack depth or deeper. The go routine is no longer inside the tracing point
+// if no entry remains, e.g. the depth is 0.
+func (p *tracingPoints) Exit(goRoutineID int64, depth int) {
+	depths := p.goRoutinesInside[goRoutineID]
+	i := len(depths)
+	for i > 0 && depths[i-1] >= depth {
+		i--
+	}
+	if i > 0 {
+		p.goRoutinesInside[goRoutineID] = depths[:i]
+		return
+	}
+
+	if len(depths) > 0 {
+		log.Debugf("End tracing of go routine #%d", goRoutineID)
 	}
-	return
+	delete(p.goRoutinesInside, goRoutineID)
 }
 
 // Inside returns true if the go routine is inside the tracing point.
 func (p *tracingPoints) Inside(goRoutineID int64) bool {
-	for _, existingGoRoutine := range p.goRoutinesInside {
-		if existingGoRoutine == goRoutineID {
-			return true
-		}
+	return len(p.goRoutinesInside[goRoutineID]) > 0
+}
+
+// Depth returns the stack depth relative to the innermost entry of the tracing point.
+// The depth is returned as it is if the go routine is not inside the tracing point.
+func (p *tracingPoints) Depth(goRoutineID int64, depth int) int {
+	depths := p.goRoutinesInside[goRoutineID]
+	if len(depths) == 0 {
+		return depth
 	}
-	return false
+	return depth - depths[len(depths)-1]
 }
@@ -5,13 +5,64 @@ import "testing"
 func TestTracingPoints_EnterAndExit(t *testing.T) {
 	points := tracingPoints{}
 	var id int64 = 1
-	points.Enter(id)
+	points.Enter(id, 0)
 	if !points.Inside(id) {
 		t.Errorf("go routine id %d is not traced", id)
 	}
 
-	points.Exit(1)
+	points.Exit(1, 0)
 	if points.Inside(id) {
 		t.Errorf("go routine id %d is still traced", id)
 	}
 }
+
+func TestTracingPoints_Recursive(t *testing.T) {
+	points := tracingPoints{}
+	var id int64 = 1
+	points.Enter(id, 0)
+	points.Enter(id, 2)
+	points.Enter(id, 5)
+	if depth := points.Depth(id, 6); depth != 1 {
+		t.Errorf("wrong depth: %d", depth)
+	}
+
+	// the innermost call returns.
+	points.Exit(id, 5)
+	if depth := points.Depth(id, 3); depth != 1 {
+		t.Errorf("wrong depth: %d", depth)
+	}
+
+	// the calls at depth 2 or deeper return at once, e.g. due to the panic.
+	points.Enter(id, 4)
+	points.Exit(id, 2)
+	if !points.Inside(id) {
+		t.Errorf("go routine id %d is not traced", id)
+	}
+	if depth := points.Depth(id, 1); depth != 1 {
+		t.Errorf("wrong depth: %d", depth)
+	}
+
+	points.Exit(id, 0)
+	if points.Inside(id) {
+		t.Errorf("go routine id %d is still traced", id)
+	}
+}
+
+func TestTracingPoints_EnterSameDepth(t *testing.T) {
+	points := tracingPoints{}
+	var id int64 = 1
+	points.Enter(id, 0)
+	points.Enter(id, 3)
+	// the last entry exited without notice.
+	points.Enter(id, 3)
+	if len(points.goRoutinesInside[id]) != 2 {
+		t.Errorf("wrong entries: %v", points.goRoutinesInside[id])
+	}
+}
+
+func TestTracingPoints_DepthNotInside(t *testing.T) {
+	points := tracingPoints{}
+	if depth := points.Depth(1, 2); depth != 2 {
+		t.Errorf("wrong depth: %d", depth)
+	}
+}